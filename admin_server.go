@@ -1,13 +1,19 @@
 package chordstore
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // AdminServer allows to query key-values, objects and lookup key locations
@@ -44,6 +50,74 @@ func (svr *AdminServer) handleConfig(w http.ResponseWriter, r *http.Request) {
 	w.Write(b)
 }
 
+func (svr *AdminServer) handleHeal(w http.ResponseWriter, r *http.Request) {
+	b, _ := json.Marshal(svr.store.HealStats())
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(b)
+}
+
+// handleContent handles content addressed objects.  Keys are hex encoded.
+func (svr *AdminServer) handleContent(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx = r.Context()
+		key = ctx.Value("key").(string)
+		n   = ctx.Value("n").(int)
+	)
+
+	switch r.Method {
+	case "GET":
+		hkey, err := hex.DecodeString(key)
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		rsps, err := svr.store.GetContent(n, hkey)
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		vds := make([]*VnodeData, len(rsps))
+		for i, v := range rsps {
+			vds[i] = &VnodeData{Vnode: v.Vnode}
+			if v.Err == nil {
+				vds[i].Data, vds[i].Err = ioutil.ReadAll(v.Reader())
+				v.Close()
+			} else {
+				vds[i].Err = v.Err
+			}
+		}
+		b, _ := json.Marshal(vds)
+		w.Write(b)
+
+	case "POST":
+		if key != "" {
+			w.WriteHeader(400)
+			w.Write([]byte("key is computed from the content"))
+			return
+		}
+
+		hkey, rsps, err := svr.store.PutContent(n, r.Body)
+		defer r.Body.Close()
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		b, _ := json.Marshal(map[string]interface{}{"key": hex.EncodeToString(hkey), "replicas": rsps})
+		w.Write(b)
+
+	default:
+		w.WriteHeader(405)
+		return
+	}
+}
+
 func (svr *AdminServer) handleObject(w http.ResponseWriter, r *http.Request) {
 	var (
 		ctx = r.Context()
@@ -53,6 +127,10 @@ func (svr *AdminServer) handleObject(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case "GET":
+		if rng := r.Header.Get("Range"); rng != "" {
+			svr.handleObjectRange(w, n, oid, rng)
+			return
+		}
 
 		rsps, err := svr.store.GetObject(n, oid)
 		if err != nil {
@@ -67,6 +145,7 @@ func (svr *AdminServer) handleObject(w http.ResponseWriter, r *http.Request) {
 			if v.Err == nil {
 				r := v.Reader()
 				vds[i].Data, vds[i].Err = ioutil.ReadAll(r)
+				v.Close()
 			} else {
 				vds[i].Err = v.Err
 			}
@@ -75,7 +154,16 @@ func (svr *AdminServer) handleObject(w http.ResponseWriter, r *http.Request) {
 		w.Write(b)
 
 	case "POST":
-		rsps, err := svr.store.PutObject(n, oid, r.Body)
+		var (
+			rsps []*VnodeDataIO
+			err  error
+		)
+		meta := requestMeta(r)
+		if _, ok := r.URL.Query()["erasure"]; ok {
+			rsps, err = svr.store.PutErasure(oid, r.Body, meta)
+		} else {
+			rsps, err = svr.store.PutObjectWithMeta(n, oid, r.Body, meta)
+		}
 		defer r.Body.Close()
 		if err != nil {
 			w.WriteHeader(400)
@@ -86,6 +174,21 @@ func (svr *AdminServer) handleObject(w http.ResponseWriter, r *http.Request) {
 		b, _ := json.Marshal(rsps)
 		w.Write(b)
 
+	case "HEAD":
+		rsps, err := svr.store.StatObject(n, oid)
+		if err != nil {
+			w.WriteHeader(400)
+			return
+		}
+
+		for _, v := range rsps {
+			if v.Err == nil {
+				writeMetaHeaders(w, v.Meta)
+				return
+			}
+		}
+		w.WriteHeader(404)
+
 	default:
 		w.WriteHeader(405)
 		return
@@ -93,6 +196,139 @@ func (svr *AdminServer) handleObject(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// Prefix of request and response headers holding user defined object metadata
+const metaHeaderPrefix = "X-Chordstore-Meta-"
+
+// requestMeta returns the object metadata from the request headers
+func requestMeta(r *http.Request) *ObjectMeta {
+	meta := &ObjectMeta{ContentType: r.Header.Get("Content-Type")}
+	for k, v := range r.Header {
+		if strings.HasPrefix(k, metaHeaderPrefix) && len(v) > 0 {
+			if meta.Headers == nil {
+				meta.Headers = map[string]string{}
+			}
+			meta.Headers[strings.TrimPrefix(k, metaHeaderPrefix)] = v[0]
+		}
+	}
+	return meta
+}
+
+// writeMetaHeaders writes the object metadata as response headers.  The ETag is
+// the hex encoded sha256 of the object if known.
+func writeMetaHeaders(w http.ResponseWriter, meta *ObjectMeta) {
+	h := w.Header()
+	h.Set("Content-Length", strconv.FormatInt(meta.Size, 10))
+	h.Set("Last-Modified", time.Unix(0, meta.Mtime).UTC().Format(http.TimeFormat))
+	if meta.ContentType != "" {
+		h.Set("Content-Type", meta.ContentType)
+	}
+	if len(meta.Hash) > 0 {
+		h.Set("ETag", `"`+hex.EncodeToString(meta.Hash)+`"`)
+	}
+	for k, v := range meta.Headers {
+		h.Set(metaHeaderPrefix+k, v)
+	}
+	w.WriteHeader(200)
+}
+
+// handleObjectRange writes the requested byte range of the object from the first
+// replica that has it as a 206 response
+func (svr *AdminServer) handleObjectRange(w http.ResponseWriter, n int, oid []byte, rng string) {
+	offset, length, err := parseRange(rng)
+	if err != nil {
+		w.WriteHeader(416)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	rsps, err := svr.store.GetObjectRange(n, oid, offset, length)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	var vd *VnodeDataIO
+	for _, v := range rsps {
+		if v.Err == nil && vd == nil {
+			vd = v
+		} else {
+			v.Close()
+		}
+	}
+	if vd == nil {
+		w.WriteHeader(404)
+		if len(rsps) > 0 {
+			w.Write([]byte(rsps[0].Err.Error()))
+		}
+		return
+	}
+	defer vd.Close()
+
+	var (
+		rd    = vd.Reader()
+		total = "*"
+		end   = offset + length - 1
+	)
+	if vd.Size >= 0 {
+		total = strconv.FormatInt(vd.Size, 10)
+		if offset >= vd.Size {
+			w.Header().Set("Content-Range", "bytes */"+total)
+			w.WriteHeader(416)
+			return
+		}
+		if length < 1 || end >= vd.Size {
+			end = vd.Size - 1
+		}
+	} else if length < 1 {
+		// Size unknown.  Read the rest to find out where it ends.
+		b, err := ioutil.ReadAll(rd)
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if len(b) == 0 {
+			w.WriteHeader(416)
+			return
+		}
+		rd, end = bytes.NewReader(b), offset+int64(len(b))-1
+	}
+
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%s", offset, end, total))
+	w.Header().Set("Content-Length", strconv.FormatInt(end-offset+1, 10))
+	w.WriteHeader(206)
+	if _, err = io.Copy(w, rd); err != nil {
+		log.Printf("ERR [admin] object range %s: %v", oid, err)
+	}
+}
+
+// parseRange parses a single byte range of the form bytes=start-end or
+// bytes=start- returning the offset and length.  A length of 0 means to the end.
+func parseRange(rng string) (int64, int64, error) {
+	if !strings.HasPrefix(rng, "bytes=") || strings.Contains(rng, ",") {
+		return 0, 0, fmt.Errorf("unsupported range: %s", rng)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(rng, "bytes="), "-", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return 0, 0, fmt.Errorf("unsupported range: %s", rng)
+	}
+
+	start, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, fmt.Errorf("invalid range: %s", rng)
+	}
+	if parts[1] == "" {
+		return start, 0, nil
+	}
+
+	end, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || end < start {
+		return 0, 0, fmt.Errorf("invalid range: %s", rng)
+	}
+	return start, end - start + 1, nil
+}
+
 func (svr *AdminServer) handleLookup(w http.ResponseWriter, r *http.Request) {
 	var (
 		ctx = r.Context()
@@ -100,6 +336,10 @@ func (svr *AdminServer) handleLookup(w http.ResponseWriter, r *http.Request) {
 		n   = ctx.Value("n").(int)
 	)
 
+	if n < 1 {
+		n = svr.cfg.Replicas
+	}
+
 	rsp, err := svr.cfg.Ring.Lookup(n, key)
 	if err != nil {
 		w.WriteHeader(400)
@@ -112,6 +352,72 @@ func (svr *AdminServer) handleLookup(w http.ResponseWriter, r *http.Request) {
 	w.Write(b)
 }
 
+func (svr *AdminServer) handleKeyHistory(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx = r.Context()
+		key = ctx.Value("key").([]byte)
+		n   = ctx.Value("n").(int)
+	)
+
+	if r.Method != "GET" {
+		w.WriteHeader(405)
+		return
+	}
+
+	rsp, err := svr.store.KeyHistory(n, key)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	b, _ := json.Marshal(rsp)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(b)
+}
+
+// defaultListLimit is the page size of key listings when no limit is given
+const defaultListLimit = 1000
+
+// handleListKeys lists a page of keys across the ring.  The next cursor is empty
+// once all keys have been listed.
+func (svr *AdminServer) handleListKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(405)
+		return
+	}
+
+	q := r.URL.Query()
+	limit := defaultListLimit
+	if l := q.Get("limit"); l != "" {
+		i, err := strconv.Atoi(l)
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		limit = i
+	}
+
+	keys, next, err := svr.store.ListKeys([]byte(q.Get("prefix")), []byte(q.Get("cursor")), limit)
+	if err != nil && err != ErrPartialList {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	strs := make([]string, len(keys))
+	for i, k := range keys {
+		strs[i] = string(k)
+	}
+
+	b, _ := json.Marshal(map[string]interface{}{"keys": strs, "next": string(next), "partial": err == ErrPartialList})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(b)
+}
+
 func (svr *AdminServer) handleKV(w http.ResponseWriter, r *http.Request) {
 	var (
 		ctx = r.Context()
@@ -122,14 +428,28 @@ func (svr *AdminServer) handleKV(w http.ResponseWriter, r *http.Request) {
 		err error
 	)
 
+	if _, ok := r.URL.Query()["versions"]; ok {
+		svr.handleVersionedKV(w, r)
+		return
+	}
+	if cstr := r.URL.Query().Get("consistency"); cstr != "" {
+		svr.handleConsistentKV(w, r, cstr)
+		return
+	}
+
 	switch r.Method {
 	case "GET":
 		rsp, err = svr.store.GetKey(n, key)
 
 	case "POST":
-		var value []byte
-		if value, err = ioutil.ReadAll(r.Body); err == nil {
-			rsp, err = svr.store.PutKey(n, key, value)
+		var (
+			value []byte
+			ttl   time.Duration
+		)
+		if ttl, err = parseTTL(r.URL.Query().Get("ttl")); err == nil {
+			if value, err = ioutil.ReadAll(r.Body); err == nil {
+				rsp, err = svr.store.PutKeyWithTTL(n, key, value, ttl)
+			}
 		}
 
 	case "PUT":
@@ -138,18 +458,133 @@ func (svr *AdminServer) handleKV(w http.ResponseWriter, r *http.Request) {
 			rsp, err = svr.store.UpdateKey(n, key, value)
 		}
 
+	case "DELETE":
+		rsp, err = svr.store.RemoveKey(n, key)
+
+	default:
+		w.WriteHeader(405)
+		return
+	}
+
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	b, _ := json.Marshal(rsp)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(b)
+}
+
+// handleConsistentKV returns a single resolved value or error based on the
+// requested consistency level rather than per replica responses.
+func (svr *AdminServer) handleConsistentKV(w http.ResponseWriter, r *http.Request, cstr string) {
+	key := r.Context().Value("key").([]byte)
+
+	c, err := ParseConsistency(cstr)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	var val []byte
+	switch r.Method {
+	case "GET":
+		val, err = svr.store.Get(key, c)
+
+	case "POST":
+		if val, err = ioutil.ReadAll(r.Body); err == nil {
+			err = svr.store.Put(key, val, c)
+			val = nil
+		}
+
+	case "DELETE":
+		err = svr.store.Remove(key, c)
+
 	default:
 		w.WriteHeader(405)
 		return
 	}
 
+	if err != nil {
+		if isNotFound(err) {
+			w.WriteHeader(404)
+		} else {
+			w.WriteHeader(503)
+		}
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(val)
+}
+
+// handleVersionedKV reads the siblings of a key or writes a version of it.  The
+// context of a read is returned in the versionContextHeader and passing it back
+// on a write supersedes the siblings read.  The consistency defaults to the
+// configured levels.
+func (svr *AdminServer) handleVersionedKV(w http.ResponseWriter, r *http.Request) {
+	key := r.Context().Value("key").([]byte)
+
+	c := ConsistencyDefault
+	var err error
+	if cstr := r.URL.Query().Get("consistency"); cstr != "" {
+		c, err = ParseConsistency(cstr)
+	}
 	if err != nil {
 		w.WriteHeader(400)
 		w.Write([]byte(err.Error()))
 		return
 	}
 
+	var (
+		rsp interface{}
+		vc  VectorClock
+	)
+	switch r.Method {
+	case "GET":
+		var v *Versioned
+		if v, err = svr.store.GetVersioned(key, c); err == nil {
+			rsp, vc = v.Siblings, v.Context
+		}
+
+	case "POST":
+		var (
+			value []byte
+			sib   *Sibling
+		)
+		if vc, err = decodeVersionContext(r.Header.Get(versionContextHeader)); err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if value, err = ioutil.ReadAll(r.Body); err == nil {
+			if sib, err = svr.store.PutVersioned(key, value, vc, c); err == nil {
+				rsp, vc = sib, sib.Clock()
+			}
+		}
+
+	default:
+		w.WriteHeader(405)
+		return
+	}
+
+	if err != nil {
+		if isNotFound(err) {
+			w.WriteHeader(404)
+		} else {
+			w.WriteHeader(503)
+		}
+		w.Write([]byte(err.Error()))
+		return
+	}
+
 	b, _ := json.Marshal(rsp)
+	w.Header().Set(versionContextHeader, encodeVersionContext(vc))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(200)
 	w.Write(b)
@@ -157,9 +592,10 @@ func (svr *AdminServer) handleKV(w http.ResponseWriter, r *http.Request) {
 
 // ServeHTTP routes the user request and sets the context
 func (svr *AdminServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// n < 1 uses the configured replica count
 	n, err := parseN(r)
 	if err != nil {
-		n = 1
+		n = 0
 	}
 
 	ctx := context.WithValue(context.Background(), "n", n)
@@ -168,6 +604,9 @@ func (svr *AdminServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case strings.HasPrefix(r.URL.Path, "/config"):
 		svr.handleConfig(w, r.WithContext(ctx))
 
+	case strings.HasPrefix(r.URL.Path, "/heal"):
+		svr.handleHeal(w, r.WithContext(ctx))
+
 	case strings.HasPrefix(r.URL.Path, "/object/"):
 		s := strings.TrimPrefix(r.URL.Path, "/object/")
 		if len(s) == 0 {
@@ -177,10 +616,19 @@ func (svr *AdminServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 		svr.handleObject(w, r.WithContext(context.WithValue(ctx, "oid", []byte(s))))
 
+	case strings.HasPrefix(r.URL.Path, "/content"):
+		key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/content"), "/")
+		svr.handleContent(w, r.WithContext(context.WithValue(ctx, "key", key)))
+
 	case strings.HasPrefix(r.URL.Path, "/kv"):
-		key := strings.TrimPrefix(r.URL.Path, "/kv/")
+		key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/kv"), "/")
 		if len(key) == 0 {
-			w.WriteHeader(404)
+			svr.handleListKeys(w, r.WithContext(ctx))
+			return
+		}
+
+		if _, ok := r.URL.Query()["history"]; ok {
+			svr.handleKeyHistory(w, r.WithContext(context.WithValue(ctx, "key", []byte(key))))
 			return
 		}
 
@@ -202,8 +650,46 @@ func (svr *AdminServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// versionContextHeader carries the version context of versioned reads and writes
+const versionContextHeader = "X-Version-Context"
+
+// encodeVersionContext encodes the clock for use in a header
+func encodeVersionContext(vc VectorClock) string {
+	b, _ := json.Marshal(vc)
+	return base64.URLEncoding.EncodeToString(b)
+}
+
+// decodeVersionContext decodes a clock encoded by encodeVersionContext.  An
+// empty string is an empty clock.
+func decodeVersionContext(s string) (VectorClock, error) {
+	vc := VectorClock{}
+	if s == "" {
+		return vc, nil
+	}
+	b, err := base64.URLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(b, &vc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid version context: %v", err)
+	}
+	return vc, nil
+}
+
+// parseTTL parses a ttl given either in seconds or as a duration e.g. 1m30s.  An
+// empty string is no ttl.
+func parseTTL(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Duration(i) * time.Second, nil
+	}
+	return time.ParseDuration(s)
+}
+
 func parseN(r *http.Request) (int, error) {
-	n := 1
+	n := 0
 	nstr, ok := r.URL.Query()["n"]
 	if ok && len(nstr) > 0 {
 		i, err := strconv.ParseInt(nstr[0], 10, 32)
@@ -0,0 +1,70 @@
+package chordstore
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_parseRange(t *testing.T) {
+	tests := []struct {
+		rng            string
+		offset, length int64
+		ok             bool
+	}{
+		{"bytes=0-99", 0, 100, true},
+		{"bytes=100-", 100, 0, true},
+		{"bytes=5-5", 5, 1, true},
+		{"bytes=-100", 0, 0, false},
+		{"bytes=10-5", 0, 0, false},
+		{"bytes=0-1,5-6", 0, 0, false},
+		{"items=0-1", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		offset, length, err := parseRange(tt.rng)
+		if (err == nil) != tt.ok {
+			t.Fatal(tt.rng, err)
+		}
+		if offset != tt.offset || length != tt.length {
+			t.Fatal(tt.rng, offset, length)
+		}
+	}
+}
+
+func Test_parseTTL(t *testing.T) {
+	tests := []struct {
+		s   string
+		ttl time.Duration
+		ok  bool
+	}{
+		{"", 0, true},
+		{"30", 30 * time.Second, true},
+		{"1m30s", 90 * time.Second, true},
+		{"soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		ttl, err := parseTTL(tt.s)
+		if (err == nil) != tt.ok || ttl != tt.ttl {
+			t.Fatal(tt.s, ttl, err)
+		}
+	}
+}
+
+func Test_decodeVersionContext(t *testing.T) {
+	vc := VectorClock{"n1": 3, "n2": 1}
+	out, err := decodeVersionContext(encodeVersionContext(vc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !out.Descends(vc) || !vc.Descends(out) {
+		t.Fatal("context mismatch", out)
+	}
+
+	if out, err = decodeVersionContext(""); err != nil || len(out) != 0 {
+		t.Fatal("empty context should be an empty clock", out, err)
+	}
+	if _, err = decodeVersionContext("not a context"); err == nil {
+		t.Fatal("should fail")
+	}
+}
@@ -0,0 +1,263 @@
+package chordstore
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	chord "github.com/euforia/go-chord"
+)
+
+// antiEntropy periodically compares the hash trees of each local vnode with
+// those of the vnodes replicating its keys.  Only the leaves that differ are
+// exchanged and the differing keys are queued for healing.
+type antiEntropy struct {
+	cs       *ChordStore
+	host     string
+	interval time.Duration
+	stop     chan bool
+}
+
+func newAntiEntropy(cs *ChordStore, host string, interval time.Duration) *antiEntropy {
+	return &antiEntropy{cs: cs, host: host, interval: interval, stop: make(chan bool, 1)}
+}
+
+// start syncing on every interval.  This blocks until stop is called.
+func (ae *antiEntropy) start() {
+	tick := time.NewTicker(ae.interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-tick.C:
+			ae.syncAll()
+		case <-ae.stop:
+			return
+		}
+	}
+}
+
+func (ae *antiEntropy) shutdown() {
+	ae.stop <- true
+}
+
+func (ae *antiEntropy) syncAll() {
+	vns, err := ae.cs.ring.ListVnodes(ae.host)
+	if err != nil {
+		log.Printf("ERR [anti-entropy] %v", err)
+		return
+	}
+
+	for _, vn := range vns {
+		n, err := ae.cs.syncVnode(vn)
+		if err != nil {
+			log.Printf("ERR [anti-entropy] vnode=%s %v", shortID(vn), err)
+		} else if n > 0 {
+			log.Printf("DBG [anti-entropy] vnode=%s queued=%d", shortID(vn), n)
+		}
+	}
+}
+
+type merklePeer struct {
+	vn     *chord.Vnode
+	leaves []int
+}
+
+// replicaPeers returns the vnodes sharing keys with the local vnode along with
+// the leaves they share.  The keys of the vnode span a contiguous arc ending at
+// the vnode so leaves are walked back from that of the vnode until one it holds
+// no keys of.
+func (cs *ChordStore) replicaPeers(vn *chord.Vnode) (map[string]*merklePeer, error) {
+	if _, ok := cs.store.local[vn.StringID()]; !ok {
+		return nil, fmt.Errorf("not a local vnode")
+	}
+
+	peers := map[string]*merklePeer{}
+	for i := 0; i < merkleLeaves; i++ {
+		leaf := (int(vn.Id[0]) - i + merkleLeaves) % merkleLeaves
+		vns, err := cs.leafReplicas(vn, leaf)
+		if err != nil {
+			return nil, err
+		}
+		if !containsVnode(vns, vn) {
+			break
+		}
+
+		for _, pvn := range vns {
+			id := pvn.StringID()
+			if id == vn.StringID() {
+				continue
+			}
+			if _, ok := peers[id]; !ok {
+				peers[id] = &merklePeer{vn: pvn}
+			}
+			peers[id].leaves = append(peers[id].leaves, leaf)
+		}
+	}
+	return peers, nil
+}
+
+// leafReplicas returns the vnodes that may hold keys of the leaf.  Each vnode
+// within the leaf starts another arc so these are the successors of the start of
+// the leaf up to the replica count past the last of them.
+func (cs *ChordStore) leafReplicas(vn *chord.Vnode, leaf int) ([]*chord.Vnode, error) {
+	start := make([]byte, len(vn.Id))
+	start[0] = byte(leaf)
+	succs, err := cs.trans.FindSuccessors(vn, cs.successors, start)
+	if err != nil {
+		return nil, err
+	}
+
+	n := cs.replicas
+	for i := 0; i < n && i < len(succs); i++ {
+		if int(succs[i].Id[0]) == leaf {
+			n++
+		}
+	}
+	if n > len(succs) {
+		n = len(succs)
+	}
+	return succs[:n], nil
+}
+
+// syncVnode compares the local vnode with each of its replica peers queuing keys
+// that differ for healing.  It returns the number of keys queued.
+func (cs *ChordStore) syncVnode(vn *chord.Vnode) (int, error) {
+	peers, err := cs.replicaPeers(vn)
+	if err != nil {
+		return 0, err
+	}
+
+	var queued int
+	for _, p := range peers {
+		keys, err := cs.diffMerkle(vn, p.vn, p.leaves)
+		if err != nil {
+			log.Printf("ERR [anti-entropy] %s <-> %s %v", shortID(vn), shortID(p.vn), err)
+			continue
+		}
+
+		for _, key := range keys {
+			// Leaves may span the boundary of the replicated range
+			vns, err := cs.lookup(cs.replicas, key)
+			if err != nil || !containsVnode(vns, vn) || !containsVnode(vns, p.vn) {
+				continue
+			}
+			// Replicas of strong keys are kept by their consensus group
+			if cs.strong(key) {
+				continue
+			}
+			cs.enqueueHeal(HealRequest{Type: HealTypeKey, Vnode: p.vn, Key: key})
+			queued++
+		}
+	}
+
+	return queued, nil
+}
+
+// diffMerkle returns the keys in the given leaves that differ between the 2
+// vnodes descending only into subtrees whose hashes differ.
+func (cs *ChordStore) diffMerkle(local, remote *chord.Vnode, leaves []int) ([][]byte, error) {
+	sort.Ints(leaves)
+
+	parents := []int{}
+	for _, l := range leaves {
+		if p := l / merkleFanout; len(parents) == 0 || parents[len(parents)-1] != p {
+			parents = append(parents, p)
+		}
+	}
+
+	differ, err := cs.diffMerkleLevel(local, remote, merkleDepth-1, parents)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []int{}
+	for _, l := range leaves {
+		if differ[l/merkleFanout] {
+			candidates = append(candidates, l)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	if differ, err = cs.diffMerkleLevel(local, remote, merkleDepth, candidates); err != nil {
+		return nil, err
+	}
+	changed := []int{}
+	for _, l := range candidates {
+		if differ[l] {
+			changed = append(changed, l)
+		}
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	le, err := cs.store.MerkleEntries(local, changed)
+	if err != nil {
+		return nil, err
+	}
+	re, err := cs.store.MerkleEntries(remote, changed)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffMerkleEntries(le, re), nil
+}
+
+// diffMerkleLevel returns the indexes at the level whose hashes differ
+func (cs *ChordStore) diffMerkleLevel(local, remote *chord.Vnode, level int, indexes []int) (map[int]bool, error) {
+	lh, err := cs.store.MerkleLevel(local, level, indexes)
+	if err != nil {
+		return nil, err
+	}
+	rh, err := cs.store.MerkleLevel(remote, level, indexes)
+	if err != nil {
+		return nil, err
+	}
+	if len(lh) != len(rh) {
+		return nil, fmt.Errorf("merkle level size mismatch %d!=%d", len(lh), len(rh))
+	}
+
+	out := map[int]bool{}
+	for i, idx := range indexes {
+		if !bytes.Equal(lh[i], rh[i]) {
+			out[idx] = true
+		}
+	}
+	return out, nil
+}
+
+// diffMerkleEntries returns keys missing from either side or with different
+// hashes
+func diffMerkleEntries(a, b []*MerkleEntry) [][]byte {
+	m := map[string][]byte{}
+	for _, e := range a {
+		m[string(e.Key)] = e.Hash
+	}
+
+	out := [][]byte{}
+	for _, e := range b {
+		k := string(e.Key)
+		if h, ok := m[k]; !ok || !bytes.Equal(h, e.Hash) {
+			out = append(out, e.Key)
+		}
+		delete(m, k)
+	}
+	for k := range m {
+		out = append(out, []byte(k))
+	}
+	return out
+}
+
+func containsVnode(vns []*chord.Vnode, vn *chord.Vnode) bool {
+	for _, v := range vns {
+		if bytes.Equal(v.Id, vn.Id) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,184 @@
+package chordstore
+
+import (
+	"fmt"
+
+	context "golang.org/x/net/context"
+)
+
+const (
+	batchOpGet    = "get"
+	batchOpPut    = "put"
+	batchOpRemove = "remove"
+)
+
+// Limits of the ops sent in a single call to a host, keeping calls well under
+// the gRPC message size limit
+const (
+	batchMaxOps   = 1000
+	batchMaxBytes = 1 << 20
+)
+
+// KeyResult is the outcome of the operation on a key of a batch
+type KeyResult struct {
+	Key   []byte
+	Value []byte // Only set for gets
+	Err   error
+}
+
+// batchRef locates the replica of a key an op sent to a host is for
+type batchRef struct {
+	key     int
+	replica int
+}
+
+// batchCall is the ops sent in a call to a host and the refs of their results
+type batchCall struct {
+	ops  []*BatchOp
+	refs []batchRef
+}
+
+// splitBatch splits the ops of a host into calls of at most maxOps ops and
+// maxBytes of keys and values.  An op larger than maxBytes is sent on its own.
+func splitBatch(ops []*BatchOp, refs []batchRef, maxOps, maxBytes int) []*batchCall {
+	var (
+		calls []*batchCall
+		call  *batchCall
+		size  int
+	)
+	for i, op := range ops {
+		n := len(op.Key) + len(op.Value)
+		if call == nil || len(call.ops) == maxOps || (len(call.ops) > 0 && size+n > maxBytes) {
+			call = &batchCall{}
+			calls = append(calls, call)
+			size = 0
+		}
+		call.ops = append(call.ops, op)
+		call.refs = append(call.refs, refs[i])
+		size += n
+	}
+	return calls
+}
+
+// BatchGet the values of the keys.  Keys are grouped by the host of their
+// replicas and sent in a single call per host.  A result is returned for each
+// key in order, resolved as Get would.
+func (cs *ChordStore) BatchGet(keys [][]byte, c Consistency) ([]*KeyResult, error) {
+	return cs.batch(batchOpGet, keys, nil, c)
+}
+
+// BatchPut the values of the keys in a single call per host.  values must be the
+// same length as keys.  A result is returned for each key in order with an error
+// if not enough replicas acknowledged the write to satisfy the consistency level.
+func (cs *ChordStore) BatchPut(keys, values [][]byte, c Consistency) ([]*KeyResult, error) {
+	if len(keys) != len(values) {
+		return nil, fmt.Errorf("keys and values mismatch %d/%d", len(keys), len(values))
+	}
+	return cs.batch(batchOpPut, keys, values, c)
+}
+
+// BatchRemove the keys in a single call per host.  A result is returned for each
+// key in order.
+func (cs *ChordStore) BatchRemove(keys [][]byte, c Consistency) ([]*KeyResult, error) {
+	return cs.batch(batchOpRemove, keys, nil, c)
+}
+
+// batch sends the op on each replica of the keys grouped by host.  The ops of a
+// host are split into calls bounded by batchMaxOps and batchMaxBytes which are
+// sent concurrently.  Keys of strong namespaces are submitted to their consensus
+// group one at a time.
+func (cs *ChordStore) batch(op string, keys, values [][]byte, c Consistency) ([]*KeyResult, error) {
+	// Writes to all keys share a stamp
+	var ws *WriteStamp
+	if op == batchOpGet {
+		c = cs.readConsistency(c)
+	} else {
+		c = cs.writeConsistency(c)
+		ws = cs.newWriteStamp()
+	}
+
+	var (
+		hosts []string
+		ops   = map[string][]*BatchOp{}
+		refs  = map[string][]batchRef{}
+		vds   = make([][]*VnodeData, len(keys))
+	)
+	for i, key := range keys {
+		if cs.strong(key) {
+			continue
+		}
+		vns, err := cs.lookup(cs.replicas, key)
+		if err != nil {
+			return nil, err
+		}
+		vds[i] = make([]*VnodeData, len(vns))
+		for j, vn := range vns {
+			vds[i][j] = &VnodeData{Vnode: vn, Err: errReplicaPending}
+			bop := &BatchOp{Vn: vn, Key: key, Op: op, Stamp: ws}
+			if values != nil {
+				bop.Value = values[i]
+			}
+			if _, ok := ops[vn.Host]; !ok {
+				hosts = append(hosts, vn.Host)
+			}
+			ops[vn.Host] = append(ops[vn.Host], bop)
+			refs[vn.Host] = append(refs[vn.Host], batchRef{key: i, replica: j})
+		}
+	}
+
+	var calls []*batchCall
+	for _, host := range hosts {
+		calls = append(calls, splitBatch(ops[host], refs[host], batchMaxOps, batchMaxBytes)...)
+	}
+
+	results := make([][]*BatchResult, len(calls))
+	errs := make([]error, len(calls))
+	done := fanOut(len(calls), len(calls), cs.timeout, func(ctx context.Context, i int) bool {
+		results[i], errs[i] = cs.store.withContext(ctx).Batch(calls[i].ops)
+		return errs[i] == nil
+	})
+
+	for i, call := range calls {
+		if !done[i] {
+			continue
+		}
+		for k, ref := range call.refs {
+			vd := vds[ref.key][ref.replica]
+			if errs[i] != nil {
+				vd.Err = errs[i]
+				continue
+			}
+			vd.Data, vd.Err = results[i][k].Value, nil
+			if results[i][k].Err != "" {
+				vd.Err = fmt.Errorf(results[i][k].Err)
+			}
+		}
+	}
+
+	out := make([]*KeyResult, len(keys))
+	for i, key := range keys {
+		r := &KeyResult{Key: key}
+		out[i] = r
+
+		if vds[i] == nil {
+			switch op {
+			case batchOpGet:
+				r.Value, r.Err = cs.Get(key, c)
+			case batchOpPut:
+				r.Err = cs.Put(key, values[i], c)
+			default:
+				r.Err = cs.Remove(key, c)
+			}
+			continue
+		}
+
+		need := c.Required(len(vds[i]))
+		if op == batchOpGet {
+			cs.readRepair(key, vds[i])
+			r.Value, r.Err = cs.resolveValue(key, vds[i], need)
+		} else {
+			r.Err = resolveWrite(vds[i], need)
+		}
+	}
+	return out, nil
+}
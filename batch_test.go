@@ -0,0 +1,102 @@
+package chordstore
+
+import (
+	"fmt"
+	"testing"
+)
+
+func Test_splitBatch(t *testing.T) {
+	var (
+		ops  []*BatchOp
+		refs []batchRef
+	)
+	for i := 0; i < 10; i++ {
+		ops = append(ops, &BatchOp{Key: []byte(fmt.Sprintf("k%d", i)), Value: []byte("vv")})
+		refs = append(refs, batchRef{key: i})
+	}
+	// An op larger than the byte limit is sent alone
+	ops[5].Value = make([]byte, 20)
+
+	calls := splitBatch(ops, refs, 3, 10)
+	var n []int
+	for _, c := range calls {
+		n = append(n, len(c.ops))
+		if len(c.refs) != len(c.ops) {
+			t.Fatal("refs mismatch", len(c.refs), len(c.ops))
+		}
+	}
+	if fmt.Sprint(n) != "[2 2 1 1 2 2]" {
+		t.Fatal("wrong calls", n)
+	}
+	if calls[3].refs[0].key != 5 || calls[5].refs[1].key != 9 {
+		t.Fatal("refs out of order", calls[3].refs, calls[5].refs)
+	}
+
+	if calls = splitBatch(ops[:7], refs[:7], 3, 1000); len(calls) != 3 || len(calls[2].ops) != 1 {
+		t.Fatal("should split by count", len(calls))
+	}
+}
+
+func Test_ChordStore_Batch(t *testing.T) {
+	cs1, cs2, stop := newTestRing(t, 36045, nil)
+	defer stop()
+
+	keys := make([][]byte, 50)
+	values := make([][]byte, len(keys))
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("batch/%d", i))
+		values[i] = []byte(fmt.Sprintf("value-%d", i))
+	}
+
+	_, err := cs1.BatchPut(keys, values[:1], ConsistencyAll)
+	if err == nil {
+		t.Fatal("should fail with mismatched values")
+	}
+
+	res, err := cs1.BatchPut(keys, values, ConsistencyAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range res {
+		if r.Err != nil {
+			t.Fatal(string(r.Key), r.Err)
+		}
+	}
+
+	if res, err = cs2.BatchGet(keys, ConsistencyAll); err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != len(keys) {
+		t.Fatal("result count mismatch", len(res))
+	}
+	for i, r := range res {
+		if r.Err != nil || string(r.Key) != string(keys[i]) || string(r.Value) != string(values[i]) {
+			t.Fatal("value mismatch", string(r.Key), string(r.Value), r.Err)
+		}
+	}
+	// Writes are on every replica
+	if val, err := cs2.Get(keys[7], ConsistencyAll); err != nil || string(val) != string(values[7]) {
+		t.Fatal(string(val), err)
+	}
+
+	if res, err = cs2.BatchRemove(keys[:25], ConsistencyAll); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range res {
+		if r.Err != nil {
+			t.Fatal(string(r.Key), r.Err)
+		}
+	}
+
+	if res, err = cs1.BatchGet(keys, ConsistencyQuorum); err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range res {
+		if i < 25 && !isNotFound(r.Err) {
+			t.Fatal("should not be found", string(r.Key), r.Err)
+		}
+		if i >= 25 && (r.Err != nil || string(r.Value) != string(values[i])) {
+			t.Fatal("value mismatch", string(r.Key), string(r.Value), r.Err)
+		}
+	}
+}
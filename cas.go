@@ -0,0 +1,155 @@
+package chordstore
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	chord "github.com/euforia/go-chord"
+	context "golang.org/x/net/context"
+)
+
+// keyLocks serializes operations per key
+type keyLocks struct {
+	mu sync.Mutex
+	m  map[string]*keyLock
+}
+
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+func newKeyLocks() *keyLocks {
+	return &keyLocks{m: map[string]*keyLock{}}
+}
+
+// lock the key returning the function to unlock it
+func (kl *keyLocks) lock(key []byte) func() {
+	k := string(key)
+
+	kl.mu.Lock()
+	l, ok := kl.m[k]
+	if !ok {
+		l = &keyLock{}
+		kl.m[k] = l
+	}
+	l.refs++
+	kl.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+
+		kl.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(kl.m, k)
+		}
+		kl.mu.Unlock()
+	}
+}
+
+// CompareAndSwap sets the key to value if it currently holds old.  A nil old
+// value swaps only if the key does not exist.  Swaps of a key are coordinated by
+// its primary vnode, whose value is the one compared, and each replica only
+// applies the swap if it still holds the old value.  Two vnodes that both take
+// themselves for the primary during a membership change can therefore not both
+// swap on the replicas required by the consistency level.  A swap not
+// acknowledged by enough replicas is rolled back on the replicas still holding
+// it, and is visible on them until then.  It returns the value of the key after
+// the call and whether it was swapped, so on a conflict the current value is
+// returned for the caller to retry with.  A nil value is returned if the key does
+// not exist.  Keys written with Put or UpdateKey are not serialized with swaps.
+func (cs *ChordStore) CompareAndSwap(key, old, value []byte, c Consistency) ([]byte, bool, error) {
+	if err := cs.checkWeak(key); err != nil {
+		return nil, false, err
+	}
+	vns, err := cs.lookup(1, key)
+	if err != nil {
+		return nil, false, err
+	}
+	return cs.store.CompareAndSwap(vns[0], key, old, value, cs.writeConsistency(c), cs.newWriteStamp())
+}
+
+// swapPrimary coordinates a compare-and-swap on the local primary vnode of the
+// key.  It fails if the vnode is no longer the primary so the caller retries on
+// the new one.
+func (cs *ChordStore) swapPrimary(vn *chord.Vnode, key, old, value []byte, c Consistency, ws *WriteStamp) ([]byte, bool, error) {
+	vns, err := cs.lookup(cs.replicas, key)
+	if err != nil {
+		return nil, false, err
+	}
+	if vns[0].Host != vn.Host || vns[0].StringID() != vn.StringID() {
+		return nil, false, fmt.Errorf("vnode %s is not the primary of key: %s", shortID(vn), key)
+	}
+	if value == nil {
+		value = []byte{}
+	}
+
+	unlock := cs.swaps.lock(key)
+	defer unlock()
+
+	cur, ok, err := cs.store.SwapKey(vn, key, old, value, ws)
+	if err != nil || !ok {
+		return cur, false, err
+	}
+
+	var (
+		res     = make([]*VnodeData, len(vns))
+		pending sync.WaitGroup
+	)
+	pending.Add(len(vns))
+	done := fanOut(len(vns), c.Required(len(vns)), cs.timeout, func(ctx context.Context, i int) bool {
+		defer pending.Done()
+		o := &VnodeData{Vnode: vns[i]}
+		if i > 0 {
+			if _, ok, err := cs.store.withContext(ctx).SwapKey(vns[i], key, old, value, ws); err != nil {
+				o.Err = err
+			} else if !ok {
+				o.Err = fmt.Errorf("value changed on replica: %s", key)
+			}
+		}
+		res[i] = o
+		return o.Err == nil
+	})
+
+	if err = resolveWrite(collectVnodeData(vns, res, done), c.Required(len(vns))); err != nil {
+		// Writes still outstanding could land after the rollback.  They return
+		// once their deadline passes at the latest.
+		pending.Wait()
+		if rerr := cs.rollbackSwap(vns, key, old, value); rerr != nil {
+			err = mergeErrors(err, rerr)
+		}
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// rollbackSwap restores the value of the key held before a failed swap on the
+// replicas still holding the swapped value once all writes of the swap have
+// returned.  Replicas written since are left as is.  A nil prev removes the key.
+func (cs *ChordStore) rollbackSwap(vns []*chord.Vnode, key, prev, value []byte) error {
+	// The rollback is written after the swap
+	ws := cs.newWriteStamp()
+	errs := make([]error, len(vns))
+	done := fanOut(len(vns), len(vns), cs.timeout, func(ctx context.Context, i int) bool {
+		_, _, errs[i] = cs.store.withContext(ctx).SwapKey(vns[i], key, value, prev, ws)
+		return errs[i] == nil
+	})
+
+	var failed error
+	for i, ok := range done {
+		err := errReplicaPending
+		if ok {
+			if err = errs[i]; err == nil {
+				continue
+			}
+		}
+		log.Printf("ERR [cas] Rollback failed vnode=%s key=%s %v", shortID(vns[i]), key, err)
+		failed = mergeErrors(failed, err)
+	}
+	if failed != nil {
+		return fmt.Errorf("rollback failed: %v", failed)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package chordstore
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	context "golang.org/x/net/context"
+)
+
+func Test_ChordStore_CompareAndSwap(t *testing.T) {
+	cs1, cs2, stop := newTestRing(t, 36039, nil)
+	defer stop()
+
+	key := []byte("counter")
+	if _, ok, err := cs1.CompareAndSwap(key, []byte("0"), []byte("1"), ConsistencyAll); err != nil || ok {
+		t.Fatal("missing key should not be swapped", ok, err)
+	}
+	if val, ok, err := cs1.CompareAndSwap(key, nil, []byte("0"), ConsistencyAll); err != nil || !ok || string(val) != "0" {
+		t.Fatal("key should be created", string(val), ok, err)
+	}
+	val, ok, err := cs2.CompareAndSwap(key, nil, []byte("1"), ConsistencyAll)
+	if err != nil || ok {
+		t.Fatal("existing key should not be created", ok, err)
+	}
+	if string(val) != "0" {
+		t.Fatal("conflict should return the current value", string(val))
+	}
+
+	// Empty values are told apart from missing keys on either primary
+	empty := []byte("empty")
+	if val, ok, err := cs1.CompareAndSwap(empty, nil, []byte{}, ConsistencyAll); err != nil || !ok || val == nil {
+		t.Fatal("key should be created", ok, err)
+	}
+	for _, cs := range []*ChordStore{cs1, cs2} {
+		if val, ok, err := cs.CompareAndSwap(empty, nil, []byte("v"), ConsistencyAll); err != nil || ok || val == nil {
+			t.Fatal("existing empty key should not be created", val, ok, err)
+		}
+	}
+
+	// Concurrent increments from both nodes are not lost
+	var wg sync.WaitGroup
+	for _, cs := range []*ChordStore{cs1, cs2, cs1, cs2} {
+		wg.Add(1)
+		go func(cs *ChordStore) {
+			defer wg.Done()
+			cur := []byte("0")
+			for i := 0; i < 5; {
+				n, _ := strconv.Atoi(string(cur))
+				val, ok, err := cs.CompareAndSwap(key, cur, []byte(strconv.Itoa(n+1)), ConsistencyAll)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if ok {
+					i++
+				}
+				cur = val
+			}
+		}(cs)
+	}
+	wg.Wait()
+
+	vds, err := cs1.GetKey(3, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, vd := range vds {
+		if vd.Err != nil || string(vd.Data) != "20" {
+			t.Fatal("replica mismatch", vd.Vnode.StringID(), string(vd.Data), vd.Err)
+		}
+	}
+
+	// Rollbacks leave replicas written since the swap as is
+	rolled := []byte("rolled")
+	vns, err := cs1.lookup(3, rolled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, vn := range vns {
+		val := []byte("swapped")
+		if i == 1 {
+			val = []byte("later")
+		}
+		if err = cs1.store.PutKey(vn, rolled, val, 0, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = cs1.rollbackSwap(vns, rolled, []byte("prev"), []byte("swapped")); err != nil {
+		t.Fatal(err)
+	}
+	for i, vn := range vns {
+		want := "prev"
+		if i == 1 {
+			want = "later"
+		}
+		if val, err := cs1.store.GetKey(vn, rolled); err != nil || string(val) != want {
+			t.Fatal("wrong value after rollback", shortID(vn), string(val), err)
+		}
+	}
+
+	// Writes arriving once the writer gave up are dropped so a rollback of a
+	// failed swap is not overwritten
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	resp, err := cs2.PutKeyRPC(ctx, &DHTKeyValue{Vn: vds[0].Vnode, Key: key, Value: []byte("21")})
+	if err != nil || resp.Err == "" {
+		t.Fatal("late write should fail", resp.Err, err)
+	}
+	if val, err := cs1.Get(key, ConsistencyAll); err != nil || string(val) != "20" {
+		t.Fatal("late write should be dropped", string(val), err)
+	}
+}
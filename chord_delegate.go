@@ -12,11 +12,37 @@ import (
 // vnode based on chord events.
 type ChordDelegate struct {
 	Store Store
+	// Called after keys are transferred to a new predecessor to remove those the
+	// local vnode is no longer a replica of.  Pruning is disabled if nil.
+	prune func(local *chord.Vnode, kr *KeyRange)
+	// Called when a neighbouring vnode leaves to restore the replica count of
+	// the keys of the local vnode.  Re-replication is disabled if nil.
+	rereplicate func(local, departed *chord.Vnode)
+	// Called when the ring changes to move the members of consensus groups.
+	// leaving is the local vnode leaving the ring or nil.  Disabled if nil.
+	reconfigure func(leaving *chord.Vnode)
+	// Called when a new predecessor joins or the local vnode leaves to move the
+	// keys of strong namespaces in the range to the consensus groups now
+	// responsible for them.  Disabled if nil.
+	handoff func(local *chord.Vnode, kr *KeyRange, leaving bool)
+	// Key prefixes not transferred.  Keys of strong namespaces are handed off
+	// between consensus groups instead.
+	exclude []string
 }
 
-func (cd *ChordDelegate) transferVnodeData(src, dst *chord.Vnode) error {
+// transferVnodeData copies the keys in the range from src to dst.  A nil range
+// copies all keys.
+func (cd *ChordDelegate) transferVnodeData(src, dst *chord.Vnode, kr *KeyRange) error {
+	if len(cd.exclude) > 0 {
+		r := &KeyRange{Exclude: cd.exclude}
+		if kr != nil {
+			r.Start, r.End = kr.Start, kr.End
+		}
+		kr = r
+	}
+
 	buf := new(bytes.Buffer)
-	err := cd.Store.Snapshot(src, buf)
+	err := cd.Store.Snapshot(src, buf, kr)
 
 	if err != nil {
 		if err != io.EOF {
@@ -30,36 +56,73 @@ func (cd *ChordDelegate) transferVnodeData(src, dst *chord.Vnode) error {
 		return nil
 	}
 
-	log.Printf("DBG [transfer] Copying %s --> %s", shortID(src), shortID(dst))
+	log.Printf("DBG [transfer] Copying %s --> %s range=%s", shortID(src), shortID(dst), kr)
 	return cd.Store.Restore(dst, buf)
 }
 
 // NewPredecessor is called when a new predecessor is found
 func (cd *ChordDelegate) NewPredecessor(local, remoteNew, remotePrev *chord.Vnode) {
 	log.Printf("DBG [chord] NewPredecessor local=%s remote=%s old=%s", shortID(local), shortID(remoteNew), shortID(remotePrev))
-	// Ship a copy of the local vnode to the remote
-	if err := cd.transferVnodeData(local, remoteNew); err != nil {
+	if cd.reconfigure != nil {
+		cd.reconfigure(nil)
+	}
+	// The new predecessor takes on all keys held locally except those in
+	// (remoteNew, local] which remain the primary responsibility of the local
+	// vnode.
+	kr := &KeyRange{Start: local.Id, End: remoteNew.Id}
+	if err := cd.transferVnodeData(local, remoteNew, kr); err != nil {
 		log.Printf("ERR [transfer] %s %s %v", local.StringID(), remoteNew.StringID(), err)
+		return
 	}
 
+	if cd.prune != nil {
+		cd.prune(local, kr)
+	}
+
+	if cd.handoff != nil && remotePrev != nil {
+		// Keys in (remotePrev, remoteNew] moved from the group of the local vnode
+		cd.handoff(local, &KeyRange{Start: remotePrev.Id, End: remoteNew.Id}, false)
+	}
 }
 
 // Leaving is called when local node is leaving the ring
 func (cd *ChordDelegate) Leaving(local, pred, succ *chord.Vnode) {
 	log.Printf("DBG [chord] Leaving local=%s succ=%s", shortID(local), shortID(succ))
-	if err := cd.transferVnodeData(local, succ); err != nil {
+	if cd.reconfigure != nil {
+		cd.reconfigure(local)
+	}
+	if err := cd.transferVnodeData(local, succ, nil); err != nil {
 		log.Printf("ERR [transfer] %s %s %v", local.StringID(), succ.StringID(), err)
 	}
+	if cd.handoff != nil {
+		var kr *KeyRange
+		if pred != nil {
+			kr = &KeyRange{Start: pred.Id, End: local.Id}
+		}
+		cd.handoff(local, kr, true)
+	}
 }
 
 // PredecessorLeaving is called when a predecessor leaves
 func (cd *ChordDelegate) PredecessorLeaving(local, remote *chord.Vnode) {
 	log.Printf("DBG [chord] PredecessorLeaving local=%s remote=%s", shortID(local), shortID(remote))
+	if cd.rereplicate != nil {
+		cd.rereplicate(local, remote)
+	}
+	if cd.reconfigure != nil {
+		cd.reconfigure(nil)
+	}
 }
 
 // SuccessorLeaving is called when a successor leaves
 func (cd *ChordDelegate) SuccessorLeaving(local, remote *chord.Vnode) {
 	log.Printf("DBG [chord] SuccessorLeaving local=%s remote=%s", shortID(local), shortID(remote))
+	if cd.rereplicate != nil {
+		cd.rereplicate(local, remote)
+	}
+	if cd.reconfigure != nil {
+		cd.reconfigure(nil)
+	}
 }
 
 // Shutdown is called when the node is shutting down
@@ -5,8 +5,12 @@ import (
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"hash"
 	"io"
 	"log"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	chord "github.com/euforia/go-chord"
 	context "golang.org/x/net/context"
@@ -15,45 +19,160 @@ import (
 // Store is the overall store abstracting local and remote vnodes
 type Store interface {
 	GetKey(vn *chord.Vnode, key []byte) ([]byte, error)
-	PutKey(vn *chord.Vnode, key, value []byte) error
-	UpdateKey(vn *chord.Vnode, prevHash, key, value []byte) error
-	RemoveKey(vn *chord.Vnode, key []byte) error
+	PutKey(vn *chord.Vnode, key, value []byte, expiry int64, ws *WriteStamp) error
+	KeyExpiry(vn *chord.Vnode, key []byte) (int64, error)
+	KeyTombstone(vn *chord.Vnode, key []byte) (int64, error)
+	UpdateKey(vn *chord.Vnode, prevHash, key, value []byte, ws *WriteStamp) error
+	RemoveKey(vn *chord.Vnode, key []byte, ws *WriteStamp) error
+	KeyHistory(vn *chord.Vnode, key []byte) ([]*KeyTxn, error)
+	GetVersions(vn *chord.Vnode, key []byte) ([]*Sibling, error)
+	PutVersion(vn *chord.Vnode, key, value []byte, ctx VectorClock, ws *WriteStamp) (*Sibling, error)
+	AddSibling(vn *chord.Vnode, key []byte, sib *Sibling) error
+	CompareAndSwap(vn *chord.Vnode, key, old, value []byte, c Consistency, ws *WriteStamp) ([]byte, bool, error)
+	// Compare and set the key on the vnode only.  A nil value removes the key.
+	SwapKey(vn *chord.Vnode, key, old, value []byte, ws *WriteStamp) ([]byte, bool, error)
+	Batch(ops []*BatchOp) ([]*BatchResult, error) // Ops on vnodes of a single host
+	MerkleLevel(vn *chord.Vnode, level int, indexes []int) ([][]byte, error)
+	MerkleEntries(vn *chord.Vnode, leaves []int) ([]*MerkleEntry, error)
 	Shutdown() error
 
-	Snapshot(vn *chord.Vnode, wr io.Writer) error
+	Snapshot(vn *chord.Vnode, wr io.Writer, kr *KeyRange) error
 	Restore(vn *chord.Vnode, rd io.Reader) error
 
 	GetObject(vn *chord.Vnode, key []byte) (io.Reader, error)
-	PutObject(vn *chord.Vnode, key []byte, rd io.Reader) error
+	// Reader of length bytes from offset, or to the end if length < 1, along
+	// with the size of the whole object
+	GetObjectRange(vn *chord.Vnode, key []byte, offset, length int64) (io.Reader, int64, error)
+	PutObject(vn *chord.Vnode, key []byte, rd io.Reader, meta *ObjectMeta) error
+	StatObject(vn *chord.Vnode, key []byte) (*ObjectMeta, error)
+	// Sorted keys with the prefix after the cursor.  All are returned if limit < 1.
+	ListKeys(vn *chord.Vnode, prefix, cursor []byte, limit int) ([][]byte, error)
+	ListObjects(vn *chord.Vnode, prefix, cursor []byte, limit int) ([][]byte, error)
+	PutContent(vn *chord.Vnode, key []byte, rd io.Reader, meta *ObjectMeta) error // Key is the sha256 of the data
 	RemoveObject(vn *chord.Vnode, key []byte) error
 }
 
-// VnodeStore are operations for local vnodes. It also instantiates new stores
+// VnodeStore are operations for local vnodes. It also instantiates new stores.
+// Writes with a nil stamp originate on the vnode.
 type VnodeStore interface {
 	New(*chord.Vnode) (VnodeStore, error) // Instantiate a new store
 
-	GetKey(key []byte) ([]byte, error)
-	PutKey(key, value []byte) error
-	UpdateKey(prevHash, key, value []byte) error
-	RemoveKey(key []byte) error
-
-	Snapshot(io.Writer) error
+	GetKey(key []byte) ([]byte, error)                            // Expired keys are not found
+	PutKey(key, value []byte, expiry int64, ws *WriteStamp) error // Expiry in unix nanoseconds, 0 for none
+	KeyExpiry(key []byte) (int64, error)
+	ExpireKeys() (int, error) // Remove expired keys returning the count
+	UpdateKey(prevHash, key, value []byte, ws *WriteStamp) error
+	RemoveKey(key []byte, ws *WriteStamp) error                                      // Leaves a tombstone
+	KeyTombstone(key []byte) (int64, error)                                          // Removal time of the key or 0
+	PurgeTombstones(before int64) (int, error)                                       // Remove tombstones older than before
+	PruneKey(key []byte) error                                                       // Remove a key no longer replicated by the vnode without a tombstone
+	KeyHistory(key []byte) ([]*KeyTxn, error)                                        // Transaction log of the key
+	GetVersions(key []byte) ([]*Sibling, error)                                      // Siblings of the key.  A single unversioned one for plain keys.
+	PutVersion(key, value []byte, ctx VectorClock, ws *WriteStamp) (*Sibling, error) // New version by the vnode superseding ctx
+	AddSibling(key []byte, sib *Sibling) error                                       // Version written on another replica
+	MerkleTree() *MerkleTree                                                         // Hash tree over the keys
+
+	Snapshot(io.Writer, *KeyRange) error // Keys in the range or all if nil
 	Restore(io.Reader) error
 
 	GetObject(key []byte) (io.Reader, error)
-	PutObject(key []byte, rd io.Reader) error
+	GetObjectRange(key []byte, offset, length int64) (io.Reader, int64, error) // Bytes from offset and the object size
+	PutObject(key []byte, rd io.Reader, meta *ObjectMeta) error                // Size, Hash and Mtime of meta are set by the store
+	StatObject(key []byte) (*ObjectMeta, error)
 	RemoveObject(key []byte) error
+
+	ListKeys(prefix, cursor []byte, limit int) ([][]byte, error) // Sorted keys with the prefix after the cursor
+	ListObjects(prefix, cursor []byte, limit int) ([][]byte, error)
+}
+
+// conflictResolving is implemented by VnodeStores that resolve conflicting values
+// on restore
+type conflictResolving interface {
+	setConflictResolver(ConflictResolver)
+}
+
+// ringHashing is implemented by VnodeStores that place keys by their ring hash
+type ringHashing interface {
+	setHashFunc(func() hash.Hash)
+}
+
+// raftPersisting is implemented by vnode stores that persist the state of the
+// consensus group members of their vnode.  Strong namespaces require it.
+type raftPersisting interface {
+	saveRaftState(group string, state []byte) error
+	appendRaftLog(group string, rec []byte) error
+	loadRaftState(group string) ([]byte, [][]byte, error) // State and appended records.  nil if none.
+}
+
+// txnPersisting is implemented by vnode stores that persist the transactions
+// prepared by their vnode so they stay locked and are resolved after a restart.
+// Prepared transactions are otherwise kept in memory.
+type txnPersisting interface {
+	savePreparedTxn(id string, txn []byte) error
+	removePreparedTxn(id string) error
+	loadPreparedTxns() ([][]byte, error)
 }
 
 // ChordStore implements chord ring base storage
 type ChordStore struct {
 	ring  *chord.Ring
 	store *TransparentStore
+	// default replica count used when n < 1
+	replicas int
+	// deadline for all replicas of an operation to respond
+	timeout time.Duration
+	// read repair
+	healer *HealingEngine
+	// periodic replica sync.  nil if disabled
+	ae *antiEntropy
+	// replica peer failure detection.  nil if disabled
+	fd *failureDetector
+	// expired key and tombstone removal.  nil if disabled
+	reaper *expiryReaper
+	// time allowed for the ring to route around a departed vnode
+	settle time.Duration
+	// staging directory for content addressed uploads
+	spoolDir string
+	// objects larger than this are chunked.  0 disables chunking.
+	chunkSize int
+	// unreferenced chunk removal.  nil if disabled
+	gc *chunkCollector
+	// age below which unreferenced chunks are kept
+	chunkGrace time.Duration
+	// erasure code for PutErasure.  nil if disabled.
+	rs *reedSolomon
+	// chord transport and local vnodes used to walk the ring
+	trans  *chord.GRPCTransport
+	vnodes []*chord.Vnode
+	// successors returned per ring walk step
+	successors int
+	// namespaces placed in key order
+	ordered []string
+	// current layouts of the ordered namespaces.  nil if none.
+	layouts *orderedLayouts
+	// ordered key moves and splits.  nil if disabled.
+	balancer *orderedBalancer
+	// keys of an ordered namespace on a vnode above which it is split
+	splitKeys int
+	// chooses the value of divergent replicas.  nil uses the quorum value.
+	resolver ConflictResolver
+	// compare-and-swaps in progress on local primary vnodes
+	swaps *keyLocks
+	// consensus groups of strong namespaces.  nil if disabled.
+	raft *raftGroups
+	// transactions coordinated or prepared locally
+	txns *txnManager
+	// levels used for ConsistencyDefault
+	readLevel  Consistency
+	writeLevel Consistency
 }
 
 // NewChordStore instantiaties a new chord store using the given VnodeStore for
 // local storage.
 func NewChordStore(cfg *Config, vnstore VnodeStore) (*ChordStore, error) {
+	if _, ok := vnstore.(raftPersisting); !ok && len(cfg.StrongNamespaces) > 0 {
+		return nil, fmt.Errorf("strong namespaces require a store persisting raft state: %T", vnstore)
+	}
 	if err := initChordRing(cfg); err != nil {
 		return nil, err
 	}
@@ -63,163 +182,781 @@ func NewChordStore(cfg *Config, vnstore VnodeStore) (*ChordStore, error) {
 		return nil, err
 	}
 
-	cs := &ChordStore{ring: cfg.Ring}
+	cs := &ChordStore{
+		ring:       cfg.Ring,
+		replicas:   cfg.Replicas,
+		timeout:    cfg.RequestTimeout,
+		settle:     cfg.Chord.StabilizeMax,
+		spoolDir:   cfg.SpoolDir,
+		chunkSize:  cfg.ChunkSize,
+		chunkGrace: cfg.ChunkGracePeriod,
+		trans:      cfg.Chord.Transport,
+		vnodes:     vnodes,
+		ordered:    cfg.OrderedNamespaces,
+		layouts:    cfg.layouts,
+		splitKeys:  cfg.OrderedSplitKeys,
+		resolver:   cfg.ConflictResolver,
+		swaps:      newKeyLocks(),
+		readLevel:  cfg.ReadConsistency,
+		writeLevel: cfg.WriteConsistency,
+	}
+	if cs.readLevel == ConsistencyDefault {
+		cs.readLevel = ConsistencyQuorum
+	}
+	if cs.writeLevel == ConsistencyDefault {
+		cs.writeLevel = ConsistencyQuorum
+	}
+	if cs.successors = cfg.Chord.NumSuccessors; cs.successors < 1 {
+		cs.successors = 1
+	}
+	if cs.replicas < 1 {
+		cs.replicas = 1
+	}
+	if cfg.ErasureDataShards > 0 {
+		if cs.rs, err = newReedSolomon(cfg.ErasureDataShards, cfg.ErasureParityShards); err != nil {
+			return nil, err
+		}
+	}
+	if rs, ok := vnstore.(conflictResolving); ok && cfg.ConflictResolver != nil {
+		rs.setConflictResolver(cfg.ConflictResolver)
+	}
+	if rh, ok := vnstore.(ringHashing); ok {
+		rh.setHashFunc(cfg.Chord.HashFunc)
+	}
 	if cs.store, err = NewTransparentStore(vnstore, vnodes...); err != nil {
 		return nil, err
 	}
+	cs.store.hashFunc = cfg.Chord.HashFunc
+	cs.store.swap = cs.swapPrimary
 	cfg.ChordDelegate().Store = cs.store
+	if cfg.PruneOnTransfer {
+		cfg.ChordDelegate().prune = cs.pruneRange
+	}
+	cfg.ChordDelegate().rereplicate = cs.scheduleRereplicate
+	cfg.ChordDelegate().exclude = cfg.StrongNamespaces
+
+	cs.txns = newTxnManager(cs, cfg.TxnTimeout)
+	if err = cs.txns.load(); err != nil {
+		return nil, err
+	}
+	go cs.txns.start()
+
+	if len(cfg.StrongNamespaces) > 0 {
+		cs.raft = newRaftGroups(cs, cfg.StrongNamespaces, cfg.RaftElectionTimeout, cfg.RaftCompactEntries)
+		cfg.ChordDelegate().reconfigure = cs.raft.reconfigure
+		cfg.ChordDelegate().handoff = cs.raft.handoff
+		go cs.raft.start()
+	}
+
+	cs.healer = NewHealingEngine(cs, cfg.HealQueueSize)
+	go cs.healer.Start()
+
+	if cfg.AntiEntropyInterval > 0 {
+		cs.ae = newAntiEntropy(cs, cfg.Chord.Hostname, cfg.AntiEntropyInterval)
+		go cs.ae.start()
+	}
+
+	if cfg.FailureCheckInterval > 0 {
+		cs.fd = newFailureDetector(cs, cfg.Chord.Hostname, cfg.FailureCheckInterval)
+		go cs.fd.start()
+	}
+
+	if cfg.ExpiryInterval > 0 {
+		cs.reaper = newExpiryReaper(cs, cfg.ExpiryInterval, cfg.TombstoneGracePeriod)
+		go cs.reaper.start()
+	}
+
+	if cfg.ChunkCollectInterval > 0 {
+		cs.gc = newChunkCollector(cs, cfg.ChunkCollectInterval)
+		go cs.gc.start()
+	}
+
+	if cs.layouts != nil && cfg.OrderedBalanceInterval > 0 {
+		cs.balancer = newOrderedBalancer(cs, cfg.OrderedBalanceInterval)
+		go cs.balancer.start()
+	}
 
 	// register server with grpc
 	RegisterDHTServer(cfg.Server, cs)
 	return cs, nil
 }
 
-func (cs *ChordStore) GetObject(n int, key []byte) ([]*VnodeDataIO, error) {
-	vns, err := cs.ring.Lookup(n, key)
+// readConsistency returns the configured read level for ConsistencyDefault
+func (cs *ChordStore) readConsistency(c Consistency) Consistency {
+	if c == ConsistencyDefault {
+		return cs.readLevel
+	}
+	return c
+}
+
+// writeConsistency returns the configured write level for ConsistencyDefault
+func (cs *ChordStore) writeConsistency(c Consistency) Consistency {
+	if c == ConsistencyDefault {
+		return cs.writeLevel
+	}
+	return c
+}
+
+// lookup returns n vnodes for the key.  The configured replica count is used if
+// n is less than 1.
+func (cs *ChordStore) lookup(n int, key []byte) ([]*chord.Vnode, error) {
+	if n < 1 {
+		n = cs.replicas
+	}
+	return cs.ring.Lookup(n, key)
+}
+
+// Get the value of a key from the replicas.  It returns once enough replicas
+// agree on a value to satisfy the consistency level.  If a conflict resolver is
+// configured it chooses the value when the replicas disagree.  Keys of strong
+// namespaces are read through the consensus group of the key regardless of the
+// level.  ConsistencyDefault uses the configured ReadConsistency.
+func (cs *ChordStore) Get(key []byte, c Consistency) ([]byte, error) {
+	if cs.strong(key) {
+		return cs.raft.submit(key, &RaftEntry{Op: raftOpGet, Key: key})
+	}
+	c = cs.readConsistency(c)
+	vds, err := cs.getKey(cs.replicas, c, key, true)
 	if err != nil {
 		return nil, err
 	}
+	return cs.resolveValue(key, vds, c.Required(len(vds)))
+}
 
-	vds := make([]*VnodeDataIO, len(vns))
-	for i, vn := range vns {
-		vds[i] = &VnodeDataIO{Vnode: vn}
+// Put a key-value on all replicas.  It returns an error if not enough replicas
+// acknowledge the write to satisfy the consistency level.  Keys of strong
+// namespaces are written through the consensus group of the key regardless of
+// the level.  ConsistencyDefault uses the configured WriteConsistency.
+func (cs *ChordStore) Put(key, value []byte, c Consistency) error {
+	if cs.strong(key) {
+		_, err := cs.raft.submit(key, &RaftEntry{Op: raftOpPut, Key: key, Value: value, Stamp: cs.newWriteStamp()})
+		return err
+	}
+	c = cs.writeConsistency(c)
+	vds, err := cs.putKey(cs.replicas, c, key, value, 0)
+	if err != nil {
+		return err
+	}
+	return resolveWrite(vds, c.Required(len(vds)))
+}
+
+// Remove a key from all replicas.  It returns an error if not enough replicas
+// acknowledge the removal to satisfy the consistency level.  Keys of strong
+// namespaces are removed through the consensus group of the key regardless of
+// the level.  ConsistencyDefault uses the configured WriteConsistency.
+func (cs *ChordStore) Remove(key []byte, c Consistency) error {
+	if cs.strong(key) {
+		_, err := cs.raft.submit(key, &RaftEntry{Op: raftOpRemove, Key: key, Stamp: cs.newWriteStamp()})
+		return err
+	}
+	c = cs.writeConsistency(c)
+	vds, err := cs.removeKey(cs.replicas, c, key)
+	if err != nil {
+		return err
+	}
+	return resolveWrite(vds, c.Required(len(vds)))
+}
+
+// GetObject with n copies.  Objects stored in chunks are reassembled
+// transparently.  For erasure coded objects a single reader reconstructing the
+// object from its shards is returned.  Readers not read to the end must be
+// closed.
+func (cs *ChordStore) GetObject(n int, key []byte) ([]*VnodeDataIO, error) {
+	return cs.getObject(n, key, 0, 0, true)
+}
+
+// GetObjectRange is GetObject reading length bytes from offset, or to the end if
+// length < 1.  Chunked objects only fetch the chunks covering the range and
+// erasure coded objects only the stripes covering it.
+func (cs *ChordStore) GetObjectRange(n int, key []byte, offset, length int64) ([]*VnodeDataIO, error) {
+	if offset < 0 {
+		return nil, fmt.Errorf("invalid offset: %d", offset)
+	}
+	return cs.getObject(n, key, offset, length, true)
+}
 
-		buf := bytes.NewBuffer(nil)
+// getObject opens readers to a byte range of n replicas.  If resolve is true
+// replicas holding a manifest return a reader reassembling the chunked object and
+// replicas holding a shard switch to reading the erasure coded object.
+func (cs *ChordStore) getObject(n int, key []byte, offset, length int64, resolve bool) ([]*VnodeDataIO, error) {
+	vns, err := cs.lookup(n, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu        sync.Mutex
+		collected bool
+		res       = make([]*VnodeDataIO, len(vns))
+	)
+	done := fanOut(len(vns), len(vns), cs.timeout, func(ctx context.Context, i int) bool {
+		o := &VnodeDataIO{Vnode: vns[i]}
 		//log.Printf("GET try=%d vnode=%s key=%x", i+1, shortID(vn), key)
-		rd, err := cs.store.GetObject(vn, key)
-		if err == nil {
-			if _, err = io.Copy(buf, rd); err == nil {
-				vds[i].r = buf
-				continue
-			}
+		o.r, o.Size, o.Err = cs.openObject(ctx, vns[i], key, offset, length, resolve)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if collected {
+			// Too late.  Release the stream.
+			o.Close()
+			return false
+		}
+		res[i] = o
+		return o.Err == nil
+	})
+
+	mu.Lock()
+	collected = true
+	// Release streams that opened after the deadline but before collection
+	for i, o := range res {
+		if !done[i] && o != nil {
+			o.Close()
+		}
+	}
+	mu.Unlock()
+
+	vds := collectVnodeDataIO(vns, res, done)
+	for _, vd := range vds {
+		ss, ok := vd.r.(*shardStream)
+		if !ok {
+			continue
 		}
-		vds[i].Err = err
-		log.Printf("TODO [consistency] Check: key=%x index=%d msg='%s'", key, i, err.Error())
-		//cs.healQ <- HealRequest{Vnode: vn, Key: key}
+		for _, o := range vds {
+			o.Close()
+		}
+		return cs.getErasure(key, ss.hdr, offset, length)
+	}
+
+	// Queue missing replicas for repair if any replica has the object
+	var (
+		missing *chord.Vnode
+		found   bool
+	)
+	for _, vd := range vds {
+		if vd.Err == nil {
+			found = true
+		} else if missing == nil && isNotFound(vd.Err) {
+			missing = vd.Vnode
+		}
+	}
+	if found && missing != nil {
+		cs.enqueueHeal(HealRequest{Type: HealTypeObject, Vnode: missing, Key: key})
 	}
 	return vds, nil
 }
 
-// PutObject from reader returning the sha256 hash as the key
+// openObject opens a byte range of the object on the vnode returning the size of
+// the whole object or -1 if unknown.  If resolve is true the encoding of the
+// object is checked for a manifest or shard before reading the range.  The
+// context bounds opening the object.
+func (cs *ChordStore) openObject(ctx context.Context, vn *chord.Vnode, key []byte, offset, length int64, resolve bool) (io.Reader, int64, error) {
+	st := cs.store.withContext(ctx)
+	if !resolve {
+		return st.GetObjectRange(vn, key, offset, length)
+	}
+
+	meta, err := st.StatObject(vn, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	if meta.Encoding != ObjectEncodingChunked && meta.Encoding != ObjectEncodingErasure {
+		return st.GetObjectRange(vn, key, offset, length)
+	}
+
+	rd, size, err := st.GetObjectRange(vn, key, 0, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	if rd, err = cs.resolveObject(rd, meta); err != nil {
+		return nil, 0, err
+	}
+
+	switch r := rd.(type) {
+	case *chunkReader:
+		size = r.m.Size
+		r.seek(offset)
+		rd = limitReader(r, length)
+	case *shardStream:
+		// The range is read by getErasure
+		size = -1
+	}
+	return rd, size, nil
+}
+
+// PutObject streams the reader to all n replicas concurrently.  Objects larger
+// than the chunk size are split into chunks placed on the ring independently
+// with a manifest stored under the key.  At most chunkParallelism chunks are held
+// in memory at a time.
 func (cs *ChordStore) PutObject(n int, key []byte, rd io.Reader) ([]*VnodeDataIO, error) {
-	vns, err := cs.ring.Lookup(n, key)
+	return cs.PutObjectWithMeta(n, key, rd, nil)
+}
+
+// PutObjectWithMeta is PutObject storing the content type and headers of the
+// metadata with the object.  Other fields are set by the store.
+func (cs *ChordStore) PutObjectWithMeta(n int, key []byte, rd io.Reader, meta *ObjectMeta) ([]*VnodeDataIO, error) {
+	meta = userMeta(meta)
+	if cs.chunkSize < 1 {
+		return cs.putObject(n, key, rd, putMeta(meta))
+	}
+
+	// Read a chunk and a byte to find out if the object needs chunking
+	buf := make([]byte, cs.chunkSize+1)
+	nr, err := io.ReadFull(rd, buf)
+	switch err {
+	case nil:
+		return cs.putChunked(n, key, io.MultiReader(bytes.NewReader(buf), rd), meta)
+	case io.EOF, io.ErrUnexpectedEOF:
+		return cs.putObject(n, key, bytes.NewReader(buf[:nr]), putMeta(meta))
+	}
+	return nil, err
+}
+
+// putMeta returns a put storing objects with the metadata
+func putMeta(meta *ObjectMeta) func(*TransparentStore, *chord.Vnode, []byte, io.Reader) error {
+	return func(ts *TransparentStore, vn *chord.Vnode, key []byte, rd io.Reader) error {
+		return ts.PutObject(vn, key, rd, meta)
+	}
+}
+
+// putContent returns a put storing content addressed objects with the metadata
+func putContent(meta *ObjectMeta) func(*TransparentStore, *chord.Vnode, []byte, io.Reader) error {
+	return func(ts *TransparentStore, vn *chord.Vnode, key []byte, rd io.Reader) error {
+		return ts.PutContent(vn, key, rd, meta)
+	}
+}
+
+// userMeta returns a copy of the user defined fields of the metadata
+func userMeta(in *ObjectMeta) *ObjectMeta {
+	m := &ObjectMeta{}
+	if in != nil {
+		m.ContentType, m.Headers = in.ContentType, in.Headers
+	}
+	return m
+}
+
+func (cs *ChordStore) putObject(n int, key []byte, rd io.Reader, put func(*TransparentStore, *chord.Vnode, []byte, io.Reader) error) ([]*VnodeDataIO, error) {
+	vns, err := cs.lookup(n, key)
 	if err != nil {
 		return nil, err
 	}
 
-	// Copy initial data
-	buf := new(bytes.Buffer)
-	if _, err = io.Copy(buf, rd); err != nil {
-		return nil, err
+	return cs.putStreams(vns, key, put, func(pws []*io.PipeWriter) {
+		teeObject(rd, pws)
+	}), nil
+}
+
+// putStreams concurrently puts a stream to each vnode.  feed is called in its own
+// goroutine with a writer for each vnode in order and must close all of them.
+// Uploads are not bounded as a whole as their duration depends on the size and
+// source of the data.  Instead a stream is abandoned once the vnode has spent the
+// request timeout without asking for more data, be it setting up the stream,
+// sending data or storing it at the end.
+func (cs *ChordStore) putStreams(vns []*chord.Vnode, key []byte, put func(*TransparentStore, *chord.Vnode, []byte, io.Reader) error, feed func([]*io.PipeWriter)) []*VnodeDataIO {
+	prs := make([]*io.PipeReader, len(vns))
+	pws := make([]*io.PipeWriter, len(vns))
+	for i := range vns {
+		prs[i], pws[i] = io.Pipe()
 	}
+	go feed(pws)
+
+	res := make([]*VnodeDataIO, len(vns))
+	var wg sync.WaitGroup
+	wg.Add(len(vns))
+	for i := range vns {
+		go func(i int) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			// Unblock the feed and the call if the vnode stalls
+			rd := newIdleReader(prs[i], cs.timeout, func() {
+				prs[i].CloseWithError(errReplicaPending)
+				cancel()
+			})
+
+			res[i] = &VnodeDataIO{Vnode: vns[i]}
+			res[i].Err = put(cs.store.withContext(ctx), vns[i], key, rd)
+			if rd.stop() {
+				res[i].Err = errReplicaPending
+			}
+			// Unblock the feed if the vnode stopped reading early
+			prs[i].Close()
+		}(i)
+	}
+	wg.Wait()
 
-	vds := make([]*VnodeDataIO, len(vns))
-	for i, vn := range vns {
-		vds[i] = &VnodeDataIO{Vnode: vn}
-		vds[i].Err = cs.store.PutObject(vn, key, bytes.NewBuffer(buf.Bytes()))
+	return res
+}
+
+// idleReader calls onIdle once if the timeout elapses between the return of a
+// read and the next one, or before the first one.  Time spent in a read waiting
+// for data does not count.  A timeout of 0 disables it.
+type idleReader struct {
+	rd      io.Reader
+	timeout time.Duration
+	timer   *time.Timer
+	idle    int32
+}
+
+func newIdleReader(rd io.Reader, timeout time.Duration, onIdle func()) *idleReader {
+	ir := &idleReader{rd: rd, timeout: timeout}
+	if timeout > 0 {
+		ir.timer = time.AfterFunc(timeout, func() {
+			atomic.StoreInt32(&ir.idle, 1)
+			onIdle()
+		})
 	}
+	return ir
+}
 
-	return vds, nil
+func (ir *idleReader) Read(p []byte) (int, error) {
+	if ir.timer == nil {
+		return ir.rd.Read(p)
+	}
+	ir.timer.Stop()
+	n, err := ir.rd.Read(p)
+	ir.timer.Reset(ir.timeout)
+	return n, err
+}
+
+// stop the timer returning true if the reader went idle
+func (ir *idleReader) stop() bool {
+	if ir.timer != nil {
+		ir.timer.Stop()
+	}
+	return atomic.LoadInt32(&ir.idle) == 1
+}
+
+// teeObject copies the reader to all writers.  A writer that fails is dropped
+// so a failed replica does not stop the others.  All writers are closed once the
+// reader is exhausted, with the read error if it is not io.EOF.
+func teeObject(rd io.Reader, pws []*io.PipeWriter) {
+	active := make([]bool, len(pws))
+	for i := range active {
+		active[i] = true
+	}
+
+	buf := make([]byte, 65519)
+	for {
+		n, err := rd.Read(buf)
+		if n > 0 {
+			var writing bool
+			for i, pw := range pws {
+				if !active[i] {
+					continue
+				}
+				if _, e := pw.Write(buf[:n]); e != nil {
+					active[i] = false
+				} else {
+					writing = true
+				}
+			}
+			if !writing && err == nil {
+				err = fmt.Errorf("all replicas failed")
+			}
+		}
+
+		if err != nil {
+			for _, pw := range pws {
+				if err == io.EOF {
+					pw.Close()
+				} else {
+					pw.CloseWithError(err)
+				}
+			}
+			return
+		}
+	}
 }
 
 // RemoveObject with n copies
 func (cs *ChordStore) RemoveObject(n int, key []byte) ([]*VnodeDataIO, error) {
-	vns, err := cs.ring.Lookup(n, key)
+	vns, err := cs.lookup(n, key)
 	if err != nil {
 		return nil, err
 	}
 
-	vds := make([]*VnodeDataIO, len(vns))
-	for i, vn := range vns {
-		vds[i] = &VnodeDataIO{Vnode: vn}
-		vds[i].Err = cs.store.RemoveObject(vn, key)
-	}
+	res := make([]*VnodeDataIO, len(vns))
+	done := fanOut(len(vns), len(vns), cs.timeout, func(ctx context.Context, i int) bool {
+		res[i] = &VnodeDataIO{Vnode: vns[i]}
+		res[i].Err = cs.store.withContext(ctx).RemoveObject(vns[i], key)
+		return res[i].Err == nil
+	})
 
-	return vds, nil
+	return collectVnodeDataIO(vns, res, done), nil
 }
 
-// PutKey with value on the ring with a replica count of n
+// PutKey with value on the ring with a replica count of n.  Keys of strong
+// namespaces are rejected.
 func (cs *ChordStore) PutKey(n int, key, value []byte) ([]*VnodeData, error) {
-	vns, err := cs.ring.Lookup(n, key)
-	if err == nil {
-		out := make([]*VnodeData, len(vns))
-		for i, vn := range vns {
-			o := &VnodeData{Vnode: vn}
-			o.Err = cs.store.PutKey(vn, key, value)
-			out[i] = o
-		}
-		return out, nil
+	if err := cs.checkWeak(key); err != nil {
+		return nil, err
 	}
-	return nil, err
+	return cs.putKey(n, ConsistencyAll, key, value, 0)
+}
+
+func (cs *ChordStore) putKey(n int, c Consistency, key, value []byte, expiry int64) ([]*VnodeData, error) {
+	vns, err := cs.lookup(n, key)
+	if err != nil {
+		return nil, err
+	}
+
+	ws := cs.newWriteStamp()
+	res := make([]*VnodeData, len(vns))
+	done := fanOut(len(vns), c.Required(len(vns)), cs.timeout, func(ctx context.Context, i int) bool {
+		o := &VnodeData{Vnode: vns[i]}
+		o.Err = cs.store.withContext(ctx).PutKey(vns[i], key, value, expiry, ws)
+		res[i] = o
+		return o.Err == nil
+	})
+
+	return collectVnodeData(vns, res, done), nil
 }
 
-// UpdateKey with value on the ring with a replica count of n
+// UpdateKey with value on the ring with a replica count of n.  Keys of strong
+// namespaces are rejected.
 func (cs *ChordStore) UpdateKey(n int, key, value []byte) ([]*VnodeData, error) {
+	if err := cs.checkWeak(key); err != nil {
+		return nil, err
+	}
 	// Get current
-	rsp, err := cs.GetKey(n, key)
+	// Divergent copies are queued for repair.  With a conflict resolver the
+	// chosen value is updated and replicas not holding it are overwritten
+	// instead, so they are not queued as the repair would race the update.
+	rsp, err := cs.getKey(n, ConsistencyAll, key, cs.resolver == nil)
 	if err != nil {
 		return nil, err
 	}
-	// Check all copies for same hash to use as previousHash
+	// Check all copies for same hash to use as previousHash.
+	var resolved bool
 	hash := rsp[0].Hash()
 	for i := 1; i < len(rsp); i++ {
 		h := rsp[i].Hash()
-		if !bytes.Equal(hash[:], h[:]) {
-
-			log.Printf("TODO [consistency] Check: key=%s index=%d msg='inconsistent hash %x!=%x'", key, i, hash, h)
-			//cs.healQ <- HealRequest{Vnode: rsp[i].Vnode, Key: key}
+		if bytes.Equal(hash[:], h[:]) {
+			continue
+		}
+		if cs.resolver == nil {
 			return nil, fmt.Errorf("inconsistent hash %x!=%x", hash, h)
 		}
+		val, err := cs.resolveValue(key, rsp, 1)
+		if err != nil {
+			return nil, err
+		}
+		hash, resolved = valueHash(val), true
+		break
 	}
 
 	// Update each vnode from GetKey
-	out := make([]*VnodeData, len(rsp))
+	vns := make([]*chord.Vnode, len(rsp))
 	for i, r := range rsp {
-		o := &VnodeData{Vnode: r.Vnode}
-		o.Err = cs.store.UpdateKey(r.Vnode, hash[:], key, value)
-		out[i] = o
+		vns[i] = r.Vnode
 	}
-	return out, nil
+
+	ws := cs.newWriteStamp()
+	res := make([]*VnodeData, len(vns))
+	done := fanOut(len(vns), len(vns), cs.timeout, func(ctx context.Context, i int) bool {
+		o := &VnodeData{Vnode: vns[i]}
+		if resolved && !bytes.Equal(hash, rsp[i].Hash()) && (rsp[i].Err == nil || isNotFound(rsp[i].Err)) {
+			o.Err = cs.store.withContext(ctx).PutKey(vns[i], key, value, 0, ws)
+		} else {
+			o.Err = cs.store.withContext(ctx).UpdateKey(vns[i], hash[:], key, value, ws)
+		}
+		res[i] = o
+		return o.Err == nil
+	})
+
+	return collectVnodeData(vns, res, done), nil
 }
 
-// GetKey with n replicas
+// GetKey with n replicas.  Missing or divergent replicas are queued for repair.
 func (cs *ChordStore) GetKey(n int, key []byte) ([]*VnodeData, error) {
-	vns, err := cs.ring.Lookup(n, key)
-	if err == nil {
-		out := make([]*VnodeData, len(vns))
-		for i, vn := range vns {
-			o := &VnodeData{Vnode: vn}
-			if o.Data, o.Err = cs.store.GetKey(vn, key); o.Err != nil {
-				log.Printf("TODO [consistency] Check: key=%s index=%d msg='%s'", key, i, o.Err.Error())
-				// TODO:
-				//cs.healQ <- HealRequest{Vnode: vn, Key: key}
-			}
-			out[i] = o
+	return cs.getKey(n, ConsistencyAll, key, true)
+}
+
+// readRepair queues the key for healing if any replica is missing it or has a
+// divergent value.  Keys of strong namespaces are left to their consensus group.
+func (cs *ChordStore) readRepair(key []byte, vds []*VnodeData) {
+	if cs.strong(key) {
+		return
+	}
+	if vn := needsHealing(vds); vn != nil {
+		cs.enqueueHeal(HealRequest{Type: HealTypeKey, Vnode: vn, Key: key})
+	}
+}
+
+func (cs *ChordStore) enqueueHeal(hr HealRequest) {
+	if !cs.healer.Enqueue(hr) {
+		log.Printf("ERR Heal queue full: %s=%s vnode=%s", hr.Type, hr.Key, shortID(hr.Vnode))
+	}
+}
+
+// pruneRange removes keys in the range from the local vnode that it is no longer
+// a replica of.  Objects are not pruned.
+func (cs *ChordStore) pruneRange(vn *chord.Vnode, kr *KeyRange) {
+	st, ok := cs.store.local[vn.StringID()]
+	if !ok {
+		return
+	}
+	if kr.HashFunc == nil {
+		kr.HashFunc = cs.store.hashFunc
+	}
+
+	leaves := make([]int, merkleLeaves)
+	for i := range leaves {
+		leaves[i] = i
+	}
+	entries, err := st.MerkleTree().Entries(leaves)
+	if err != nil {
+		log.Printf("ERR [prune] vnode=%s %v", shortID(vn), err)
+		return
+	}
+
+	var pruned int
+	for _, e := range entries {
+		if !kr.Contains(e.Key) {
+			continue
 		}
-		return out, nil
+		vns, err := cs.lookup(cs.replicas, e.Key)
+		if err != nil {
+			log.Printf("ERR [prune] vnode=%s %v", shortID(vn), err)
+			return
+		}
+		if containsVnode(vns, vn) {
+			continue
+		}
+		if err = st.PruneKey(e.Key); err != nil {
+			log.Printf("ERR [prune] vnode=%s key=%s %v", shortID(vn), e.Key, err)
+			continue
+		}
+		pruned++
 	}
-	return nil, err
+
+	log.Printf("DBG [prune] vnode=%s range=%s pruned=%d", shortID(vn), kr, pruned)
 }
 
-// RemoveKey with n replicas.
+// HealStats returns the healing engine counters
+func (cs *ChordStore) HealStats() HealStats {
+	return cs.healer.Stats()
+}
+
+// getKey reads the key from n replicas returning once enough have it to satisfy
+// the consistency level.  With repair set replicas missing the key or holding a
+// divergent value are queued for healing.  Replicas that had not replied when
+// the read returned are waited on in the background up to the timeout, so the
+// repair sees their replies too.
+func (cs *ChordStore) getKey(n int, c Consistency, key []byte, repair bool) ([]*VnodeData, error) {
+	vns, err := cs.lookup(n, key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*VnodeData, len(vns))
+	done, late := fanOutUntil(len(vns), cs.timeout, func(ctx context.Context, i int) bool {
+		o := &VnodeData{Vnode: vns[i]}
+		o.Data, o.Err = cs.store.withContext(ctx).GetKey(vns[i], key)
+		res[i] = o
+		return o.Err == nil
+	}, readSettled(res, c.Required(len(vns)), cs.resolver != nil))
+
+	vds := collectVnodeData(vns, res, done)
+	if repair {
+		select {
+		case all := <-late:
+			cs.readRepair(key, collectVnodeData(vns, res, all))
+		default:
+			go func() {
+				cs.readRepair(key, collectVnodeData(vns, res, <-late))
+			}()
+		}
+	}
+	return vds, nil
+}
+
+// RemoveKey with n replicas.  Keys of strong namespaces are rejected.
 func (cs *ChordStore) RemoveKey(n int, key []byte) ([]*VnodeData, error) {
-	vns, err := cs.ring.Lookup(n, key)
-	if err == nil {
-		out := make([]*VnodeData, len(vns))
-		for i, vn := range vns {
-			o := &VnodeData{Vnode: vn}
-			o.Err = cs.store.RemoveKey(vn, key)
-			out[i] = o
+	if err := cs.checkWeak(key); err != nil {
+		return nil, err
+	}
+	return cs.removeKey(n, ConsistencyAll, key)
+}
+
+func (cs *ChordStore) removeKey(n int, c Consistency, key []byte) ([]*VnodeData, error) {
+	vns, err := cs.lookup(n, key)
+	if err != nil {
+		return nil, err
+	}
+
+	ws := cs.newWriteStamp()
+	res := make([]*VnodeData, len(vns))
+	done := fanOut(len(vns), c.Required(len(vns)), cs.timeout, func(ctx context.Context, i int) bool {
+		o := &VnodeData{Vnode: vns[i]}
+		o.Err = cs.store.withContext(ctx).RemoveKey(vns[i], key, ws)
+		res[i] = o
+		return o.Err == nil
+	})
+
+	return collectVnodeData(vns, res, done), nil
+}
+
+// KeyHistory returns the transaction log of the key from n replicas
+func (cs *ChordStore) KeyHistory(n int, key []byte) ([]*VnodeKeyHistory, error) {
+	vns, err := cs.lookup(n, key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*VnodeKeyHistory, len(vns))
+	done := fanOut(len(vns), len(vns), cs.timeout, func(ctx context.Context, i int) bool {
+		o := &VnodeKeyHistory{Vnode: vns[i]}
+		o.Txns, o.Err = cs.store.withContext(ctx).KeyHistory(vns[i], key)
+		res[i] = o
+		return o.Err == nil
+	})
+
+	out := make([]*VnodeKeyHistory, len(vns))
+	for i, vn := range vns {
+		if done[i] {
+			out[i] = res[i]
+		} else {
+			out[i] = &VnodeKeyHistory{Vnode: vn, Err: errReplicaPending}
 		}
-		return out, nil
 	}
-	return nil, err
+	return out, nil
+}
+
+// MerkleRPC server-side
+func (cs *ChordStore) MerkleRPC(ctx context.Context, req *DHTMerkleRequest) (*DHTMerkleResponse, error) {
+	var (
+		resp    = &DHTMerkleResponse{}
+		indexes = make([]int, len(req.Indexes))
+		err     error
+	)
+	for i, idx := range req.Indexes {
+		indexes[i] = int(idx)
+	}
+
+	if req.Entries {
+		resp.Entries, err = cs.store.MerkleEntries(req.Vn, indexes)
+	} else {
+		resp.Hashes, err = cs.store.MerkleLevel(req.Vn, int(req.Level), indexes)
+	}
+	if err != nil {
+		resp.Err = err.Error()
+	}
+	return resp, nil
 }
 
 // PutKeyRPC server-side
 func (cs *ChordStore) PutKeyRPC(ctx context.Context, dkv *DHTKeyValue) (*chord.ErrResponse, error) {
 	resp := &chord.ErrResponse{}
-	if err := cs.store.PutKey(dkv.Vn, dkv.Key, dkv.Value); err != nil {
+	if err := ctx.Err(); err != nil {
+		// The writer has given up on the write and may be undoing it
+		resp.Err = err.Error()
+		return resp, nil
+	}
+	if err := cs.checkLayout(dkv.Key, dkv.Stamp); err != nil {
+		resp.Err = err.Error()
+	} else if err = cs.store.PutKey(dkv.Vn, dkv.Key, dkv.Value, dkv.Expiry, dkv.Stamp); err != nil {
 		resp.Err = err.Error()
 	}
 	return resp, nil
@@ -228,7 +965,9 @@ func (cs *ChordStore) PutKeyRPC(ctx context.Context, dkv *DHTKeyValue) (*chord.E
 // UpdateKeyRPC server-side
 func (cs *ChordStore) UpdateKeyRPC(ctx context.Context, dkv *DHTHashKeyValue) (*chord.ErrResponse, error) {
 	resp := &chord.ErrResponse{}
-	if err := cs.store.UpdateKey(dkv.Vn, dkv.PrevHash, dkv.Key, dkv.Value); err != nil {
+	if err := cs.checkLayout(dkv.Key, dkv.Stamp); err != nil {
+		resp.Err = err.Error()
+	} else if err = cs.store.UpdateKey(dkv.Vn, dkv.PrevHash, dkv.Key, dkv.Value, dkv.Stamp); err != nil {
 		resp.Err = err.Error()
 	}
 	return resp, nil
@@ -246,21 +985,238 @@ func (cs *ChordStore) GetKeyRPC(ctx context.Context, key *DHTBytes) (*DHTBytesEr
 	return resp, nil
 }
 
+// KeyExpiryRPC server-side
+func (cs *ChordStore) KeyExpiryRPC(ctx context.Context, key *DHTBytes) (*DHTExpiry, error) {
+	resp := &DHTExpiry{}
+	e, err := cs.store.KeyExpiry(key.Vn, key.B)
+	if err == nil {
+		resp.Expiry = e
+	} else {
+		resp.Err = err.Error()
+	}
+	return resp, nil
+}
+
+// KeyTombstoneRPC server-side
+func (cs *ChordStore) KeyTombstoneRPC(ctx context.Context, key *DHTBytes) (*DHTTombstone, error) {
+	resp := &DHTTombstone{}
+	t, err := cs.store.KeyTombstone(key.Vn, key.B)
+	if err == nil {
+		resp.Timestamp = t
+	} else {
+		resp.Err = err.Error()
+	}
+	return resp, nil
+}
+
 // RemoveKeyRPC server-side
 func (cs *ChordStore) RemoveKeyRPC(ctx context.Context, key *DHTBytes) (*chord.ErrResponse, error) {
 	resp := &chord.ErrResponse{}
-	if err := cs.store.RemoveKey(key.Vn, key.B); err != nil {
+	if err := cs.checkLayout(key.B, key.Stamp); err != nil {
+		resp.Err = err.Error()
+	} else if err = cs.store.RemoveKey(key.Vn, key.B, key.Stamp); err != nil {
+		resp.Err = err.Error()
+	}
+	return resp, nil
+}
+
+// KeyHistoryRPC server-side
+func (cs *ChordStore) KeyHistoryRPC(ctx context.Context, key *DHTBytes) (*DHTKeyTxns, error) {
+	resp := &DHTKeyTxns{}
+	txns, err := cs.store.KeyHistory(key.Vn, key.B)
+	if err == nil {
+		resp.Txns = txns
+	} else {
+		resp.Err = err.Error()
+	}
+	return resp, nil
+}
+
+// GetVersionsRPC server-side
+func (cs *ChordStore) GetVersionsRPC(ctx context.Context, key *DHTBytes) (*DHTSiblings, error) {
+	resp := &DHTSiblings{}
+	sibs, err := cs.store.GetVersions(key.Vn, key.B)
+	if err == nil {
+		resp.Siblings = sibs
+	} else {
+		resp.Err = err.Error()
+	}
+	return resp, nil
+}
+
+// PutVersionRPC server-side.  The new version is returned as the only sibling.
+func (cs *ChordStore) PutVersionRPC(ctx context.Context, dkv *DHTVersionedKeyValue) (*DHTSiblings, error) {
+	resp := &DHTSiblings{}
+	if err := cs.checkLayout(dkv.Key, dkv.Stamp); err != nil {
+		resp.Err = err.Error()
+		return resp, nil
+	}
+	sib, err := cs.store.PutVersion(dkv.Vn, dkv.Key, dkv.Value, dkv.Context, dkv.Stamp)
+	if err == nil {
+		resp.Siblings = []*Sibling{sib}
+	} else {
+		resp.Err = err.Error()
+	}
+	return resp, nil
+}
+
+// AddSiblingRPC server-side
+func (cs *ChordStore) AddSiblingRPC(ctx context.Context, sk *DHTSiblingKey) (*chord.ErrResponse, error) {
+	resp := &chord.ErrResponse{}
+	if sk.Sibling == nil {
+		resp.Err = "sibling required"
+	} else if err := cs.store.AddSibling(sk.Vn, sk.Key, sk.Sibling); err != nil {
+		resp.Err = err.Error()
+	}
+	return resp, nil
+}
+
+// CompareAndSwapRPC server-side
+func (cs *ChordStore) CompareAndSwapRPC(ctx context.Context, req *DHTSwapRequest) (*DHTSwapResponse, error) {
+	old := req.Old
+	if req.Create {
+		old = nil
+	} else if old == nil {
+		old = []byte{}
+	}
+
+	value := req.Value
+	if req.Remove {
+		value = nil
+	} else if value == nil {
+		value = []byte{}
+	}
+
+	resp := &DHTSwapResponse{}
+	if err := cs.checkLayout(req.Key, req.Stamp); err != nil {
+		resp.Err = err.Error()
+		return resp, nil
+	}
+
+	var (
+		val []byte
+		ok  bool
+		err error
+	)
+	if req.Replica {
+		val, ok, err = cs.store.SwapKey(req.Vn, req.Key, old, value, req.Stamp)
+	} else {
+		val, ok, err = cs.store.CompareAndSwap(req.Vn, req.Key, old, value, Consistency(req.Consistency), req.Stamp)
+	}
+	if err == nil {
+		resp.Value, resp.Swapped, resp.Exists = val, ok, val != nil
+	} else {
+		resp.Err = err.Error()
+	}
+	return resp, nil
+}
+
+// RaftVoteRPC server-side
+func (cs *ChordStore) RaftVoteRPC(ctx context.Context, req *RaftVoteRequest) (*RaftVoteResponse, error) {
+	if cs.raft == nil {
+		return &RaftVoteResponse{Err: errNoStrongNamespaces.Error()}, nil
+	}
+	resp, err := cs.raft.handleVote(req)
+	if err != nil {
+		resp = &RaftVoteResponse{Err: err.Error()}
+	}
+	return resp, nil
+}
+
+// RaftAppendRPC server-side
+func (cs *ChordStore) RaftAppendRPC(ctx context.Context, req *RaftAppendRequest) (*RaftAppendResponse, error) {
+	if cs.raft == nil {
+		return &RaftAppendResponse{Err: errNoStrongNamespaces.Error()}, nil
+	}
+	resp, err := cs.raft.handleAppend(req)
+	if err != nil {
+		resp = &RaftAppendResponse{Err: err.Error()}
+	}
+	return resp, nil
+}
+
+// RaftProposeRPC server-side
+func (cs *ChordStore) RaftProposeRPC(ctx context.Context, req *RaftProposal) (*RaftProposeResponse, error) {
+	if cs.raft == nil {
+		return &RaftProposeResponse{Err: errNoStrongNamespaces.Error()}, nil
+	}
+	return cs.raft.handlePropose(req), nil
+}
+
+// TxnPrepareRPC server-side
+func (cs *ChordStore) TxnPrepareRPC(ctx context.Context, req *DHTTxn) (*chord.ErrResponse, error) {
+	resp := &chord.ErrResponse{}
+	for _, op := range req.Ops {
+		if err := cs.checkLayout(op.Key, req.Stamp); err != nil {
+			resp.Err = err.Error()
+			return resp, nil
+		}
+	}
+	if err := cs.txns.prepare(req); err != nil {
+		resp.Err = err.Error()
+	}
+	return resp, nil
+}
+
+// TxnCommitRPC server-side
+func (cs *ChordStore) TxnCommitRPC(ctx context.Context, req *DHTTxn) (*chord.ErrResponse, error) {
+	resp := &chord.ErrResponse{}
+	if err := cs.txns.commit(req); err != nil {
+		resp.Err = err.Error()
+	}
+	return resp, nil
+}
+
+// TxnAbortRPC server-side
+func (cs *ChordStore) TxnAbortRPC(ctx context.Context, req *DHTTxn) (*chord.ErrResponse, error) {
+	resp := &chord.ErrResponse{}
+	if err := cs.txns.abort(req); err != nil {
+		resp.Err = err.Error()
+	}
+	return resp, nil
+}
+
+// BatchRPC server-side
+func (cs *ChordStore) BatchRPC(ctx context.Context, req *DHTBatch) (*DHTBatchResponse, error) {
+	resp := &DHTBatchResponse{}
+	for _, op := range req.Ops {
+		if err := cs.checkLayout(op.Key, op.Stamp); err != nil {
+			resp.Err = err.Error()
+			return resp, nil
+		}
+	}
+	results, err := cs.store.Batch(req.Ops)
+	if err == nil {
+		resp.Results = results
+	} else {
+		resp.Err = err.Error()
+	}
+	return resp, nil
+}
+
+// StatObjectRPC server-side
+func (cs *ChordStore) StatObjectRPC(ctx context.Context, key *DHTBytes) (*DHTObjectMeta, error) {
+	resp := &DHTObjectMeta{}
+	meta, err := cs.store.StatObject(key.Vn, key.B)
+	if err == nil {
+		resp.Meta = meta
+	} else {
 		resp.Err = err.Error()
 	}
 	return resp, nil
 }
 
 // SnapshotRPC server-side
-func (cs *ChordStore) SnapshotRPC(vn *chord.Vnode, stream DHT_SnapshotRPCServer) error {
+func (cs *ChordStore) SnapshotRPC(opts *SnapshotOptions, stream DHT_SnapshotRPCServer) error {
 	//log.Println("SERVER SIDE SNAPSHOT", shortID(vn))
 	buf := new(bytes.Buffer)
 
-	err := cs.store.Snapshot(vn, buf)
+	var kr *KeyRange
+	if opts.Start != nil || opts.End != nil {
+		kr = &KeyRange{Start: opts.Start, End: opts.End}
+	}
+
+	err := cs.store.Snapshot(opts.Vn, buf, kr)
 	if err != nil {
 		//log.Println("ERR", err, shortID(vn))
 		if err == io.EOF {
@@ -326,58 +1282,80 @@ func (cs *ChordStore) PutObjectRPC(stream DHT_PutObjectRPCServer) error {
 		return stream.SendAndClose(&chord.ErrResponse{Err: err.Error()})
 	}
 
-	buf := new(bytes.Buffer)
+	// Stream chunks to the store as they arrive
+	pr, pw := io.Pipe()
+	errCh := make(chan error, 1)
+	go func() {
+		var err error
+		if args.Verify {
+			err = cs.store.PutContent(args.Vn, args.B, pr, args.Meta)
+		} else {
+			err = cs.store.PutObject(args.Vn, args.B, pr, args.Meta)
+		}
+		// Unblock the writer if the store stopped reading early
+		pr.Close()
+		errCh <- err
+	}()
 
 	for {
 		dc, err := stream.Recv()
 		if err != nil {
 			if err == io.EOF {
-				break
+				pw.Close()
+			} else {
+				pw.CloseWithError(err)
 			}
-			return stream.SendAndClose(&chord.ErrResponse{Err: err.Error()})
+			break
 		}
 
-		buf.Write(dc.Data)
+		if _, err = pw.Write(dc.Data); err != nil {
+			break
+		}
 	}
 
 	rsp := &chord.ErrResponse{}
-	if err := cs.store.PutObject(args.Vn, args.B, buf); err != nil {
+	if err := <-errCh; err != nil {
 		rsp.Err = err.Error()
 	}
 
 	return stream.SendAndClose(rsp)
 }
 
-// GetObjectRPC is the server side call
+// GetObjectRPC is the server side call.  The first message is always sent and
+// carries the object size.
 func (cs *ChordStore) GetObjectRPC(key *DHTBytes, stream DHT_GetObjectRPCServer) error {
 	var (
 		vn     = key.Vn
 		objkey = key.B
 	)
 
-	rd, err := cs.store.GetObject(vn, objkey)
+	rd, size, err := cs.store.GetObjectRange(vn, objkey, key.Offset, key.Length)
 	if err != nil {
 		return err
 	}
+	defer closeReader(rd)
 
 	out := make([]byte, 65519)
-	for {
+	for first := true; ; first = false {
 		n, err := rd.Read(out)
-		if err != nil {
-			if err != io.EOF {
-				//log.Println("ERR", err, shortID(vn))
-				return err
-			}
-			break
+		if err != nil && err != io.EOF {
+			//log.Println("ERR", err, shortID(vn))
+			return err
 		}
 
-		ds := &DataStream{Data: out[:n]}
-		if err = stream.Send(ds); err != nil {
-			return err
+		if n > 0 || first {
+			ds := &DataStream{Data: out[:n]}
+			if first {
+				ds.Size = size
+			}
+			if e := stream.Send(ds); e != nil {
+				return e
+			}
+		}
+		if err == io.EOF {
+			return nil
 		}
 	}
-
-	return nil
 }
 
 func (cs *ChordStore) RemoveObjectRPC(ctx context.Context, key *DHTBytes) (*chord.ErrResponse, error) {
@@ -394,8 +1372,29 @@ func (cs *ChordStore) RemoveObjectRPC(ctx context.Context, key *DHTBytes) (*chor
 	return rsp, nil
 }
 
-// Shutdown underlying stores.  This does not shutdown any underlying services.
+// Shutdown background replica maintenance and underlying stores.  This does not
+// shutdown any underlying services.
 func (cs *ChordStore) Shutdown() error {
+	if cs.ae != nil {
+		cs.ae.shutdown()
+	}
+	if cs.fd != nil {
+		cs.fd.shutdown()
+	}
+	if cs.reaper != nil {
+		cs.reaper.shutdown()
+	}
+	if cs.gc != nil {
+		cs.gc.shutdown()
+	}
+	if cs.balancer != nil {
+		cs.balancer.shutdown()
+	}
+	if cs.raft != nil {
+		cs.raft.shutdown()
+	}
+	cs.txns.shutdown()
+	cs.healer.Stop()
 	return cs.store.Shutdown()
 }
 
@@ -429,12 +1428,67 @@ func (vd *VnodeData) MarshalJSON() ([]byte, error) {
 	return json.Marshal(m)
 }
 
+// collectVnodeData returns the results of a fan out in lookup order.  Replicas
+// that had not responded are marked as pending.
+func collectVnodeData(vns []*chord.Vnode, res []*VnodeData, done []bool) []*VnodeData {
+	out := make([]*VnodeData, len(vns))
+	for i, vn := range vns {
+		if done[i] {
+			out[i] = res[i]
+		} else {
+			out[i] = &VnodeData{Vnode: vn, Err: errReplicaPending}
+		}
+	}
+	return out
+}
+
+// VnodeDataIO is an object reader from a vnode on the ring
 type VnodeDataIO struct {
 	Vnode *chord.Vnode
 	Err   error
-	r     io.Reader
+	// Size of the whole object for reads.  -1 if unknown.
+	Size int64
+	r    io.Reader
 }
 
+// Reader to the object data
 func (vd *VnodeDataIO) Reader() io.Reader {
 	return vd.r
 }
+
+// Close releases the underlying stream.  Readers that are not read to the end
+// must be closed.
+func (vd *VnodeDataIO) Close() error {
+	if c, ok := vd.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// MarshalJSON custom
+func (vd *VnodeDataIO) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{
+		"vnode": map[string]string{
+			"id":   vd.Vnode.StringID(),
+			"host": vd.Vnode.Host,
+		},
+	}
+	if vd.Err != nil {
+		m["error"] = vd.Err.Error()
+	}
+	return json.Marshal(m)
+}
+
+// collectVnodeDataIO returns the results of a fan out in lookup order.  Replicas
+// that had not responded are marked as pending.
+func collectVnodeDataIO(vns []*chord.Vnode, res []*VnodeDataIO, done []bool) []*VnodeDataIO {
+	out := make([]*VnodeDataIO, len(vns))
+	for i, vn := range vns {
+		if done[i] {
+			out[i] = res[i]
+		} else {
+			out[i] = &VnodeDataIO{Vnode: vn, Err: errReplicaPending}
+		}
+	}
+	return out
+}
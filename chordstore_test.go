@@ -2,9 +2,12 @@ package chordstore
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math/rand"
 	"net"
 	"testing"
 	"time"
@@ -41,6 +44,55 @@ func initConfig(p int, j ...string) (*Config, error) {
 	return c1, err
 }
 
+// newTestRing starts a ring of two ChordStores listening on port and port+1
+// returning them along with a function shutting both down.  setup, if not nil,
+// is applied to the config of each node.  The vnode stores of the nodes are
+// MemKeyValueStores unless given.
+func newTestRing(t *testing.T, port int, setup func(*Config), stores ...VnodeStore) (*ChordStore, *ChordStore, func()) {
+	var (
+		cfgs [2]*Config
+		css  [2]*ChordStore
+	)
+	stop := func() {
+		for i := len(css) - 1; i >= 0; i-- {
+			if css[i] != nil {
+				cfgs[i].Ring.Shutdown()
+				css[i].Shutdown()
+			}
+		}
+	}
+
+	for i := range css {
+		var joins []string
+		if i > 0 {
+			joins = []string{fmt.Sprintf("127.0.0.1:%d", port)}
+		}
+		cfg, err := initConfig(port+i, joins...)
+		if err != nil {
+			stop()
+			t.Fatal(err)
+		}
+		if setup != nil {
+			setup(cfg)
+		}
+		var st VnodeStore = &MemKeyValueStore{}
+		if i < len(stores) {
+			st = stores[i]
+		}
+		cs, err := NewChordStore(cfg, st)
+		if err != nil {
+			cfg.Listener.Close()
+			stop()
+			t.Fatal(err)
+		}
+		cfgs[i], css[i] = cfg, cs
+
+		// Let the ring stabilize
+		<-time.After(time.Duration(200+100*i) * time.Millisecond)
+	}
+	return css[0], css[1], stop
+}
+
 func Test_ChordDelegate(t *testing.T) {
 	c1, _ := initConfig(0)
 	defer c1.Listener.Close()
@@ -122,6 +174,32 @@ func Test_ChordStore(t *testing.T) {
 		t.Fatal("update value mismatch")
 	}
 
+	hr, err := cs2.KeyHistory(testReplicas, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range hr {
+		if h.Err != nil {
+			t.Fatal(h.Err)
+		}
+		if len(h.Txns) != 2 {
+			t.Fatal("txn count mismatch", len(h.Txns))
+		}
+		if err = h.Verify(); err != nil {
+			t.Fatal(err)
+		}
+		// Every replica records the coordinator as the writer and the time it
+		// assigned
+		for i, txn := range h.Txns {
+			if txn.Vnode != cs2.vnodes[0].StringID() {
+				t.Fatal("origin mismatch", shortID(h.Vnode), txn.Vnode)
+			}
+			if txn.Timestamp != hr[0].Txns[i].Timestamp {
+				t.Fatal("timestamp mismatch", shortID(h.Vnode), txn.Timestamp, hr[0].Txns[i].Timestamp)
+			}
+		}
+	}
+
 	rf, e := cs1.UpdateKey(testReplicas, testKey, []byte("newValue2"))
 	if e != nil {
 		t.Log("request failed")
@@ -164,6 +242,24 @@ func Test_ChordStore(t *testing.T) {
 		t.Fatal("mismatch on remove")
 	}
 
+	// Consistency levels
+	if err = cs1.Put(testKey, []byte("cvalue"), ConsistencyAll); err != nil {
+		t.Fatal(err)
+	}
+	cv, err := cs2.Get(testKey, ConsistencyQuorum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(cv) != "cvalue" {
+		t.Fatal("value mismatch", string(cv))
+	}
+	if err = cs2.Remove(testKey, ConsistencyAll); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cs1.Get(testKey, ConsistencyQuorum); !isNotFound(err) {
+		t.Fatal("should not be found", err)
+	}
+
 	c1.Ring.Shutdown()
 	c2.Ring.Shutdown()
 
@@ -334,3 +430,200 @@ func Test_ChordStore_Object(t *testing.T) {
 	}
 
 }
+
+func Test_ChordStore_Object_Stream(t *testing.T) {
+	c1, err := initConfig(36017)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs1, err := NewChordStore(c1, &MemKeyValueStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs1.Shutdown()
+	defer c1.Ring.Shutdown()
+
+	<-time.After(200 * time.Millisecond)
+	c2, err := initConfig(36018, "127.0.0.1:36017")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs2, err := NewChordStore(c2, &MemKeyValueStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs2.Shutdown()
+	defer c2.Ring.Shutdown()
+
+	<-time.After(300 * time.Millisecond)
+
+	// Larger than a single stream chunk
+	size := int64(3*65519 + 17)
+	src := io.LimitReader(rand.New(rand.NewSource(1)), size)
+	h := sha256.New()
+	key := []byte("stream")
+
+	cds, err := cs1.PutObject(4, key, io.TeeReader(src, h))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var remote bool
+	for _, v := range cds {
+		if v.Err != nil {
+			t.Fatal(v.Err)
+		}
+		remote = remote || v.Vnode.Host != c1.Chord.Hostname
+	}
+	if !remote {
+		t.Fatal("no remote replica")
+	}
+	want := h.Sum(nil)
+
+	cds, err = cs1.GetObject(4, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range cds {
+		if v.Err != nil {
+			t.Fatal(v.Err)
+		}
+		h.Reset()
+		n, err := io.Copy(h, v.Reader())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != size || !bytes.Equal(h.Sum(nil), want) {
+			t.Fatal("object mismatch", shortID(v.Vnode), n)
+		}
+		v.Close()
+	}
+}
+
+func Test_teeObject(t *testing.T) {
+	pr1, pw1 := io.Pipe()
+	pr2, pw2 := io.Pipe()
+	// Replica failing before reading
+	pr2.Close()
+
+	go teeObject(bytes.NewBufferString("payload"), []*io.PipeWriter{pw1, pw2})
+	b, err := ioutil.ReadAll(pr1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "payload" {
+		t.Fatal("payload mismatch", string(b))
+	}
+}
+
+func Test_idleReader(t *testing.T) {
+	// Waiting on the source does not count as idle
+	pr, pw := io.Pipe()
+	idle := make(chan bool, 1)
+	ir := newIdleReader(pr, 50*time.Millisecond, func() { idle <- true })
+	go func() {
+		<-time.After(100 * time.Millisecond)
+		pw.Write([]byte("data"))
+		pw.Close()
+	}()
+	if b, err := ioutil.ReadAll(ir); err != nil || string(b) != "data" {
+		t.Fatal(string(b), err)
+	}
+	if ir.stop() {
+		t.Fatal("should not be idle")
+	}
+
+	// A reader not asking for more data is
+	ir = newIdleReader(bytes.NewBufferString("data"), 50*time.Millisecond, func() { idle <- true })
+	select {
+	case <-idle:
+	case <-time.After(time.Second):
+		t.Fatal("idle not detected")
+	}
+	if !ir.stop() {
+		t.Fatal("should be idle")
+	}
+}
+
+func Test_ChordStore_ObjectRange(t *testing.T) {
+	c1, err := initConfig(36025)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1.ChunkSize = 1000
+	cs1, err := NewChordStore(c1, &MemKeyValueStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs1.Shutdown()
+	defer c1.Ring.Shutdown()
+
+	<-time.After(200 * time.Millisecond)
+	c2, err := initConfig(36026, "127.0.0.1:36025")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2.ChunkSize = 1000
+	cs2, err := NewChordStore(c2, &MemKeyValueStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs2.Shutdown()
+	defer c2.Ring.Shutdown()
+
+	<-time.After(300 * time.Millisecond)
+
+	data := make([]byte, 4*erasurePieceSize+1234)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	if _, err = cs1.PutObject(0, []byte("plain"), bytes.NewReader(data[:900])); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cs1.PutObject(0, []byte("chunked"), bytes.NewReader(data[:10500])); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cs1.PutErasure([]byte("erasure"), bytes.NewReader(data), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key            string
+		offset, length int64
+		size, expected int64
+	}{
+		{"plain", 5, 10, 900, 10},
+		{"plain", 850, 0, 900, 50},
+		{"plain", 850, 100, 900, 50},
+		{"plain", 1000, 10, 900, 0},
+		{"chunked", 2500, 3000, 10500, 3000},
+		{"chunked", 9999, 0, 10500, 501},
+		{"erasure", 100, 10, -1, 10},
+		{"erasure", 4*erasurePieceSize + 100, 1000, -1, 1000},
+		{"erasure", 1000, 4 * erasurePieceSize, -1, 4 * erasurePieceSize},
+		{"erasure", 4*erasurePieceSize + 1000, 0, -1, 234},
+	}
+
+	for _, tt := range tests {
+		vds, err := cs2.GetObjectRange(0, []byte(tt.key), tt.offset, tt.length)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, vd := range vds {
+			if vd.Err != nil {
+				t.Fatal(tt.key, vd.Err)
+			}
+			if vd.Size != tt.size {
+				t.Fatal(tt.key, "size mismatch", vd.Size)
+			}
+			b, err := ioutil.ReadAll(vd.Reader())
+			if err != nil {
+				t.Fatal(tt.key, err)
+			}
+			if int64(len(b)) != tt.expected {
+				t.Fatal(tt.key, tt.offset, "length mismatch", len(b))
+			}
+			if len(b) > 0 && !bytes.Equal(b, data[tt.offset:tt.offset+tt.expected]) {
+				t.Fatal(tt.key, tt.offset, "data mismatch")
+			}
+		}
+	}
+}
@@ -0,0 +1,463 @@
+package chordstore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"sync"
+	"time"
+
+	"gopkg.in/vmihailenco/msgpack.v2"
+)
+
+// Number of chunks uploaded or fetched concurrently for a single object
+const chunkParallelism = 4
+
+// ObjectManifest lists the chunks of an object stored in chunks.  Each chunk is
+// a content addressed object placed on the ring by its hash.
+type ObjectManifest struct {
+	Size      int64
+	ChunkSize int
+	Chunks    [][]byte
+}
+
+func decodeManifest(rd io.Reader) (*ObjectManifest, error) {
+	var m ObjectManifest
+	err := msgpack.NewDecoder(rd).Decode(&m)
+	return &m, err
+}
+
+// putChunked splits the reader into chunks storing each on n replicas followed by
+// the manifest under the key.  Chunks already stored on all replicas are skipped
+// so an interrupted upload can be resumed by putting the same data again.  It
+// returns the results of writing the manifest.  The manifest is stored with the
+// metadata describing the whole object.
+func (cs *ChordStore) putChunked(n int, key []byte, rd io.Reader, meta *ObjectMeta) ([]*VnodeDataIO, error) {
+	m := &ObjectManifest{ChunkSize: cs.chunkSize}
+	mr := newMetaReader(rd)
+
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, chunkParallelism)
+		errs = make(chan error, 1)
+		err  error
+	)
+
+	for err == nil {
+		buf := make([]byte, cs.chunkSize)
+		var nr int
+		nr, err = io.ReadFull(mr, buf)
+		if nr > 0 {
+			h := sha256.Sum256(buf[:nr])
+			m.Chunks = append(m.Chunks, h[:])
+			m.Size += int64(nr)
+
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(hash, data []byte) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				if e := cs.putChunk(n, hash, data); e != nil {
+					select {
+					case errs <- e:
+					default:
+					}
+				}
+			}(h[:], buf[:nr])
+		}
+
+		select {
+		case e := <-errs:
+			err = e
+		default:
+		}
+	}
+	wg.Wait()
+
+	if err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	select {
+	case err = <-errs:
+		return nil, err
+	default:
+	}
+
+	b, err := msgpack.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	meta.Size, meta.Hash, meta.Encoding = mr.n, mr.h.Sum(nil), ObjectEncodingChunked
+	return cs.putObject(n, key, bytes.NewReader(b), putMeta(meta))
+}
+
+// putChunk stores the chunk on n replicas unless they all already have it.  It
+// fails unless a quorum of replicas store the chunk.
+func (cs *ChordStore) putChunk(n int, hash, data []byte) error {
+	if cs.hasChunk(n, hash) {
+		return nil
+	}
+
+	meta := &ObjectMeta{Encoding: ObjectEncodingChunk}
+	vds, err := cs.putObject(n, hash, bytes.NewReader(data), putContent(meta))
+	if err != nil {
+		return err
+	}
+
+	var ok int
+	for _, vd := range vds {
+		if vd.Err == nil {
+			ok++
+		}
+	}
+	if ok < ConsistencyQuorum.Required(len(vds)) {
+		return fmt.Errorf("chunk %x stored on %d/%d replicas", hash, ok, len(vds))
+	}
+	return nil
+}
+
+// hasChunk returns true if all n replicas have the chunk.  With chunk collection
+// enabled chunks written more than half the grace period ago are written again
+// so they are not collected before the manifest referencing them is stored.
+func (cs *ChordStore) hasChunk(n int, hash []byte) bool {
+	vms, err := cs.StatObject(n, hash)
+	if err != nil {
+		return false
+	}
+
+	fresh := time.Now().Add(-cs.chunkGrace / 2).UnixNano()
+	for _, vm := range vms {
+		if vm.Err != nil {
+			return false
+		}
+		if cs.gc != nil && vm.Meta.Encoding == ObjectEncodingChunk && vm.Meta.Mtime < fresh {
+			return false
+		}
+	}
+	return true
+}
+
+// chunkCollector periodically removes the chunks of the local vnodes no longer
+// referenced by the manifest of any object.  Chunks are shared by objects holding
+// the same data so they are not removed along with an object.
+type chunkCollector struct {
+	cs       *ChordStore
+	interval time.Duration
+	stop     chan bool
+}
+
+func newChunkCollector(cs *ChordStore, interval time.Duration) *chunkCollector {
+	return &chunkCollector{cs: cs, interval: interval, stop: make(chan bool, 1)}
+}
+
+// start collecting on every interval.  This blocks until stop is called.
+func (cc *chunkCollector) start() {
+	tick := time.NewTicker(cc.interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-tick.C:
+			n, err := cc.cs.collectChunks()
+			if err != nil {
+				log.Printf("ERR [chunks] %v", err)
+			} else if n > 0 {
+				log.Printf("DBG [chunks] removed=%d", n)
+			}
+		case <-cc.stop:
+			return
+		}
+	}
+}
+
+func (cc *chunkCollector) shutdown() {
+	cc.stop <- true
+}
+
+// collectChunks removes the chunks of the local vnodes written before the grace
+// period that no manifest on the ring references.  Chunks written within it are
+// kept as the manifest of an upload in progress may not be stored yet.  Nothing
+// is removed if any object could not be checked.  It returns the number of chunks
+// removed.
+func (cs *ChordStore) collectChunks() (int, error) {
+	before := time.Now().Add(-cs.chunkGrace).UnixNano()
+
+	// Candidate chunks by hash along with the local vnodes holding them
+	cands := map[string][]VnodeStore{}
+	for _, st := range cs.store.local {
+		var cursor []byte
+		for {
+			keys, err := st.ListObjects(nil, cursor, listBatchSize)
+			if err != nil {
+				return 0, err
+			}
+			for _, key := range keys {
+				if meta, err := st.StatObject(key); err == nil && meta.Encoding == ObjectEncodingChunk && meta.Mtime < before {
+					cands[string(key)] = append(cands[string(key)], st)
+				}
+			}
+			if len(keys) < listBatchSize {
+				break
+			}
+			cursor = keys[len(keys)-1]
+		}
+	}
+	if len(cands) == 0 {
+		return 0, nil
+	}
+
+	// Drop the chunks referenced by any manifest
+	var cursor []byte
+	for len(cands) > 0 {
+		keys, next, err := cs.ListObjects(nil, cursor, listBatchSize)
+		if err != nil {
+			return 0, err
+		}
+		for _, key := range keys {
+			if _, ok := cands[string(key)]; ok {
+				continue
+			}
+			m, err := cs.objectManifest(key)
+			if err != nil {
+				return 0, err
+			}
+			if m == nil {
+				continue
+			}
+			for _, h := range m.Chunks {
+				delete(cands, string(h))
+			}
+		}
+		if next == nil {
+			break
+		}
+		cursor = next
+	}
+
+	var removed int
+	for k, sts := range cands {
+		for _, st := range sts {
+			// Skip chunks written again by an upload since
+			if meta, err := st.StatObject([]byte(k)); err != nil || meta.Mtime >= before {
+				continue
+			}
+			if err := st.RemoveObject([]byte(k)); err == nil {
+				removed++
+			}
+		}
+	}
+	return removed, nil
+}
+
+// objectManifest returns the manifest of the object or nil if it is not chunked.
+// The first replica holding the object is used.
+func (cs *ChordStore) objectManifest(key []byte) (*ObjectManifest, error) {
+	vms, err := cs.StatObject(0, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs error
+	for _, vm := range vms {
+		if vm.Err != nil {
+			if !isNotFound(vm.Err) {
+				errs = mergeErrors(errs, vm.Err)
+			}
+			continue
+		}
+		if vm.Meta.Encoding != ObjectEncodingChunked {
+			return nil, nil
+		}
+		rd, err := cs.store.GetObject(vm.Vnode, key)
+		if err != nil {
+			errs = mergeErrors(errs, err)
+			continue
+		}
+		m, err := decodeManifest(rd)
+		closeReader(rd)
+		if err == nil {
+			return m, nil
+		}
+		errs = mergeErrors(errs, err)
+	}
+	if errs == nil {
+		// Removed since it was listed
+		return nil, nil
+	}
+	return nil, fmt.Errorf("object %x unavailable: %v", key, errs)
+}
+
+// fetchChunk returns the data of a chunk from the first replica whose data
+// hashes to the chunk hash
+func (cs *ChordStore) fetchChunk(hash []byte) ([]byte, error) {
+	vds, err := cs.GetContent(0, hash)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, vd := range vds {
+			vd.Close()
+		}
+	}()
+
+	var errs error
+	for _, vd := range vds {
+		if vd.Err != nil {
+			errs = mergeErrors(errs, vd.Err)
+			continue
+		}
+		b, err := ioutil.ReadAll(vd.Reader())
+		if err == nil {
+			return b, nil
+		}
+		errs = mergeErrors(errs, err)
+	}
+	return nil, fmt.Errorf("chunk %x unavailable: %v", hash, errs)
+}
+
+// resolveObject returns a reader reassembling the object if the replica reader
+// holds a manifest or the shard stream if it holds an erasure coded shard, as told
+// by the encoding in the metadata of the object.  Other readers are returned as
+// is.
+func (cs *ChordStore) resolveObject(rd io.Reader, meta *ObjectMeta) (io.Reader, error) {
+	switch meta.Encoding {
+	case ObjectEncodingChunked:
+		m, err := decodeManifest(rd)
+		closeReader(rd)
+		if err != nil {
+			return nil, err
+		}
+		return newChunkReader(cs, m), nil
+
+	case ObjectEncodingErasure:
+		hdr, err := readShard(rd)
+		if err != nil {
+			closeReader(rd)
+			return nil, err
+		}
+		return &shardStream{hdr: hdr, rd: rd}, nil
+	}
+	return rd, nil
+}
+
+// closingReader reads through a reader wrapping rd such as one prefixed with data
+// peeked from rd.  Closing it closes rd.
+type closingReader struct {
+	io.Reader
+	rd io.Reader
+}
+
+// Close the underlying reader
+func (cr *closingReader) Close() error {
+	return closeReader(cr.rd)
+}
+
+// limitReader limits the reader to length bytes.  Readers are returned as is if
+// length < 1.
+func limitReader(rd io.Reader, length int64) io.Reader {
+	if length < 1 {
+		return rd
+	}
+	return &closingReader{Reader: io.LimitReader(rd, length), rd: rd}
+}
+
+func closeReader(rd io.Reader) error {
+	if c, ok := rd.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+type chunkResult struct {
+	data []byte
+	err  error
+}
+
+// chunkReader reassembles an object from its manifest fetching up to
+// chunkParallelism chunks ahead of the reader.
+type chunkReader struct {
+	cs *ChordStore
+	m  *ObjectManifest
+
+	// index of the next chunk to fetch
+	next    int
+	pending []chan chunkResult
+	// bytes to skip from the first chunk read
+	skip int
+
+	cur []byte
+	err error
+}
+
+func newChunkReader(cs *ChordStore, m *ObjectManifest) *chunkReader {
+	return &chunkReader{cs: cs, m: m}
+}
+
+// seek positions the reader at the offset.  It must be called before reading.
+func (cr *chunkReader) seek(offset int64) {
+	if offset >= cr.m.Size || cr.m.ChunkSize < 1 {
+		cr.next = len(cr.m.Chunks)
+		return
+	}
+	cr.next = int(offset / int64(cr.m.ChunkSize))
+	cr.skip = int(offset % int64(cr.m.ChunkSize))
+}
+
+func (cr *chunkReader) fill() {
+	for len(cr.pending) < chunkParallelism && cr.next < len(cr.m.Chunks) {
+		ch := make(chan chunkResult, 1)
+		go func(hash []byte) {
+			b, err := cr.cs.fetchChunk(hash)
+			ch <- chunkResult{data: b, err: err}
+		}(cr.m.Chunks[cr.next])
+
+		cr.pending = append(cr.pending, ch)
+		cr.next++
+	}
+}
+
+func (cr *chunkReader) Read(p []byte) (int, error) {
+	for len(cr.cur) == 0 {
+		if cr.err != nil {
+			return 0, cr.err
+		}
+
+		cr.fill()
+		if len(cr.pending) == 0 {
+			cr.err = io.EOF
+			continue
+		}
+
+		res := <-cr.pending[0]
+		cr.pending = cr.pending[1:]
+		if res.err != nil {
+			cr.err = res.err
+			continue
+		}
+		cr.cur = res.data
+		if cr.skip > 0 {
+			if cr.skip > len(cr.cur) {
+				cr.skip = len(cr.cur)
+			}
+			cr.cur, cr.skip = cr.cur[cr.skip:], 0
+		}
+	}
+
+	n := copy(p, cr.cur)
+	cr.cur = cr.cur[n:]
+	return n, nil
+}
+
+// Close stops fetching further chunks.  Chunks in flight are discarded.
+func (cr *chunkReader) Close() error {
+	if cr.err == nil {
+		cr.err = fmt.Errorf("reader closed")
+	}
+	cr.pending = nil
+	return nil
+}
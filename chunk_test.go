@@ -0,0 +1,156 @@
+package chordstore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+	"time"
+
+	"gopkg.in/vmihailenco/msgpack.v2"
+)
+
+func Test_ChordStore_Chunked(t *testing.T) {
+	c1, err := initConfig(36021)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1.ChunkSize = 1000
+	cs1, err := NewChordStore(c1, &MemKeyValueStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs1.Shutdown()
+	defer c1.Ring.Shutdown()
+
+	<-time.After(200 * time.Millisecond)
+	c2, err := initConfig(36022, "127.0.0.1:36021")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2.ChunkSize = 1000
+	cs2, err := NewChordStore(c2, &MemKeyValueStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs2.Shutdown()
+	defer c2.Ring.Shutdown()
+
+	<-time.After(300 * time.Millisecond)
+
+	data := make([]byte, 10500)
+	rand.New(rand.NewSource(1)).Read(data)
+	key := []byte("large")
+
+	cds, err := cs1.PutObject(0, key, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range cds {
+		if v.Err != nil {
+			t.Fatal(v.Err)
+		}
+	}
+
+	// Stored as a manifest
+	meta, err := cs1.store.StatObject(cds[0].Vnode, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Encoding != ObjectEncodingChunked {
+		t.Fatal("manifest not stored", meta.Encoding)
+	}
+	rd, err := cs1.store.GetObject(cds[0].Vnode, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := decodeManifest(rd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Chunks) != 11 || m.Size != int64(len(data)) {
+		t.Fatal("manifest mismatch", len(m.Chunks), m.Size)
+	}
+
+	// Chunks are spread across the ring
+	primaries := map[string]bool{}
+	for _, h := range m.Chunks {
+		vns, _ := cs1.lookup(1, h)
+		primaries[vns[0].StringID()] = true
+	}
+	if len(primaries) < 2 {
+		t.Fatal("chunks not spread", len(primaries))
+	}
+
+	cds, err = cs2.GetObject(0, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range cds {
+		if v.Err != nil {
+			t.Fatal(v.Err)
+		}
+		b, err := ioutil.ReadAll(v.Reader())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, data) {
+			t.Fatal("data mismatch", shortID(v.Vnode), len(b))
+		}
+	}
+
+	// Resume with a chunk missing
+	if _, err = cs1.RemoveObject(0, m.Chunks[3]); err != nil {
+		t.Fatal(err)
+	}
+	if cs1.hasChunk(0, m.Chunks[3]) {
+		t.Fatal("chunk should be missing")
+	}
+	if _, err = cs2.PutObject(0, key, bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if !cs1.hasChunk(0, m.Chunks[3]) {
+		t.Fatal("chunk not uploaded")
+	}
+
+	// Small objects are stored as is
+	if _, err = cs1.PutObject(0, []byte("small"), bytes.NewBufferString("small")); err != nil {
+		t.Fatal(err)
+	}
+	cds, _ = cs2.GetObject(0, []byte("small"))
+	if b, _ := ioutil.ReadAll(cds[0].Reader()); string(b) != "small" {
+		t.Fatal("data mismatch", string(b))
+	}
+
+	// Small objects starting like a manifest are stored as is
+	manifest, _ := msgpack.Marshal(m)
+	if _, err = cs1.PutObject(0, []byte("lookalike"), bytes.NewReader(manifest)); err != nil {
+		t.Fatal(err)
+	}
+	cds, _ = cs2.GetObject(0, []byte("lookalike"))
+	if b, _ := ioutil.ReadAll(cds[0].Reader()); !bytes.Equal(b, manifest) {
+		t.Fatal("data mismatch", len(b))
+	}
+
+	// Chunks are only collected once no object references them
+	cs1.chunkGrace, cs2.chunkGrace = 0, 0
+	for _, cs := range []*ChordStore{cs1, cs2} {
+		if n, err := cs.collectChunks(); err != nil || n != 0 {
+			t.Fatal("referenced chunks collected", n, err)
+		}
+	}
+	if _, err = cs1.RemoveObject(0, key); err != nil {
+		t.Fatal(err)
+	}
+	var removed int
+	for _, cs := range []*ChordStore{cs1, cs2} {
+		n, err := cs.collectChunks()
+		if err != nil {
+			t.Fatal(err)
+		}
+		removed += n
+	}
+	if removed != len(m.Chunks)*c1.Replicas {
+		t.Fatal("chunks not collected", removed)
+	}
+}
@@ -12,6 +12,8 @@ var (
 	bindAddr  = flag.String("b", "127.0.0.1:3243", "Bind address")
 	httpAddr  = flag.String("http", "127.0.0.1:9090", "HTTP Bind address")
 	joinAddrs = flag.String("j", "", "Initial cluster membders to join")
+	dataDir   = flag.String("d", "", "Data directory.  Data is kept in memory if not provided")
+	conflict  = flag.String("conflict", "", "Conflict resolution for divergent replicas: lww or version.  Quorum value if not provided")
 )
 
 func init() {
@@ -21,26 +23,31 @@ func init() {
 
 func main() {
 
-	cfg := chordstore.DefaultConfig(*bindAddr)
+	cfg, err := chordstore.DefaultConfig(*bindAddr, "")
+	if err != nil {
+		log.Fatal(err)
+	}
 	cfg.Chord.Peers = chordstore.ParsePeersList(*joinAddrs)
+	if cfg.ConflictResolver, err = chordstore.ParseConflictResolver(*conflict); err != nil {
+		log.Fatal(err)
+	}
 
-	var (
-		chordStore *chordstore.ChordStore
-		err        error
-	)
+	var vnstore chordstore.VnodeStore = &chordstore.MemKeyValueStore{}
+	if *dataDir != "" {
+		vnstore = &chordstore.DiskKeyValueStore{DataDir: *dataDir}
+	}
+
+	var chordStore *chordstore.ChordStore
 
 	// Init listener
 	if cfg.Listener, err = net.Listen("tcp", *bindAddr); err != nil {
 		log.Fatal(err)
 	}
 
-	if chordStore, err = chordstore.NewChordStore(cfg, &chordstore.MemKeyValueStore{}); err != nil {
+	if chordStore, err = chordstore.NewChordStore(cfg, vnstore); err != nil {
 		log.Fatal(err)
 	}
 
-	/*he := chordstore.NewHealingEngine(chordStore)
-	go he.Start()*/
-
 	admServer := chordstore.NewAdminServer(cfg, chordStore)
 	admServer.Start(*httpAddr)
 	select {}
@@ -30,8 +30,87 @@ type ChordConfig struct {
 type Config struct {
 	// Underlying chord config
 	Chord *ChordConfig
-	// Key and object replication count.
+	// Key and object replication count.  This is the default N for operations.
 	Replicas int
+	// Default consistency levels used for reads and writes
+	ReadConsistency  Consistency
+	WriteConsistency Consistency
+	// Deadline for the replicas of a single operation to respond
+	RequestTimeout time.Duration
+	// Max pending read repair requests.  Requests are dropped when full.
+	HealQueueSize int
+	// Interval between anti-entropy syncs of each local vnode with its replicas.
+	// Zero disables anti-entropy.
+	AntiEntropyInterval time.Duration
+	// Remove keys a vnode is no longer a replica of once transferred to a new
+	// predecessor.  This relies on the ring having stabilized when the new
+	// predecessor is notified.
+	PruneOnTransfer bool
+	// Interval between liveness probes of the replica peers of local vnodes.
+	// Zero disables failure detection.
+	FailureCheckInterval time.Duration
+	// Interval between removals of expired keys from the local vnodes.  Zero
+	// disables the reaper leaving expired keys to be removed when read.
+	ExpiryInterval time.Duration
+	// Chooses the value of a key when replicas disagree on reads, healing,
+	// updates and restores.  nil keeps the value held by a quorum of the
+	// replicas.
+	ConflictResolver ConflictResolver `json:"-"`
+	// Time tombstones of removed keys are kept by the reaper.  Replicas that
+	// have been unreachable for longer may resurrect removed keys.  Zero keeps
+	// tombstones forever.
+	TombstoneGracePeriod time.Duration
+	// Directory used to stage content addressed uploads while their hash is
+	// computed.  Defaults to the system temp directory.
+	SpoolDir string
+	// Objects larger than this are split into chunks of this size, each placed
+	// on the ring independently.  Zero, the default, disables chunking.
+	ChunkSize int
+	// Interval between removals of the chunks of the local vnodes no longer
+	// referenced by any object.  Each run lists the objects of the whole ring
+	// if any chunk is found.  Zero disables removal leaving the chunks of
+	// removed objects in place.
+	ChunkCollectInterval time.Duration
+	// Age below which unreferenced chunks are kept.  It must be well above the
+	// time taken to upload a chunked object.
+	ChunkGracePeriod time.Duration
+	// Data and parity shard counts of erasure coded objects stored with
+	// PutErasure.  Zero data shards disables erasure coding.
+	ErasureDataShards   int
+	ErasureParityShards int
+	// Key prefixes whose keys are placed on the ring in key order rather than
+	// by hash so they can be scanned in order.  Namespaces should end in a
+	// separator such as "ts/" and must be the same on all nodes.  Namespaces
+	// whose keys concentrate on a vnode, such as keys sharing a long common
+	// prefix, are split at the quantiles of their keys to spread them over the
+	// ring.
+	OrderedNamespaces []string
+	// Interval at which the layouts of ordered namespaces are refreshed, keys
+	// are moved to the vnodes now responsible for them and namespaces are split.
+	// Zero disables splitting and must then be zero on all nodes.
+	OrderedBalanceInterval time.Duration
+	// Number of keys of an ordered namespace on a vnode above which the
+	// namespace is split.  Zero disables splitting.
+	OrderedSplitKeys int
+	// Key prefixes whose keys are read and written through a Raft consensus
+	// group for linearizability rather than by replica fan out.  The group of a
+	// key is formed by its primary vnode and the successors replicating it and
+	// follows the ring as vnodes join and leave.  Namespaces must be the same
+	// on all nodes.  Group state is persisted by the vnode store which must
+	// support it, as DiskKeyValueStore does.
+	StrongNamespaces []string
+	// Time a group member waits to hear from the leader before starting an
+	// election.  Defaults to a second.
+	RaftElectionTimeout time.Duration
+	// Number of applied entries above which the log of a group member is
+	// compacted.  Members behind the compacted log of the leader are sent the
+	// keys of the group instead.  Defaults to 1024.
+	RaftCompactEntries int
+	// Time a transaction prepared by a replica waits on the decision of its
+	// coordinator before the replica resolves it from the recorded decision,
+	// aborting it if none was recorded.  It should be well above the request
+	// timeout.  Defaults to a minute.
+	TxnTimeout time.Duration
 	// GRPC server. This is so multiple services can be registered with grpc
 	Server *grpc.Server `json:"-"`
 	// This can be provided or a tcp listener is created using the bind address.
@@ -39,6 +118,8 @@ type Config struct {
 	Listener net.Listener `json:"-"`
 	// Actual chord ring
 	Ring *chord.Ring `json:"-"`
+	// layouts of the ordered namespaces used by the ring hash function
+	layouts *orderedLayouts
 }
 
 // ChordDelegate returns a type delegate
@@ -58,8 +139,23 @@ func DefaultConfig(bindAddr, advAddr string) (*Config, error) {
 			ConnMaxIdle: time.Second * 300,
 			Peers:       []string{},
 		},
-		Server:   grpc.NewServer(),
-		Replicas: 3,
+		Server:                 grpc.NewServer(),
+		Replicas:               3,
+		ReadConsistency:        ConsistencyQuorum,
+		WriteConsistency:       ConsistencyQuorum,
+		RequestTimeout:         time.Second * 10,
+		HealQueueSize:          defaultHealQueueSize,
+		AntiEntropyInterval:    time.Minute,
+		FailureCheckInterval:   5 * time.Second,
+		ExpiryInterval:         30 * time.Second,
+		TombstoneGracePeriod:   24 * time.Hour,
+		TxnTimeout:             time.Minute,
+		ChunkCollectInterval:   time.Hour,
+		ChunkGracePeriod:       time.Hour,
+		OrderedBalanceInterval: time.Minute,
+		OrderedSplitKeys:       10000,
+		ErasureDataShards:      4,
+		ErasureParityShards:    2,
 	}
 
 	addr, err := getAdvertiseAddr(bindAddr, advAddr)
@@ -79,6 +175,10 @@ func DefaultConfig(bindAddr, advAddr string) (*Config, error) {
 // initialized ring back to the config.
 func initChordRing(cfg *Config) (err error) {
 	cfg.Chord.Transport = chord.NewGRPCTransport(cfg.Listener, cfg.Server, cfg.Chord.Timeout, cfg.Chord.ConnMaxIdle)
+	if len(cfg.OrderedNamespaces) > 0 {
+		cfg.layouts = newOrderedLayouts(cfg.OrderedNamespaces)
+		cfg.Chord.HashFunc = orderedHashFunc(cfg.Chord.HashFunc, cfg.layouts)
+	}
 
 	if len(cfg.Chord.Peers) == 0 {
 		log.Println("[chord] Creating ring...")
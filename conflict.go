@@ -0,0 +1,182 @@
+package chordstore
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+
+	chord "github.com/euforia/go-chord"
+)
+
+// KeyVersion is the value of a key held by a replica along with what its
+// transaction log tells of how it was written
+type KeyVersion struct {
+	// nil for values not read from a replica, such as those of a snapshot
+	Vnode *chord.Vnode
+	Value []byte
+	// Hybrid logical clock time of the last write.  0 if unknown.
+	Timestamp int64
+	// Number of writes in the transaction log
+	Version int
+}
+
+func newKeyVersion(vn *chord.Vnode, value []byte, txns []*KeyTxn) *KeyVersion {
+	return &KeyVersion{
+		Vnode:     vn,
+		Value:     value,
+		Timestamp: keyWriteTime(txns),
+		Version:   keyWriteCount(txns),
+	}
+}
+
+// ConflictResolver chooses the value of a key when replicas disagree.  It is
+// given the version held by each replica and returns the one to keep.  A
+// resolver may also return a new version e.g. merging the values, which is then
+// written to all replicas.
+type ConflictResolver interface {
+	Resolve(key []byte, versions []*KeyVersion) (*KeyVersion, error)
+}
+
+// ConflictResolverFunc is an application callback used as a ConflictResolver
+type ConflictResolverFunc func(key []byte, versions []*KeyVersion) (*KeyVersion, error)
+
+// Resolve calls the function
+func (f ConflictResolverFunc) Resolve(key []byte, versions []*KeyVersion) (*KeyVersion, error) {
+	return f(key, versions)
+}
+
+// LastWriteWins chooses the version written last according to the hybrid
+// logical clock timestamps of the replica transaction logs
+type LastWriteWins struct{}
+
+// Resolve returns the latest version.  Ties are broken by value so all nodes
+// choose the same.
+func (LastWriteWins) Resolve(key []byte, versions []*KeyVersion) (*KeyVersion, error) {
+	var best *KeyVersion
+	for _, kv := range versions {
+		if best == nil || kv.Timestamp > best.Timestamp ||
+			(kv.Timestamp == best.Timestamp && bytes.Compare(kv.Value, best.Value) > 0) {
+			best = kv
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("key not found: %s", key)
+	}
+	return best, nil
+}
+
+// HighestVersionWins chooses the version with the most writes in its
+// transaction log, falling back to the last write on a tie
+type HighestVersionWins struct{}
+
+// Resolve returns the version with the most writes
+func (HighestVersionWins) Resolve(key []byte, versions []*KeyVersion) (*KeyVersion, error) {
+	var (
+		max  int
+		tied []*KeyVersion
+	)
+	for _, kv := range versions {
+		if kv.Version > max {
+			max, tied = kv.Version, tied[:0]
+		}
+		if kv.Version == max {
+			tied = append(tied, kv)
+		}
+	}
+	return LastWriteWins{}.Resolve(key, tied)
+}
+
+// ParseConflictResolver returns the built-in resolver with the given name i.e.
+// lww or version.  An empty name returns nil keeping the value held by a quorum
+// of the replicas.
+func ParseConflictResolver(s string) (ConflictResolver, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return nil, nil
+	case "lww":
+		return LastWriteWins{}, nil
+	case "version":
+		return HighestVersionWins{}, nil
+	}
+	return nil, fmt.Errorf("invalid conflict resolver: %s", s)
+}
+
+// divergent returns true if the replicas holding the key do not all have the
+// same value
+func divergent(vds []*VnodeData) bool {
+	var ref *VnodeData
+	for _, vd := range vds {
+		if vd.Err != nil {
+			continue
+		}
+		if ref == nil {
+			ref = vd
+		} else if !bytes.Equal(ref.Data, vd.Data) {
+			return true
+		}
+	}
+	return false
+}
+
+// keyVersions returns the versions of the replicas holding the key.  Replicas
+// whose history cannot be read are given a zero timestamp and version.
+func (cs *ChordStore) keyVersions(key []byte, vds []*VnodeData) []*KeyVersion {
+	out := make([]*KeyVersion, 0, len(vds))
+	for _, vd := range vds {
+		if vd.Err != nil {
+			continue
+		}
+		txns, err := cs.store.KeyHistory(vd.Vnode, key)
+		if err != nil {
+			log.Printf("ERR [conflict] vnode=%s key=%s %v", shortID(vd.Vnode), key, err)
+		}
+		kv := newKeyVersion(vd.Vnode, vd.Data, txns)
+		hlc.Update(kv.Timestamp)
+		out = append(out, kv)
+	}
+	return out
+}
+
+// resolveValue returns the value of the key from the replica responses.  If the
+// replicas holding the key disagree and a conflict resolver is configured it
+// chooses the value as long as r replicas hold one.  Otherwise the value held by
+// at least r replicas is returned.
+func (cs *ChordStore) resolveValue(key []byte, vds []*VnodeData, r int) ([]byte, error) {
+	if cs.resolver == nil || !divergent(vds) {
+		return resolveRead(key, vds, r)
+	}
+
+	var have int
+	for _, vd := range vds {
+		if vd.Err == nil {
+			have++
+		}
+	}
+	if have < r {
+		return resolveRead(key, vds, r)
+	}
+
+	kv, err := cs.resolver.Resolve(key, cs.keyVersions(key, vds))
+	if err != nil {
+		return nil, err
+	}
+	return kv.Value, nil
+}
+
+// resolveRestore returns the value a vnode keeps when a restored value differs
+// from its own.  Without a resolver the restored value is kept.
+func resolveRestore(r ConflictResolver, key, local, restored []byte, localTxns, restoredTxns []*KeyTxn) []byte {
+	if r == nil {
+		return restored
+	}
+	kv, err := r.Resolve(key, []*KeyVersion{
+		newKeyVersion(nil, local, localTxns),
+		newKeyVersion(nil, restored, restoredTxns),
+	})
+	if err != nil {
+		log.Printf("ERR [restore] Failed to resolve key=%s %v", key, err)
+		return restored
+	}
+	return kv.Value
+}
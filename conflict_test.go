@@ -0,0 +1,147 @@
+package chordstore
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func Test_hybridClock(t *testing.T) {
+	c := &hybridClock{}
+	a := c.Now()
+	if b := c.Now(); b <= a {
+		t.Fatal("clock went backwards", a, b)
+	}
+
+	// A timestamp from a node with a clock ahead
+	ahead := time.Now().Add(time.Hour).UnixNano()
+	c.Update(ahead)
+	if ts := c.Now(); ts <= ahead {
+		t.Fatal("clock should move past observed timestamps", ts, ahead)
+	}
+}
+
+func Test_ConflictResolvers(t *testing.T) {
+	versions := []*KeyVersion{
+		&KeyVersion{Value: []byte("old"), Timestamp: 1, Version: 3},
+		&KeyVersion{Value: []byte("new"), Timestamp: 3, Version: 1},
+		&KeyVersion{Value: []byte("mid"), Timestamp: 2, Version: 3},
+	}
+
+	kv, err := LastWriteWins{}.Resolve([]byte("k"), versions)
+	if err != nil || string(kv.Value) != "new" {
+		t.Fatal("last write should win", kv, err)
+	}
+	// Ties on version fall back to the last write
+	kv, err = HighestVersionWins{}.Resolve([]byte("k"), versions)
+	if err != nil || string(kv.Value) != "mid" {
+		t.Fatal("highest version should win", kv, err)
+	}
+
+	merge := ConflictResolverFunc(func(key []byte, versions []*KeyVersion) (*KeyVersion, error) {
+		vals := make([][]byte, len(versions))
+		for i, v := range versions {
+			vals[i] = v.Value
+		}
+		return &KeyVersion{Value: bytes.Join(vals, []byte(","))}, nil
+	})
+	if kv, _ = merge.Resolve([]byte("k"), versions); string(kv.Value) != "old,new,mid" {
+		t.Fatal("callback not used", string(kv.Value))
+	}
+
+	if _, err = (LastWriteWins{}).Resolve([]byte("k"), nil); err == nil {
+		t.Fatal("should fail without versions")
+	}
+
+	for name, ok := range map[string]bool{"": true, "lww": true, "VERSION": true, "quorum": false} {
+		if _, err = ParseConflictResolver(name); (err == nil) != ok {
+			t.Fatal(name, err)
+		}
+	}
+}
+
+func Test_MemKeyValueStore_Restore_Conflict(t *testing.T) {
+	src, _ := (&MemKeyValueStore{}).New(testVn2)
+	src.PutKey([]byte("k"), []byte("older"), 0, nil)
+
+	st := &MemKeyValueStore{}
+	st.setConflictResolver(LastWriteWins{})
+	kvs, _ := st.New(testVn1)
+	kvs.PutKey([]byte("k"), []byte("newer"), 0, nil)
+
+	buf := new(bytes.Buffer)
+	if err := src.Snapshot(buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	snap := buf.Bytes()
+
+	if err := kvs.Restore(bytes.NewReader(snap)); err != nil {
+		t.Fatal(err)
+	}
+	if val, _ := kvs.GetKey([]byte("k")); string(val) != "newer" {
+		t.Fatal("later local write should win", string(val))
+	}
+
+	// Without a resolver the restored value is kept
+	plain, _ := (&MemKeyValueStore{}).New(testVn1)
+	plain.PutKey([]byte("k"), []byte("newer"), 0, nil)
+	if err := plain.Restore(bytes.NewReader(snap)); err != nil {
+		t.Fatal(err)
+	}
+	if val, _ := plain.GetKey([]byte("k")); string(val) != "older" {
+		t.Fatal("restored value should be kept", string(val))
+	}
+}
+
+func Test_ChordStore_ConflictResolver(t *testing.T) {
+	c1, err := initConfig(36038)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1.ConflictResolver = LastWriteWins{}
+
+	cs1, err := NewChordStore(c1, &MemKeyValueStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs1.Shutdown()
+	defer c1.Ring.Shutdown()
+
+	<-time.After(200 * time.Millisecond)
+
+	key := []byte("conflict")
+	vns, _ := cs1.lookup(3, key)
+	// Every replica has a different value
+	diverge := func() {
+		for i, vn := range vns {
+			cs1.store.PutKey(vn, key, []byte(fmt.Sprintf("v%d", i)), 0, nil)
+		}
+	}
+
+	// Divergent replicas no longer block updates
+	diverge()
+	vds, err := cs1.UpdateKey(3, key, []byte("updated"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, vd := range vds {
+		if vd.Err != nil {
+			t.Fatal(vd.Err)
+		}
+	}
+	for _, vn := range vns {
+		if val, _ := cs1.store.GetKey(vn, key); string(val) != "updated" {
+			t.Fatal("replica not updated", vn.StringID(), string(val))
+		}
+	}
+
+	diverge()
+	val, err := cs1.Get(key, ConsistencyAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "v2" {
+		t.Fatal("last write should win", string(val))
+	}
+}
@@ -0,0 +1,171 @@
+package chordstore
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Consistency is the level of replica acknowledgement required for a read or
+// write to succeed.  Using QUORUM for both reads and writes satisfies W+R>N so a
+// read always sees the latest acknowledged write.
+type Consistency int
+
+// Consistency levels
+const (
+	// ConsistencyDefault uses the configured ReadConsistency or WriteConsistency
+	ConsistencyDefault Consistency = iota
+	ConsistencyOne
+	ConsistencyQuorum
+	ConsistencyAll
+)
+
+// ParseConsistency parses a consistency level name i.e. default, one, quorum or
+// all
+func ParseConsistency(s string) (Consistency, error) {
+	switch strings.ToLower(s) {
+	case "default":
+		return ConsistencyDefault, nil
+	case "one":
+		return ConsistencyOne, nil
+	case "quorum":
+		return ConsistencyQuorum, nil
+	case "all":
+		return ConsistencyAll, nil
+	}
+	return 0, fmt.Errorf("invalid consistency level: %s", s)
+}
+
+func (c Consistency) String() string {
+	switch c {
+	case ConsistencyDefault:
+		return "default"
+	case ConsistencyOne:
+		return "one"
+	case ConsistencyQuorum:
+		return "quorum"
+	case ConsistencyAll:
+		return "all"
+	}
+	return fmt.Sprintf("Consistency(%d)", int(c))
+}
+
+// MarshalText so the level is human readable in the config
+func (c Consistency) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText parses the level name
+func (c *Consistency) UnmarshalText(b []byte) (err error) {
+	*c, err = ParseConsistency(string(b))
+	return
+}
+
+// Required returns the number of replicas out of n that must acknowledge
+func (c Consistency) Required(n int) int {
+	switch c {
+	case ConsistencyOne:
+		return 1
+	case ConsistencyAll:
+		return n
+	}
+	return n/2 + 1
+}
+
+func isNotFound(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "not found")
+}
+
+// resolveWrite returns an error if fewer than w replicas acknowledged the write.
+func resolveWrite(vds []*VnodeData, w int) error {
+	var (
+		ok   int
+		errs error
+	)
+	for _, vd := range vds {
+		if vd.Err == nil {
+			ok++
+		} else {
+			errs = mergeErrors(errs, fmt.Errorf("%s: %v", shortID(vd.Vnode), vd.Err))
+		}
+	}
+
+	if ok >= w {
+		return nil
+	}
+	return fmt.Errorf("write consistency not met %d/%d: %v", ok, w, errs)
+}
+
+// readSettled returns the fanOutUntil callback of a read at level r.  Replies
+// are collected until a value is held by r replicas, or no value can reach r with
+// the replicas yet to reply and the key is settled as not found or unavailable.
+// A missing replica or a fast one with a stale value does not cut short a read
+// that other replicas can satisfy.  With resolve set differing values are
+// resolved so any r values settle the read.
+func readSettled(res []*VnodeData, r int, resolve bool) func(int, bool) bool {
+	var (
+		replied, best, notFound int
+		counts                  = map[string]int{}
+	)
+	return func(i int, ok bool) bool {
+		replied++
+		if ok {
+			var h string
+			if !resolve {
+				h = string(valueHash(res[i].Data))
+			}
+			if counts[h]++; counts[h] > best {
+				best = counts[h]
+			}
+		} else if isNotFound(res[i].Err) {
+			notFound++
+		}
+		left := len(res) - replied
+		if best >= r {
+			return true
+		}
+		return best+left < r && (notFound >= r || notFound+left < r)
+	}
+}
+
+// resolveRead returns the value held by at least r replicas.  If replicas
+// disagree the value with the most replicas is chosen, preferring replicas in
+// lookup order on a tie.  A not found error is returned if at least r replicas do
+// not have the key.
+func resolveRead(key []byte, vds []*VnodeData, r int) ([]byte, error) {
+	var (
+		best     *VnodeData
+		bestCnt  int
+		notFound int
+		errs     error
+	)
+
+	for i, vd := range vds {
+		if vd.Err != nil {
+			if isNotFound(vd.Err) {
+				notFound++
+			} else {
+				errs = mergeErrors(errs, fmt.Errorf("%s: %v", shortID(vd.Vnode), vd.Err))
+			}
+			continue
+		}
+
+		cnt := 0
+		for _, v := range vds[i:] {
+			if v.Err == nil && bytes.Equal(vd.Data, v.Data) {
+				cnt++
+			}
+		}
+		if cnt > bestCnt {
+			best, bestCnt = vd, cnt
+		}
+	}
+
+	if bestCnt >= r {
+		return best.Data, nil
+	}
+	if notFound >= r {
+		return nil, fmt.Errorf("key not found: %s", key)
+	}
+	return nil, fmt.Errorf("read consistency not met %d/%d: %v", bestCnt, r, errs)
+}
@@ -0,0 +1,130 @@
+package chordstore
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	chord "github.com/euforia/go-chord"
+	context "golang.org/x/net/context"
+)
+
+func Test_Consistency_Required(t *testing.T) {
+	for _, tc := range []struct {
+		c    Consistency
+		n    int
+		want int
+	}{
+		{ConsistencyOne, 3, 1},
+		{ConsistencyQuorum, 3, 2},
+		{ConsistencyQuorum, 4, 3},
+		{ConsistencyAll, 3, 3},
+	} {
+		if got := tc.c.Required(tc.n); got != tc.want {
+			t.Fatalf("%s n=%d have=%d want=%d", tc.c, tc.n, got, tc.want)
+		}
+	}
+
+	c, err := ParseConsistency("QUORUM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != ConsistencyQuorum {
+		t.Fatal("wrong level", c)
+	}
+	if _, err = ParseConsistency("some"); err == nil {
+		t.Fatal("should fail")
+	}
+
+	cs := &ChordStore{readLevel: ConsistencyOne, writeLevel: ConsistencyAll}
+	if cs.readConsistency(ConsistencyDefault) != ConsistencyOne || cs.writeConsistency(ConsistencyDefault) != ConsistencyAll {
+		t.Fatal("configured levels not used")
+	}
+	if cs.readConsistency(ConsistencyQuorum) != ConsistencyQuorum {
+		t.Fatal("explicit level overridden")
+	}
+}
+
+func Test_resolveRead(t *testing.T) {
+	notFound := fmt.Errorf("key not found: key")
+	vds := []*VnodeData{
+		{Vnode: testVn1, Data: []byte("old")},
+		{Vnode: testVn2, Data: []byte("new")},
+		{Vnode: testVn1, Data: []byte("new")},
+	}
+
+	val, err := resolveRead([]byte("key"), vds, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "new" {
+		t.Fatal("wrong value", string(val))
+	}
+	if _, err = resolveRead([]byte("key"), vds, 3); err == nil {
+		t.Fatal("should fail")
+	}
+	if val, _ = resolveRead([]byte("key"), vds[:2], 1); string(val) != "old" {
+		t.Fatal("should prefer first replica", string(val))
+	}
+
+	vds[1].Err, vds[2].Err = notFound, notFound
+	if _, err = resolveRead([]byte("key"), vds, 2); !isNotFound(err) {
+		t.Fatal("should be not found", err)
+	}
+}
+
+func Test_resolveWrite(t *testing.T) {
+	vds := []*VnodeData{
+		{Vnode: testVn1},
+		{Vnode: testVn2, Err: fmt.Errorf("failed")},
+		{Vnode: testVn1},
+	}
+	if err := resolveWrite(vds, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := resolveWrite(vds, 3); err == nil {
+		t.Fatal("should fail")
+	}
+}
+
+func Test_readSettled(t *testing.T) {
+	// The stale replica replies first
+	var (
+		vns    = []*chord.Vnode{testVn1, testVn2, testVn1}
+		values = []string{"old", "new", "new"}
+		res    = make([]*VnodeData, len(vns))
+	)
+	done, _ := fanOutUntil(len(vns), time.Second, func(_ context.Context, i int) bool {
+		time.Sleep(time.Duration(i) * 20 * time.Millisecond)
+		res[i] = &VnodeData{Vnode: vns[i], Data: []byte(values[i])}
+		return true
+	}, readSettled(res, 2, false))
+
+	val, err := resolveRead([]byte("key"), collectVnodeData(vns, res, done), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "new" {
+		t.Fatal("wrong value", string(val))
+	}
+
+	// Replies stop being collected once no value can reach the level
+	settled := readSettled(res, 3, false)
+	res[0] = &VnodeData{Vnode: vns[0], Data: []byte("a")}
+	if settled(0, true) {
+		t.Fatal("settled early")
+	}
+	res[1] = &VnodeData{Vnode: vns[1], Data: []byte("b")}
+	if !settled(1, true) {
+		t.Fatal("should settle once the level is unreachable")
+	}
+
+	// or once enough replicas are missing the key
+	settled = readSettled(res, 2, false)
+	for i := 0; i < 2; i++ {
+		res[i] = &VnodeData{Vnode: vns[i], Err: fmt.Errorf("key not found: key")}
+		if settled(i, false) != (i == 1) {
+			t.Fatal("wrong not found settle", i)
+		}
+	}
+}
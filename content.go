@@ -0,0 +1,99 @@
+package chordstore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// PutContent stores the data under its sha256 hash on n replicas returning the
+// key.  The data is staged in the spool directory to compute the key before it
+// is streamed to the replicas, each of which verifies the hash before storing.
+// Identical data always maps to the same key and replicas.
+func (cs *ChordStore) PutContent(n int, rd io.Reader) ([]byte, []*VnodeDataIO, error) {
+	fh, err := ioutil.TempFile(cs.spoolDir, "chordstore-content-")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		fh.Close()
+		os.Remove(fh.Name())
+	}()
+
+	h := sha256.New()
+	if _, err = io.Copy(fh, io.TeeReader(rd, h)); err != nil {
+		return nil, nil, err
+	}
+	if _, err = fh.Seek(0, io.SeekStart); err != nil {
+		return nil, nil, err
+	}
+
+	key := h.Sum(nil)
+	vds, err := cs.putObject(n, key, fh, putContent(nil))
+	return key, vds, err
+}
+
+// GetContent returns readers from n replicas of a content addressed object.  A
+// reader returns an error at EOF if its data does not hash to the key, in which
+// case the replica is queued for healing.
+func (cs *ChordStore) GetContent(n int, key []byte) ([]*VnodeDataIO, error) {
+	vds, err := cs.getObject(n, key, 0, 0, false)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, vd := range vds {
+		if vd.Err != nil {
+			continue
+		}
+		hv := newHashVerifier(vd.r, key)
+		vn := vd.Vnode
+		hv.onMismatch = func() {
+			cs.enqueueHeal(HealRequest{Type: HealTypeContent, Vnode: vn, Key: key})
+		}
+		vd.r = hv
+	}
+	return vds, nil
+}
+
+// hashVerifier hashes data as it is read returning an error in place of io.EOF
+// if the data does not match the expected sha256 hash.
+type hashVerifier struct {
+	rd   io.Reader
+	h    hash.Hash
+	want []byte
+	// called once on a mismatch
+	onMismatch func()
+}
+
+func newHashVerifier(rd io.Reader, want []byte) *hashVerifier {
+	return &hashVerifier{rd: rd, h: sha256.New(), want: want}
+}
+
+func (hv *hashVerifier) Read(p []byte) (int, error) {
+	n, err := hv.rd.Read(p)
+	hv.h.Write(p[:n])
+
+	if err == io.EOF {
+		if got := hv.h.Sum(nil); !bytes.Equal(got, hv.want) {
+			if hv.onMismatch != nil {
+				hv.onMismatch()
+				hv.onMismatch = nil
+			}
+			return n, fmt.Errorf("content hash mismatch: %x != %x", got, hv.want)
+		}
+	}
+	return n, err
+}
+
+// Close the underlying reader if it can be closed
+func (hv *hashVerifier) Close() error {
+	if c, ok := hv.rd.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package chordstore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func Test_hashVerifier(t *testing.T) {
+	h := sha256.Sum256([]byte("payload"))
+
+	b, err := ioutil.ReadAll(newHashVerifier(bytes.NewBufferString("payload"), h[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "payload" {
+		t.Fatal("payload mismatch")
+	}
+
+	var called bool
+	hv := newHashVerifier(bytes.NewBufferString("tampered"), h[:])
+	hv.onMismatch = func() { called = true }
+	if _, err = ioutil.ReadAll(hv); err == nil {
+		t.Fatal("should fail with hash mismatch")
+	}
+	if !called {
+		t.Fatal("mismatch callback not called")
+	}
+}
+
+func Test_ChordStore_Content(t *testing.T) {
+	cs1, cs2, stop := newTestRing(t, 36019, nil)
+	defer stop()
+
+	key, cds, err := cs1.PutContent(4, bytes.NewBufferString("artifact"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := sha256.Sum256([]byte("artifact")); !bytes.Equal(key, h[:]) {
+		t.Fatal("key mismatch")
+	}
+	for _, v := range cds {
+		if v.Err != nil {
+			t.Fatal(v.Err)
+		}
+	}
+
+	// Replicas reject data not matching the key
+	for _, v := range cds {
+		if err = cs1.store.PutContent(v.Vnode, key, bytes.NewBufferString("tampered"), nil); err == nil {
+			t.Fatal("should fail with hash mismatch", shortID(v.Vnode))
+		}
+	}
+
+	// Corrupt the data of a replica leaving its metadata intact
+	corrupt := cds[1].Vnode
+	vs, ok := cs1.store.local[corrupt.StringID()]
+	if !ok {
+		vs = cs2.store.local[corrupt.StringID()]
+	}
+	mkv := vs.(*MemKeyValueStore)
+	mkv.mu.Lock()
+	mkv.o[fmt.Sprintf("%x", key)] = []byte("tampered")
+	mkv.mu.Unlock()
+
+	cds, err = cs2.GetContent(4, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range cds {
+		if v.Err != nil {
+			t.Fatal(v.Err)
+		}
+		b, err := ioutil.ReadAll(v.Reader())
+		if v.Vnode.StringID() == corrupt.StringID() {
+			if err == nil {
+				t.Fatal("corrupt replica not rejected")
+			}
+			continue
+		}
+		if err != nil || string(b) != "artifact" {
+			t.Fatal("content mismatch", err, string(b))
+		}
+	}
+
+	<-time.After(200 * time.Millisecond)
+	rd, err := cs1.store.GetObject(corrupt, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(rd); string(b) != "artifact" {
+		t.Fatal("replica not healed", string(b))
+	}
+}
@@ -0,0 +1,1402 @@
+package chordstore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	chord "github.com/euforia/go-chord"
+	"gopkg.in/vmihailenco/msgpack.v2"
+)
+
+const (
+	diskKeysDir    = "keys"
+	diskObjectsDir = "objects"
+	diskTxlogDir   = "txlog"
+	diskMetaDir    = "meta"
+	diskNamesDir   = "names"
+	diskRaftDir    = "raft"
+	diskTxnDir     = "txn"
+	// prefix for files being written.  These are renamed once fully written and
+	// synced, and removed if left behind by a crash.
+	diskTmpPrefix = ".tmp-"
+	// longest hex encoded key used as a file name, leaving room for the temp
+	// file prefix and suffix under NAME_MAX.  Longer keys are named by their
+	// hash.
+	diskMaxName = 200
+	// prefix of file names hashed from long keys.  It is not valid hex so these
+	// never clash with names of short keys.
+	diskHashPrefix = "h-"
+)
+
+// DiskKeyValueStore is a persistent store.  Each vnode gets its own directory
+// under DataDir containing a record file per key and a file per object.  A key
+// record holds the value along with its expiry, tombstone and siblings so a
+// write is a single file fsync'd and atomically renamed into place, and a crash
+// never leaves a partially written key behind.  Key transaction logs are kept in
+// an append-only file per key.  An entry is appended before the record is
+// written and entries the record does not account for are truncated on open.
+// Object metadata is kept in a file per object and the state of the consensus
+// group members of the vnode in a file per group.  Files are named by the hex
+// encoded key, or by its hash for long keys.
+type DiskKeyValueStore struct {
+	// Directory under which all vnode data directories are created
+	DataDir string
+
+	mu sync.RWMutex
+	// vnode data directory
+	dir string
+	// hash tree over the keys.  This is rebuilt from the records on open.
+	mt *MerkleTree
+	// live keys and objects in order so listing does not read the directories.
+	// These are loaded on open.
+	keys    *keyIndex
+	objects *keyIndex
+	// key expiries and removal times in unix nanoseconds
+	ex map[string]int64
+	ts map[string]int64
+	// number of entries in the transaction log of each key with one
+	lc map[string]int
+	// raw keys of objects named by their hash, by file name
+	names map[string]string
+	// chooses between differing local and restored values.  nil restores.
+	resolver ConflictResolver
+	// ring hash function placing keys in the hash tree
+	hashFunc func() hash.Hash
+	// vnode
+	vn *chord.Vnode
+}
+
+// diskKey is the record file of a key.  Removed and expired keys keep their
+// record for their tombstone and transaction log.
+type diskKey struct {
+	Key   []byte
+	Value []byte
+	// false once the key is removed or expired
+	Live      bool
+	Expiry    int64
+	Tombstone int64
+	Versions  []*Sibling
+	// entries in the transaction log as of the write of the record
+	Logged int
+}
+
+// versions returns the siblings of the key or nil if it is not versioned
+func (rec *diskKey) versions() []*Sibling {
+	if !rec.Live {
+		return nil
+	}
+	return rec.Versions
+}
+
+// diskName returns the file name of the key
+func diskName(key []byte) string {
+	if name := hex.EncodeToString(key); len(name) <= diskMaxName {
+		return name
+	}
+	h := sha256.Sum256(key)
+	return diskHashPrefix + hex.EncodeToString(h[:])
+}
+
+// New instantiates a new store for the vnode, opening existing data if the
+// vnode directory already exists.
+func (s *DiskKeyValueStore) New(vn *chord.Vnode) (VnodeStore, error) {
+	st := &DiskKeyValueStore{
+		DataDir:  s.DataDir,
+		dir:      filepath.Join(s.DataDir, vn.StringID()),
+		mt:       NewMerkleTree(s.hashFunc),
+		resolver: s.resolver,
+		hashFunc: s.hashFunc,
+		vn:       vn,
+	}
+
+	for _, d := range []string{st.keysDir(), st.objectsDir(), st.txlogDir(), st.metaDir(), st.namesDir(), st.raftDir(), st.txnDir()} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return nil, err
+		}
+		if err := removeTmpFiles(d); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := st.loadKeys(); err != nil {
+		return nil, err
+	}
+	if err := st.loadObjects(); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// loadKeys reads the key records and reconciles the transaction logs with them.
+// Entries appended by writes whose record was never written are truncated.
+func (s *DiskKeyValueStore) loadKeys() error {
+	s.ex = map[string]int64{}
+	s.ts = map[string]int64{}
+	s.lc = map[string]int{}
+
+	files, err := readDirFiles(s.keysDir())
+	if err != nil {
+		return err
+	}
+
+	var live []string
+	for name, b := range files {
+		var rec diskKey
+		if err = msgpack.Unmarshal(b, &rec); err != nil {
+			log.Printf("ERR [disk] Skipping invalid record %s: %v", filepath.Join(s.keysDir(), name), err)
+			continue
+		}
+		k := string(rec.Key)
+		if rec.Live {
+			live = append(live, k)
+			s.mt.Set(rec.Key, merkleValue(rec.Value, rec.Versions))
+		}
+		if rec.Expiry > 0 {
+			s.ex[k] = rec.Expiry
+		}
+		if rec.Tombstone > 0 {
+			s.ts[k] = rec.Tombstone
+		}
+
+		n, err := repairTxnLog(s.txlogPath(rec.Key), rec.Logged)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			s.lc[k] = n
+		}
+	}
+	s.keys = newKeyIndex(live)
+
+	// Logs of keys without a record were only appended by writes that never
+	// completed
+	logs, err := ioutil.ReadDir(s.txlogDir())
+	if err != nil {
+		return err
+	}
+	for _, fi := range logs {
+		if _, ok := files[fi.Name()]; !ok {
+			log.Printf("ERR [disk] Removing transaction log without a record %s", filepath.Join(s.txlogDir(), fi.Name()))
+			if err = os.Remove(filepath.Join(s.txlogDir(), fi.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadObjects reads the names of long object keys and indexes the objects
+func (s *DiskKeyValueStore) loadObjects() error {
+	files, err := readDirFiles(s.namesDir())
+	if err != nil {
+		return err
+	}
+	s.names = make(map[string]string, len(files))
+	for name, b := range files {
+		s.names[name] = string(b)
+	}
+
+	fis, err := ioutil.ReadDir(s.objectsDir())
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(fis))
+	for _, fi := range fis {
+		k, ok := s.objectKey(fi.Name())
+		if !ok {
+			log.Printf("ERR [disk] Skipping invalid file %s", filepath.Join(s.objectsDir(), fi.Name()))
+			continue
+		}
+		keys = append(keys, k)
+	}
+	s.objects = newKeyIndex(keys)
+	return nil
+}
+
+func (s *DiskKeyValueStore) setConflictResolver(r ConflictResolver) {
+	s.resolver = r
+}
+
+func (s *DiskKeyValueStore) setHashFunc(hf func() hash.Hash) {
+	s.hashFunc = hf
+}
+
+func (s *DiskKeyValueStore) keysDir() string {
+	return filepath.Join(s.dir, diskKeysDir)
+}
+
+func (s *DiskKeyValueStore) objectsDir() string {
+	return filepath.Join(s.dir, diskObjectsDir)
+}
+
+func (s *DiskKeyValueStore) txlogDir() string {
+	return filepath.Join(s.dir, diskTxlogDir)
+}
+
+func (s *DiskKeyValueStore) metaDir() string {
+	return filepath.Join(s.dir, diskMetaDir)
+}
+
+func (s *DiskKeyValueStore) namesDir() string {
+	return filepath.Join(s.dir, diskNamesDir)
+}
+
+func (s *DiskKeyValueStore) raftDir() string {
+	return filepath.Join(s.dir, diskRaftDir)
+}
+
+func (s *DiskKeyValueStore) txnDir() string {
+	return filepath.Join(s.dir, diskTxnDir)
+}
+
+func (s *DiskKeyValueStore) txlogPath(key []byte) string {
+	return filepath.Join(s.txlogDir(), diskName(key))
+}
+
+func (s *DiskKeyValueStore) objectPath(key []byte) string {
+	return filepath.Join(s.objectsDir(), diskName(key))
+}
+
+// objectKey returns the raw key of an object file name
+func (s *DiskKeyValueStore) objectKey(name string) (string, bool) {
+	if strings.HasPrefix(name, diskHashPrefix) {
+		k, ok := s.names[name]
+		return k, ok
+	}
+	b, err := hex.DecodeString(name)
+	return string(b), err == nil
+}
+
+// nameObject records the raw key of an object named by its hash so it can be
+// listed.  This is a no-op for short keys.  The lock must be held.
+func (s *DiskKeyValueStore) nameObject(key []byte) error {
+	name := diskName(key)
+	if !strings.HasPrefix(name, diskHashPrefix) {
+		return nil
+	}
+	if _, ok := s.names[name]; ok {
+		return nil
+	}
+	if err := writeFileSync(s.namesDir(), name, bytes.NewReader(key)); err != nil {
+		return err
+	}
+	s.names[name] = string(key)
+	return nil
+}
+
+// unnameObject removes the raw key of an object named by its hash.  The lock
+// must be held.
+func (s *DiskKeyValueStore) unnameObject(key []byte) error {
+	name := diskName(key)
+	if _, ok := s.names[name]; !ok {
+		return nil
+	}
+	if err := removeFileSync(s.namesDir(), name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	delete(s.names, name)
+	return nil
+}
+
+// raftLogPath is the path of the records appended to the raft state of the group
+func (s *DiskKeyValueStore) raftLogPath(group string) string {
+	return filepath.Join(s.raftDir(), diskName([]byte(group))+".log")
+}
+
+// saveRaftState replaces the raft state of the group dropping the records
+// appended to the previous one.  Records of earlier generations left by a crash
+// before they are removed are ignored by the caller.
+func (s *DiskKeyValueStore) saveRaftState(group string, state []byte) error {
+	if err := writeFileSync(s.raftDir(), diskName([]byte(group)), bytes.NewReader(state)); err != nil {
+		return err
+	}
+	if err := os.Remove(s.raftLogPath(group)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// appendRaftLog appends a record to the raft state of the group.  A record only
+// partially written is truncated.
+func (s *DiskKeyValueStore) appendRaftLog(group string, rec []byte) error {
+	fpath := s.raftLogPath(group)
+	fi, err := os.Stat(fpath)
+	created := os.IsNotExist(err)
+
+	fh, err := os.OpenFile(fpath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if err = msgpack.NewEncoder(fh).Encode(rec); err == nil {
+		err = fh.Sync()
+	}
+	if err != nil {
+		var size int64
+		if fi != nil {
+			size = fi.Size()
+		}
+		err = mergeErrors(err, fh.Truncate(size))
+	}
+	if err = mergeErrors(err, fh.Close()); err != nil {
+		return err
+	}
+
+	if created {
+		return syncDir(s.raftDir())
+	}
+	return nil
+}
+
+// loadRaftState returns the raft state of the group along with the records
+// appended to it.  A partially written last record is dropped.
+func (s *DiskKeyValueStore) loadRaftState(group string) ([]byte, [][]byte, error) {
+	b, err := ioutil.ReadFile(filepath.Join(s.raftDir(), diskName([]byte(group))))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	lb, err := ioutil.ReadFile(s.raftLogPath(group))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return b, nil, nil
+		}
+		return nil, nil, err
+	}
+	var (
+		recs [][]byte
+		dec  = msgpack.NewDecoder(bytes.NewReader(lb))
+	)
+	for {
+		var rec []byte
+		if err = dec.Decode(&rec); err != nil {
+			break
+		}
+		recs = append(recs, rec)
+	}
+	return b, recs, nil
+}
+
+// savePreparedTxn writes the transaction prepared by the vnode
+func (s *DiskKeyValueStore) savePreparedTxn(id string, txn []byte) error {
+	return writeFileSync(s.txnDir(), diskName([]byte(id)), bytes.NewReader(txn))
+}
+
+// removePreparedTxn removes the transaction once decided
+func (s *DiskKeyValueStore) removePreparedTxn(id string) error {
+	if err := removeFileSync(s.txnDir(), diskName([]byte(id))); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// loadPreparedTxns returns the transactions prepared by the vnode and not yet
+// decided
+func (s *DiskKeyValueStore) loadPreparedTxns() ([][]byte, error) {
+	files, err := readDirFiles(s.txnDir())
+	if err != nil {
+		return nil, err
+	}
+	out := make([][]byte, 0, len(files))
+	for _, b := range files {
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+// GetObject returns a reader to the object.  The underlying file is closed once
+// the reader has been read to the end.
+func (s *DiskKeyValueStore) GetObject(key []byte) (io.Reader, error) {
+	rd, _, err := s.GetObjectRange(key, 0, 0)
+	return rd, err
+}
+
+// GetObjectRange returns a reader to length bytes of the object from offset, or
+// to the end if length < 1, along with the object size.  The underlying file is
+// closed once the range has been read.
+func (s *DiskKeyValueStore) GetObjectRange(key []byte, offset, length int64) (io.Reader, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	fh, err := os.Open(s.objectPath(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, fmt.Errorf("object not found: %x", key)
+		}
+		return nil, 0, err
+	}
+
+	fi, err := fh.Stat()
+	if err == nil && offset > 0 {
+		_, err = fh.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		fh.Close()
+		return nil, 0, err
+	}
+
+	fr := &fileReader{f: fh, n: -1}
+	if length > 0 {
+		fr.n = length
+	}
+	return fr, fi.Size(), nil
+}
+
+// PutObject with the given key with the data from the reader.  The data is
+// streamed to a temporary file and the store is only locked to rename it into
+// place so large objects do not block other operations.  The metadata is written
+// once the object is in place.
+func (s *DiskKeyValueStore) PutObject(key []byte, rd io.Reader, meta *ObjectMeta) error {
+	mr := newMetaReader(rd)
+	tmp, err := writeTempFile(s.objectsDir(), diskName(key), mr)
+	if err != nil {
+		return err
+	}
+	return s.placeObject(key, tmp, mr.meta(meta))
+}
+
+// placeObject renames the temporary file of the object into place and writes
+// its metadata
+func (s *DiskKeyValueStore) placeObject(key []byte, tmp string, meta *ObjectMeta) error {
+	b, err := msgpack.Marshal(meta)
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err = s.nameObject(key); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err = os.Rename(tmp, s.objectPath(key)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err = syncDir(s.objectsDir()); err != nil {
+		return err
+	}
+	s.objects.add(string(key))
+	return writeFileSync(s.metaDir(), diskName(key), bytes.NewReader(b))
+}
+
+// StatObject returns the metadata of the object.  It is computed from the data
+// if the metadata file is missing, as for objects written by older versions.
+func (s *DiskKeyValueStore) StatObject(key []byte) (*ObjectMeta, error) {
+	name := diskName(key)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	b, err := ioutil.ReadFile(filepath.Join(s.metaDir(), name))
+	if err == nil {
+		var meta ObjectMeta
+		if err = msgpack.Unmarshal(b, &meta); err != nil {
+			return nil, err
+		}
+		return &meta, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	fh, err := os.Open(filepath.Join(s.objectsDir(), name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("object not found: %x", key)
+		}
+		return nil, err
+	}
+	defer fh.Close()
+
+	mr := newMetaReader(fh)
+	if _, err = io.Copy(ioutil.Discard, mr); err != nil {
+		return nil, err
+	}
+	meta := mr.meta(nil)
+	if fi, err := fh.Stat(); err == nil {
+		meta.Mtime = fi.ModTime().UnixNano()
+	}
+	return meta, nil
+}
+
+// RemoveObject with the given key along with its metadata
+func (s *DiskKeyValueStore) RemoveObject(key []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := diskName(key)
+	err := removeFileSync(s.objectsDir(), name)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("object not found: %x", key)
+	}
+	if err != nil {
+		return err
+	}
+	s.objects.remove(string(key))
+
+	if err = removeFileSync(s.metaDir(), name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return s.unnameObject(key)
+}
+
+// ListKeys returns the sorted keys with the prefix after the cursor
+func (s *DiskKeyValueStore) ListKeys(prefix, cursor []byte, limit int) ([][]byte, error) {
+	now := time.Now().UnixNano()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.keys.page(prefix, cursor, limit, func(k string) bool {
+		return expired(s.ex[k], now) || hiddenKey(k, prefix)
+	}), nil
+}
+
+// ListObjects returns the sorted object keys with the prefix after the cursor
+func (s *DiskKeyValueStore) ListObjects(prefix, cursor []byte, limit int) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.objects.page(prefix, cursor, limit, nil), nil
+}
+
+// GetKey from datastore.  An expired key is removed.
+func (s *DiskKeyValueStore) GetKey(key []byte) ([]byte, error) {
+	now := time.Now().UnixNano()
+
+	s.mu.RLock()
+	if !expired(s.ex[string(key)], now) {
+		defer s.mu.RUnlock()
+		return s.readKey(key)
+	}
+	s.mu.RUnlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if expired(s.ex[string(key)], now) {
+		if err := s.expireKey(key); err != nil {
+			return nil, err
+		}
+	}
+	return s.readKey(key)
+}
+
+// KeyExpiry returns the expiry of the key or 0 if it does not expire
+func (s *DiskKeyValueStore) KeyExpiry(key []byte) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e := s.ex[string(key)]
+	if expired(e, time.Now().UnixNano()) || !s.keys.has(string(key)) {
+		return 0, fmt.Errorf("key not found: %s", key)
+	}
+	return e, nil
+}
+
+// ExpireKeys removes all expired keys
+func (s *DiskKeyValueStore) ExpireKeys() (int, error) {
+	now := time.Now().UnixNano()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int
+	for k, e := range s.ex {
+		if !expired(e, now) {
+			continue
+		}
+		if err := s.expireKey([]byte(k)); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// expireKey removes the key recording it as expired.  The lock must be held.
+func (s *DiskKeyValueStore) expireKey(key []byte) error {
+	rec, err := s.readRecord(key)
+	if err != nil {
+		return err
+	}
+	if !rec.Live {
+		return s.commit(rec, nil)
+	}
+	txn := newKeyTxn(s.vn, nil, TxnOpExpire, valueHash(rec.Value), nil)
+	rec.Live = false
+	return s.commit(rec, txn)
+}
+
+// readRecord returns the record of the key or a new one if it has none
+func (s *DiskKeyValueStore) readRecord(key []byte) (*diskKey, error) {
+	b, err := ioutil.ReadFile(filepath.Join(s.keysDir(), diskName(key)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &diskKey{Key: key}, nil
+		}
+		return nil, err
+	}
+	var rec diskKey
+	err = msgpack.Unmarshal(b, &rec)
+	return &rec, err
+}
+
+func (s *DiskKeyValueStore) readKey(key []byte) ([]byte, error) {
+	rec, err := s.readRecord(key)
+	if err != nil {
+		return nil, err
+	}
+	if !rec.Live {
+		return nil, fmt.Errorf("key not found: %s", key)
+	}
+	return rec.Value, nil
+}
+
+// commit appends the entry, if any, to the transaction log of the key then
+// writes its record.  The entry is truncated if the record cannot be written.
+// The lock must be held.
+func (s *DiskKeyValueStore) commit(rec *diskKey, txn *KeyTxn) error {
+	if !rec.Live {
+		rec.Value, rec.Expiry, rec.Versions = nil, 0, nil
+	}
+
+	k := string(rec.Key)
+	logged := s.lc[k]
+	if txn != nil {
+		if err := s.appendTxn(rec.Key, txn); err != nil {
+			return err
+		}
+	}
+	rec.Logged = s.lc[k]
+
+	b, err := msgpack.Marshal(rec)
+	if err == nil {
+		err = writeFileSync(s.keysDir(), diskName(rec.Key), bytes.NewReader(b))
+	}
+	if err != nil {
+		if txn != nil {
+			_, terr := repairTxnLog(s.txlogPath(rec.Key), logged)
+			err = mergeErrors(err, terr)
+			s.lc[k] = logged
+		}
+		return err
+	}
+
+	setKeyTime(s.ex, k, rec.Expiry)
+	setKeyTime(s.ts, k, rec.Tombstone)
+	if rec.Live {
+		s.keys.add(k)
+		s.mt.Set(rec.Key, merkleValue(rec.Value, rec.Versions))
+	} else {
+		s.keys.remove(k)
+		s.mt.Delete(rec.Key)
+	}
+	return nil
+}
+
+// setKeyTime sets a per key timestamp in the map, removing it if 0
+func setKeyTime(times map[string]int64, k string, t int64) {
+	if t > 0 {
+		times[k] = t
+	} else {
+		delete(times, k)
+	}
+}
+
+// PutKey key-value expiring at the given time in unix nanoseconds.  0 never
+// expires.
+func (s *DiskKeyValueStore) PutKey(key []byte, v []byte, expiry int64, ws *WriteStamp) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, err := s.readRecord(key)
+	if err != nil {
+		return err
+	}
+	var prevHash []byte
+	if rec.Live {
+		prevHash = valueHash(rec.Value)
+	}
+
+	rec.Value, rec.Live, rec.Expiry, rec.Tombstone, rec.Versions = v, true, expiry, 0, nil
+	return s.commit(rec, newKeyTxn(s.vn, ws, TxnOpPut, prevHash, valueHash(v)))
+}
+
+// UpdateKey that exists.  previousHash is the hash of the previous value of the
+// key.
+func (s *DiskKeyValueStore) UpdateKey(prevHash, key, value []byte, ws *WriteStamp) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, err := s.readRecord(key)
+	if err != nil {
+		return err
+	}
+	if !rec.Live {
+		return fmt.Errorf("key not found: %s", key)
+	}
+
+	pv := sha256.Sum256(rec.Value)
+	if bytes.Equal(pv[:], prevHash) {
+		rec.Value, rec.Versions = value, nil
+		return s.commit(rec, newKeyTxn(s.vn, ws, TxnOpUpdate, pv[:], valueHash(value)))
+	}
+
+	return fmt.Errorf("invalid previous hash: %x != %x", pv, prevHash)
+}
+
+// RemoveKey a key from the datastore leaving a tombstone.  The tombstone is
+// recorded even if the key does not exist, the same as the MemKeyValueStore.
+func (s *DiskKeyValueStore) RemoveKey(key []byte, ws *WriteStamp) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, err := s.readRecord(key)
+	if err != nil {
+		return err
+	}
+	rec.Tombstone = stampTime(ws)
+	if !rec.Live {
+		// Nothing to remove
+		return s.commit(rec, nil)
+	}
+	txn := newKeyTxn(s.vn, ws, TxnOpRemove, valueHash(rec.Value), nil)
+	rec.Live = false
+	return s.commit(rec, txn)
+}
+
+// PruneKey removes a key the vnode no longer replicates along with its history.
+// No tombstone is recorded.
+func (s *DiskKeyValueStore) PruneKey(key []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The record goes last so a log is never left without one
+	name := diskName(key)
+	for _, d := range []string{s.txlogDir(), s.keysDir()} {
+		if err := removeFileSync(d, name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	k := string(key)
+	delete(s.lc, k)
+	delete(s.ex, k)
+	s.keys.remove(k)
+	s.mt.Delete(key)
+	return nil
+}
+
+// KeyTombstone returns the time the key was removed or 0 if it has no tombstone
+func (s *DiskKeyValueStore) KeyTombstone(key []byte) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.ts[string(key)], nil
+}
+
+// PurgeTombstones removes tombstones of keys removed before the given time
+func (s *DiskKeyValueStore) PurgeTombstones(before int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int
+	for k, t := range s.ts {
+		if t >= before {
+			continue
+		}
+		rec, err := s.readRecord([]byte(k))
+		if err != nil {
+			return n, err
+		}
+		rec.Tombstone = 0
+		if err = s.commit(rec, nil); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// GetVersions returns the siblings of the key.  A key written without a version
+// has a single sibling with its value.
+func (s *DiskKeyValueStore) GetVersions(key []byte) ([]*Sibling, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if expired(s.ex[string(key)], time.Now().UnixNano()) {
+		return nil, fmt.Errorf("key not found: %s", key)
+	}
+	rec, err := s.readRecord(key)
+	if err != nil {
+		return nil, err
+	}
+	if !rec.Live {
+		return nil, fmt.Errorf("key not found: %s", key)
+	}
+	if len(rec.Versions) > 0 {
+		return rec.Versions, nil
+	}
+	return []*Sibling{&Sibling{Value: rec.Value}}, nil
+}
+
+// PutVersion writes a new version of the key by this vnode superseding the
+// versions in ctx
+func (s *DiskKeyValueStore) PutVersion(key, value []byte, ctx VectorClock, ws *WriteStamp) (*Sibling, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, err := s.readRecord(key)
+	if err != nil {
+		return nil, err
+	}
+	sib := newSibling(s.vn.StringID(), rec.versions(), value, ctx, ws)
+	return sib, s.addSibling(rec, sib)
+}
+
+// AddSibling adds a version written on another replica
+func (s *DiskKeyValueStore) AddSibling(key []byte, sib *Sibling) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, err := s.readRecord(key)
+	if err != nil {
+		return err
+	}
+	return s.addSibling(rec, sib)
+}
+
+// addSibling merges the version into the current siblings of the key writing the
+// value of the latest.  Versioned keys do not expire.  The lock must be held.
+func (s *DiskKeyValueStore) addSibling(rec *diskKey, sib *Sibling) error {
+	sibs, ok := addSibling(rec.versions(), sib)
+	if !ok {
+		return nil
+	}
+
+	var prevHash []byte
+	if rec.Live {
+		prevHash = valueHash(rec.Value)
+	}
+
+	v := versionedValue(sibs)
+	rec.Value, rec.Live, rec.Expiry, rec.Tombstone, rec.Versions = v, true, 0, 0, sibs
+	return s.commit(rec, newKeyTxn(s.vn, siblingStamp(sib), TxnOpPut, prevHash, valueHash(v)))
+}
+
+// KeyHistory returns the transaction log for the key
+func (s *DiskKeyValueStore) KeyHistory(key []byte) ([]*KeyTxn, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	txns, err := readTxnLog(s.txlogPath(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("key not found: %s", key)
+		}
+		return nil, err
+	}
+	return txns, nil
+}
+
+// appendTxn appends the entry to the key's transaction log and fsyncs it.  A
+// failed append is truncated so later entries are not hidden behind it.  The
+// lock must be held.
+func (s *DiskKeyValueStore) appendTxn(key []byte, txn *KeyTxn) error {
+	fpath := s.txlogPath(key)
+	fi, err := os.Stat(fpath)
+	created := os.IsNotExist(err)
+
+	fh, err := os.OpenFile(fpath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	if err = msgpack.NewEncoder(fh).Encode(txn); err == nil {
+		err = fh.Sync()
+	}
+	if err != nil {
+		var size int64
+		if fi != nil {
+			size = fi.Size()
+		}
+		err = mergeErrors(err, fh.Truncate(size))
+	}
+	if err = mergeErrors(err, fh.Close()); err != nil {
+		return err
+	}
+
+	if created {
+		if err = syncDir(s.txlogDir()); err != nil {
+			return err
+		}
+	}
+	s.lc[string(key)]++
+	return nil
+}
+
+// MerkleTree returns the hash tree over the keys
+func (s *DiskKeyValueStore) MerkleTree() *MerkleTree {
+	return s.mt
+}
+
+// Snapshot the keys in the range serializing and compressing them to the writer.
+// The format is the same as that of the MemKeyValueStore.  Objects are filtered
+// by key and streamed from their files.
+func (s *DiskKeyValueStore) Snapshot(wr io.Writer, kr *KeyRange) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := map[string][]byte{}
+	versions := map[string][]*Sibling{}
+	for _, k := range s.keys.keys {
+		if !kr.ContainsKey([]byte(k)) {
+			continue
+		}
+		rec, err := s.readRecord([]byte(k))
+		if err != nil {
+			return err
+		}
+		keys[k] = rec.Value
+		if len(rec.Versions) > 0 {
+			versions[k] = rec.Versions
+		}
+	}
+	logs := map[string][]*KeyTxn{}
+	for k := range s.lc {
+		if !kr.ContainsKey([]byte(k)) {
+			continue
+		}
+		txns, err := readTxnLog(s.txlogPath([]byte(k)))
+		if err != nil {
+			return err
+		}
+		logs[k] = txns
+	}
+
+	var objects []string
+	for _, k := range s.objects.keys {
+		if kr.Contains([]byte(k)) {
+			objects = append(objects, k)
+		}
+	}
+
+	tombstones := filterKeyTimes(kr, s.ts)
+	if len(keys) == 0 && len(objects) == 0 && len(tombstones) == 0 {
+		return io.EOF
+	}
+
+	// Objects are named by their hex encoded key in snapshots
+	om := make(map[string]*ObjectMeta, len(objects))
+	for _, k := range objects {
+		b, err := ioutil.ReadFile(filepath.Join(s.metaDir(), diskName([]byte(k))))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		var meta ObjectMeta
+		if err = msgpack.Unmarshal(b, &meta); err != nil {
+			return err
+		}
+		om[hex.EncodeToString([]byte(k))] = &meta
+	}
+
+	log.Printf("DBG [snapshot] vnode=%s range=%s keys=%d objects=%d tombstones=%d", s.vn.StringID(), kr, len(keys), len(objects), len(tombstones))
+
+	sw, err := encodeSnapshot(wr, &vnodeSnapshot{
+		Keys:       keys,
+		Logs:       logs,
+		Metas:      om,
+		Expiries:   keyExpiries(keys, s.ex),
+		Tombstones: tombstones,
+		Versions:   versions,
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range objects {
+		if err = s.snapshotObject(sw, []byte(k)); err != nil {
+			return err
+		}
+	}
+	return sw.Close()
+}
+
+// snapshotObject streams the object file to the snapshot
+func (s *DiskKeyValueStore) snapshotObject(sw *snapshotWriter, key []byte) error {
+	fh, err := os.Open(s.objectPath(key))
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	fi, err := fh.Stat()
+	if err != nil {
+		return err
+	}
+	return sw.writeObject(hex.EncodeToString(key), fi.Size(), fh)
+}
+
+// Restore dataset from reader merging it with the existing data.  Existing keys
+// and objects are overwritten.  Transaction logs, tombstones, versions and
+// conflicting values are handled the same as the MemKeyValueStore.  Objects are
+// streamed to their files after the keys are restored.
+func (s *DiskKeyValueStore) Restore(r io.Reader) error {
+	snap, err := decodeSnapshot(r)
+	if err != nil {
+		return err
+	}
+	defer snap.Close()
+
+	if err = s.restoreKeys(snap.vnodeSnapshot); err != nil {
+		return err
+	}
+
+	var n int
+	for ; ; n++ {
+		name, rd, err := snap.nextObject()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if err = s.restoreObject(name, rd, snap.Metas[name]); err != nil {
+			return err
+		}
+	}
+	log.Printf("DBG [restore] Received vnode=%s keys=%d objects=%d tombstones=%d", s.vn.StringID(), len(snap.Keys), n, len(snap.Tombstones))
+	return nil
+}
+
+// restoreObject writes the object and its metadata.  The metadata is computed
+// from the data if the snapshot has none.  name must be a valid hex encoded key.
+func (s *DiskKeyValueStore) restoreObject(name string, rd io.Reader, meta *ObjectMeta) error {
+	key, err := hex.DecodeString(name)
+	if err != nil {
+		return err
+	}
+	mr := newMetaReader(rd)
+	tmp, err := writeTempFile(s.objectsDir(), diskName(key), mr)
+	if err != nil {
+		return err
+	}
+	if meta == nil {
+		meta = mr.meta(nil)
+	}
+	return s.placeObject(key, tmp, meta)
+}
+
+// restoreKeys merges the keys, logs and tombstones of the snapshot
+func (s *DiskKeyValueStore) restoreKeys(snap *vnodeSnapshot) error {
+	tk, tl, te := snap.Keys, snap.Logs, snap.Expiries
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, v := range tk {
+		key := []byte(k)
+		if t, ok := s.ts[k]; ok && t >= keyWriteTime(tl[k]) {
+			// Removed after the value was written
+			continue
+		}
+
+		rec, err := s.readRecord(key)
+		if err != nil {
+			return err
+		}
+		cv, exists := rec.Value, rec.Live
+		sibs := snap.Versions[k]
+		if exists && len(sibs) == 0 && !bytes.Equal(cv, v) {
+			txns, _ := readTxnLog(s.txlogPath(key))
+			if v = resolveRestore(s.resolver, key, cv, v, txns, tl[k]); bytes.Equal(v, cv) {
+				// Local value wins
+				continue
+			}
+		}
+
+		if len(sibs) > 0 {
+			sibs = mergeSiblings(rec.versions(), sibs)
+			v = versionedValue(sibs)
+		}
+
+		var txn *KeyTxn
+		if s.lc[k] == 0 && len(tl[k]) > 0 {
+			if err = s.writeTxnLog(key, tl[k]); err != nil {
+				return err
+			}
+		} else {
+			txn = restoreKeyTxn(s.vn, cv, exists, v)
+		}
+
+		rec.Value, rec.Live, rec.Expiry, rec.Tombstone, rec.Versions = v, true, te[k], 0, sibs
+		if err = s.commit(rec, txn); err != nil {
+			return err
+		}
+	}
+	// History of removed keys
+	for k, txns := range tl {
+		if s.lc[k] > 0 {
+			continue
+		}
+		rec, err := s.readRecord([]byte(k))
+		if err != nil {
+			return err
+		}
+		if err = s.writeTxnLog([]byte(k), txns); err != nil {
+			return err
+		}
+		if err = s.commit(rec, nil); err != nil {
+			return err
+		}
+	}
+	for k, t := range snap.Tombstones {
+		if err := s.restoreTombstone([]byte(k), t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreTombstone removes the key if it was last written before the removal
+// time and records the tombstone.  The lock must be held.
+func (s *DiskKeyValueStore) restoreTombstone(key []byte, t int64) error {
+	rec, err := s.readRecord(key)
+	if err != nil {
+		return err
+	}
+
+	var txn *KeyTxn
+	if rec.Live {
+		txns, _ := readTxnLog(s.txlogPath(key))
+		if keyWriteTime(txns) >= t {
+			// Written after the removal
+			return nil
+		}
+		txn = newKeyTxn(s.vn, nil, TxnOpRemove, valueHash(rec.Value), nil)
+		rec.Live = false
+	} else if t <= rec.Tombstone {
+		return nil
+	}
+	if t > rec.Tombstone {
+		rec.Tombstone = t
+	}
+	return s.commit(rec, txn)
+}
+
+// writeTxnLog atomically replaces the key's transaction log with the given one.
+// The lock must be held.
+func (s *DiskKeyValueStore) writeTxnLog(key []byte, txns []*KeyTxn) error {
+	buf := new(bytes.Buffer)
+	enc := msgpack.NewEncoder(buf)
+	for _, txn := range txns {
+		if err := enc.Encode(txn); err != nil {
+			return err
+		}
+	}
+	if err := writeFileSync(s.txlogDir(), diskName(key), buf); err != nil {
+		return err
+	}
+	s.lc[string(key)] = len(txns)
+	return nil
+}
+
+// readTxnLog reads all entries from a transaction log file.  A partially
+// written trailing entry, as left behind by a crash, is ignored.
+func readTxnLog(fpath string) ([]*KeyTxn, error) {
+	b, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		return nil, err
+	}
+	txns, n := decodeTxnLog(b, -1)
+	if n < len(b) {
+		log.Printf("ERR [disk] Truncated transaction log %s at %d/%d", fpath, n, len(b))
+	}
+	return txns, nil
+}
+
+// decodeTxnLog decodes up to max entries of a transaction log, or all if max <
+// 0, returning them along with the length of the log up to the end of the last
+// one
+func decodeTxnLog(b []byte, max int) ([]*KeyTxn, int) {
+	// bytes.Reader is not buffered further by the decoder so the offset is exact
+	br := bytes.NewReader(b)
+	dec := msgpack.NewDecoder(br)
+
+	txns := []*KeyTxn{}
+	var n int
+	for max < 0 || len(txns) < max {
+		var txn KeyTxn
+		if err := dec.Decode(&txn); err != nil {
+			break
+		}
+		txns = append(txns, &txn)
+		n = len(b) - br.Len()
+	}
+	return txns, n
+}
+
+// repairTxnLog truncates a transaction log after its first max entries.  This
+// drops the entries of writes whose record was never written as well as a
+// partially written entry left behind by a crash, which would otherwise hide
+// later entries.  The number of entries kept is returned.
+func repairTxnLog(fpath string, max int) (int, error) {
+	b, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	txns, n := decodeTxnLog(b, max)
+	if n < len(b) {
+		log.Printf("ERR [disk] Repairing transaction log %s at %d/%d", fpath, n, len(b))
+		if err = os.Truncate(fpath, int64(n)); err != nil {
+			return 0, err
+		}
+	}
+	return len(txns), nil
+}
+
+// readDirFiles reads all files in the directory by name
+func readDirFiles(dir string) (map[string][]byte, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string][]byte, len(files))
+	for _, fi := range files {
+		name := fi.Name()
+		if fi.IsDir() || strings.HasPrefix(name, diskTmpPrefix) {
+			continue
+		}
+		if out[name], err = ioutil.ReadFile(filepath.Join(dir, name)); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// writeFileSync atomically writes the file and syncs the directory so the rename
+// itself is durable.
+func writeFileSync(dir, name string, rd io.Reader) error {
+	if err := writeFile(dir, name, rd); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// writeFile writes the data from the reader to a temp file, fsyncs it and renames
+// it to the given name.  The directory itself is not synced.
+func writeFile(dir, name string, rd io.Reader) error {
+	tmp, err := writeTempFile(dir, name, rd)
+	if err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmp, filepath.Join(dir, name)); err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// writeTempFile writes and fsyncs the reader to a temporary file in the
+// directory returning its path.  The file is removed on error.
+func writeTempFile(dir, name string, rd io.Reader) (string, error) {
+	fh, err := ioutil.TempFile(dir, diskTmpPrefix+name)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err = io.Copy(fh, rd); err == nil {
+		err = fh.Sync()
+	}
+	if err = mergeErrors(err, fh.Close()); err != nil {
+		os.Remove(fh.Name())
+		return "", err
+	}
+	return fh.Name(), nil
+}
+
+// removeFileSync removes the file and syncs the directory.  The error returned
+// is that of os.Remove when it fails so callers may check os.IsNotExist.
+func removeFileSync(dir, name string) error {
+	if err := os.Remove(filepath.Join(dir, name)); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+func syncDir(dir string) error {
+	fh, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	return mergeErrors(fh.Sync(), fh.Close())
+}
+
+// removeTmpFiles removes partially written files left behind by a crash
+func removeTmpFiles(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range files {
+		if strings.HasPrefix(fi.Name(), diskTmpPrefix) {
+			if err = os.Remove(filepath.Join(dir, fi.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fileReader closes the underlying file once it has been read to the end or a
+// read error occurs.
+type fileReader struct {
+	f *os.File
+	// bytes left to read.  -1 reads to the end of the file.
+	n int64
+}
+
+func (fr *fileReader) Read(p []byte) (int, error) {
+	if fr.n == 0 {
+		fr.f.Close()
+		return 0, io.EOF
+	}
+	if fr.n > 0 && int64(len(p)) > fr.n {
+		p = p[:fr.n]
+	}
+
+	n, err := fr.f.Read(p)
+	if fr.n > 0 {
+		fr.n -= int64(n)
+	}
+	if err != nil {
+		fr.f.Close()
+	}
+	return n, err
+}
+
+// Close the file for readers not read to the end
+func (fr *fileReader) Close() error {
+	fr.f.Close()
+	return nil
+}
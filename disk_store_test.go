@@ -0,0 +1,309 @@
+package chordstore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/vmihailenco/msgpack.v2"
+)
+
+func Test_DiskKeyValueStore(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "chordstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	st := &DiskKeyValueStore{DataDir: tmpdir}
+	kvs, err := st.New(testVn1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for k, v := range testKeyValue {
+		if err = kvs.PutKey([]byte(k), v, 0, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	meta := &ObjectMeta{ContentType: "text/plain", Headers: map[string]string{"Owner": "test"}}
+	if err = kvs.PutObject([]byte("object"), bytes.NewBufferString("object data"), meta); err != nil {
+		t.Fatal(err)
+	}
+
+	ph := sha256.Sum256([]byte("foo"))
+	if err = kvs.UpdateKey(ph[:], []byte("foo"), []byte("foo2"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = kvs.UpdateKey(ph[:], []byte("foo"), []byte("foo3"), nil); err == nil {
+		t.Fatal("should fail with invalid previous hash")
+	}
+	if err = kvs.RemoveKey([]byte("xyz"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Re-open existing data
+	kvs, err = st.New(testVn1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	val, err := kvs.GetKey([]byte("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "foo2" {
+		t.Fatal("value mismatch", string(val))
+	}
+	if _, err = kvs.GetKey([]byte("xyz")); err == nil {
+		t.Fatal("key should not exist")
+	}
+
+	txns, err := kvs.KeyHistory([]byte("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txns) != 2 || txns[1].Op != TxnOpUpdate {
+		t.Fatal("history mismatch", txns)
+	}
+	if err = VerifyKeyTxns(txns); err != nil {
+		t.Fatal(err)
+	}
+	if txns, _ = kvs.KeyHistory([]byte("xyz")); len(txns) != 2 || txns[1].Op != TxnOpRemove {
+		t.Fatal("history mismatch", txns)
+	}
+
+	rd, err := kvs.GetObject([]byte("object"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(rd)
+	if string(b) != "object data" {
+		t.Fatal("object mismatch", string(b))
+	}
+
+	rd, size, err := kvs.GetObjectRange([]byte("object"), 7, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ = ioutil.ReadAll(rd)
+	if string(b) != "dat" || size != 11 {
+		t.Fatal("range mismatch", string(b), size)
+	}
+	rd, _, _ = kvs.GetObjectRange([]byte("object"), 7, 0)
+	if b, _ = ioutil.ReadAll(rd); string(b) != "data" {
+		t.Fatal("range mismatch", string(b))
+	}
+
+	om, err := kvs.StatObject([]byte("object"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oh := sha256.Sum256([]byte("object data"))
+	if om.Size != 11 || !bytes.Equal(om.Hash, oh[:]) || om.ContentType != "text/plain" || om.Headers["Owner"] != "test" || om.Mtime == 0 {
+		t.Fatal("meta mismatch", om)
+	}
+
+	// Restore to an in-memory store
+	buf := new(bytes.Buffer)
+	if err = kvs.Snapshot(buf, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	ms, _ := (&MemKeyValueStore{}).New(testVn2)
+	if err = ms.Restore(buf); err != nil {
+		t.Fatal(err)
+	}
+	mkvs := ms.(*MemKeyValueStore)
+	if len(mkvs.m) != 4 || len(mkvs.o) != 1 {
+		t.Fatal("count mismatch", len(mkvs.m), len(mkvs.o))
+	}
+	if m, _ := mkvs.StatObject([]byte("object")); m == nil || m.Mtime != om.Mtime || m.ContentType != "text/plain" {
+		t.Fatal("meta not restored", m)
+	}
+
+	// Restore back to a new disk store
+	buf.Reset()
+	if err = mkvs.Snapshot(buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	kvs2, err := st.New(testVn2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = kvs2.Restore(buf); err != nil {
+		t.Fatal(err)
+	}
+	val, _ = kvs2.GetKey([]byte("bizzle"))
+	if string(val) != "bizzle" {
+		t.Fatal("value mismatch", string(val))
+	}
+	if txns, _ = kvs2.KeyHistory([]byte("foo")); len(txns) != 2 {
+		t.Fatal("history not restored", txns)
+	}
+
+	rd, err = kvs2.GetObject([]byte("object"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(rd); string(b) != "object data" {
+		t.Fatal("object not restored", string(b))
+	}
+
+	if err = kvs2.RemoveObject([]byte("object")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = kvs2.GetObject([]byte("object")); err == nil {
+		t.Fatal("object should not exist")
+	}
+}
+
+func Test_DiskKeyValueStore_RestoreObjectKey(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "chordstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	kvs, err := (&DiskKeyValueStore{DataDir: tmpdir}).New(testVn1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	sw, err := encodeSnapshot(buf, &vnodeSnapshot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = sw.writeObject("../../x", 4, bytes.NewBufferString("data")); err != nil {
+		t.Fatal(err)
+	}
+	sw.Close()
+
+	if err = kvs.Restore(buf); err == nil {
+		t.Fatal("should reject invalid object key")
+	}
+	if _, err = os.Stat(filepath.Join(tmpdir, "x")); !os.IsNotExist(err) {
+		t.Fatal("file written outside the store", err)
+	}
+}
+
+func Test_DiskKeyValueStore_TxnLog(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "chordstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	st := &DiskKeyValueStore{DataDir: tmpdir}
+	kvs, err := st.New(testVn1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := []byte("key")
+	writes := 200
+	for i := 0; i < writes; i++ {
+		if err = kvs.PutKey(key, []byte(fmt.Sprintf("v%d", i)), 0, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	txns, err := kvs.KeyHistory(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every write is kept
+	if len(txns) != writes || keyWriteCount(txns) != writes {
+		t.Fatal("write count mismatch", keyWriteCount(txns))
+	}
+	if err = VerifyKeyTxns(txns); err != nil {
+		t.Fatal(err)
+	}
+
+	// A torn entry left by a crash is truncated on open
+	fh, err := os.OpenFile(filepath.Join(tmpdir, testVn1.StringID(), diskTxlogDir, hex.EncodeToString(key)), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fh.Write([]byte{0x85, 0xa2})
+	fh.Close()
+
+	if kvs, err = st.New(testVn1); err != nil {
+		t.Fatal(err)
+	}
+	if err = kvs.RemoveKey(key, nil); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := kvs.KeyHistory(key)
+	if len(after) != len(txns)+1 || after[len(after)-1].Op != TxnOpRemove {
+		t.Fatal("entry after torn entry hidden", len(txns), len(after))
+	}
+}
+
+func Test_DiskKeyValueStore_Records(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "chordstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	st := &DiskKeyValueStore{DataDir: tmpdir}
+	kvs, err := st.New(testVn1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Keys too long for a hex encoded file name
+	long := bytes.Repeat([]byte("k"), 300)
+	if err = kvs.PutKey(long, []byte("long"), 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = kvs.PutObject(long, bytes.NewBufferString("object"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = kvs.PutKey([]byte("key"), []byte("v1"), 0, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// An entry appended by a write whose record was never written is dropped
+	// on open
+	b, _ := msgpack.Marshal(newKeyTxn(testVn1, nil, TxnOpPut, valueHash([]byte("v1")), valueHash([]byte("v2"))))
+	fh, err := os.OpenFile(filepath.Join(tmpdir, testVn1.StringID(), diskTxlogDir, hex.EncodeToString([]byte("key"))), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fh.Write(b)
+	fh.Close()
+
+	if kvs, err = st.New(testVn1); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := kvs.GetKey(long); err != nil || string(v) != "long" {
+		t.Fatal("long key not found", err)
+	}
+	objs, _ := kvs.ListObjects(nil, nil, 0)
+	if len(objs) != 1 || !bytes.Equal(objs[0], long) {
+		t.Fatal("long object not listed", len(objs))
+	}
+	if v, err := kvs.GetKey([]byte("key")); err != nil || string(v) != "v1" {
+		t.Fatal("value mismatch", string(v), err)
+	}
+	if txns, _ := kvs.KeyHistory([]byte("key")); len(txns) != 1 {
+		t.Fatal("orphan entry kept", len(txns))
+	}
+
+	// Pages follow the cursor
+	keys, _ := kvs.ListKeys(nil, nil, 1)
+	if len(keys) != 1 || string(keys[0]) != "key" {
+		t.Fatal("first page mismatch", len(keys))
+	}
+	keys, _ = kvs.ListKeys(nil, keys[0], 1)
+	if len(keys) != 1 || !bytes.Equal(keys[0], long) {
+		t.Fatal("second page mismatch", len(keys))
+	}
+}
@@ -0,0 +1,553 @@
+package chordstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"sync"
+
+	chord "github.com/euforia/go-chord"
+	context "golang.org/x/net/context"
+)
+
+// Bytes of a stripe stored in each shard.  A stripe holds k pieces of object
+// data.
+const erasurePieceSize = 64 << 10
+
+// shardMagic prefixes stored shards to distinguish them from object data
+var shardMagic = []byte("\x00chordstore-shard\x00")
+
+// Bytes of the magic and header at the start of a shard
+var shardHeaderSize = len(shardMagic) + 7
+
+// shardHeader follows the magic at the start of every shard.  It is followed by
+// the stripes, each a 4 byte length of the object data in the stripe and the
+// piece of the stripe belonging to the shard.
+type shardHeader struct {
+	K, M      int
+	Index     int
+	PieceSize int
+}
+
+func (sh *shardHeader) encode() []byte {
+	b := make([]byte, shardHeaderSize)
+	n := copy(b, shardMagic)
+	b[n], b[n+1], b[n+2] = byte(sh.K), byte(sh.M), byte(sh.Index)
+	binary.BigEndian.PutUint32(b[n+3:], uint32(sh.PieceSize))
+	return b
+}
+
+// readShardHeader reads the remainder of a header following the magic
+func readShardHeader(rd io.Reader) (*shardHeader, error) {
+	b := make([]byte, 7)
+	if _, err := io.ReadFull(rd, b); err != nil {
+		return nil, err
+	}
+	sh := &shardHeader{
+		K:         int(b[0]),
+		M:         int(b[1]),
+		Index:     int(b[2]),
+		PieceSize: int(binary.BigEndian.Uint32(b[3:])),
+	}
+	if sh.K < 1 || sh.M < 1 || sh.Index >= sh.K+sh.M || sh.PieceSize < 1 {
+		return nil, fmt.Errorf("invalid shard header: %+v", *sh)
+	}
+	return sh, nil
+}
+
+// shardStream is an open shard positioned after its header
+type shardStream struct {
+	hdr *shardHeader
+	rd  io.Reader
+	// stripes read so far
+	stripe int
+	failed bool
+}
+
+// Read implements io.Reader so a shard can be returned in place of an object
+// reader
+func (ss *shardStream) Read(p []byte) (int, error) {
+	return ss.rd.Read(p)
+}
+
+// Close the underlying reader
+func (ss *shardStream) Close() error {
+	return closeReader(ss.rd)
+}
+
+// PutErasure stores the object as k data and m parity shards, one on each of the
+// k+m successor vnodes of the key.  The object is encoded a stripe at a time so
+// only one stripe is held in memory.  It fails if fewer than k shards are stored
+// as the object could not be read back.  Otherwise shards that failed are queued
+// to be rebuilt.  The content type and headers of the metadata are stored with
+// each shard.
+func (cs *ChordStore) PutErasure(key []byte, rd io.Reader, meta *ObjectMeta) ([]*VnodeDataIO, error) {
+	if cs.rs == nil {
+		return nil, fmt.Errorf("erasure coding disabled")
+	}
+	k, m := cs.rs.k, cs.rs.m
+
+	vns, err := cs.lookup(k+m, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(vns) < k+m {
+		return nil, fmt.Errorf("not enough vnodes for %d+%d shards: %d", k, m, len(vns))
+	}
+
+	meta = userMeta(meta)
+	meta.Encoding = ObjectEncodingErasure
+	vds := cs.putStreams(vns, key, putMeta(meta), func(pws []*io.PipeWriter) {
+		encodeShards(rd, cs.rs, erasurePieceSize, pws)
+	})
+
+	var (
+		ok     int
+		failed *chord.Vnode
+	)
+	for _, vd := range vds {
+		if vd.Err == nil {
+			ok++
+		} else if failed == nil {
+			failed = vd.Vnode
+		}
+	}
+	if ok < k {
+		return vds, fmt.Errorf("object stored on %d/%d shards", ok, k+m)
+	}
+	if failed != nil {
+		cs.enqueueHeal(HealRequest{Type: HealTypeShard, Vnode: failed, Key: key})
+	}
+	return vds, nil
+}
+
+// encodeShards writes the shard header and stripes of the reader to the writer
+// of each shard.  Shards that fail are dropped.  All writers are closed once the
+// reader is exhausted, with the read error if it is not io.EOF.
+func encodeShards(rd io.Reader, rs *reedSolomon, pieceSize int, pws []*io.PipeWriter) {
+	active := make([]bool, len(pws))
+	write := func(i int, b []byte) {
+		if !active[i] {
+			return
+		}
+		if _, err := pws[i].Write(b); err != nil {
+			active[i] = false
+		}
+	}
+
+	for i := range pws {
+		active[i] = true
+		hdr := &shardHeader{K: rs.k, M: rs.m, Index: i, PieceSize: pieceSize}
+		write(i, hdr.encode())
+	}
+
+	var (
+		buf  = make([]byte, rs.k*pieceSize)
+		size = make([]byte, 4)
+		err  error
+	)
+	for err == nil {
+		var nr int
+		nr, err = io.ReadFull(rd, buf)
+		if nr == 0 {
+			continue
+		}
+
+		// Zero pad the last stripe
+		for i := nr; i < len(buf); i++ {
+			buf[i] = 0
+		}
+		shards := make([][]byte, rs.k+rs.m)
+		for i := 0; i < rs.k; i++ {
+			shards[i] = buf[i*pieceSize : (i+1)*pieceSize]
+		}
+		rs.encode(shards)
+
+		binary.BigEndian.PutUint32(size, uint32(nr))
+		for i := range pws {
+			write(i, size)
+			write(i, shards[i])
+		}
+	}
+
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	for _, pw := range pws {
+		if err == io.EOF {
+			pw.Close()
+		} else {
+			pw.CloseWithError(err)
+		}
+	}
+}
+
+// stripeDecoder reads stripes from the available shards reconstructing the
+// missing ones.  Only k shards are read per stripe, preferring data shards.  A
+// spare shard is skipped ahead when it replaces one that failed.
+type stripeDecoder struct {
+	rs        *reedSolomon
+	pieceSize int
+	// indexed by shard.  nil if missing.
+	streams []*shardStream
+	stripe  int
+}
+
+func newStripeDecoder(key []byte, streams []*shardStream) (*stripeDecoder, error) {
+	var hdr *shardHeader
+	for _, ss := range streams {
+		if ss != nil {
+			hdr = ss.hdr
+			break
+		}
+	}
+	if hdr == nil {
+		return nil, fmt.Errorf("object not found: %x", key)
+	}
+
+	rs, err := newReedSolomon(hdr.K, hdr.M)
+	if err != nil {
+		return nil, err
+	}
+	return &stripeDecoder{rs: rs, pieceSize: hdr.PieceSize, streams: streams}, nil
+}
+
+// next returns all shards of the next stripe and the length of the object data
+// held in it.  It returns io.EOF after the last stripe.
+func (sd *stripeDecoder) next() ([][]byte, int, error) {
+	var (
+		shards = make([][]byte, len(sd.streams))
+		size   int
+		got    int
+		stride = int64(4 + sd.pieceSize)
+	)
+	for i, ss := range sd.streams {
+		if got == sd.rs.k {
+			break
+		}
+		if ss == nil || ss.failed {
+			continue
+		}
+
+		if ss.stripe < sd.stripe {
+			skip := int64(sd.stripe-ss.stripe) * stride
+			if _, err := io.CopyN(ioutil.Discard, ss.rd, skip); err != nil {
+				ss.failed = true
+				continue
+			}
+			ss.stripe = sd.stripe
+		}
+
+		piece := make([]byte, stride)
+		if _, err := io.ReadFull(ss.rd, piece); err != nil {
+			if err == io.EOF {
+				// All shards hold the same number of stripes
+				return nil, 0, io.EOF
+			}
+			ss.failed = true
+			continue
+		}
+		ss.stripe++
+
+		size = int(binary.BigEndian.Uint32(piece[:4]))
+		shards[i] = piece[4:]
+		got++
+	}
+
+	if got < sd.rs.k {
+		return nil, 0, fmt.Errorf("not enough shards for stripe %d: %d/%d", sd.stripe, got, sd.rs.k)
+	}
+	if size > sd.rs.k*sd.pieceSize {
+		return nil, 0, fmt.Errorf("invalid stripe size: %d", size)
+	}
+	if err := sd.rs.reconstruct(shards); err != nil {
+		return nil, 0, err
+	}
+	sd.stripe++
+	return shards, size, nil
+}
+
+// Close all shard streams
+func (sd *stripeDecoder) Close() error {
+	for _, ss := range sd.streams {
+		if ss != nil {
+			ss.Close()
+		}
+	}
+	return nil
+}
+
+// erasureReader reads an erasure coded object from its shards
+type erasureReader struct {
+	sd  *stripeDecoder
+	cur []byte
+	err error
+	// bytes to skip from the first stripe read
+	skip int
+}
+
+func (er *erasureReader) Read(p []byte) (int, error) {
+	for len(er.cur) == 0 {
+		if er.err != nil {
+			return 0, er.err
+		}
+
+		shards, size, err := er.sd.next()
+		if err != nil {
+			er.err = err
+			continue
+		}
+		er.cur = bytes.Join(shards[:er.sd.rs.k], nil)[:size]
+		if er.skip > 0 {
+			if er.skip > len(er.cur) {
+				er.skip = len(er.cur)
+			}
+			er.cur, er.skip = er.cur[er.skip:], 0
+		}
+	}
+
+	n := copy(p, er.cur)
+	er.cur = er.cur[n:]
+	return n, nil
+}
+
+// Close releases all shard streams
+func (er *erasureReader) Close() error {
+	if er.err == nil {
+		er.err = fmt.Errorf("reader closed")
+	}
+	return er.sd.Close()
+}
+
+// openShards opens the shards of the key on the vnodes concurrently positioned
+// at the given stripe.  Streams are indexed by the shard index in their header
+// and missing shards are nil.  Errors are those of the vnodes in lookup order.
+func (cs *ChordStore) openShards(vns []*chord.Vnode, key []byte, stripe int) ([]*shardStream, []error) {
+	var (
+		mu        sync.Mutex
+		collected bool
+		res       = make([]*shardStream, len(vns))
+		errs      = make([]error, len(vns))
+	)
+	done := fanOut(len(vns), len(vns), cs.timeout, func(ctx context.Context, i int) bool {
+		ss, err := cs.openShard(ctx, vns[i], key, stripe)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if collected {
+			// Too late.  Release the stream.
+			if ss != nil {
+				ss.Close()
+			}
+			return false
+		}
+		res[i], errs[i] = ss, err
+		return err == nil
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	collected = true
+
+	streams := make([]*shardStream, len(vns))
+	for i, ss := range res {
+		if !done[i] {
+			if ss != nil {
+				ss.Close()
+			}
+			errs[i] = errReplicaPending
+			continue
+		}
+		if ss == nil {
+			continue
+		}
+		if ss.hdr.K+ss.hdr.M != len(vns) || streams[ss.hdr.Index] != nil {
+			ss.Close()
+			errs[i] = fmt.Errorf("unexpected shard %d of %d+%d", ss.hdr.Index, ss.hdr.K, ss.hdr.M)
+			continue
+		}
+		streams[ss.hdr.Index] = ss
+	}
+	return streams, errs
+}
+
+// openShard opens the shard on the vnode positioned at the stripe.  Only the
+// header is read before seeking to a stripe other than the first.
+func (cs *ChordStore) openShard(ctx context.Context, vn *chord.Vnode, key []byte, stripe int) (*shardStream, error) {
+	if stripe > 0 {
+		hdr, err := cs.shardHeader(vn, key)
+		if err != nil {
+			return nil, err
+		}
+		offset := int64(shardHeaderSize) + int64(stripe)*int64(4+hdr.PieceSize)
+		rd, _, err := cs.store.withContext(ctx).GetObjectRange(vn, key, offset, 0)
+		if err != nil {
+			return nil, err
+		}
+		return &shardStream{hdr: hdr, rd: rd, stripe: stripe}, nil
+	}
+
+	rd, _, err := cs.store.withContext(ctx).GetObjectRange(vn, key, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	hdr, err := readShard(rd)
+	if err != nil {
+		closeReader(rd)
+		return nil, err
+	}
+	return &shardStream{hdr: hdr, rd: rd}, nil
+}
+
+// shardHeader reads only the header of the shard on the vnode
+func (cs *ChordStore) shardHeader(vn *chord.Vnode, key []byte) (*shardHeader, error) {
+	rd, _, err := cs.store.GetObjectRange(vn, key, 0, int64(shardHeaderSize))
+	if err != nil {
+		return nil, err
+	}
+	defer closeReader(rd)
+	return readShard(rd)
+}
+
+// readShard reads the magic and header at the start of a shard
+func readShard(rd io.Reader) (*shardHeader, error) {
+	head := make([]byte, len(shardMagic))
+	if _, err := io.ReadFull(rd, head); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(head, shardMagic) {
+		return nil, fmt.Errorf("not a shard")
+	}
+	return readShardHeader(rd)
+}
+
+// getErasure returns a single reader reconstructing length bytes of the object
+// from offset, or to the end if length < 1, from any k of the k+m shards.  Only
+// the stripes covering the range are read.  Missing shards are queued for
+// healing.
+func (cs *ChordStore) getErasure(key []byte, hdr *shardHeader, offset, length int64) ([]*VnodeDataIO, error) {
+	vns, err := cs.lookup(hdr.K+hdr.M, key)
+	if err != nil {
+		return nil, err
+	}
+
+	stripeSize := int64(hdr.K * hdr.PieceSize)
+	stripe := int(offset / stripeSize)
+	streams, errs := cs.openShards(vns, key, stripe)
+	for i, err := range errs {
+		if err != nil && isNotFound(err) {
+			cs.enqueueHeal(HealRequest{Type: HealTypeShard, Vnode: vns[i], Key: key})
+			break
+		}
+	}
+
+	vd := &VnodeDataIO{Vnode: vns[0], Size: -1}
+	sd, err := newStripeDecoder(key, streams)
+	if err != nil {
+		vd.Err = err
+	} else {
+		sd.stripe = stripe
+		vd.r = limitReader(&erasureReader{sd: sd, skip: int(offset % stripeSize)}, length)
+	}
+	return []*VnodeDataIO{vd}, nil
+}
+
+// healShards rebuilds the shards missing from the successor vnodes of the key by
+// decoding the remaining shards a stripe at a time.
+func (he *HealingEngine) healShards(hr HealRequest) error {
+	cs := he.cs
+	if cs.rs == nil {
+		return fmt.Errorf("erasure coding disabled")
+	}
+
+	vns, err := cs.lookup(cs.rs.k+cs.rs.m, hr.Key)
+	if err != nil {
+		return err
+	}
+	streams, errs := cs.openShards(vns, hr.Key, 0)
+
+	var missing []*chord.Vnode
+	for i, err := range errs {
+		if err != nil && isNotFound(err) {
+			missing = append(missing, vns[i])
+		}
+	}
+	sd, err := newStripeDecoder(hr.Key, streams)
+	if err != nil {
+		return err
+	}
+	defer sd.Close()
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	// Shard indexes held by no vnode
+	var idxs []int
+	for i, ss := range streams {
+		if ss == nil {
+			idxs = append(idxs, i)
+		}
+	}
+	if len(idxs) > len(missing) {
+		idxs = idxs[:len(missing)]
+	}
+	missing = missing[:len(idxs)]
+
+	// Rebuilt shards keep the metadata of the others
+	meta := &ObjectMeta{Encoding: ObjectEncodingErasure}
+	for i, err := range errs {
+		if err != nil {
+			continue
+		}
+		if m, err := cs.store.StatObject(vns[i], hr.Key); err == nil {
+			meta = m
+		}
+		break
+	}
+
+	vds := cs.putStreams(missing, hr.Key, putMeta(meta), func(pws []*io.PipeWriter) {
+		var (
+			size = make([]byte, 4)
+			err  error
+		)
+		for j, idx := range idxs {
+			hdr := &shardHeader{K: sd.rs.k, M: sd.rs.m, Index: idx, PieceSize: sd.pieceSize}
+			pws[j].Write(hdr.encode())
+		}
+		for err == nil {
+			var (
+				shards [][]byte
+				n      int
+			)
+			if shards, n, err = sd.next(); err != nil {
+				break
+			}
+			binary.BigEndian.PutUint32(size, uint32(n))
+			for j, idx := range idxs {
+				pws[j].Write(size)
+				pws[j].Write(shards[idx])
+			}
+		}
+		for _, pw := range pws {
+			if err == io.EOF {
+				pw.Close()
+			} else {
+				pw.CloseWithError(err)
+			}
+		}
+	})
+
+	var failed error
+	for i, vd := range vds {
+		if vd.Err != nil {
+			failed = mergeErrors(failed, vd.Err)
+			continue
+		}
+		log.Printf("Healed shard %d %s/%s", idxs[i], vd.Vnode.StringID(), hr.Key)
+	}
+	return failed
+}
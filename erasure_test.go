@@ -0,0 +1,144 @@
+package chordstore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func Test_reedSolomon(t *testing.T) {
+	rs, err := newReedSolomon(4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = newReedSolomon(0, 2); err == nil {
+		t.Fatal("should fail")
+	}
+
+	r := rand.New(rand.NewSource(1))
+	shards := make([][]byte, 6)
+	for i := 0; i < 4; i++ {
+		shards[i] = make([]byte, 100)
+		r.Read(shards[i])
+	}
+	rs.encode(shards)
+
+	// Every combination of 2 lost shards
+	for a := 0; a < 6; a++ {
+		for b := a + 1; b < 6; b++ {
+			lost := make([][]byte, 6)
+			copy(lost, shards)
+			lost[a], lost[b] = nil, nil
+			if err = rs.reconstruct(lost); err != nil {
+				t.Fatal(a, b, err)
+			}
+			for i := range shards {
+				if !bytes.Equal(lost[i], shards[i]) {
+					t.Fatal("shard mismatch", a, b, i)
+				}
+			}
+		}
+	}
+
+	lost := make([][]byte, 6)
+	copy(lost, shards[:3])
+	if err = rs.reconstruct(lost); err == nil {
+		t.Fatal("should fail with 3 shards")
+	}
+}
+
+func Test_ChordStore_Erasure(t *testing.T) {
+	c1, err := initConfig(36023)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs1, err := NewChordStore(c1, &MemKeyValueStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs1.Shutdown()
+	defer c1.Ring.Shutdown()
+
+	<-time.After(200 * time.Millisecond)
+	c2, err := initConfig(36024, "127.0.0.1:36023")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs2, err := NewChordStore(c2, &MemKeyValueStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs2.Shutdown()
+	defer c2.Ring.Shutdown()
+
+	<-time.After(300 * time.Millisecond)
+
+	// More than one stripe
+	data := make([]byte, 4*erasurePieceSize+1234)
+	rand.New(rand.NewSource(1)).Read(data)
+	key := []byte("archive")
+
+	cds, err := cs1.PutErasure(key, bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cds) != 6 {
+		t.Fatal("should have 6 shards", len(cds))
+	}
+
+	// Shards are a fraction of the object
+	rd, err := cs1.store.GetObject(cds[0].Vnode, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := ioutil.ReadAll(rd)
+	if !bytes.HasPrefix(raw, shardMagic) || len(raw) > len(data)/2 {
+		t.Fatal("shard not stored", len(raw))
+	}
+
+	// Lose a data and a parity shard
+	for _, i := range []int{1, 4} {
+		if err = cs1.store.RemoveObject(cds[i].Vnode, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	vds, err := cs2.GetObject(0, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vds) != 1 || vds[0].Err != nil {
+		t.Fatal("should have a single reader", vds)
+	}
+	b, err := ioutil.ReadAll(vds[0].Reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, data) {
+		t.Fatal("data mismatch", len(b))
+	}
+
+	// Missing shards are rebuilt
+	<-time.After(300 * time.Millisecond)
+	for _, i := range []int{1, 4} {
+		hdr, err := cs1.shardHeader(cds[i].Vnode, key)
+		if err != nil {
+			t.Fatal("shard not healed", i, err)
+		}
+		if hdr.Index != i {
+			t.Fatal("wrong shard healed", i)
+		}
+	}
+
+	// Lose 3 shards
+	for _, i := range []int{0, 2, 5} {
+		cs1.store.RemoveObject(cds[i].Vnode, key)
+	}
+	vds, _ = cs2.GetObject(0, key)
+	if _, err = ioutil.ReadAll(vds[0].Reader()); err == nil {
+		t.Fatal("should fail with 3 shards")
+	}
+	vds[0].Close()
+}
@@ -0,0 +1,216 @@
+package chordstore
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	chord "github.com/euforia/go-chord"
+)
+
+// Number of times re-replication waits for the ring to route around a failed
+// vnode before giving up.
+const rereplicateAttempts = 5
+
+// failureDetector periodically probes the replica peers of each local vnode.
+// Chord only notifies the delegate of vnodes that leave cleanly so this catches
+// peers that die.  When a peer stops responding the keys and objects of the local
+// vnodes it replicated are re-replicated to the vnodes now responsible for them.
+type failureDetector struct {
+	cs       *ChordStore
+	host     string
+	interval time.Duration
+
+	mu sync.Mutex
+	// peers that have failed and are already being handled
+	failed map[string]bool
+
+	stop chan bool
+}
+
+func newFailureDetector(cs *ChordStore, host string, interval time.Duration) *failureDetector {
+	return &failureDetector{
+		cs:       cs,
+		host:     host,
+		interval: interval,
+		failed:   map[string]bool{},
+		stop:     make(chan bool, 1),
+	}
+}
+
+// start probing on every interval.  This blocks until stop is called.
+func (fd *failureDetector) start() {
+	tick := time.NewTicker(fd.interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-tick.C:
+			fd.check()
+		case <-fd.stop:
+			return
+		}
+	}
+}
+
+func (fd *failureDetector) shutdown() {
+	fd.stop <- true
+}
+
+// check probes each remote replica peer once, re-replicating the keys of the
+// local vnodes sharing keys with peers that fail to respond.
+func (fd *failureDetector) check() {
+	vns, err := fd.cs.ring.ListVnodes(fd.host)
+	if err != nil {
+		log.Printf("ERR [failure] %v", err)
+		return
+	}
+
+	// Local vnodes sharing keys with each remote peer
+	affected := map[string][]*chord.Vnode{}
+	peers := map[string]*chord.Vnode{}
+	for _, vn := range vns {
+		mps, err := fd.cs.replicaPeers(vn)
+		if err != nil {
+			log.Printf("ERR [failure] vnode=%s %v", shortID(vn), err)
+			continue
+		}
+		for id, mp := range mps {
+			if mp.vn.Host == fd.host {
+				continue
+			}
+			peers[id] = mp.vn
+			affected[id] = append(affected[id], vn)
+		}
+	}
+
+	for id, pvn := range peers {
+		_, err := fd.cs.store.MerkleLevel(pvn, 0, []int{0})
+
+		fd.mu.Lock()
+		if err == nil {
+			delete(fd.failed, id)
+			fd.mu.Unlock()
+			continue
+		}
+		if fd.failed[id] {
+			fd.mu.Unlock()
+			continue
+		}
+		fd.failed[id] = true
+		fd.mu.Unlock()
+
+		log.Printf("ERR [failure] vnode=%s unreachable: %v", shortID(pvn), err)
+		for _, vn := range affected[id] {
+			fd.cs.scheduleRereplicate(vn, pvn)
+		}
+	}
+}
+
+// scheduleRereplicate re-replicates the keys of the local vnode after the ring
+// has had time to route around the departed vnode.
+func (cs *ChordStore) scheduleRereplicate(vn, departed *chord.Vnode) {
+	var attempt func(n int)
+	attempt = func(n int) {
+		time.AfterFunc(cs.settle, func() {
+			done, err := cs.rereplicate(vn, departed)
+			if err != nil {
+				log.Printf("ERR [rereplicate] vnode=%s %v", shortID(vn), err)
+			} else if !done && n > 1 {
+				attempt(n - 1)
+			} else if !done {
+				log.Printf("ERR [rereplicate] vnode=%s ring still routes to %s", shortID(vn), shortID(departed))
+			}
+		})
+	}
+	attempt(rereplicateAttempts)
+}
+
+// rereplicate syncs the local vnode with its current replica peers restoring
+// the replica count of its keys and objects.  It returns false if the ring still
+// routes to the departed vnode in which case nothing is done.
+func (cs *ChordStore) rereplicate(vn, departed *chord.Vnode) (bool, error) {
+	peers, err := cs.replicaPeers(vn)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := peers[departed.StringID()]; ok {
+		return false, nil
+	}
+
+	n, err := cs.syncVnode(vn)
+	if err != nil {
+		return true, err
+	}
+	o, err := cs.rereplicateObjects(vn)
+	log.Printf("DBG [rereplicate] vnode=%s departed=%s queued=%d objects=%d", shortID(vn), shortID(departed), n, o)
+	return true, err
+}
+
+// rereplicateObjects queues healing of the objects of the local vnode that are
+// missing from one of their current replicas.  It returns the number of objects
+// queued.
+func (cs *ChordStore) rereplicateObjects(vn *chord.Vnode) (int, error) {
+	st, ok := cs.store.local[vn.StringID()]
+	if !ok {
+		return 0, fmt.Errorf("not a local vnode")
+	}
+
+	var (
+		queued int
+		cursor []byte
+	)
+	for {
+		keys, err := st.ListObjects(nil, cursor, listBatchSize)
+		if err != nil {
+			return queued, err
+		}
+		for _, key := range keys {
+			if hr, ok := cs.objectHeal(vn, key); ok {
+				cs.enqueueHeal(hr)
+				queued++
+			}
+		}
+		if len(keys) < listBatchSize {
+			return queued, nil
+		}
+		cursor = keys[len(keys)-1]
+	}
+}
+
+// objectHeal returns the request healing the object of the local vnode if one of
+// its current replicas is missing it.  Content addressed objects and the shards
+// of erasure coded objects are healed as such.
+func (cs *ChordStore) objectHeal(vn *chord.Vnode, key []byte) (HealRequest, bool) {
+	hr := HealRequest{Type: HealTypeObject, Key: key}
+	meta, err := cs.store.StatObject(vn, key)
+	if err != nil {
+		return hr, false
+	}
+
+	n := cs.replicas
+	switch {
+	case meta.Encoding == ObjectEncodingErasure:
+		hdr, err := cs.shardHeader(vn, key)
+		if err != nil {
+			return hr, false
+		}
+		hr.Type, n = HealTypeShard, hdr.K+hdr.M
+	case bytes.Equal(meta.Hash, key):
+		hr.Type = HealTypeContent
+	}
+
+	vns, err := cs.lookup(n, key)
+	if err != nil || !containsVnode(vns, vn) {
+		return hr, false
+	}
+	for _, rvn := range vns {
+		if _, err = cs.store.StatObject(rvn, key); isNotFound(err) {
+			hr.Vnode = rvn
+			return hr, true
+		}
+	}
+	return hr, false
+}
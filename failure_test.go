@@ -0,0 +1,88 @@
+package chordstore
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func Test_ChordStore_FailureDetector(t *testing.T) {
+	c1, err := initConfig(36015)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1.AntiEntropyInterval = 0
+	c1.FailureCheckInterval = 0
+
+	cs1, err := NewChordStore(c1, &MemKeyValueStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs1.Shutdown()
+	defer c1.Ring.Shutdown()
+
+	<-time.After(200 * time.Millisecond)
+	c2, err := initConfig(36016, "127.0.0.1:36015")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2.AntiEntropyInterval = 0
+	c2.FailureCheckInterval = 0
+
+	cs2, err := NewChordStore(c2, &MemKeyValueStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs2.Shutdown()
+
+	<-time.After(300 * time.Millisecond)
+	for k, v := range testKeyValue {
+		if _, err = cs1.PutKey(0, []byte(k), v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = cs1.PutObject(0, []byte("object"), bytes.NewReader([]byte("object data"))); err != nil {
+		t.Fatal(err)
+	}
+	content, _, err := cs1.PutContent(0, bytes.NewReader([]byte("content data")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Node 2 dies without leaving the ring
+	c2.Server.Stop()
+
+	fd := newFailureDetector(cs1, c1.Chord.Hostname, time.Minute)
+	fd.check()
+	if len(fd.failed) == 0 {
+		t.Fatal("failure not detected")
+	}
+
+	// Ring routes around the failed node
+	c2.Ring.Shutdown()
+	<-time.After(500 * time.Millisecond)
+
+	for k, v := range testKeyValue {
+		vds, err := cs1.GetKey(0, []byte(k))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, vd := range vds {
+			if vd.Err != nil {
+				t.Fatal(k, shortID(vd.Vnode), vd.Err)
+			}
+			if string(vd.Data) != string(v) {
+				t.Fatal("value mismatch", k, string(vd.Data))
+			}
+		}
+	}
+
+	for _, key := range [][]byte{[]byte("object"), content} {
+		vns, _ := cs1.lookup(0, key)
+		for _, vn := range vns {
+			if _, err = cs1.store.StatObject(vn, key); err != nil {
+				t.Fatalf("object %x not re-replicated to %s: %v", key, shortID(vn), err)
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package chordstore
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	context "golang.org/x/net/context"
+)
+
+// errReplicaPending is set on replicas that had not responded by the time a
+// fan out returned
+var errReplicaPending = errors.New("replica response pending")
+
+type fanOutResult struct {
+	i  int
+	ok bool
+}
+
+// fanOut concurrently calls fn for each of the n replicas.  It returns once need
+// calls have acknowledged, all calls have returned or the timeout has elapsed,
+// whichever comes first.  If need is less than 1 all replicas are waited on.  A
+// timeout of 0 waits indefinitely.  The returned slice marks the calls that
+// returned.  Any state written by calls that have not returned must not be read
+// by the caller.  The context passed to fn carries the deadline and is cancelled
+// once it passes or all calls have returned, so calls still running after an
+// early return are abandoned no later than the deadline.
+func fanOut(n, need int, timeout time.Duration, fn func(ctx context.Context, i int) bool) []bool {
+	done, _ := fanOutLate(n, need, timeout, fn)
+	return done
+}
+
+// fanOutLate is fanOut also returning a channel that receives the calls that
+// returned by the time all of them have or the deadline has passed.  Calls still
+// running on an early return keep being collected in the background so late
+// replies are not lost.  The channel receives exactly once.
+func fanOutLate(n, need int, timeout time.Duration, fn func(ctx context.Context, i int) bool) ([]bool, <-chan []bool) {
+	if need < 1 || need > n {
+		need = n
+	}
+	acks := 0
+	return fanOutUntil(n, timeout, fn, func(i int, ok bool) bool {
+		if ok {
+			acks++
+		}
+		return acks >= need
+	})
+}
+
+// fanOutUntil is fanOutLate returning once enough returns true.  enough is
+// called with each call that returns, in the order they return, from a single
+// goroutine and may read the state written by the call.
+func fanOutUntil(n int, timeout time.Duration, fn func(ctx context.Context, i int) bool, enough func(i int, ok bool) bool) ([]bool, <-chan []bool) {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	var wg sync.WaitGroup
+	wg.Add(n)
+	go func() {
+		wg.Wait()
+		cancel()
+	}()
+
+	// Buffered so calls completing after we return never block
+	ch := make(chan fanOutResult, n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			ch <- fanOutResult{i: i, ok: fn(ctx, i)}
+		}(i)
+	}
+
+	late := make(chan []bool, 1)
+	done := make([]bool, n)
+	recvd, stop := 0, false
+	for ; recvd < n && !stop; recvd++ {
+		select {
+		case r := <-ch:
+			done[r.i] = true
+			stop = enough(r.i, r.ok)
+
+		case <-ctx.Done():
+			// Either the deadline passed or every call has returned and
+			// its result is already buffered.
+			drainFanOut(ch, done)
+			late <- append([]bool(nil), done...)
+			return done, late
+		}
+	}
+	if recvd == n {
+		late <- append([]bool(nil), done...)
+		return done, late
+	}
+
+	all := append([]bool(nil), done...)
+	go func() {
+		for ; recvd < n; recvd++ {
+			select {
+			case r := <-ch:
+				all[r.i] = true
+			case <-ctx.Done():
+				drainFanOut(ch, all)
+				late <- all
+				return
+			}
+		}
+		late <- all
+	}()
+	return done, late
+}
+
+// drainFanOut marks the results already buffered on ch as done
+func drainFanOut(ch <-chan fanOutResult, done []bool) {
+	for {
+		select {
+		case r := <-ch:
+			done[r.i] = true
+		default:
+			return
+		}
+	}
+}
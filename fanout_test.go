@@ -0,0 +1,89 @@
+package chordstore
+
+import (
+	"testing"
+	"time"
+
+	context "golang.org/x/net/context"
+)
+
+func Test_fanOut(t *testing.T) {
+	// Early return once enough calls acknowledge
+	start := time.Now()
+	done := fanOut(3, 2, time.Second, func(_ context.Context, i int) bool {
+		if i == 2 {
+			time.Sleep(500 * time.Millisecond)
+		}
+		return true
+	})
+	if time.Since(start) > 400*time.Millisecond {
+		t.Fatal("should return before the slow call")
+	}
+	if !done[0] || !done[1] || done[2] {
+		t.Fatal("wrong calls marked done", done)
+	}
+
+	// Failed calls do not count towards acknowledgements
+	done = fanOut(3, 2, time.Second, func(_ context.Context, i int) bool {
+		return i == 0
+	})
+	for i, d := range done {
+		if !d {
+			t.Fatal("call not done", i)
+		}
+	}
+
+	// Deadline
+	start = time.Now()
+	done = fanOut(2, 0, 100*time.Millisecond, func(_ context.Context, i int) bool {
+		if i == 1 {
+			time.Sleep(500 * time.Millisecond)
+		}
+		return true
+	})
+	if time.Since(start) > 400*time.Millisecond {
+		t.Fatal("deadline not honored")
+	}
+	if !done[0] || done[1] {
+		t.Fatal("wrong calls marked done", done)
+	}
+
+	// Calls still running are cancelled at the deadline
+	cancelled := make(chan error, 1)
+	fanOut(2, 1, 100*time.Millisecond, func(ctx context.Context, i int) bool {
+		if i == 1 {
+			<-ctx.Done()
+			cancelled <- ctx.Err()
+		}
+		return true
+	})
+	select {
+	case err := <-cancelled:
+		if err == nil {
+			t.Fatal("context should have an error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("context not cancelled at the deadline")
+	}
+
+	// Late replies after an early return are still collected
+	done, late := fanOutLate(3, 1, time.Second, func(_ context.Context, i int) bool {
+		if i > 0 {
+			time.Sleep(100 * time.Millisecond)
+		}
+		return true
+	})
+	if !done[0] || done[1] || done[2] {
+		t.Fatal("wrong calls marked done", done)
+	}
+	select {
+	case all := <-late:
+		for i, d := range all {
+			if !d {
+				t.Fatal("late call not collected", i)
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatal("late replies not delivered")
+	}
+}
@@ -2,83 +2,212 @@ package chordstore
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"fmt"
+	"io"
 	"log"
+	"sync/atomic"
 
 	chord "github.com/euforia/go-chord"
 )
 
+const defaultHealQueueSize = 1024
+
+// HealType is the type of data to be healed
+type HealType int
+
+// Heal types
+const (
+	HealTypeKey HealType = iota
+	HealTypeObject
+	// Content addressed object.  Replicas are healed from one whose data hashes
+	// to the key.
+	HealTypeContent
+	// Erasure coded object.  Missing shards are rebuilt from the others.
+	HealTypeShard
+)
+
+func (ht HealType) String() string {
+	switch ht {
+	case HealTypeObject:
+		return "object"
+	case HealTypeContent:
+		return "content"
+	case HealTypeShard:
+		return "shard"
+	}
+	return "key"
+}
+
+// HealRequest is a request to repair the replicas of a key or object.  Vnode is
+// the replica found to be missing or divergent.
 type HealRequest struct {
+	Type  HealType
 	Vnode *chord.Vnode
 	Key   []byte
 }
 
+// HealStats are counters for the healing engine
+type HealStats struct {
+	Enqueued uint64 `json:"enqueued"`
+	Dropped  uint64 `json:"dropped"` // queue full
+	Healed   uint64 `json:"healed"`
+	Failed   uint64 `json:"failed"`
+	Queued   int    `json:"queued"`
+}
+
+// HealingEngine asynchronously repairs missing or divergent replicas
 type HealingEngine struct {
 	q    chan HealRequest
 	stop chan bool
 
 	cs *ChordStore
+
+	enqueued uint64
+	dropped  uint64
+	healed   uint64
+	failed   uint64
 }
 
-func NewHealingEngine(cs *ChordStore) *HealingEngine {
+// NewHealingEngine instantiates a new engine with a queue of the given size
+func NewHealingEngine(cs *ChordStore, queueSize int) *HealingEngine {
+	if queueSize < 1 {
+		queueSize = defaultHealQueueSize
+	}
 	return &HealingEngine{
-		q:    make(chan HealRequest),
+		q:    make(chan HealRequest, queueSize),
 		stop: make(chan bool, 1),
 		cs:   cs,
 	}
 }
 
+// Enqueue a request.  It does not block and returns false if the queue is full.
+func (he *HealingEngine) Enqueue(hr HealRequest) bool {
+	select {
+	case he.q <- hr:
+		atomic.AddUint64(&he.enqueued, 1)
+		return true
+	default:
+		atomic.AddUint64(&he.dropped, 1)
+		return false
+	}
+}
+
+// Stats returns the current counters
+func (he *HealingEngine) Stats() HealStats {
+	return HealStats{
+		Enqueued: atomic.LoadUint64(&he.enqueued),
+		Dropped:  atomic.LoadUint64(&he.dropped),
+		Healed:   atomic.LoadUint64(&he.healed),
+		Failed:   atomic.LoadUint64(&he.failed),
+		Queued:   len(he.q),
+	}
+}
+
+// Start processing requests.  This blocks until Stop is called.
 func (he *HealingEngine) Start() {
 	log.Printf("[heal] Engine started. Waiting for requests...")
 	for {
 		select {
 		case hr := <-he.q:
-			if err := he.healKey(hr); err != nil {
-				log.Printf("ERR failed to heal key: %s %v", hr.Key, err)
+			var err error
+			switch hr.Type {
+			case HealTypeKey:
+				err = he.healKey(hr)
+			case HealTypeShard:
+				err = he.healShards(hr)
+			default:
+				err = he.healObject(hr)
+			}
+
+			if err != nil {
+				atomic.AddUint64(&he.failed, 1)
+				log.Printf("ERR failed to heal %s: %s %v", hr.Type, hr.Key, err)
+			} else {
+				atomic.AddUint64(&he.healed, 1)
 			}
 
 		case <-he.stop:
-			goto cleanup
+			log.Printf("[heal] Engine stopped")
+			return
 		}
 	}
+}
 
-cleanup:
-	close(he.q)
-	close(he.stop)
+// Stop the engine.  Requests still queued are discarded.
+func (he *HealingEngine) Stop() {
+	he.stop <- true
 }
 
+// healKey writes the value agreed upon by a quorum of the replicas holding the
+// key, or chosen by the conflict resolver, to the replicas that are missing it or
+// have a different value.
 func (he *HealingEngine) healKey(hr HealRequest) error {
-	vnds, err := he.cs.GetKey(3, hr.Key)
+	// Replicas of strong keys are kept by their consensus group
+	if he.cs.strong(hr.Key) {
+		return nil
+	}
+	vnds, err := he.cs.getKey(he.cs.replicas, ConsistencyAll, hr.Key, false)
 	if err != nil {
 		return err
 	}
 
-	pok := []*VnodeData{}
-	heal := []*VnodeData{}
-	for i := 0; i < len(vnds); i++ {
-		if vnds[i].Err != nil {
-			heal = append(heal, vnds[i])
-		} else {
-			pok = append(pok, vnds[i])
+	var have int
+	for _, vd := range vnds {
+		if vd.Err == nil {
+			have++
 		}
 	}
 
-	if len(pok) == 0 {
-		return fmt.Errorf("fatal: all keys exausted: '%s'", hr.Key)
+	// A removal newer than the last write is propagated rather than the value
+	if removed, err := he.healRemoval(hr.Key, vnds); err != nil || removed {
+		return err
+	}
+	// Siblings of versioned keys are merged rather than picking a value
+	if versioned, err := he.healVersions(hr.Key); err != nil || versioned {
+		return err
+	}
+
+	val, err := he.cs.resolveValue(hr.Key, vnds, ConsistencyQuorum.Required(have))
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("fatal: all keys exausted: '%s'", hr.Key)
+		}
+		return err
 	}
 
-	h := pok[0].Hash()
-	if len(pok) > 1 {
-		for i := 1; i < len(pok); i++ {
-			hc := pok[i].Hash()
-			if !bytes.Equal(h, hc) {
-				return fmt.Errorf("inconsistent key data: '%x' != '%x'", h, hc)
+	// Healed replicas expire along with the source and record the time it was
+	// written rather than that of the repair
+	var (
+		expiry int64
+		ws     = he.cs.newWriteStamp()
+		h      = valueHash(val)
+	)
+	for _, v := range vnds {
+		if v.Err == nil && bytes.Equal(h, v.Hash()) {
+			if expiry, err = he.cs.store.KeyExpiry(v.Vnode, hr.Key); err != nil {
+				continue
+			}
+			if txns, err := he.cs.store.KeyHistory(v.Vnode, hr.Key); err == nil && keyWriteTime(txns) > 0 {
+				ws.Timestamp = keyWriteTime(txns)
 			}
+			break
 		}
 	}
+	if err != nil {
+		return err
+	}
+
+	for _, v := range vnds {
+		if v.Err == nil && bytes.Equal(h, v.Hash()) {
+			continue
+		}
+		if v.Err != nil && !isNotFound(v.Err) {
+			// Replica unreachable.  Nothing we can do here.
+			continue
+		}
 
-	for _, v := range heal {
-		if err := he.cs.store.PutKey(v.Vnode, hr.Key, pok[0].Data); err != nil {
+		if err := he.cs.store.PutKey(v.Vnode, hr.Key, val, expiry, ws); err != nil {
 			log.Printf("ERR Failed heal key %s: %v", hr.Key, err)
 		} else {
 			log.Printf("Healed %s/%s", v.Vnode.StringID(), hr.Key)
@@ -87,3 +216,232 @@ func (he *HealingEngine) healKey(hr HealRequest) error {
 
 	return nil
 }
+
+// healRemoval removes the key from the replicas holding it if another replica
+// has a tombstone newer than the last write of every replica holding the key.
+// It returns true if the removal was propagated.
+func (he *HealingEngine) healRemoval(key []byte, vnds []*VnodeData) (bool, error) {
+	var removed, written int64
+	for _, v := range vnds {
+		if !isNotFound(v.Err) {
+			continue
+		}
+		t, err := he.cs.store.KeyTombstone(v.Vnode, key)
+		if err != nil {
+			log.Printf("ERR [heal] vnode=%s key=%s %v", shortID(v.Vnode), key, err)
+		} else if t > removed {
+			removed = t
+		}
+	}
+	if removed == 0 {
+		return false, nil
+	}
+
+	for _, v := range vnds {
+		if v.Err != nil {
+			continue
+		}
+		txns, err := he.cs.store.KeyHistory(v.Vnode, key)
+		if err != nil {
+			return false, err
+		}
+		if t := keyWriteTime(txns); t > written {
+			written = t
+		}
+	}
+	if written >= removed {
+		return false, nil
+	}
+
+	// Replicas record the time of the removal
+	ws := he.cs.newWriteStamp()
+	ws.Timestamp = removed
+	for _, v := range vnds {
+		if v.Err != nil {
+			continue
+		}
+		if err := he.cs.store.RemoveKey(v.Vnode, key, ws); err != nil {
+			log.Printf("ERR Failed heal removal %s: %v", key, err)
+		} else {
+			log.Printf("Healed removal %s/%s", v.Vnode.StringID(), key)
+		}
+	}
+	return true, nil
+}
+
+// healVersions adds the siblings of a versioned key held by any replica to the
+// replicas missing them.  It returns false if no replica has a version of the
+// key.
+func (he *HealingEngine) healVersions(key []byte) (bool, error) {
+	vns, err := he.cs.lookup(he.cs.replicas, key)
+	if err != nil {
+		return false, err
+	}
+
+	sets := make([][]*Sibling, len(vns))
+	errs := make([]error, len(vns))
+	var merged []*Sibling
+	for i, vn := range vns {
+		if sets[i], errs[i] = he.cs.store.GetVersions(vn, key); errs[i] == nil {
+			merged = mergeSiblings(merged, sets[i])
+		}
+	}
+	if !isVersioned(merged) {
+		return false, nil
+	}
+
+	for i, vn := range vns {
+		if errs[i] != nil && !isNotFound(errs[i]) {
+			// Replica unreachable.  Nothing we can do here.
+			continue
+		}
+		for _, sib := range missingSiblings(sets[i], merged) {
+			if err := he.cs.store.AddSibling(vn, key, sib); err != nil {
+				log.Printf("ERR Failed heal version %s: %v", key, err)
+			} else {
+				log.Printf("Healed version %s/%s %d", vn.StringID(), key, sib.Counter)
+			}
+		}
+	}
+	return true, nil
+}
+
+// healObject re-streams the object from a replica holding the content agreed
+// upon by a quorum of the replicas holding the object to the replicas missing it
+// or holding different content.  For content addressed objects the source is a
+// replica whose data hashes to the key.
+func (he *HealingEngine) healObject(hr HealRequest) error {
+	vns, err := he.cs.lookup(he.cs.replicas, hr.Key)
+	if err != nil {
+		return err
+	}
+
+	// Hash each replica without buffering the object
+	hashes := make([][]byte, len(vns))
+	errs := make([]error, len(vns))
+	for i, vn := range vns {
+		hashes[i], errs[i] = he.objectHash(vn, hr.Key)
+	}
+
+	var (
+		src  *chord.Vnode
+		hash []byte
+		cnt  int
+		have int
+	)
+	for i, h := range hashes {
+		if errs[i] != nil {
+			continue
+		}
+		have++
+		c := 0
+		for j := i; j < len(hashes); j++ {
+			if errs[j] == nil && bytes.Equal(h, hashes[j]) {
+				c++
+			}
+		}
+		if c > cnt {
+			src, hash, cnt = vns[i], h, c
+		}
+	}
+
+	if hr.Type == HealTypeContent {
+		src, hash = nil, hr.Key
+		for i, h := range hashes {
+			if errs[i] == nil && bytes.Equal(h, hash) {
+				src = vns[i]
+				break
+			}
+		}
+	} else if src != nil && cnt < ConsistencyQuorum.Required(have) {
+		return fmt.Errorf("no quorum for object: %s", hr.Key)
+	}
+
+	if src == nil {
+		return fmt.Errorf("fatal: all objects exausted: '%s'", hr.Key)
+	}
+	// Healed replicas keep the metadata of the source
+	meta, err := he.cs.store.StatObject(src, hr.Key)
+	if err != nil {
+		return err
+	}
+	put := putMeta(meta)
+	if hr.Type == HealTypeContent {
+		put = putContent(meta)
+	}
+
+	for i, vn := range vns {
+		if errs[i] == nil && bytes.Equal(hash, hashes[i]) {
+			continue
+		}
+		if errs[i] != nil && !isNotFound(errs[i]) {
+			// Replica unreachable.  Nothing we can do here.
+			continue
+		}
+
+		rd, err := he.cs.store.GetObject(src, hr.Key)
+		if err != nil {
+			return err
+		}
+		vds := he.cs.putStreams([]*chord.Vnode{vn}, hr.Key, put, func(pws []*io.PipeWriter) {
+			teeObject(rd, pws)
+		})
+		if err = vds[0].Err; err != nil {
+			log.Printf("ERR Failed heal object %s: %v", hr.Key, err)
+		} else {
+			log.Printf("Healed object %s/%s", vn.StringID(), hr.Key)
+		}
+		if c, ok := rd.(io.Closer); ok {
+			c.Close()
+		}
+	}
+
+	return nil
+}
+
+// objectHash returns the sha256 hash of the object data on the vnode.  The data
+// is always read as the hash in the metadata does not catch a corrupt replica.
+func (he *HealingEngine) objectHash(vn *chord.Vnode, key []byte) ([]byte, error) {
+	rd, err := he.cs.store.GetObject(vn, key)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := rd.(io.Closer); ok {
+		defer c.Close()
+	}
+	h := sha256.New()
+	if _, err = io.Copy(h, rd); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// needsHealing returns the first replica that is missing the key or has a value
+// different from the others.  Replicas that could not be reached are ignored.
+// It returns nil if no replica has the key or all have the same value.
+func needsHealing(vds []*VnodeData) *chord.Vnode {
+	var (
+		ref     []byte
+		missing *chord.Vnode
+	)
+	for _, vd := range vds {
+		if vd.Err != nil {
+			if missing == nil && isNotFound(vd.Err) {
+				missing = vd.Vnode
+			}
+			continue
+		}
+
+		h := vd.Hash()
+		if ref == nil {
+			ref = h
+		} else if !bytes.Equal(ref, h) {
+			return vd.Vnode
+		}
+	}
+
+	if ref == nil {
+		return nil
+	}
+	return missing
+}
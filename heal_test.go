@@ -0,0 +1,134 @@
+package chordstore
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func Test_needsHealing(t *testing.T) {
+	vds := []*VnodeData{
+		&VnodeData{Vnode: testVn1, Data: []byte("value")},
+		&VnodeData{Vnode: testVn2, Data: []byte("value")},
+	}
+	if vn := needsHealing(vds); vn != nil {
+		t.Fatal("should not need healing")
+	}
+
+	vds[1].Err = errReplicaPending
+	if vn := needsHealing(vds); vn != nil {
+		t.Fatal("pending replica should be ignored")
+	}
+
+	vds[1].Err = fmt.Errorf("key not found: key")
+	if vn := needsHealing(vds); vn != testVn2 {
+		t.Fatal("missing replica should need healing")
+	}
+
+	vds[1].Err = nil
+	vds[1].Data = []byte("other")
+	if vn := needsHealing(vds); vn != testVn2 {
+		t.Fatal("divergent replica should need healing")
+	}
+
+	vds[0].Err = fmt.Errorf("key not found: key")
+	vds[1].Err = fmt.Errorf("key not found: key")
+	if vn := needsHealing(vds); vn != nil {
+		t.Fatal("should not heal a key no replica has")
+	}
+}
+
+func Test_ChordStore_ReadRepair(t *testing.T) {
+	c1, err := initConfig(36012)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs1, err := NewChordStore(c1, &MemKeyValueStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs1.Shutdown()
+	defer c1.Ring.Shutdown()
+
+	<-time.After(200 * time.Millisecond)
+
+	key := []byte("repair")
+	if _, err = cs1.PutKey(3, key, []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	vns, _ := cs1.lookup(3, key)
+	// Lose a replica.  A removal would leave a tombstone.
+	cs1.store.local[vns[2].StringID()].PruneKey(key)
+
+	val, err := cs1.Get(key, ConsistencyQuorum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "value" {
+		t.Fatal("value mismatch", string(val))
+	}
+
+	<-time.After(200 * time.Millisecond)
+
+	st := cs1.HealStats()
+	if st.Enqueued != 1 || st.Healed != 1 || st.Failed != 0 {
+		t.Fatalf("stats mismatch %+v", st)
+	}
+
+	vds, _ := cs1.GetKey(3, key)
+	for _, vd := range vds {
+		if vd.Err != nil {
+			t.Fatal(vd.Err)
+		}
+		if string(vd.Data) != "value" {
+			t.Fatal("replica not healed", vd.Vnode.StringID(), string(vd.Data))
+		}
+	}
+}
+
+func Test_ChordStore_ObjectRepair(t *testing.T) {
+	c1, err := initConfig(36013)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs1, err := NewChordStore(c1, &MemKeyValueStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs1.Shutdown()
+	defer c1.Ring.Shutdown()
+
+	<-time.After(200 * time.Millisecond)
+
+	key := []byte("repair-object")
+	if _, err = cs1.PutObject(0, key, bytes.NewBufferString("payload")); err != nil {
+		t.Fatal(err)
+	}
+	vns, _ := cs1.lookup(0, key)
+	// Drop a replica
+	cs1.store.RemoveObject(vns[1], key)
+
+	if _, err = cs1.GetObject(0, key); err != nil {
+		t.Fatal(err)
+	}
+
+	<-time.After(200 * time.Millisecond)
+
+	st := cs1.HealStats()
+	if st.Enqueued != 1 || st.Healed != 1 || st.Failed != 0 {
+		t.Fatalf("stats mismatch %+v", st)
+	}
+
+	rd, err := cs1.store.GetObject(vns[1], key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(rd)
+	if string(b) != "payload" {
+		t.Fatal("object not healed", string(b))
+	}
+}
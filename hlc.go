@@ -0,0 +1,51 @@
+package chordstore
+
+import (
+	"sync"
+	"time"
+)
+
+// hybridClock is a hybrid logical clock packed into unix nanoseconds.  It
+// follows the wall clock but never goes backwards and is moved past every
+// timestamp observed from other nodes, so a write is always timestamped after
+// any write it could have seen even if the node clocks are skewed.
+type hybridClock struct {
+	mu   sync.Mutex
+	last int64
+}
+
+// hlc timestamps all key writes and removals of the process
+var hlc = &hybridClock{}
+
+// Now returns a timestamp after all previously returned or observed ones
+func (c *hybridClock) Now() int64 {
+	now := time.Now().UnixNano()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now <= c.last {
+		now = c.last + 1
+	}
+	c.last = now
+	return now
+}
+
+// stampTime returns the timestamp of a write moving the clock past it, or a new
+// one if the write has none
+func stampTime(ws *WriteStamp) int64 {
+	if ws.GetTimestamp() == 0 {
+		return hlc.Now()
+	}
+	hlc.Update(ws.Timestamp)
+	return ws.Timestamp
+}
+
+// Update the clock with a timestamp observed from another node
+func (c *hybridClock) Update(ts int64) {
+	c.mu.Lock()
+	if ts > c.last {
+		c.last = ts
+	}
+	c.mu.Unlock()
+}
@@ -0,0 +1,110 @@
+package chordstore
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"hash"
+)
+
+// KeyRange is the arc (Start, End] of the ring over the ring hash of keys.  It
+// wraps around the ring if End is less than Start.  A Start equal to End covers
+// the whole ring.  A nil range contains all keys.
+type KeyRange struct {
+	Start []byte
+	End   []byte
+	// Ring hash function.  This defaults to sha1, the chord default.
+	HashFunc func() hash.Hash
+	// Prefixes of keys left out of the range regardless of their position, such
+	// as strong namespaces whose keys are moved by their consensus groups.
+	// Objects are not affected.
+	Exclude []string
+}
+
+// Contains returns true if the ring hash of the key is in the range
+func (kr *KeyRange) Contains(key []byte) bool {
+	if kr == nil {
+		return true
+	}
+
+	hf := kr.HashFunc
+	if hf == nil {
+		hf = sha1.New
+	}
+	h := hf()
+	h.Write(key)
+	return kr.ContainsHash(h.Sum(nil))
+}
+
+// ContainsKey returns true if the key is in the range and not excluded
+func (kr *KeyRange) ContainsKey(key []byte) bool {
+	if kr != nil && orderedNamespace(kr.Exclude, key) != "" {
+		return false
+	}
+	return kr.Contains(key)
+}
+
+// ContainsHash returns true if the ring hash is in the range
+func (kr *KeyRange) ContainsHash(h []byte) bool {
+	if kr == nil {
+		return true
+	}
+
+	switch bytes.Compare(kr.Start, kr.End) {
+	case -1:
+		return bytes.Compare(h, kr.Start) > 0 && bytes.Compare(h, kr.End) <= 0
+	case 1:
+		return bytes.Compare(h, kr.Start) > 0 || bytes.Compare(h, kr.End) <= 0
+	}
+	return true
+}
+
+func (kr *KeyRange) String() string {
+	if kr == nil {
+		return "(all)"
+	}
+	return fmt.Sprintf("(%x, %x]", kr.Start, kr.End)
+}
+
+// filterSnapshot returns the keys, objects and transaction logs in the range.
+// Objects are keyed by hex encoded keys.
+func filterSnapshot(kr *KeyRange, keys, objects map[string][]byte, logs map[string][]*KeyTxn) (map[string][]byte, map[string][]byte, map[string][]*KeyTxn) {
+	if kr == nil {
+		return keys, objects, logs
+	}
+
+	fk := map[string][]byte{}
+	for k, v := range keys {
+		if kr.ContainsKey([]byte(k)) {
+			fk[k] = v
+		}
+	}
+
+	fo := map[string][]byte{}
+	for k, v := range objects {
+		if key, err := hex.DecodeString(k); err == nil && kr.Contains(key) {
+			fo[k] = v
+		}
+	}
+
+	fl := map[string][]*KeyTxn{}
+	for k, v := range logs {
+		if kr.ContainsKey([]byte(k)) {
+			fl[k] = v
+		}
+	}
+
+	return fk, fo, fl
+}
+
+// filterKeyTimes returns the per key timestamps of the keys in the range
+func filterKeyTimes(kr *KeyRange, times map[string]int64) map[string]int64 {
+	out := map[string]int64{}
+	for k, t := range times {
+		if kr.ContainsKey([]byte(k)) {
+			out[k] = t
+		}
+	}
+	return out
+}
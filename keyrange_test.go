@@ -0,0 +1,85 @@
+package chordstore
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"io"
+	"testing"
+)
+
+func Test_KeyRange(t *testing.T) {
+	var (
+		lo  = []byte{0x10}
+		mid = []byte{0x80}
+		hi  = []byte{0xf0}
+	)
+
+	kr := &KeyRange{Start: lo, End: hi}
+	if !kr.ContainsHash(mid) || !kr.ContainsHash(hi) || kr.ContainsHash(lo) {
+		t.Fatal("range mismatch", kr)
+	}
+
+	// Wrap around
+	kr = &KeyRange{Start: hi, End: lo}
+	if kr.ContainsHash(mid) || !kr.ContainsHash(lo) || !kr.ContainsHash([]byte{0xff}) || kr.ContainsHash(hi) {
+		t.Fatal("range mismatch", kr)
+	}
+
+	kr = &KeyRange{Start: mid, End: mid}
+	if !kr.ContainsHash(lo) || !kr.ContainsHash(mid) {
+		t.Fatal("should cover the whole ring")
+	}
+
+	var nkr *KeyRange
+	if !nkr.Contains([]byte("foo")) || !nkr.ContainsKey([]byte("foo")) {
+		t.Fatal("nil range should contain all keys")
+	}
+
+	kr = &KeyRange{Exclude: []string{"cfg/"}}
+	if kr.ContainsKey([]byte("cfg/foo")) || !kr.ContainsKey([]byte("foo")) || !kr.Contains([]byte("cfg/foo")) {
+		t.Fatal("excluded keys should only be left out of keys")
+	}
+}
+
+func Test_MemKeyValueStore_Snapshot_KeyRange(t *testing.T) {
+	kvs, _ := (&MemKeyValueStore{}).New(testVn1)
+	for k, v := range testKeyValue {
+		kvs.PutKey([]byte(k), v, 0, nil)
+	}
+
+	// Range covering only foo
+	h := sha1.Sum([]byte("foo"))
+	start := make([]byte, len(h))
+	copy(start, h[:])
+	start[len(start)-1]--
+	kr := &KeyRange{Start: start, End: h[:]}
+
+	buf := new(bytes.Buffer)
+	if err := kvs.Snapshot(buf, kr); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := decodeSnapshot(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snap.Keys) != 1 || string(snap.Keys["foo"]) != "foo" || len(snap.Logs) != 1 {
+		t.Fatal("snapshot mismatch", snap.Keys, snap.Logs)
+	}
+
+	kr = &KeyRange{Start: h[:], End: start}
+	buf.Reset()
+	if err = kvs.Snapshot(buf, kr); err != nil {
+		t.Fatal(err)
+	}
+	if snap, err = decodeSnapshot(buf); err != nil || len(snap.Keys) != len(testKeyValue)-1 {
+		t.Fatal("snapshot mismatch", err)
+	}
+
+	// Range with no keys
+	before := append([]byte{}, start...)
+	before[len(before)-1]--
+	kr = &KeyRange{Start: before, End: start}
+	if err = kvs.Snapshot(buf, kr); err != io.EOF {
+		t.Fatal("should have no data", err)
+	}
+}
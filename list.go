@@ -0,0 +1,238 @@
+package chordstore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	chord "github.com/euforia/go-chord"
+	context "golang.org/x/net/context"
+)
+
+// Keys sent per message when streaming a listing
+const listBatchSize = 1000
+
+// ErrPartialList is returned along with the keys listed when some vnodes could
+// not be listed.  Keys held only by those vnodes may be missing.
+var ErrPartialList = errors.New("partial listing: vnodes unavailable")
+
+// internalKeyPrefix prefixes the keys kept by the store itself, such as
+// transaction decisions and ordered namespace layouts
+const internalKeyPrefix = "\x00"
+
+// hiddenKey returns true if the key is internal and not listed with the prefix.
+// Internal keys are only listed when the prefix asks for them.
+func hiddenKey(key string, prefix []byte) bool {
+	return strings.HasPrefix(key, internalKeyPrefix) && !bytes.HasPrefix(prefix, []byte(internalKeyPrefix))
+}
+
+// selectKeys returns the keys with the prefix sorting after the cursor in order.
+// At most limit keys are returned unless limit is less than 1.
+func selectKeys(keys []string, prefix, cursor []byte, limit int) [][]byte {
+	out := [][]byte{}
+	for _, k := range keys {
+		key := []byte(k)
+		if !bytes.HasPrefix(key, prefix) {
+			continue
+		}
+		if len(cursor) > 0 && bytes.Compare(key, cursor) <= 0 {
+			continue
+		}
+		out = append(out, key)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return bytes.Compare(out[i], out[j]) < 0 })
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+// keyIndex is a sorted set of keys so pages can be listed without reading or
+// sorting all keys
+type keyIndex struct {
+	keys []string
+}
+
+func newKeyIndex(keys []string) *keyIndex {
+	sort.Strings(keys)
+	return &keyIndex{keys: keys}
+}
+
+// search returns the position of the key or where it would be inserted
+func (ki *keyIndex) search(k string) int {
+	return sort.SearchStrings(ki.keys, k)
+}
+
+func (ki *keyIndex) has(k string) bool {
+	i := ki.search(k)
+	return i < len(ki.keys) && ki.keys[i] == k
+}
+
+func (ki *keyIndex) add(k string) {
+	i := ki.search(k)
+	if i < len(ki.keys) && ki.keys[i] == k {
+		return
+	}
+	ki.keys = append(ki.keys, "")
+	copy(ki.keys[i+1:], ki.keys[i:])
+	ki.keys[i] = k
+}
+
+func (ki *keyIndex) remove(k string) {
+	i := ki.search(k)
+	if i < len(ki.keys) && ki.keys[i] == k {
+		ki.keys = append(ki.keys[:i], ki.keys[i+1:]...)
+	}
+}
+
+// page returns the keys with the prefix sorting after the cursor in order,
+// leaving out those skip returns true for.  At most limit keys are returned
+// unless limit is less than 1.
+func (ki *keyIndex) page(prefix, cursor []byte, limit int, skip func(string) bool) [][]byte {
+	i := ki.search(string(prefix))
+	if len(cursor) > 0 && bytes.Compare(cursor, prefix) >= 0 {
+		i = sort.Search(len(ki.keys), func(j int) bool { return ki.keys[j] > string(cursor) })
+	}
+
+	out := [][]byte{}
+	for ; i < len(ki.keys); i++ {
+		k := ki.keys[i]
+		if !strings.HasPrefix(k, string(prefix)) {
+			break
+		}
+		if skip != nil && skip(k) {
+			continue
+		}
+		out = append(out, []byte(k))
+		if limit > 0 && len(out) == limit {
+			break
+		}
+	}
+	return out
+}
+
+// mergeKeys merges sorted key lists dropping duplicates.  At most limit keys are
+// returned unless limit is less than 1.
+func mergeKeys(lists [][][]byte, limit int) [][]byte {
+	all := [][]byte{}
+	for _, l := range lists {
+		all = append(all, l...)
+	}
+	sort.Slice(all, func(i, j int) bool { return bytes.Compare(all[i], all[j]) < 0 })
+
+	out := [][]byte{}
+	for _, k := range all {
+		if len(out) > 0 && bytes.Equal(out[len(out)-1], k) {
+			continue
+		}
+		out = append(out, k)
+		if limit > 0 && len(out) == limit {
+			break
+		}
+	}
+	return out
+}
+
+// ringVnodes returns all vnodes of the ring in ring order by walking the
+// successors starting from a local vnode
+func (cs *ChordStore) ringVnodes() ([]*chord.Vnode, error) {
+	if len(cs.vnodes) == 0 {
+		return nil, fmt.Errorf("no local vnodes")
+	}
+
+	var out []*chord.Vnode
+	err := cs.walkRing(cs.vnodes[0].Id, func(vn *chord.Vnode) bool {
+		out = append(out, vn)
+		return true
+	})
+	return out, err
+}
+
+// ListKeys returns up to limit keys with the prefix sorting after the cursor in
+// order, along with the cursor of the next page.  The next cursor is nil once all
+// keys have been listed.  All vnodes in the ring are queried concurrently and
+// keys held by several replicas are returned once.  A limit less than 1 returns
+// all keys.  If any vnode could not be listed the keys from the others are
+// returned with ErrPartialList.
+func (cs *ChordStore) ListKeys(prefix, cursor []byte, limit int) ([][]byte, []byte, error) {
+	return cs.list(prefix, cursor, limit, (*TransparentStore).ListKeys)
+}
+
+// ListObjects is ListKeys for objects.  This includes the chunks of chunked
+// objects which are stored under their hash.
+func (cs *ChordStore) ListObjects(prefix, cursor []byte, limit int) ([][]byte, []byte, error) {
+	return cs.list(prefix, cursor, limit, (*TransparentStore).ListObjects)
+}
+
+func (cs *ChordStore) list(prefix, cursor []byte, limit int, fn func(*TransparentStore, *chord.Vnode, []byte, []byte, int) ([][]byte, error)) ([][]byte, []byte, error) {
+	vns, err := cs.ringVnodes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res := make([][][]byte, len(vns))
+	errs := make([]error, len(vns))
+	done := fanOut(len(vns), len(vns), cs.timeout, func(ctx context.Context, i int) bool {
+		res[i], errs[i] = fn(cs.store.withContext(ctx), vns[i], prefix, cursor, limit)
+		return errs[i] == nil
+	})
+
+	var (
+		lists  [][][]byte
+		failed error
+	)
+	for i, ok := range done {
+		err := errReplicaPending
+		if ok {
+			if err = errs[i]; err == nil {
+				lists = append(lists, res[i])
+				continue
+			}
+		}
+		// The keys of the vnode are likely on its replicas
+		log.Printf("ERR [list] %s %v", shortID(vns[i]), err)
+		failed = mergeErrors(failed, err)
+	}
+	if len(lists) == 0 {
+		return nil, nil, failed
+	}
+
+	keys := mergeKeys(lists, limit)
+	var next []byte
+	if limit > 0 && len(keys) == limit {
+		next = keys[len(keys)-1]
+	}
+	if failed != nil {
+		return keys, next, ErrPartialList
+	}
+	return keys, next, nil
+}
+
+// ListKeysRPC server-side.  Keys are streamed in batches of listBatchSize.
+func (cs *ChordStore) ListKeysRPC(req *DHTListRequest, stream DHT_ListKeysRPCServer) error {
+	fn := cs.store.ListKeys
+	if req.Objects {
+		fn = cs.store.ListObjects
+	}
+
+	keys, err := fn(req.Vn, req.Prefix, req.Cursor, int(req.Limit))
+	if err != nil {
+		return stream.Send(&DHTKeys{Err: err.Error()})
+	}
+
+	for len(keys) > 0 {
+		n := listBatchSize
+		if n > len(keys) {
+			n = len(keys)
+		}
+		if err = stream.Send(&DHTKeys{Keys: keys[:n]}); err != nil {
+			return err
+		}
+		keys = keys[n:]
+	}
+	return nil
+}
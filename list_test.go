@@ -0,0 +1,110 @@
+package chordstore
+
+import (
+	"fmt"
+	"testing"
+
+	chord "github.com/euforia/go-chord"
+)
+
+func Test_MemKeyValueStore_ListKeys(t *testing.T) {
+	kvs, _ := (&MemKeyValueStore{}).New(testVn1)
+	for _, k := range []string{"b/2", "a/1", "b/1", "b/3", "c"} {
+		kvs.PutKey([]byte(k), []byte(k), 0, nil)
+	}
+
+	keys, err := kvs.ListKeys([]byte("b/"), nil, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || string(keys[0]) != "b/1" || string(keys[1]) != "b/2" {
+		t.Fatalf("wrong keys %q", keys)
+	}
+
+	keys, _ = kvs.ListKeys([]byte("b/"), keys[1], 2)
+	if len(keys) != 1 || string(keys[0]) != "b/3" {
+		t.Fatalf("wrong keys after cursor %q", keys)
+	}
+
+	if keys, _ = kvs.ListKeys(nil, nil, 0); len(keys) != 5 {
+		t.Fatal("should list all keys", len(keys))
+	}
+
+	// Internal keys are only listed by their prefix
+	kvs.PutKey(txnRecordKey("id"), []byte(txnAborted), 0, nil)
+	if keys, _ = kvs.ListKeys(nil, nil, 0); len(keys) != 5 {
+		t.Fatal("should hide internal keys", len(keys))
+	}
+	if keys, _ = kvs.ListKeys([]byte(txnRecordPrefix), nil, 0); len(keys) != 1 {
+		t.Fatal("should list internal keys by prefix", len(keys))
+	}
+}
+
+func Test_ChordStore_ListKeys(t *testing.T) {
+	cs1, cs2, stop := newTestRing(t, 36029, nil)
+	defer stop()
+
+	vns, err := cs1.ringVnodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vns) != 16 {
+		t.Fatal("should walk all vnodes", len(vns))
+	}
+
+	for i := 0; i < 25; i++ {
+		if _, err = cs1.PutKey(3, []byte(fmt.Sprintf("list/%02d", i)), []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cs1.PutKey(3, []byte("other"), []byte("v"))
+
+	// Page through from the other node.  Replicas are returned once.
+	var (
+		all    []string
+		cursor []byte
+	)
+	for {
+		keys, next, err := cs2.ListKeys([]byte("list/"), cursor, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, k := range keys {
+			all = append(all, string(k))
+		}
+		if next == nil {
+			break
+		}
+		cursor = next
+	}
+
+	if len(all) != 25 {
+		t.Fatal("wrong key count", len(all))
+	}
+	for i, k := range all {
+		if k != fmt.Sprintf("list/%02d", i) {
+			t.Fatal("wrong order", i, k)
+		}
+	}
+
+	// Unreachable vnodes make the listing partial
+	remote := cs1.store.remote
+	cs1.store.remote = unlistableStore{remote}
+	defer func() { cs1.store.remote = remote }()
+	keys, _, err := cs1.ListKeys([]byte("list/"), nil, 0)
+	if err != ErrPartialList {
+		t.Fatal("should be partial", err)
+	}
+	if len(keys) == 0 {
+		t.Fatal("local keys should be listed")
+	}
+}
+
+// unlistableStore fails to list keys of remote vnodes
+type unlistableStore struct {
+	Store
+}
+
+func (s unlistableStore) ListKeys(vn *chord.Vnode, prefix, cursor []byte, limit int) ([][]byte, error) {
+	return nil, fmt.Errorf("vnode unavailable: %s", shortID(vn))
+}
@@ -0,0 +1,186 @@
+package chordstore
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"sync"
+)
+
+const (
+	merkleFanout = 16
+	// Levels below the root
+	merkleDepth  = 2
+	merkleLeaves = merkleFanout * merkleFanout
+)
+
+// MerkleTree is a fixed shape hash tree over the key-values of a vnode.  Keys
+// are placed in leaves by the first byte of their ring hash so a leaf covers a
+// contiguous arc of the ring.  A leaf hash is the XOR
+// of the hashes of its entries allowing it to be updated in place.  Level 0 is the
+// root and level merkleDepth are the leaves.
+type MerkleTree struct {
+	mu      sync.RWMutex
+	leaves  [merkleLeaves][sha256.Size]byte
+	entries [merkleLeaves]map[string][]byte
+	// leaf of each key.  The placement of keys of ordered namespaces changes
+	// when they are split.
+	leafOf map[string]int
+	// ring hash function
+	hashFunc func() hash.Hash
+}
+
+// NewMerkleTree instantiates an empty tree placing keys by the given ring hash
+// function.  This defaults to sha1, the chord default.
+func NewMerkleTree(hashFunc func() hash.Hash) *MerkleTree {
+	if hashFunc == nil {
+		hashFunc = sha1.New
+	}
+	mt := &MerkleTree{hashFunc: hashFunc, leafOf: map[string]int{}}
+	for i := range mt.entries {
+		mt.entries[i] = map[string][]byte{}
+	}
+	return mt
+}
+
+// merkleLeaf returns the leaf of the key given its ring hash function
+func merkleLeaf(hashFunc func() hash.Hash, key []byte) int {
+	h := hashFunc()
+	h.Write(key)
+	return int(h.Sum(nil)[0])
+}
+
+func merkleEntryHash(key, value []byte) []byte {
+	h := sha256.New()
+	h.Write(key)
+	h.Write(valueHash(value))
+	return h.Sum(nil)
+}
+
+func (mt *MerkleTree) xorLeaf(i int, h []byte) {
+	for j := range mt.leaves[i] {
+		mt.leaves[i][j] ^= h[j]
+	}
+}
+
+// Set the value of a key replacing any previous value
+func (mt *MerkleTree) Set(key, value []byte) {
+	i := merkleLeaf(mt.hashFunc, key)
+	h := merkleEntryHash(key, value)
+
+	mt.mu.Lock()
+	mt.remove(string(key))
+	mt.entries[i][string(key)] = h
+	mt.leafOf[string(key)] = i
+	mt.xorLeaf(i, h)
+	mt.mu.Unlock()
+}
+
+// Delete a key from the tree
+func (mt *MerkleTree) Delete(key []byte) {
+	mt.mu.Lock()
+	mt.remove(string(key))
+	mt.mu.Unlock()
+}
+
+func (mt *MerkleTree) remove(key string) {
+	i, ok := mt.leafOf[key]
+	if !ok {
+		return
+	}
+	mt.xorLeaf(i, mt.entries[i][key])
+	delete(mt.entries[i], key)
+	delete(mt.leafOf, key)
+}
+
+// rehash moves the keys whose placement on the ring changed to their new leaves
+func (mt *MerkleTree) rehash() {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	for key, i := range mt.leafOf {
+		j := merkleLeaf(mt.hashFunc, []byte(key))
+		if j == i {
+			continue
+		}
+		h := mt.entries[i][key]
+		mt.xorLeaf(i, h)
+		delete(mt.entries[i], key)
+		mt.entries[j][key] = h
+		mt.leafOf[key] = j
+		mt.xorLeaf(j, h)
+	}
+}
+
+// Root returns the root hash
+func (mt *MerkleTree) Root() []byte {
+	h, _ := mt.Level(0, []int{0})
+	return h[0]
+}
+
+// Level returns the hashes of the nodes at the given indexes of a level
+func (mt *MerkleTree) Level(level int, indexes []int) ([][]byte, error) {
+	if level < 0 || level > merkleDepth {
+		return nil, fmt.Errorf("invalid merkle level: %d", level)
+	}
+	width := merkleLevelWidth(level)
+
+	mt.mu.RLock()
+	defer mt.mu.RUnlock()
+
+	out := make([][]byte, len(indexes))
+	for i, idx := range indexes {
+		if idx < 0 || idx >= width {
+			return nil, fmt.Errorf("invalid merkle index: level=%d index=%d", level, idx)
+		}
+		out[i] = mt.node(level, idx)
+	}
+	return out, nil
+}
+
+func (mt *MerkleTree) node(level, idx int) []byte {
+	if level == merkleDepth {
+		h := mt.leaves[idx]
+		return h[:]
+	}
+
+	h := sha256.New()
+	for i := idx * merkleFanout; i < (idx+1)*merkleFanout; i++ {
+		h.Write(mt.node(level+1, i))
+	}
+	return h.Sum(nil)
+}
+
+// Entries returns the key and entry hash of all keys in the given leaves
+func (mt *MerkleTree) Entries(leaves []int) ([]*MerkleEntry, error) {
+	mt.mu.RLock()
+	defer mt.mu.RUnlock()
+
+	out := []*MerkleEntry{}
+	for _, l := range leaves {
+		if l < 0 || l >= merkleLeaves {
+			return nil, fmt.Errorf("invalid merkle leaf: %d", l)
+		}
+		for k, h := range mt.entries[l] {
+			out = append(out, &MerkleEntry{Key: []byte(k), Hash: h})
+		}
+	}
+	return out, nil
+}
+
+func merkleLevelWidth(level int) int {
+	w := 1
+	for i := 0; i < level; i++ {
+		w *= merkleFanout
+	}
+	return w
+}
+
+func toInt32s(a []int) []int32 {
+	out := make([]int32, len(a))
+	for i, v := range a {
+		out[i] = int32(v)
+	}
+	return out
+}
@@ -0,0 +1,111 @@
+package chordstore
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"testing"
+	"time"
+)
+
+func Test_MerkleTree(t *testing.T) {
+	mt1 := NewMerkleTree(nil)
+	mt2 := NewMerkleTree(nil)
+	empty := mt1.Root()
+
+	for k, v := range testKeyValue {
+		mt1.Set([]byte(k), v)
+	}
+	// Different order and an overwritten value
+	mt2.Set([]byte("foo"), []byte("other"))
+	for _, k := range []string{"xyz", "bizzle", "foo", "baz", "bar"} {
+		mt2.Set([]byte(k), testKeyValue[k])
+	}
+	if !bytes.Equal(mt1.Root(), mt2.Root()) {
+		t.Fatal("root mismatch")
+	}
+
+	mt2.Delete([]byte("foo"))
+	if bytes.Equal(mt1.Root(), mt2.Root()) {
+		t.Fatal("root should differ")
+	}
+	leaf := merkleLeaf(sha1.New, []byte("foo"))
+	h1, _ := mt1.Level(merkleDepth, []int{leaf})
+	h2, _ := mt2.Level(merkleDepth, []int{leaf})
+	if bytes.Equal(h1[0], h2[0]) {
+		t.Fatal("leaf should differ")
+	}
+
+	e1, _ := mt1.Entries([]int{leaf})
+	e2, _ := mt2.Entries([]int{leaf})
+	diff := diffMerkleEntries(e1, e2)
+	if len(diff) != 1 || string(diff[0]) != "foo" {
+		t.Fatal("diff mismatch", diff)
+	}
+
+	for k := range testKeyValue {
+		mt1.Delete([]byte(k))
+	}
+	if !bytes.Equal(mt1.Root(), empty) {
+		t.Fatal("should be empty")
+	}
+
+	if _, err := mt1.Level(merkleDepth+1, []int{0}); err == nil {
+		t.Fatal("should fail with invalid level")
+	}
+	if _, err := mt1.Level(1, []int{merkleFanout}); err == nil {
+		t.Fatal("should fail with invalid index")
+	}
+}
+
+func Test_ChordStore_AntiEntropy(t *testing.T) {
+	c1, err := initConfig(36014)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1.AntiEntropyInterval = 0
+
+	cs1, err := NewChordStore(c1, &MemKeyValueStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs1.Shutdown()
+	defer c1.Ring.Shutdown()
+
+	<-time.After(200 * time.Millisecond)
+
+	for k, v := range testKeyValue {
+		if _, err = cs1.PutKey(0, []byte(k), v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	key := []byte("bizzle")
+	vns, _ := cs1.lookup(0, key)
+	// Drift a replica without going through the read path
+	cs1.store.local[vns[1].StringID()].PruneKey(key)
+
+	n, err := cs1.syncVnode(vns[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatal("queued mismatch", n)
+	}
+
+	<-time.After(200 * time.Millisecond)
+	if _, err = cs1.store.GetKey(vns[1], key); err != nil {
+		t.Fatal("replica not healed", err)
+	}
+	if n, _ = cs1.syncVnode(vns[0]); n != 0 {
+		t.Fatal("should be in sync", n)
+	}
+
+	// Keys missing locally are found even when their leaf is empty
+	cs1.store.local[vns[0].StringID()].PruneKey(key)
+	if n, _ = cs1.syncVnode(vns[0]); n == 0 {
+		t.Fatal("missing key not queued")
+	}
+	<-time.After(200 * time.Millisecond)
+	if _, err = cs1.store.GetKey(vns[0], key); err != nil {
+		t.Fatal("vnode not healed", err)
+	}
+}
@@ -0,0 +1,144 @@
+package chordstore
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+
+	chord "github.com/euforia/go-chord"
+	context "golang.org/x/net/context"
+)
+
+// Object encodings
+const (
+	// Manifest of an object stored in chunks.  Size and Hash describe the
+	// whole object.
+	ObjectEncodingChunked = "chunked"
+	// Shard of an erasure coded object
+	ObjectEncodingErasure = "erasure"
+	// Chunk of a chunked object.  Chunks are content addressed and shared by
+	// the objects containing the same data.
+	ObjectEncodingChunk = "chunk"
+)
+
+// metaReader computes the size and sha256 of the data read through it
+type metaReader struct {
+	rd io.Reader
+	h  hash.Hash
+	n  int64
+}
+
+func newMetaReader(rd io.Reader) *metaReader {
+	return &metaReader{rd: rd, h: sha256.New()}
+}
+
+func (mr *metaReader) Read(p []byte) (int, error) {
+	n, err := mr.rd.Read(p)
+	mr.h.Write(p[:n])
+	mr.n += int64(n)
+	return n, err
+}
+
+// meta returns the metadata to store for the data read, taking the user defined
+// fields from the given metadata which may be nil.  Size and Hash are those of
+// the data read unless the object is the manifest of a chunked object.
+func (mr *metaReader) meta(in *ObjectMeta) *ObjectMeta {
+	m := &ObjectMeta{}
+	if in != nil {
+		*m = *in
+	}
+	if m.Encoding != ObjectEncodingChunked {
+		m.Size, m.Hash = mr.n, mr.h.Sum(nil)
+	}
+	m.Mtime = time.Now().UnixNano()
+	return m
+}
+
+// VnodeObjectMeta is the metadata of an object on a vnode
+type VnodeObjectMeta struct {
+	Vnode *chord.Vnode
+	Meta  *ObjectMeta
+	Err   error
+}
+
+// MarshalJSON custom
+func (vm *VnodeObjectMeta) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{
+		"vnode": map[string]string{
+			"id":   vm.Vnode.StringID(),
+			"host": vm.Vnode.Host,
+		},
+	}
+	if vm.Err != nil {
+		m["error"] = vm.Err.Error()
+	} else {
+		m["meta"] = vm.Meta
+	}
+	return json.Marshal(m)
+}
+
+// StatObject returns the metadata of the object from n replicas.  For chunked
+// objects it describes the whole object.  For erasure coded objects the replicas
+// are shards and Size is that of the whole object while Hash is not known.
+func (cs *ChordStore) StatObject(n int, key []byte) ([]*VnodeObjectMeta, error) {
+	vns, err := cs.lookup(n, key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*VnodeObjectMeta, len(vns))
+	done := fanOut(len(vns), len(vns), cs.timeout, func(ctx context.Context, i int) bool {
+		o := &VnodeObjectMeta{Vnode: vns[i]}
+		o.Meta, o.Err = cs.store.withContext(ctx).StatObject(vns[i], key)
+		if o.Err == nil && o.Meta.Encoding == ObjectEncodingErasure {
+			o.Meta.Size, o.Err = cs.erasureSize(vns[i], key, o.Meta.Size)
+			o.Meta.Hash = nil
+		}
+		res[i] = o
+		return o.Err == nil
+	})
+
+	out := make([]*VnodeObjectMeta, len(vns))
+	for i, vn := range vns {
+		if done[i] {
+			out[i] = res[i]
+		} else {
+			out[i] = &VnodeObjectMeta{Vnode: vn, Err: errReplicaPending}
+		}
+	}
+	return out, nil
+}
+
+// erasureSize returns the size of the erasure coded object given the size of one
+// of its shards.  The length of the last stripe is read from the shard.
+func (cs *ChordStore) erasureSize(vn *chord.Vnode, key []byte, shardSize int64) (int64, error) {
+	hdr, err := cs.shardHeader(vn, key)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		stride     = int64(4 + hdr.PieceSize)
+		stripes    = (shardSize - int64(shardHeaderSize)) / stride
+		stripeSize = int64(hdr.K * hdr.PieceSize)
+	)
+	if stripes < 1 {
+		return 0, nil
+	}
+
+	rd, _, err := cs.store.GetObjectRange(vn, key, int64(shardHeaderSize)+(stripes-1)*stride, 4)
+	if err != nil {
+		return 0, err
+	}
+	defer closeReader(rd)
+
+	b := make([]byte, 4)
+	if _, err = io.ReadFull(rd, b); err != nil {
+		return 0, fmt.Errorf("invalid shard: %v", err)
+	}
+	return (stripes-1)*stripeSize + int64(binary.BigEndian.Uint32(b)), nil
+}
@@ -0,0 +1,97 @@
+package chordstore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func Test_ChordStore_StatObject(t *testing.T) {
+	c1, err := initConfig(36027)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1.ChunkSize = 1000
+	cs1, err := NewChordStore(c1, &MemKeyValueStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs1.Shutdown()
+	defer c1.Ring.Shutdown()
+
+	<-time.After(200 * time.Millisecond)
+	c2, err := initConfig(36028, "127.0.0.1:36027")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2.ChunkSize = 1000
+	cs2, err := NewChordStore(c2, &MemKeyValueStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs2.Shutdown()
+	defer c2.Ring.Shutdown()
+
+	<-time.After(300 * time.Millisecond)
+
+	data := make([]byte, 4*erasurePieceSize+1234)
+	rand.New(rand.NewSource(1)).Read(data)
+	meta := &ObjectMeta{
+		ContentType: "video/mp4",
+		Headers:     map[string]string{"Owner": "test"},
+		// Set by the store
+		Size:     1,
+		Encoding: "bogus",
+	}
+
+	if _, err = cs1.PutObjectWithMeta(0, []byte("plain"), bytes.NewReader(data[:900]), meta); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cs1.PutObjectWithMeta(0, []byte("chunked"), bytes.NewReader(data[:10500]), meta); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cs1.PutErasure([]byte("erasure"), bytes.NewReader(data), meta); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key      string
+		size     int
+		encoding string
+		hash     bool
+	}{
+		{"plain", 900, "", true},
+		{"chunked", 10500, ObjectEncodingChunked, true},
+		{"erasure", len(data), ObjectEncodingErasure, false},
+	}
+
+	for _, tt := range tests {
+		vms, err := cs2.StatObject(0, []byte(tt.key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, vm := range vms {
+			if vm.Err != nil {
+				t.Fatal(tt.key, vm.Err)
+			}
+			m := vm.Meta
+			if m.Size != int64(tt.size) || m.Encoding != tt.encoding {
+				t.Fatal(tt.key, "meta mismatch", m.Size, m.Encoding)
+			}
+			if m.ContentType != "video/mp4" || m.Headers["Owner"] != "test" || m.Mtime == 0 {
+				t.Fatal(tt.key, "user meta mismatch", m)
+			}
+			h := sha256.Sum256(data[:tt.size])
+			if tt.hash != bytes.Equal(m.Hash, h[:]) {
+				t.Fatal(tt.key, "hash mismatch", m.Hash)
+			}
+		}
+	}
+
+	vms, _ := cs2.StatObject(0, []byte("missing"))
+	if vms[0].Err == nil || !isNotFound(vms[0].Err) {
+		t.Fatal("should not be found", vms[0].Err)
+	}
+}
@@ -0,0 +1,665 @@
+package chordstore
+
+import (
+	"bytes"
+	"fmt"
+	"hash"
+	"log"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	chord "github.com/euforia/go-chord"
+	context "golang.org/x/net/context"
+	"gopkg.in/vmihailenco/msgpack.v2"
+)
+
+// orderedHash places the keys of ordered namespaces on the ring in key order.
+// The remainder of the key after the namespace is used as the offset from the
+// hash of the namespace, so each namespace occupies its own arc of the ring and
+// a walk of the successors from the position of a key visits the following keys
+// in order.  All other data is hashed with the base hash.
+type orderedHash struct {
+	hash.Hash
+	base    func() hash.Hash
+	layouts *orderedLayouts
+	buf     []byte
+}
+
+// orderedHashFunc wraps the ring hash function placing keys of the namespaces in
+// key order according to their current layout.  The namespaces must be the same
+// on all nodes of the ring.
+func orderedHashFunc(base func() hash.Hash, layouts *orderedLayouts) func() hash.Hash {
+	return func() hash.Hash {
+		return &orderedHash{Hash: base(), base: base, layouts: layouts}
+	}
+}
+
+func (h *orderedHash) Write(p []byte) (int, error) {
+	h.buf = append(h.buf, p...)
+	return h.Hash.Write(p)
+}
+
+func (h *orderedHash) Reset() {
+	h.buf = h.buf[:0]
+	h.Hash.Reset()
+}
+
+func (h *orderedHash) Sum(b []byte) []byte {
+	ns := orderedNamespace(h.layouts.namespaces, h.buf)
+	if ns == "" {
+		return h.Hash.Sum(b)
+	}
+
+	nh := h.base()
+	nh.Write([]byte(ns))
+	pos := nh.Sum(nil)
+
+	off := h.layouts.get(ns).offset(h.buf[len(ns):], len(pos))
+	var carry int
+	for i := len(pos) - 1; i >= 0; i-- {
+		s := int(pos[i]) + int(off[i]) + carry
+		pos[i], carry = byte(s), s>>8
+	}
+	return append(b, pos...)
+}
+
+// orderedLayout is the placement of the keys of an ordered namespace.  The keys
+// are split into ranges at the split keys, each range spread over an equal arc
+// of the ring, so a namespace whose keys share a long prefix is not held by a
+// single vnode.  Keys appended after the last split, as with time series, share
+// the arc of the last range until the namespace is split again.  Split keys are
+// sorted and exclude the namespace.
+type orderedLayout struct {
+	Version int64
+	Splits  [][]byte
+}
+
+// offset returns the offset from the hash of the namespace of the key remainder
+// as size bytes.  Without splits this is the remainder truncated or zero padded
+// to the size.
+func (l *orderedLayout) offset(rem []byte, size int) []byte {
+	var (
+		ring = new(big.Int).Lsh(big.NewInt(1), uint(8*size))
+		lo   = new(big.Int)
+		hi   = ring
+	)
+	j := sort.Search(len(l.Splits), func(i int) bool { return bytes.Compare(rem, l.Splits[i]) < 0 })
+	if j > 0 {
+		lo = orderedInt(l.Splits[j-1], size)
+	}
+	if j < len(l.Splits) {
+		hi = orderedInt(l.Splits[j], size)
+	}
+
+	// Scale the remainder from its range to the arc of the range
+	width := new(big.Int).Div(ring, big.NewInt(int64(len(l.Splits)+1)))
+	off := new(big.Int).Mul(width, big.NewInt(int64(j)))
+	if d := new(big.Int).Sub(hi, lo); d.Sign() > 0 {
+		x := new(big.Int).Sub(orderedInt(rem, size), lo)
+		x.Mul(x, width).Div(x, d)
+		off.Add(off, x)
+	}
+
+	b := off.Bytes()
+	out := make([]byte, size)
+	copy(out[size-len(b):], b)
+	return out
+}
+
+// orderedInt returns b truncated or zero padded to size bytes as an integer
+func orderedInt(b []byte, size int) *big.Int {
+	buf := make([]byte, size)
+	copy(buf, b)
+	return new(big.Int).SetBytes(buf)
+}
+
+// orderedSplits returns the keys splitting the sorted keys of the namespace into
+// n ranges holding about as many keys each.  The first and last keys bound the
+// ranges so keys outside of them are placed in the arcs before and after.
+func orderedSplits(ns string, keys [][]byte, n int) [][]byte {
+	var splits [][]byte
+	for i := 0; i <= n; i++ {
+		j := i * len(keys) / n
+		if j == len(keys) {
+			j--
+		}
+		split := keys[j][len(ns):]
+		if len(splits) > 0 && bytes.Compare(split, splits[len(splits)-1]) <= 0 {
+			continue
+		}
+		splits = append(splits, append([]byte{}, split...))
+	}
+	return splits
+}
+
+func equalSplits(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// orderedLayouts holds the layout of each ordered namespace last adopted by the
+// node.  It is shared by the ring hash function and the store.
+type orderedLayouts struct {
+	namespaces []string
+
+	mu sync.RWMutex
+	m  map[string]*orderedLayout
+	// namespaces whose layout is being loaded
+	loading map[string]bool
+}
+
+func newOrderedLayouts(namespaces []string) *orderedLayouts {
+	return &orderedLayouts{
+		namespaces: namespaces,
+		m:          map[string]*orderedLayout{},
+		loading:    map[string]bool{},
+	}
+}
+
+// versions returns the layout version of each namespace
+func (ol *orderedLayouts) versions() map[string]int64 {
+	ol.mu.RLock()
+	defer ol.mu.RUnlock()
+	out := make(map[string]int64, len(ol.namespaces))
+	for _, ns := range ol.namespaces {
+		if l, ok := ol.m[ns]; ok {
+			out[ns] = l.Version
+		} else {
+			out[ns] = 0
+		}
+	}
+	return out
+}
+
+// startLoading marks the namespace as being loaded.  It returns false if it
+// already is.
+func (ol *orderedLayouts) startLoading(ns string) bool {
+	ol.mu.Lock()
+	defer ol.mu.Unlock()
+	if ol.loading[ns] {
+		return false
+	}
+	ol.loading[ns] = true
+	return true
+}
+
+func (ol *orderedLayouts) doneLoading(ns string) {
+	ol.mu.Lock()
+	delete(ol.loading, ns)
+	ol.mu.Unlock()
+}
+
+// get the layout of the namespace
+func (ol *orderedLayouts) get(ns string) *orderedLayout {
+	ol.mu.RLock()
+	defer ol.mu.RUnlock()
+	if l, ok := ol.m[ns]; ok {
+		return l
+	}
+	return &orderedLayout{}
+}
+
+// set the layout of the namespace if newer than the current one.  It returns
+// whether it was set.
+func (ol *orderedLayouts) set(ns string, l *orderedLayout) bool {
+	ol.mu.Lock()
+	defer ol.mu.Unlock()
+	if cur, ok := ol.m[ns]; ok && cur.Version >= l.Version {
+		return false
+	}
+	ol.m[ns] = l
+	return true
+}
+
+// orderedNamespace returns the longest of the namespaces the key belongs to or
+// an empty string if none
+func orderedNamespace(namespaces []string, key []byte) string {
+	var ns string
+	for _, n := range namespaces {
+		if len(n) > len(ns) && bytes.HasPrefix(key, []byte(n)) {
+			ns = n
+		}
+	}
+	return ns
+}
+
+// KeyValue is a key and its value as read from a vnode
+type KeyValue struct {
+	Key   []byte
+	Value []byte
+	Vnode *chord.Vnode
+}
+
+// walkRing calls fn with each vnode of the ring in ring order starting with the
+// successor of the ring position.  It stops once fn returns false or all vnodes
+// have been visited.
+func (cs *ChordStore) walkRing(pos []byte, fn func(*chord.Vnode) bool) error {
+	if len(cs.vnodes) == 0 {
+		return fmt.Errorf("no local vnodes")
+	}
+
+	seen := map[string]bool{}
+	for {
+		succs, err := cs.trans.FindSuccessors(cs.vnodes[0], cs.successors, pos)
+		if err != nil {
+			return err
+		}
+
+		var added bool
+		for _, vn := range succs {
+			id := vn.StringID()
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			added = true
+			if !fn(vn) {
+				return nil
+			}
+		}
+		if !added {
+			return nil
+		}
+		pos = succs[len(succs)-1].Id
+	}
+}
+
+// ringPosition returns the position of the key on the ring
+func (cs *ChordStore) ringPosition(key []byte) []byte {
+	h := cs.store.hashFunc()
+	h.Write(key)
+	return h.Sum(nil)
+}
+
+// Scan returns up to limit key-values with keys from start up to but excluding
+// end in key order.  Both keys must belong to the same ordered namespace.  A nil
+// end scans to the end of the namespace and a limit less than 1 returns all keys.
+// Successors are walked from the vnode owning start and each key is read from
+// the first vnode found holding it.
+func (cs *ChordStore) Scan(start, end []byte, limit int) ([]*KeyValue, error) {
+	ns := orderedNamespace(cs.ordered, start)
+	if ns == "" {
+		return nil, fmt.Errorf("not an ordered namespace: %s", start)
+	}
+	prefix := []byte(ns)
+	if end != nil {
+		if orderedNamespace(cs.ordered, end) != ns {
+			return nil, fmt.Errorf("scan spans namespaces: %s %s", start, end)
+		}
+		if bytes.Compare(start, end) >= 0 {
+			return []*KeyValue{}, nil
+		}
+		prefix = commonPrefix(start, end)
+	}
+
+	var (
+		startPos = cs.ringPosition(start)
+		origin   = new(big.Int).SetBytes(startPos)
+		ring     = new(big.Int).Lsh(big.NewInt(1), uint(8*len(startPos)))
+		// distance of a ring position clockwise from start
+		dist = func(pos []byte) *big.Int {
+			d := new(big.Int).Sub(new(big.Int).SetBytes(pos), origin)
+			return d.Mod(d, ring)
+		}
+		endVn *chord.Vnode
+		found = map[string]*chord.Vnode{}
+		keys  [][]byte
+		err   error
+	)
+	if end != nil {
+		vns, err := cs.trans.FindSuccessors(cs.vnodes[0], 1, cs.ringPosition(end))
+		if err != nil {
+			return nil, err
+		}
+		endVn = vns[0]
+	}
+
+	werr := cs.walkRing(startPos, func(vn *chord.Vnode) bool {
+		var list [][]byte
+		if list, err = cs.store.ListKeys(vn, prefix, nil, 0); err != nil {
+			return false
+		}
+		for _, k := range list {
+			if bytes.Compare(k, start) < 0 || (end != nil && bytes.Compare(k, end) >= 0) {
+				continue
+			}
+			if _, ok := found[string(k)]; !ok {
+				found[string(k)] = vn
+				keys = append(keys, k)
+			}
+		}
+
+		if endVn != nil && vn.StringID() == endVn.StringID() {
+			return false
+		}
+		if limit < 1 {
+			return true
+		}
+		// Keys up to this vnode are all known as later vnodes only hold keys
+		// further along the ring or replicas of these.
+		var settled int
+		d := dist(vn.Id)
+		for _, k := range keys {
+			if dist(cs.ringPosition(k)).Cmp(d) <= 0 {
+				settled++
+			}
+		}
+		return settled < limit
+	})
+	if err = mergeErrors(werr, err); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	out := make([]*KeyValue, len(keys))
+	errs := make([]error, len(keys))
+	done := fanOut(len(keys), len(keys), cs.timeout, func(ctx context.Context, i int) bool {
+		vn := found[string(keys[i])]
+		kv := &KeyValue{Key: keys[i], Vnode: vn}
+		kv.Value, errs[i] = cs.store.withContext(ctx).GetKey(vn, keys[i])
+		out[i] = kv
+		return errs[i] == nil
+	})
+
+	kvs := make([]*KeyValue, 0, len(keys))
+	for i, ok := range done {
+		if !ok {
+			return nil, errReplicaPending
+		}
+		if errs[i] == nil {
+			kvs = append(kvs, out[i])
+		} else if !isNotFound(errs[i]) {
+			// Keys removed since listed are skipped
+			return nil, errs[i]
+		}
+	}
+	return kvs, nil
+}
+
+// commonPrefix returns the longest common prefix of a and b
+func commonPrefix(a, b []byte) []byte {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return a[:i]
+}
+
+// orderedLayoutKey is the key the layout of the namespace is stored under
+func orderedLayoutKey(ns string) []byte {
+	return []byte(internalKeyPrefix + "ordered/" + ns)
+}
+
+// orderedBalancer periodically adopts the latest layout of each ordered
+// namespace, moves the keys of the local vnodes to the vnodes now responsible
+// for them and splits the namespaces whose keys concentrate on a local vnode.
+type orderedBalancer struct {
+	cs       *ChordStore
+	interval time.Duration
+	stop     chan bool
+}
+
+func newOrderedBalancer(cs *ChordStore, interval time.Duration) *orderedBalancer {
+	return &orderedBalancer{cs: cs, interval: interval, stop: make(chan bool, 1)}
+}
+
+// start balancing on every interval once the stored layouts are adopted.  This
+// blocks until stop is called.
+func (ob *orderedBalancer) start() {
+	for _, ns := range ob.cs.layouts.namespaces {
+		if _, _, err := ob.cs.loadLayout(ns); err != nil {
+			log.Printf("ERR [ordered] namespace=%s %v", ns, err)
+		}
+	}
+
+	tick := time.NewTicker(ob.interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-tick.C:
+			ob.cs.balanceOrdered()
+		case <-ob.stop:
+			return
+		}
+	}
+}
+
+func (ob *orderedBalancer) shutdown() {
+	ob.stop <- true
+}
+
+// balanceOrdered adopts the latest layout of each ordered namespace, moves the
+// keys of the local vnodes no longer responsible for them and splits the
+// namespace if a local vnode holds more than the split threshold.
+func (cs *ChordStore) balanceOrdered() {
+	for _, ns := range cs.layouts.namespaces {
+		if _, _, err := cs.loadLayout(ns); err != nil {
+			log.Printf("ERR [ordered] namespace=%s %v", ns, err)
+			continue
+		}
+		held, err := cs.moveOrdered(ns)
+		if err != nil {
+			log.Printf("ERR [ordered] namespace=%s %v", ns, err)
+			continue
+		}
+		if cs.splitKeys < 1 || held <= cs.splitKeys {
+			continue
+		}
+		if err = cs.splitOrdered(ns, held); err != nil {
+			log.Printf("ERR [ordered] namespace=%s %v", ns, err)
+		}
+	}
+}
+
+// loadLayout reads the layout of the namespace from the ring adopting it if newer
+// than the current one.  It returns the layout along with its stored value which
+// is nil if none is stored.
+func (cs *ChordStore) loadLayout(ns string) (*orderedLayout, []byte, error) {
+	raw, err := cs.Get(orderedLayoutKey(ns), ConsistencyQuorum)
+	if err != nil {
+		if isNotFound(err) {
+			return cs.layouts.get(ns), nil, nil
+		}
+		return nil, nil, err
+	}
+
+	var l orderedLayout
+	if err = msgpack.Unmarshal(raw, &l); err != nil {
+		return nil, nil, err
+	}
+	cs.adoptLayout(ns, &l)
+	return &l, raw, nil
+}
+
+// checkLayout returns an error if the key belongs to an ordered namespace whose
+// layout version in the stamp differs from the one adopted by the node, as the
+// coordinator then placed the key on other vnodes than the node would.  A newer
+// layout is loaded in the background so later writes are accepted.  Writes
+// stamped without layouts are not checked.
+func (cs *ChordStore) checkLayout(key []byte, ws *WriteStamp) error {
+	if cs.layouts == nil || ws == nil || ws.Layouts == nil {
+		return nil
+	}
+	ns := orderedNamespace(cs.ordered, key)
+	if ns == "" {
+		return nil
+	}
+
+	v, cur := ws.Layouts[ns], cs.layouts.get(ns).Version
+	if v == cur {
+		return nil
+	}
+	if v > cur && cs.layouts.startLoading(ns) {
+		go func() {
+			defer cs.layouts.doneLoading(ns)
+			if _, _, err := cs.loadLayout(ns); err != nil {
+				log.Printf("ERR [ordered] namespace=%s %v", ns, err)
+			}
+		}()
+	}
+	return fmt.Errorf("layout mismatch: namespace=%s version=%d local=%d", ns, v, cur)
+}
+
+// adoptLayout sets the layout of the namespace if newer, moving the keys of the
+// local hash trees to the leaves of their new positions
+func (cs *ChordStore) adoptLayout(ns string, l *orderedLayout) {
+	if !cs.layouts.set(ns, l) {
+		return
+	}
+	for _, st := range cs.store.local {
+		st.MerkleTree().rehash()
+	}
+	log.Printf("DBG [ordered] namespace=%s version=%d splits=%d", ns, l.Version, len(l.Splits))
+}
+
+// moveOrdered moves the keys of the namespace held by local vnodes that are no
+// longer replicas of them to their replicas.  It returns the largest number of
+// keys of the namespace left on a local vnode.
+func (cs *ChordStore) moveOrdered(ns string) (int, error) {
+	var max int
+	for _, vn := range cs.vnodes {
+		st, ok := cs.store.local[vn.StringID()]
+		if !ok {
+			continue
+		}
+
+		var (
+			held   int
+			cursor []byte
+		)
+		for {
+			keys, err := st.ListKeys([]byte(ns), cursor, listBatchSize)
+			if err != nil {
+				return max, err
+			}
+			for _, key := range keys {
+				vns, err := cs.lookup(cs.replicas, key)
+				if err != nil {
+					return max, err
+				}
+				if containsVnode(vns, vn) || cs.strong(key) {
+					held++
+					continue
+				}
+				if err = cs.moveKey(st, key, vns); err != nil {
+					log.Printf("ERR [ordered] vnode=%s key=%s %v", shortID(vn), key, err)
+					held++
+				}
+			}
+			if len(keys) < listBatchSize {
+				break
+			}
+			cursor = keys[len(keys)-1]
+		}
+
+		if held > max {
+			max = held
+		}
+	}
+	return max, nil
+}
+
+// moveKey copies the key of the local store to the replicas that neither hold
+// nor removed it then prunes it locally
+func (cs *ChordStore) moveKey(st VnodeStore, key []byte, vns []*chord.Vnode) error {
+	val, err := st.GetKey(key)
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	expiry, err := st.KeyExpiry(key)
+	if err != nil {
+		return err
+	}
+
+	for _, vn := range vns {
+		if _, err = cs.store.GetKey(vn, key); err == nil {
+			continue
+		} else if !isNotFound(err) {
+			return err
+		}
+		t, err := cs.store.KeyTombstone(vn, key)
+		if err != nil {
+			return err
+		}
+		if t > 0 {
+			continue
+		}
+		if err = cs.store.PutKey(vn, key, val, expiry, cs.newWriteStamp()); err != nil {
+			return err
+		}
+	}
+	return st.PruneKey(key)
+}
+
+// splitOrdered splits the namespace at the quantiles of its keys, one range per
+// vnode of the ring, unless the keys are already spread evenly so that no vnode
+// holds more than twice its share.  held is the most keys on a local vnode.
+func (cs *ChordStore) splitOrdered(ns string, held int) error {
+	vns, err := cs.ringVnodes()
+	if err != nil {
+		return err
+	}
+
+	var (
+		keys   [][]byte
+		cursor []byte
+	)
+	for {
+		page, next, err := cs.ListKeys([]byte(ns), cursor, listBatchSize)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, page...)
+		if next == nil {
+			break
+		}
+		cursor = next
+	}
+	if len(keys) == 0 || held <= 2*cs.replicas*len(keys)/len(vns) {
+		return nil
+	}
+
+	cur, raw, err := cs.loadLayout(ns)
+	if err != nil {
+		return err
+	}
+	l := &orderedLayout{Version: cur.Version + 1, Splits: orderedSplits(ns, keys, len(vns))}
+	if equalSplits(l.Splits, cur.Splits) {
+		return nil
+	}
+	b, err := msgpack.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	val, ok, err := cs.CompareAndSwap(orderedLayoutKey(ns), raw, b, ConsistencyQuorum)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		// Split concurrently by another node
+		l = &orderedLayout{}
+		if err = msgpack.Unmarshal(val, l); err != nil {
+			return err
+		}
+	}
+	cs.adoptLayout(ns, l)
+	return nil
+}
@@ -0,0 +1,251 @@
+package chordstore
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func Test_orderedHash(t *testing.T) {
+	layouts := newOrderedLayouts([]string{"ts/"})
+	hf := orderedHashFunc(sha1.New, layouts)
+	pos := func(key string) []byte {
+		h := hf()
+		h.Write([]byte(key))
+		return h.Sum(nil)
+	}
+
+	// Other keys use the base hash
+	s := sha1.Sum([]byte("key"))
+	if !bytes.Equal(pos("key"), s[:]) {
+		t.Fatal("should use base hash")
+	}
+
+	a, b := pos("ts/0001"), pos("ts/0002")
+	if len(a) != sha1.Size || bytes.Compare(a, b) >= 0 {
+		t.Fatalf("should be in key order %x %x", a, b)
+	}
+	// The namespace itself is at the start of its arc
+	ns := sha1.Sum([]byte("ts/"))
+	if !bytes.Equal(pos("ts/"), ns[:]) {
+		t.Fatal("namespace should be at its hash")
+	}
+
+	// Keys sharing a long prefix are spread once split
+	keys := make([][]byte, 100)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("ts/2026-10-18T10:00:%03d", i))
+	}
+	a, b = pos(string(keys[0])), pos(string(keys[99]))
+	if !bytes.Equal(a[:8], b[:8]) {
+		t.Fatalf("should be close before split %x %x", a, b)
+	}
+	splits := orderedSplits("ts/", keys, 4)
+	if len(splits) != 5 || string(splits[0]) != "2026-10-18T10:00:000" ||
+		string(splits[1]) != "2026-10-18T10:00:025" || string(splits[4]) != "2026-10-18T10:00:099" {
+		t.Fatalf("wrong splits %q", splits)
+	}
+	if !layouts.set("ts/", &orderedLayout{Version: 1, Splits: splits}) {
+		t.Fatal("should set newer layout")
+	}
+	if layouts.set("ts/", &orderedLayout{Version: 1}) {
+		t.Fatal("should not set same version")
+	}
+	// Distance of a key along the arc of the namespace
+	ring := new(big.Int).Lsh(big.NewInt(1), 8*sha1.Size)
+	off := func(key []byte) *big.Int {
+		d := new(big.Int).Sub(new(big.Int).SetBytes(pos(string(key))), new(big.Int).SetBytes(ns[:]))
+		return d.Mod(d, ring)
+	}
+	prev := off(keys[0])
+	for _, k := range keys[1:] {
+		o := off(k)
+		if prev.Cmp(o) >= 0 {
+			t.Fatalf("should be in key order after split %s %x %x", k, prev, o)
+		}
+		prev = o
+	}
+	// Each range covers an equal arc
+	arc := new(big.Int).Div(ring, big.NewInt(6))
+	if d := new(big.Int).Sub(off(keys[50]), off(keys[25])); d.Cmp(arc) != 0 {
+		t.Fatalf("split keys should be an arc apart %x", d)
+	}
+}
+
+func Test_ChordStore_Scan(t *testing.T) {
+	c1, err := initConfig(36031)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1.OrderedNamespaces = []string{"ts/"}
+	cs1, err := NewChordStore(c1, &MemKeyValueStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs1.Shutdown()
+	defer c1.Ring.Shutdown()
+
+	<-time.After(200 * time.Millisecond)
+	c2, err := initConfig(36032, "127.0.0.1:36031")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2.OrderedNamespaces = []string{"ts/"}
+	cs2, err := NewChordStore(c2, &MemKeyValueStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs2.Shutdown()
+	defer c2.Ring.Shutdown()
+
+	<-time.After(300 * time.Millisecond)
+
+	// Keys spread over the whole ring
+	keys := make([][]byte, 32)
+	for i := range keys {
+		keys[i] = append([]byte("ts/"), byte(i*8), 'x')
+		if _, err = cs1.PutKey(3, keys[i], []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = cs1.Scan([]byte("key"), nil, 0); err == nil {
+		t.Fatal("should fail outside an ordered namespace")
+	}
+
+	kvs, err := cs2.Scan(keys[5], keys[20], 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kvs) != 15 {
+		t.Fatal("wrong count", len(kvs))
+	}
+	for i, kv := range kvs {
+		if !bytes.Equal(kv.Key, keys[i+5]) || string(kv.Value) != fmt.Sprint(i+5) {
+			t.Fatalf("wrong key-value at %d: %q %q", i, kv.Key, kv.Value)
+		}
+	}
+
+	kvs, err = cs2.Scan(keys[10], nil, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kvs) != 4 || !bytes.Equal(kvs[3].Key, keys[13]) {
+		t.Fatal("limit not applied", len(kvs))
+	}
+
+	if kvs, _ = cs1.Scan(keys[0], nil, 0); len(kvs) != 32 {
+		t.Fatal("should scan the namespace", len(kvs))
+	}
+}
+
+func Test_ChordStore_OrderedSplit(t *testing.T) {
+	c1, err := initConfig(36047)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1.OrderedNamespaces = []string{"ts/"}
+	c1.OrderedBalanceInterval = 0
+	c1.OrderedSplitKeys = 10
+	cs1, err := NewChordStore(c1, &MemKeyValueStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs1.Shutdown()
+	defer c1.Ring.Shutdown()
+
+	<-time.After(200 * time.Millisecond)
+	c2, err := initConfig(36048, "127.0.0.1:36047")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2.OrderedNamespaces = []string{"ts/"}
+	c2.OrderedBalanceInterval = 0
+	c2.OrderedSplitKeys = 10
+	cs2, err := NewChordStore(c2, &MemKeyValueStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs2.Shutdown()
+	defer c2.Ring.Shutdown()
+
+	<-time.After(300 * time.Millisecond)
+
+	// Time series keys all land on the same vnodes
+	keys := make([][]byte, 100)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("ts/2026-10-18T10:00:%03d", i))
+		if err = cs1.Put(keys[i], []byte(fmt.Sprint(i)), ConsistencyAll); err != nil {
+			t.Fatal(err)
+		}
+	}
+	primaries := func() map[string]bool {
+		out := map[string]bool{}
+		for _, key := range keys {
+			vns, err := cs1.lookup(1, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out[vns[0].StringID()] = true
+		}
+		return out
+	}
+	if n := len(primaries()); n != 1 {
+		t.Fatal("should be on a single vnode", n)
+	}
+
+	cs1.balanceOrdered()
+	cs2.balanceOrdered()
+	cs1.balanceOrdered()
+
+	if cs1.layouts.get("ts/").Version == 0 || cs2.layouts.get("ts/").Version != cs1.layouts.get("ts/").Version {
+		t.Fatal("layout not adopted")
+	}
+	if n := len(primaries()); n < 4 {
+		t.Fatal("should be spread over vnodes", n)
+	}
+	for _, cs := range []*ChordStore{cs1, cs2} {
+		if held, err := cs.moveOrdered("ts/"); err != nil || held == len(keys) {
+			t.Fatal("keys not moved", held, err)
+		}
+	}
+
+	for i, key := range keys {
+		val, err := cs2.Get(key, ConsistencyAll)
+		if err != nil || string(val) != fmt.Sprint(i) {
+			t.Fatal(string(key), string(val), err)
+		}
+	}
+	kvs, err := cs2.Scan(keys[10], keys[60], 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kvs) != 50 {
+		t.Fatal("wrong count", len(kvs))
+	}
+	for i, kv := range kvs {
+		if !bytes.Equal(kv.Key, keys[i+10]) {
+			t.Fatalf("wrong key at %d: %q", i, kv.Key)
+		}
+	}
+	if kvs, _ = cs1.Scan(keys[0], nil, 0); len(kvs) != len(keys) {
+		t.Fatal("should scan the namespace", len(kvs))
+	}
+
+	// Writes placed with another layout are rejected
+	cur := cs1.layouts.get("ts/")
+	cs1.adoptLayout("ts/", &orderedLayout{Version: cur.Version + 1, Splits: cur.Splits})
+	vn := cs2.vnodes[0]
+	if err = cs1.store.PutKey(vn, keys[0], []byte("v"), 0, cs1.newWriteStamp()); err == nil {
+		t.Fatal("should fail with layout mismatch")
+	}
+	if err = cs1.store.PutKey(vn, []byte("key"), []byte("v"), 0, cs1.newWriteStamp()); err != nil {
+		t.Fatal("keys outside ordered namespaces should not be checked", err)
+	}
+	cs2.adoptLayout("ts/", cs1.layouts.get("ts/"))
+	if err = cs1.store.PutKey(vn, keys[0], []byte("v"), 0, cs1.newWriteStamp()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,1359 @@
+package chordstore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	chord "github.com/euforia/go-chord"
+	"github.com/golang/protobuf/proto"
+)
+
+// Raft log entry operations
+const (
+	raftOpPut    = "put"
+	raftOpRemove = "remove"
+	raftOpGet    = "get"
+	raftOpConfig = "config"
+	raftOpAdopt  = "adopt"
+)
+
+const defaultRaftElectionTimeout = time.Second
+
+// Default number of applied entries above which the log of a member is
+// compacted into a snapshot
+const defaultRaftCompactEntries = 1024
+
+// Election timeouts after which a member not placed in its group by the ring
+// and not hearing from a leader is retired
+const raftRetireTimeouts = 10
+
+var errNoStrongNamespaces = errors.New("no strong namespaces configured")
+
+type raftRole int
+
+const (
+	raftFollower raftRole = iota
+	raftCandidate
+	raftLeader
+)
+
+func (r raftRole) String() string {
+	switch r {
+	case raftCandidate:
+		return "candidate"
+	case raftLeader:
+		return "leader"
+	}
+	return "follower"
+}
+
+func isNotLeader(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "not the leader")
+}
+
+type raftResult struct {
+	value []byte
+	// stamp of the last write of the key read
+	stamp *WriteStamp
+	err   error
+}
+
+// raftRead is a read served by the leader once a quorum of members has
+// confirmed its leadership since the read was received, and the log has been
+// applied up to the commit index at that time
+type raftRead struct {
+	key   []byte
+	index uint64
+	start time.Time
+	// members that confirmed the leadership
+	acks map[string]bool
+	ch   chan raftResult
+}
+
+// raftGroups runs the Raft consensus groups of the strong namespaces.  A group
+// is formed by the primary vnode of a key range and its successors, i.e. the
+// replicas of the keys, and is identified by the primary vnode.  Local member
+// vnodes of a group join it when first contacted.  Committed writes are
+// applied to the vnode stores of the members so the keys are held by the same
+// replicas as other keys.
+type raftGroups struct {
+	cs         *ChordStore
+	namespaces []string
+	// election timeout.  Heartbeats are sent at a fifth of it.
+	timeout time.Duration
+	// applied entries above which logs are compacted
+	compactAt uint64
+	trans     *ChordStoreTransport
+
+	mu    sync.Mutex
+	nodes map[string]*raftNode
+	// local vnodes leaving the ring that are removed from groups
+	leaving map[string]bool
+	// set when the ring changes until the groups led locally match it
+	reconfig int32
+
+	stop chan bool
+}
+
+func newRaftGroups(cs *ChordStore, namespaces []string, timeout time.Duration, compactAt int) *raftGroups {
+	if timeout <= 0 {
+		timeout = defaultRaftElectionTimeout
+	}
+	if compactAt <= 0 {
+		compactAt = defaultRaftCompactEntries
+	}
+	return &raftGroups{
+		cs:         cs,
+		namespaces: namespaces,
+		timeout:    timeout,
+		compactAt:  uint64(compactAt),
+		trans:      NewChordStoreTransport(),
+		nodes:      map[string]*raftNode{},
+		leaving:    map[string]bool{},
+		stop:       make(chan bool, 1),
+	}
+}
+
+// start ticking the local members on every heartbeat.  This blocks until
+// shutdown is called.
+func (rg *raftGroups) start() {
+	tick := time.NewTicker(rg.timeout / 5)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-tick.C:
+		case <-rg.stop:
+			return
+		}
+
+		reconfig := atomic.SwapInt32(&rg.reconfig, 0) == 1
+		for _, n := range rg.list() {
+			if reconfig && !n.reconfigure() {
+				atomic.StoreInt32(&rg.reconfig, 1)
+			}
+			if n.tick() {
+				rg.retire(n)
+			}
+		}
+	}
+}
+
+func (rg *raftGroups) shutdown() {
+	rg.stop <- true
+	rg.trans.Shutdown()
+}
+
+// strong returns true if the key belongs to a strong namespace
+func (rg *raftGroups) strong(key []byte) bool {
+	return orderedNamespace(rg.namespaces, key) != ""
+}
+
+// checkWeak returns an error if the key belongs to a strong namespace.  Keys of
+// strong namespaces are only written through their consensus group so replicas
+// never diverge from it.
+func (cs *ChordStore) checkWeak(key []byte) error {
+	if cs.strong(key) {
+		return fmt.Errorf("key of a strong namespace only written with Put or Remove: %s", key)
+	}
+	return nil
+}
+
+// strong returns true if the key belongs to a strong namespace
+func (cs *ChordStore) strong(key []byte) bool {
+	return cs.raft != nil && cs.raft.strong(key)
+}
+
+// reconfigure the groups led locally to match the ring.  leaving is a local
+// vnode leaving the ring or nil.
+func (rg *raftGroups) reconfigure(leaving *chord.Vnode) {
+	if leaving != nil {
+		rg.mu.Lock()
+		rg.leaving[leaving.StringID()] = true
+		rg.mu.Unlock()
+	}
+	atomic.StoreInt32(&rg.reconfig, 1)
+}
+
+func (rg *raftGroups) list() []*raftNode {
+	rg.mu.Lock()
+	defer rg.mu.Unlock()
+
+	out := make([]*raftNode, 0, len(rg.nodes))
+	for _, n := range rg.nodes {
+		out = append(out, n)
+	}
+	return out
+}
+
+// node returns the member of the group on the local vnode.  It joins the group
+// with the given members if it is not yet a member.
+func (rg *raftGroups) node(group, vn *chord.Vnode, members []*chord.Vnode) (*raftNode, error) {
+	if group == nil || vn == nil {
+		return nil, fmt.Errorf("group and vnode required")
+	}
+	if _, ok := rg.cs.store.local[vn.StringID()]; !ok {
+		return nil, fmt.Errorf("vnode not found: %s", shortID(vn))
+	}
+
+	id := raftNodeID(group, vn)
+
+	rg.mu.Lock()
+	defer rg.mu.Unlock()
+
+	n, ok := rg.nodes[id]
+	if !ok {
+		var err error
+		if n, err = newRaftNode(rg, group, vn, members); err != nil {
+			return nil, err
+		}
+		rg.nodes[id] = n
+		log.Printf("DBG [raft] group=%s vnode=%s Joined members=%d", shortID(group), shortID(vn), len(members))
+	}
+	return n, nil
+}
+
+// retire the member so it is no longer ticked.  Its persisted state is kept and
+// loaded again should the vnode rejoin the group.
+func (rg *raftGroups) retire(n *raftNode) {
+	rg.mu.Lock()
+	delete(rg.nodes, raftNodeID(n.group, n.self))
+	rg.mu.Unlock()
+	log.Printf("DBG [raft] group=%s vnode=%s Retired", shortID(n.group), shortID(n.self))
+}
+
+func raftNodeID(group, vn *chord.Vnode) string {
+	return group.StringID() + "/" + vn.StringID()
+}
+
+// members returns the vnodes a group should have according to the ring
+func (rg *raftGroups) members(group *chord.Vnode) ([]*chord.Vnode, error) {
+	succs, err := rg.cs.trans.FindSuccessors(rg.cs.vnodes[0], rg.cs.successors, group.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	rg.mu.Lock()
+	defer rg.mu.Unlock()
+
+	out := make([]*chord.Vnode, 0, rg.cs.replicas)
+	for _, vn := range succs {
+		if len(out) == rg.cs.replicas {
+			break
+		}
+		if !rg.leaving[vn.StringID()] {
+			out = append(out, vn)
+		}
+	}
+	return out, nil
+}
+
+func (rg *raftGroups) isLocal(vn *chord.Vnode) bool {
+	_, ok := rg.cs.store.local[vn.StringID()]
+	return ok
+}
+
+func (rg *raftGroups) vote(req *RaftVoteRequest) (*RaftVoteResponse, error) {
+	if rg.isLocal(req.Vn) {
+		return rg.handleVote(req)
+	}
+	return rg.trans.RaftVote(req)
+}
+
+func (rg *raftGroups) appendEntries(req *RaftAppendRequest) (*RaftAppendResponse, error) {
+	if rg.isLocal(req.Vn) {
+		return rg.handleAppend(req)
+	}
+	return rg.trans.RaftAppend(req)
+}
+
+func (rg *raftGroups) propose(req *RaftProposal) (*RaftProposeResponse, error) {
+	if rg.isLocal(req.Vn) {
+		return rg.handlePropose(req), nil
+	}
+	return rg.trans.RaftPropose(req)
+}
+
+func (rg *raftGroups) handleVote(req *RaftVoteRequest) (*RaftVoteResponse, error) {
+	n, err := rg.node(req.Group, req.Vn, req.Members)
+	if err != nil {
+		return nil, err
+	}
+	return n.handleVote(req), nil
+}
+
+func (rg *raftGroups) handleAppend(req *RaftAppendRequest) (*RaftAppendResponse, error) {
+	n, err := rg.node(req.Group, req.Vn, req.Members)
+	if err != nil {
+		return nil, err
+	}
+	return n.handleAppend(req), nil
+}
+
+func (rg *raftGroups) handlePropose(req *RaftProposal) *RaftProposeResponse {
+	resp := &RaftProposeResponse{}
+	if req.Entry == nil {
+		resp.Err = "entry required"
+		return resp
+	}
+
+	n, err := rg.node(req.Group, req.Vn, req.Members)
+	if err == nil {
+		resp.Value, resp.Stamp, resp.Leader, err = n.propose(req.Entry)
+	}
+	if err != nil {
+		resp.Err = err.Error()
+	}
+	return resp
+}
+
+// submit the entry to the leader of the group of the key returning the result of
+// applying it
+func (rg *raftGroups) submit(key []byte, entry *RaftEntry) ([]byte, error) {
+	vns, err := rg.cs.lookup(rg.cs.replicas, key)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := rg.submitGroup(vns[0], vns, entry)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Value, nil
+}
+
+// submitGroup submits the entry to the leader of the group with the members.
+// Members are tried in turn until the leader is found or the request timeout
+// elapses.
+func (rg *raftGroups) submitGroup(group *chord.Vnode, members []*chord.Vnode, entry *RaftEntry) (*RaftProposeResponse, error) {
+	if len(members) == 0 {
+		return nil, fmt.Errorf("group has no members: %s", shortID(group))
+	}
+
+	var (
+		target   = members[0]
+		deadline = time.Now().Add(rg.cs.timeout)
+	)
+	for i := 1; ; i++ {
+		var leader *chord.Vnode
+		resp, err := rg.propose(&RaftProposal{Group: group, Vn: target, Entry: entry, Members: members})
+		if err == nil {
+			if resp.Err == "" {
+				return resp, nil
+			}
+			if err = fmt.Errorf(resp.Err); !isNotLeader(err) {
+				return nil, err
+			}
+			leader = resp.Leader
+		}
+
+		if time.Now().After(deadline) {
+			return nil, err
+		}
+		if leader != nil && leader.StringID() != target.StringID() {
+			target = leader
+			continue
+		}
+		// No leader yet or the member is unreachable
+		target = members[i%len(members)]
+		<-time.After(rg.timeout / 5)
+	}
+}
+
+// handoff moves the keys of the strong namespaces on the local vnode in the range
+// to the group now responsible for them.  Groups are identified by their
+// primary vnode and keys of strong namespaces are not transferred with the
+// vnode data, so when vnodes join in front of the local vnode the keys in the
+// range they took over move to the groups of their new primaries, and when the
+// node leaves the keys of the group of the local vnode move to that of the
+// first successor on another node.  Values are read through the group of the
+// local vnode and adopted by the new group unless it wrote or removed them since.
+func (rg *raftGroups) handoff(local *chord.Vnode, kr *KeyRange, leaving bool) {
+	st, ok := rg.cs.store.local[local.StringID()]
+	if !ok {
+		return
+	}
+
+	var moved int
+	for _, ns := range rg.namespaces {
+		keys, err := st.ListKeys([]byte(ns), nil, 0)
+		if err != nil {
+			log.Printf("ERR [raft] vnode=%s Handoff %v", shortID(local), err)
+			continue
+		}
+		for _, key := range keys {
+			if !kr.Contains(key) {
+				continue
+			}
+			to, err := rg.handoffTarget(local, key, leaving)
+			if err != nil {
+				log.Printf("ERR [raft] vnode=%s key=%s Handoff %v", shortID(local), key, err)
+				continue
+			}
+			if to == nil {
+				continue
+			}
+			if err = rg.handoffKey(local, to, key); err != nil {
+				log.Printf("ERR [raft] group=%s key=%s Handoff %v", shortID(to), key, err)
+				continue
+			}
+			moved++
+		}
+	}
+	if moved > 0 {
+		log.Printf("DBG [raft] vnode=%s Handed off keys=%d", shortID(local), moved)
+	}
+}
+
+// handoffTarget returns the group the key moves to from the group of the local
+// vnode, or nil if it stays.  As all local vnodes leave with the node, the
+// target of a leaving vnode is the first successor on another node.
+func (rg *raftGroups) handoffTarget(local *chord.Vnode, key []byte, leaving bool) (*chord.Vnode, error) {
+	vns, err := rg.cs.lookup(rg.cs.successors, key)
+	if err != nil {
+		return nil, err
+	}
+	if vns[0].StringID() != local.StringID() {
+		if leaving {
+			return nil, nil
+		}
+		return vns[0], nil
+	}
+	if !leaving {
+		return nil, nil
+	}
+	for _, vn := range vns[1:] {
+		if !rg.isLocal(vn) {
+			return vn, nil
+		}
+	}
+	return nil, fmt.Errorf("no successor on another node")
+}
+
+// handoffKey reads the key through the group from and has the group to adopt it
+func (rg *raftGroups) handoffKey(from, to *chord.Vnode, key []byte) error {
+	members, err := rg.members(from)
+	if err != nil {
+		return err
+	}
+	resp, err := rg.submitGroup(from, members, &RaftEntry{Op: raftOpGet, Key: key})
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	if members, err = rg.members(to); err != nil {
+		return err
+	}
+	_, err = rg.submitGroup(to, members, &RaftEntry{Op: raftOpAdopt, Key: key, Value: resp.Value, Stamp: resp.Stamp})
+	return err
+}
+
+// raftNode is a local member vnode of a consensus group.  Its term, vote and log
+// are persisted by the vnode store before they are acted upon, so a restarted
+// member resumes where it left off.  New entries are appended to the persisted
+// state, which is only rewritten when the term, vote or snapshot change or the
+// log is truncated.  Applied entries are compacted into a
+// snapshot, which is the vnode store itself, and members behind the compacted
+// log are sent the keys of the group.
+type raftNode struct {
+	rg    *raftGroups
+	group *chord.Vnode
+	self  *chord.Vnode
+	disk  raftPersisting
+
+	mu       sync.Mutex
+	role     raftRole
+	term     uint64
+	votedFor string
+	leader   *chord.Vnode
+	// last time the leader was heard from
+	contact time.Time
+	// election deadline
+	deadline time.Time
+	// log[i] is the entry at index offset+i.  log[0] is the last entry
+	// compacted into the snapshot, or a sentinel before the first entry.
+	log     []*RaftEntry
+	offset  uint64
+	commit  uint64
+	applied uint64
+	// last index persisted
+	stored uint64
+	// generation, term, vote and offset of the state last written.  The state
+	// is rewritten when these change or rewrite is set.
+	gen         uint64
+	savedTerm   uint64
+	savedVote   string
+	savedOffset uint64
+	rewrite     bool
+	// members from the latest config entry, or those of the snapshot or the
+	// member joined with
+	members   []*chord.Vnode
+	initial   []*chord.Vnode
+	configIdx uint64
+	// leader state
+	next     map[string]uint64
+	match    map[string]uint64
+	inflight map[string]bool
+	waiters  map[uint64]chan raftResult
+	reads    []*raftRead
+}
+
+// newRaftNode loads the member of the group on the local vnode from its
+// persisted state, or starts it with the given members if it has none
+func newRaftNode(rg *raftGroups, group, self *chord.Vnode, members []*chord.Vnode) (*raftNode, error) {
+	disk, ok := rg.cs.store.local[self.StringID()].(raftPersisting)
+	if !ok {
+		return nil, fmt.Errorf("vnode store does not persist raft state: %s", shortID(self))
+	}
+
+	n := &raftNode{
+		rg:      rg,
+		group:   group,
+		self:    self,
+		disk:    disk,
+		log:     []*RaftEntry{&RaftEntry{}},
+		members: members,
+		initial: members,
+		contact: time.Now(),
+		waiters: map[uint64]chan raftResult{},
+	}
+
+	b, recs, err := disk.loadRaftState(group.StringID())
+	if err != nil {
+		return nil, err
+	}
+	if b != nil {
+		var st RaftState
+		if err = proto.Unmarshal(b, &st); err != nil {
+			return nil, err
+		}
+		if len(st.Entries) == 0 {
+			return nil, fmt.Errorf("invalid raft state: group=%s vnode=%s", shortID(group), shortID(self))
+		}
+		n.term, n.votedFor, n.offset, n.initial, n.log = st.Term, st.VotedFor, st.Offset, st.Members, st.Entries
+		n.gen = st.Generation
+		for _, rb := range recs {
+			var rec RaftState
+			if err = proto.Unmarshal(rb, &rec); err != nil {
+				return nil, err
+			}
+			// Records of earlier generations are left by a crash before they
+			// were removed
+			if rec.Generation == n.gen && rec.Offset == n.lastIndex() {
+				n.log = append(n.log, rec.Entries...)
+			}
+		}
+		n.savedTerm, n.savedVote, n.savedOffset = n.term, n.votedFor, n.offset
+		// Entries up to the snapshot are in the vnode store
+		n.commit, n.applied, n.stored = n.offset, n.offset, n.lastIndex()
+		n.loadConfig()
+	}
+
+	n.resetDeadline()
+	return n, nil
+}
+
+// persist the term, vote and log of the member.  Entries appended since the
+// last write are appended to the stored state.
+func (n *raftNode) persist() error {
+	var (
+		group = n.group.StringID()
+		b     []byte
+		err   error
+	)
+	if n.gen == 0 || n.rewrite || n.term != n.savedTerm || n.votedFor != n.savedVote || n.offset != n.savedOffset {
+		st := &RaftState{Term: n.term, VotedFor: n.votedFor, Offset: n.offset, Members: n.initial, Entries: n.log, Generation: n.gen + 1}
+		if b, err = proto.Marshal(st); err == nil {
+			err = n.disk.saveRaftState(group, b)
+		}
+		if err == nil {
+			n.gen++
+			n.savedTerm, n.savedVote, n.savedOffset, n.rewrite = n.term, n.votedFor, n.offset, false
+		}
+	} else if n.stored < n.lastIndex() {
+		rec := &RaftState{Generation: n.gen, Offset: n.stored, Entries: n.log[n.stored+1-n.offset:]}
+		if b, err = proto.Marshal(rec); err == nil {
+			err = n.disk.appendRaftLog(group, b)
+		}
+	}
+	if err != nil {
+		log.Printf("ERR [raft] group=%s vnode=%s Persist %v", shortID(n.group), shortID(n.self), err)
+		return err
+	}
+	n.stored = n.lastIndex()
+	return nil
+}
+
+func (n *raftNode) lastIndex() uint64 {
+	return n.offset + uint64(len(n.log)-1)
+}
+
+// entry at the index which must not be before the snapshot
+func (n *raftNode) entry(idx uint64) *RaftEntry {
+	return n.log[idx-n.offset]
+}
+
+func (n *raftNode) lastTerm() uint64 {
+	return n.log[len(n.log)-1].Term
+}
+
+func (n *raftNode) resetDeadline() {
+	n.deadline = time.Now().Add(n.rg.timeout + time.Duration(rand.Int63n(int64(n.rg.timeout))))
+}
+
+func (n *raftNode) isMember(vn *chord.Vnode) bool {
+	return containsVnode(n.members, vn)
+}
+
+func (n *raftNode) quorum() int {
+	return len(n.members)/2 + 1
+}
+
+// tick sends heartbeats as the leader or starts an election once the deadline
+// passes.  It returns true if the member is to be retired.
+func (n *raftNode) tick() bool {
+	n.mu.Lock()
+	if n.role == raftLeader {
+		n.contact = time.Now()
+		n.replicate()
+		n.mu.Unlock()
+		return false
+	}
+	due := time.Now().After(n.deadline) && n.isMember(n.self)
+	idle := time.Since(n.contact) > raftRetireTimeouts*n.rg.timeout
+	n.mu.Unlock()
+
+	if !due && !idle {
+		return false
+	}
+	// Members no longer placed in the group by the ring, e.g. removed ones that
+	// did not hear of their removal, do not disrupt it.  They are retired once
+	// no leader has been heard from for a while.
+	if target, err := n.rg.members(n.group); err == nil && !containsVnode(target, n.self) {
+		if idle {
+			return true
+		}
+		n.mu.Lock()
+		n.resetDeadline()
+		n.mu.Unlock()
+		return false
+	}
+	if !due {
+		return false
+	}
+
+	n.mu.Lock()
+	if n.role != raftLeader && time.Now().After(n.deadline) {
+		n.campaign()
+	}
+	n.mu.Unlock()
+	return false
+}
+
+// campaign for leadership in a new term
+func (n *raftNode) campaign() {
+	n.term++
+	n.role = raftCandidate
+	n.votedFor = n.self.StringID()
+	n.leader = nil
+	n.resetDeadline()
+	if n.persist() != nil {
+		return
+	}
+
+	log.Printf("DBG [raft] group=%s vnode=%s Campaigning term=%d", shortID(n.group), shortID(n.self), n.term)
+
+	votes := 1
+	if votes >= n.quorum() {
+		n.becomeLeader()
+		return
+	}
+
+	for _, m := range n.members {
+		if m.StringID() == n.self.StringID() {
+			continue
+		}
+		req := &RaftVoteRequest{
+			Group:        n.group,
+			Vn:           m,
+			Candidate:    n.self,
+			Term:         n.term,
+			LastLogIndex: n.lastIndex(),
+			LastLogTerm:  n.lastTerm(),
+			Members:      n.members,
+		}
+		go func() {
+			resp, err := n.rg.vote(req)
+			if err != nil {
+				log.Printf("ERR [raft] group=%s vnode=%s Vote %v", shortID(n.group), shortID(req.Vn), err)
+				return
+			}
+
+			n.mu.Lock()
+			defer n.mu.Unlock()
+
+			if resp.Term > n.term {
+				n.stepDown(resp.Term)
+				return
+			}
+			if n.role != raftCandidate || n.term != req.Term || !resp.Granted {
+				return
+			}
+			if votes++; votes >= n.quorum() {
+				n.becomeLeader()
+			}
+		}()
+	}
+}
+
+func (n *raftNode) becomeLeader() {
+	n.role = raftLeader
+	n.leader = n.self
+	n.next = map[string]uint64{}
+	n.match = map[string]uint64{}
+	n.inflight = map[string]bool{}
+	for _, m := range n.members {
+		n.next[m.StringID()] = n.lastIndex() + 1
+	}
+
+	log.Printf("DBG [raft] group=%s vnode=%s Elected term=%d members=%d", shortID(n.group), shortID(n.self), n.term, len(n.members))
+
+	// Entries of previous terms commit along with this one.  It also records
+	// the members the group was joined with.
+	n.appendEntry(&RaftEntry{Op: raftOpConfig, Members: n.members})
+	n.replicate()
+}
+
+// stepDown to a follower of the term failing pending proposals and reads
+func (n *raftNode) stepDown(term uint64) {
+	if term > n.term {
+		n.term = term
+		n.votedFor = ""
+		n.persist()
+	}
+	if n.role == raftLeader {
+		for idx, ch := range n.waiters {
+			ch <- raftResult{err: fmt.Errorf("leadership lost")}
+			delete(n.waiters, idx)
+		}
+		for _, r := range n.reads {
+			r.ch <- raftResult{err: fmt.Errorf("leadership lost")}
+		}
+		n.reads = nil
+	}
+	n.role = raftFollower
+	n.resetDeadline()
+}
+
+// appendEntry to the log of the leader in the current term.  The leader counts
+// towards the quorum of an entry once it is persisted.
+func (n *raftNode) appendEntry(e *RaftEntry) uint64 {
+	e.Term = n.term
+	n.log = append(n.log, e)
+	if e.Op == raftOpConfig {
+		n.members, n.configIdx = e.Members, n.lastIndex()
+	}
+	n.persist()
+	n.advanceCommit()
+	return n.lastIndex()
+}
+
+// replicate the log to all members not waiting on a response
+func (n *raftNode) replicate() {
+	for _, m := range n.members {
+		if m.StringID() != n.self.StringID() {
+			n.sendAppend(m)
+		}
+	}
+}
+
+func (n *raftNode) sendAppend(m *chord.Vnode) {
+	id := m.StringID()
+	if n.inflight[id] {
+		return
+	}
+	next, ok := n.next[id]
+	if !ok || next < 1 || next > n.lastIndex()+1 {
+		next = n.lastIndex() + 1
+		n.next[id] = next
+	}
+
+	req := &RaftAppendRequest{
+		Group:   n.group,
+		Vn:      m,
+		Leader:  n.self,
+		Term:    n.term,
+		Commit:  n.commit,
+		Members: n.members,
+	}
+	var snap *RaftSnapshot
+	if next > n.offset {
+		req.PrevLogIndex = next - 1
+		req.PrevLogTerm = n.entry(next - 1).Term
+		req.Entries = append([]*RaftEntry(nil), n.log[next-n.offset:]...)
+	} else {
+		// The entries the member needs were compacted
+		members, _ := n.configAt(n.applied)
+		snap = &RaftSnapshot{Index: n.applied, Term: n.entry(n.applied).Term, Members: members}
+		req.PrevLogIndex, req.PrevLogTerm = snap.Index, snap.Term
+	}
+	n.inflight[id] = true
+	sent := time.Now()
+
+	go func() {
+		if snap != nil {
+			// The keys are read without holding the lock
+			if err := n.readSnapshot(snap); err != nil {
+				log.Printf("ERR [raft] group=%s vnode=%s Snapshot %v", shortID(n.group), shortID(n.self), err)
+				n.mu.Lock()
+				n.inflight[id] = false
+				n.mu.Unlock()
+				return
+			}
+			req.Snapshot = snap
+		}
+		resp, err := n.rg.appendEntries(req)
+
+		n.mu.Lock()
+		defer n.mu.Unlock()
+
+		n.inflight[id] = false
+		if err != nil {
+			log.Printf("ERR [raft] group=%s vnode=%s Append %v", shortID(n.group), shortID(m), err)
+			return
+		}
+		if resp.Term > n.term {
+			n.stepDown(resp.Term)
+			return
+		}
+		if n.role != raftLeader || n.term != req.Term {
+			return
+		}
+		// The member recognized the leadership as of the request
+		for _, r := range n.reads {
+			if !sent.Before(r.start) {
+				r.acks[id] = true
+			}
+		}
+		n.serveReads()
+
+		if resp.Success {
+			if match := req.PrevLogIndex + uint64(len(req.Entries)); match > n.match[id] {
+				n.match[id] = match
+			}
+			n.next[id] = n.match[id] + 1
+			n.advanceCommit()
+		} else {
+			next := n.next[id] - 1
+			if resp.LastLogIndex+1 < next {
+				next = resp.LastLogIndex + 1
+			}
+			if next < 1 {
+				next = 1
+			}
+			n.next[id] = next
+		}
+
+		if (n.next[id] <= n.lastIndex() || n.awaitsAck(id)) && n.isMember(m) {
+			n.sendAppend(m)
+		}
+	}()
+}
+
+// awaitsAck returns true if a pending read awaits the member confirming the
+// leadership
+func (n *raftNode) awaitsAck(id string) bool {
+	for _, r := range n.reads {
+		if !r.acks[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// serveReads serves the pending reads whose leadership has been confirmed by a
+// quorum.  The leader only knows the commit index once an entry of its term has
+// committed.
+func (n *raftNode) serveReads() {
+	if n.role != raftLeader || n.entry(n.commit).Term != n.term {
+		return
+	}
+
+	var pending []*raftRead
+	for _, r := range n.reads {
+		var acks int
+		for _, m := range n.members {
+			if r.acks[m.StringID()] {
+				acks++
+			}
+		}
+		if acks < n.quorum() || n.applied < r.index {
+			pending = append(pending, r)
+			continue
+		}
+
+		var res raftResult
+		if st, ok := n.rg.cs.store.local[n.self.StringID()]; ok {
+			if res.value, res.err = st.GetKey(r.key); res.err == nil {
+				res.stamp = lastWriteStamp(st, r.key)
+			}
+		} else {
+			res.err = fmt.Errorf("vnode not found: %s", shortID(n.self))
+		}
+		r.ch <- res
+	}
+	n.reads = pending
+}
+
+// readSnapshot reads the keys of the group from the vnode store into the
+// snapshot.  Entries applied after the snapshot index may be reflected as they
+// are applied again by the member installing it.
+func (n *raftNode) readSnapshot(snap *RaftSnapshot) error {
+	st, ok := n.rg.cs.store.local[n.self.StringID()]
+	if !ok {
+		return fmt.Errorf("vnode not found: %s", shortID(n.self))
+	}
+	keys, err := n.groupKeys()
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		val, err := st.GetKey(key)
+		if err != nil {
+			if isNotFound(err) {
+				continue
+			}
+			return err
+		}
+		snap.Entries = append(snap.Entries, &RaftEntry{Op: raftOpPut, Key: key, Value: val, Stamp: lastWriteStamp(st, key)})
+	}
+	return nil
+}
+
+// install the snapshot replacing the keys of the group in the vnode store and
+// the log.  keys are those of the group in the vnode store before.
+func (n *raftNode) install(snap *RaftSnapshot, keys [][]byte) error {
+	if snap.Index <= n.applied {
+		return nil
+	}
+	st, ok := n.rg.cs.store.local[n.self.StringID()]
+	if !ok {
+		return fmt.Errorf("vnode not found: %s", shortID(n.self))
+	}
+
+	in := map[string]bool{}
+	for _, e := range snap.Entries {
+		if err := st.PutKey(e.Key, e.Value, 0, e.Stamp); err != nil {
+			return err
+		}
+		in[string(e.Key)] = true
+	}
+	for _, key := range keys {
+		if in[string(key)] {
+			continue
+		}
+		if err := st.RemoveKey(key, nil); err != nil && !isNotFound(err) {
+			return err
+		}
+	}
+
+	n.log = []*RaftEntry{&RaftEntry{Term: snap.Term}}
+	n.offset, n.commit, n.applied = snap.Index, snap.Index, snap.Index
+	n.initial = snap.Members
+	n.loadConfig()
+
+	log.Printf("DBG [raft] group=%s vnode=%s Installed snapshot index=%d keys=%d", shortID(n.group), shortID(n.self), snap.Index, len(snap.Entries))
+	return nil
+}
+
+// groupKeys returns the keys of the strong namespaces in the vnode store whose
+// primary vnode is the group.  It is called without holding the lock as each key
+// is looked up on the ring.
+func (n *raftNode) groupKeys() ([][]byte, error) {
+	st, ok := n.rg.cs.store.local[n.self.StringID()]
+	if !ok {
+		return nil, fmt.Errorf("vnode not found: %s", shortID(n.self))
+	}
+
+	var out [][]byte
+	for _, ns := range n.rg.namespaces {
+		keys, err := st.ListKeys([]byte(ns), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			vns, err := n.rg.cs.lookup(1, key)
+			if err != nil {
+				return nil, err
+			}
+			if bytes.Equal(vns[0].Id, n.group.Id) {
+				out = append(out, key)
+			}
+		}
+	}
+	return out, nil
+}
+
+// compact the applied entries of the log into the snapshot once there are more
+// than allowed.  The snapshot is the vnode store itself.
+func (n *raftNode) compact() {
+	if n.applied-n.offset <= n.rg.compactAt {
+		return
+	}
+	n.initial, _ = n.configAt(n.applied)
+	n.log = append([]*RaftEntry{&RaftEntry{Term: n.entry(n.applied).Term}}, n.log[n.applied+1-n.offset:]...)
+	n.offset = n.applied
+	n.loadConfig()
+	n.persist()
+}
+
+// advanceCommit to the latest entry of the term stored by a quorum of members
+func (n *raftNode) advanceCommit() {
+	if n.role != raftLeader {
+		return
+	}
+	for idx := n.lastIndex(); idx > n.commit && n.entry(idx).Term == n.term; idx-- {
+		var count int
+		for _, m := range n.members {
+			if m.StringID() == n.self.StringID() && n.stored >= idx || n.match[m.StringID()] >= idx {
+				count++
+			}
+		}
+		if count >= n.quorum() {
+			n.commit = idx
+			break
+		}
+	}
+	n.apply()
+}
+
+// apply committed entries to the vnode store returning the results to pending
+// proposals
+func (n *raftNode) apply() {
+	for n.applied < n.commit {
+		n.applied++
+		e := n.entry(n.applied)
+
+		res := n.applyEntry(e)
+		if ch, ok := n.waiters[n.applied]; ok {
+			ch <- res
+			delete(n.waiters, n.applied)
+		}
+
+		// A leader removed from the group leaves once the removal commits
+		if e.Op == raftOpConfig && n.role == raftLeader && !n.isMember(n.self) {
+			log.Printf("DBG [raft] group=%s vnode=%s Removed from group", shortID(n.group), shortID(n.self))
+			n.stepDown(n.term)
+		}
+	}
+	n.serveReads()
+	n.compact()
+}
+
+func (n *raftNode) applyEntry(e *RaftEntry) raftResult {
+	if e.Op == raftOpConfig {
+		return raftResult{}
+	}
+
+	st, ok := n.rg.cs.store.local[n.self.StringID()]
+	if !ok {
+		return raftResult{err: fmt.Errorf("vnode not found: %s", shortID(n.self))}
+	}
+
+	var res raftResult
+	switch e.Op {
+	case raftOpPut:
+		res.err = st.PutKey(e.Key, e.Value, 0, e.Stamp)
+	case raftOpRemove:
+		res.err = st.RemoveKey(e.Key, e.Stamp)
+	case raftOpAdopt:
+		res.err = adoptKey(st, e)
+	default:
+		res.err = fmt.Errorf("invalid raft op: %s", e.Op)
+	}
+	return res
+}
+
+// adoptKey puts the key handed off by another group unless the store holds a
+// write or removal of it at or after the time of the adopted write
+func adoptKey(st VnodeStore, e *RaftEntry) error {
+	var at int64
+	if e.Stamp != nil {
+		at = e.Stamp.Timestamp
+	}
+	txns, err := st.KeyHistory(e.Key)
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+	ts, err := st.KeyTombstone(e.Key)
+	if err != nil {
+		return err
+	}
+	if wt := keyWriteTime(txns); (wt > 0 && wt >= at) || (ts > 0 && ts >= at) {
+		return nil
+	}
+	return st.PutKey(e.Key, e.Value, 0, e.Stamp)
+}
+
+// propose the entry returning the result of applying it once committed.  Gets
+// are not appended but served once the leadership is confirmed, along with the
+// stamp of the last write of the key.  The leader known to the member is
+// returned if it is not the leader.
+func (n *raftNode) propose(e *RaftEntry) ([]byte, *WriteStamp, *chord.Vnode, error) {
+	n.mu.Lock()
+	if n.role != raftLeader {
+		leader := n.leader
+		// Members joining through a proposal campaign right away rather than
+		// wait out the election timeout
+		if n.term == 0 && n.isMember(n.self) {
+			n.campaign()
+		}
+		n.mu.Unlock()
+		return nil, nil, leader, fmt.Errorf("vnode %s is not the leader of group %s", shortID(n.self), shortID(n.group))
+	}
+
+	var (
+		ch     = make(chan raftResult, 1)
+		cancel func()
+	)
+	if e.Op == raftOpGet {
+		r := &raftRead{key: e.Key, index: n.commit, start: time.Now(), acks: map[string]bool{n.self.StringID(): true}, ch: ch}
+		n.reads = append(n.reads, r)
+		n.serveReads()
+		cancel = func() { n.dropRead(r) }
+	} else {
+		entry := &RaftEntry{Op: e.Op, Key: e.Key, Value: e.Value, Stamp: e.Stamp}
+		n.waiters[n.lastIndex()+1] = ch
+		idx := n.appendEntry(entry)
+		cancel = func() { delete(n.waiters, idx) }
+	}
+	n.replicate()
+	n.mu.Unlock()
+
+	select {
+	case res := <-ch:
+		return res.value, res.stamp, nil, res.err
+	case <-time.After(n.rg.cs.timeout):
+	}
+
+	n.mu.Lock()
+	cancel()
+	n.mu.Unlock()
+	return nil, nil, nil, fmt.Errorf("proposal timed out: %s", e.Key)
+}
+
+func (n *raftNode) dropRead(r *raftRead) {
+	for i, pr := range n.reads {
+		if pr == r {
+			n.reads = append(n.reads[:i], n.reads[i+1:]...)
+			return
+		}
+	}
+}
+
+func (n *raftNode) handleVote(req *RaftVoteRequest) *RaftVoteResponse {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	// Ignore members that have not heard from a live leader, e.g. removed ones
+	if n.leader != nil && time.Since(n.contact) < n.rg.timeout {
+		return &RaftVoteResponse{Term: n.term}
+	}
+	if req.Term > n.term {
+		n.stepDown(req.Term)
+	}
+
+	resp := &RaftVoteResponse{Term: n.term}
+	if req.Term < n.term || req.Candidate == nil {
+		return resp
+	}
+
+	cand := req.Candidate.StringID()
+	upToDate := req.LastLogTerm > n.lastTerm() ||
+		(req.LastLogTerm == n.lastTerm() && req.LastLogIndex >= n.lastIndex())
+	if (n.votedFor == "" || n.votedFor == cand) && upToDate {
+		n.votedFor = cand
+		if n.persist() != nil {
+			n.votedFor = ""
+			return resp
+		}
+		n.resetDeadline()
+		resp.Granted = true
+	}
+	return resp
+}
+
+func (n *raftNode) handleAppend(req *RaftAppendRequest) *RaftAppendResponse {
+	// Keys replaced by a snapshot are listed without holding the lock
+	var (
+		keys [][]byte
+		kerr error
+	)
+	if req.Snapshot != nil {
+		keys, kerr = n.groupKeys()
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	resp := &RaftAppendResponse{Term: n.term, LastLogIndex: n.lastIndex()}
+	if req.Term < n.term {
+		return resp
+	}
+	if req.Term > n.term || n.role != raftFollower {
+		n.stepDown(req.Term)
+	}
+	resp.Term = n.term
+	n.leader = req.Leader
+	n.contact = time.Now()
+	n.resetDeadline()
+
+	if req.Snapshot != nil {
+		err := kerr
+		if err == nil {
+			err = n.install(req.Snapshot, keys)
+		}
+		if err != nil {
+			log.Printf("ERR [raft] group=%s vnode=%s Install %v", shortID(n.group), shortID(n.self), err)
+			return resp
+		}
+		if n.persist() != nil {
+			return resp
+		}
+		resp.Success = true
+		resp.LastLogIndex = n.lastIndex()
+		return resp
+	}
+
+	if req.PrevLogIndex > n.lastIndex() {
+		return resp
+	}
+	// Entries up to the snapshot are committed so match those of the leader
+	prev, entries := req.PrevLogIndex, req.Entries
+	if prev < n.offset {
+		skip := n.offset - prev
+		if skip > uint64(len(entries)) {
+			skip = uint64(len(entries))
+		}
+		prev, entries = n.offset, entries[skip:]
+	} else if n.entry(prev).Term != req.PrevLogTerm {
+		resp.LastLogIndex = prev - 1
+		return resp
+	}
+
+	var truncated bool
+	for i, e := range entries {
+		idx := prev + 1 + uint64(i)
+		if idx <= n.lastIndex() {
+			if n.entry(idx).Term == e.Term {
+				continue
+			}
+			n.log = n.log[:idx-n.offset]
+			truncated = true
+			n.rewrite = true
+		}
+		n.log = append(n.log, e)
+		if e.Op == raftOpConfig {
+			n.members, n.configIdx = e.Members, idx
+		}
+	}
+	if truncated {
+		n.loadConfig()
+	}
+	if n.stored != n.lastIndex() || truncated {
+		if n.persist() != nil {
+			resp.LastLogIndex = n.lastIndex()
+			return resp
+		}
+	}
+
+	if last := req.PrevLogIndex + uint64(len(req.Entries)); req.Commit > n.commit {
+		n.commit = req.Commit
+		if last < n.commit {
+			n.commit = last
+		}
+		n.apply()
+	}
+
+	resp.Success = true
+	resp.LastLogIndex = n.lastIndex()
+	return resp
+}
+
+// loadConfig sets the members from the latest config entry of the log
+func (n *raftNode) loadConfig() {
+	n.members, n.configIdx = n.configAt(n.lastIndex())
+}
+
+// configAt returns the members and index of the latest config entry up to the
+// index, or the members of the snapshot if none is in the log
+func (n *raftNode) configAt(idx uint64) ([]*chord.Vnode, uint64) {
+	for ; idx > n.offset; idx-- {
+		if e := n.entry(idx); e.Op == raftOpConfig {
+			return e.Members, idx
+		}
+	}
+	return n.initial, n.offset
+}
+
+// reconfigure moves the members of a group led by the node one vnode towards
+// those of the ring.  A change is only made once the previous one has
+// committed so the majorities of consecutive configs always overlap.  It
+// returns true once the members match the ring.
+func (n *raftNode) reconfigure() bool {
+	target, err := n.rg.members(n.group)
+	if err != nil {
+		log.Printf("ERR [raft] group=%s %v", shortID(n.group), err)
+		return false
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.role != raftLeader {
+		return true
+	}
+	if n.configIdx > n.commit {
+		return false
+	}
+
+	members, changed := stepMembers(n.members, target)
+	if !changed {
+		return true
+	}
+
+	log.Printf("DBG [raft] group=%s vnode=%s Reconfiguring members=%d", shortID(n.group), shortID(n.self), len(members))
+	n.appendEntry(&RaftEntry{Op: raftOpConfig, Members: members})
+	n.replicate()
+	return false
+}
+
+// stepMembers returns the members with a single vnode added from or removed
+// to match the target.  Vnodes are added before any are removed.
+func stepMembers(members, target []*chord.Vnode) ([]*chord.Vnode, bool) {
+	for _, vn := range target {
+		if !containsVnode(members, vn) {
+			out := make([]*chord.Vnode, len(members), len(members)+1)
+			copy(out, members)
+			return append(out, vn), true
+		}
+	}
+	for i, vn := range members {
+		if !containsVnode(target, vn) {
+			out := make([]*chord.Vnode, 0, len(members)-1)
+			out = append(out, members[:i]...)
+			return append(out, members[i+1:]...), true
+		}
+	}
+	return members, false
+}
@@ -0,0 +1,297 @@
+package chordstore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	chord "github.com/euforia/go-chord"
+)
+
+func Test_stepMembers(t *testing.T) {
+	a, b, c, d := &chord.Vnode{Id: []byte("a")}, &chord.Vnode{Id: []byte("b")}, &chord.Vnode{Id: []byte("c")}, &chord.Vnode{Id: []byte("d")}
+
+	members, changed := stepMembers([]*chord.Vnode{a, b, c}, []*chord.Vnode{a, b, d})
+	if !changed || len(members) != 4 || !containsVnode(members, d) {
+		t.Fatal("should add before removing", members)
+	}
+	if members, changed = stepMembers(members, []*chord.Vnode{a, b, d}); !changed || len(members) != 3 || containsVnode(members, c) {
+		t.Fatal("should remove", members)
+	}
+	if _, changed = stepMembers(members, []*chord.Vnode{d, b, a}); changed {
+		t.Fatal("should match")
+	}
+}
+
+func raftLeaders(group *chord.Vnode, css ...*ChordStore) []*raftNode {
+	var out []*raftNode
+	for _, cs := range css {
+		for _, n := range cs.raft.list() {
+			n.mu.Lock()
+			if n.group.StringID() == group.StringID() && n.role == raftLeader {
+				out = append(out, n)
+			}
+			n.mu.Unlock()
+		}
+	}
+	return out
+}
+
+func Test_ChordStore_Strong(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "chordstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	if _, err = NewChordStore(&Config{StrongNamespaces: []string{"cfg/"}}, &MemKeyValueStore{}); err == nil {
+		t.Fatal("should require a store persisting raft state")
+	}
+
+	cs1, cs2, stop := newTestRing(t, 36041, func(cfg *Config) {
+		cfg.StrongNamespaces = []string{"cfg/"}
+		cfg.RaftElectionTimeout = 100 * time.Millisecond
+		cfg.RaftCompactEntries = 2
+	}, &DiskKeyValueStore{DataDir: filepath.Join(tmpdir, "1")}, &DiskKeyValueStore{DataDir: filepath.Join(tmpdir, "2")})
+	defer stop()
+
+	// A key replicated on both nodes
+	var (
+		key []byte
+		vns []*chord.Vnode
+	)
+	for i := 0; key == nil; i++ {
+		k := []byte(fmt.Sprintf("cfg/%d", i))
+		if vns, _ = cs1.lookup(4, k); vns[0].Host != vns[1].Host || vns[0].Host != vns[3].Host {
+			key = k
+		}
+	}
+
+	if err = cs1.Put(key, []byte("v1"), ConsistencyOne); err != nil {
+		t.Fatal(err)
+	}
+	val, err := cs2.Get(key, ConsistencyOne)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "v1" {
+		t.Fatal("value mismatch", string(val))
+	}
+
+	// Other writes of strong keys are rejected
+	if _, err = cs1.PutKey(3, key, []byte("v0")); err == nil {
+		t.Fatal("PutKey should be rejected")
+	}
+	if _, err = cs1.PutKeyWithTTL(3, key, []byte("v0"), time.Minute); err == nil {
+		t.Fatal("PutKeyWithTTL should be rejected")
+	}
+	if _, _, err = cs1.CompareAndSwap(key, []byte("v1"), []byte("v0"), ConsistencyAll); err == nil {
+		t.Fatal("CompareAndSwap should be rejected")
+	}
+	txn := cs2.Txn()
+	txn.Put(key, []byte("v0"))
+	if err = txn.Commit(); err == nil {
+		t.Fatal("Txn should be rejected")
+	}
+	if val, err = cs2.Get(key, ConsistencyOne); err != nil || string(val) != "v1" {
+		t.Fatal("value should be unchanged", string(val), err)
+	}
+
+	if leaders := raftLeaders(vns[0], cs1, cs2); len(leaders) != 1 {
+		t.Fatal("should have 1 leader", len(leaders))
+	}
+
+	// Followers apply the write on the next heartbeat
+	<-time.After(100 * time.Millisecond)
+	for _, vn := range vns[:3] {
+		if val, err = cs1.store.GetKey(vn, key); err != nil || string(val) != "v1" {
+			t.Fatal("replica not written", vn.StringID(), string(val), err)
+		}
+	}
+
+	if err = cs2.Remove(key, ConsistencyOne); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cs1.Get(key, ConsistencyOne); !isNotFound(err) {
+		t.Fatal("should not be found", err)
+	}
+
+	// Reads are not appended to the log
+	leader := raftLeaders(vns[0], cs1, cs2)[0]
+	leader.mu.Lock()
+	last := leader.lastIndex()
+	leader.mu.Unlock()
+	for i := 0; i < 5; i++ {
+		if _, err = cs2.Get(key, ConsistencyOne); !isNotFound(err) {
+			t.Fatal("should not be found", err)
+		}
+	}
+	leader.mu.Lock()
+	if leader.lastIndex() != last {
+		t.Fatal("reads should not be appended", last, leader.lastIndex())
+	}
+	leader.mu.Unlock()
+
+	// Other keys of the group so the new member is sent a snapshot
+	var others [][]byte
+	for i := 0; len(others) < 3; i++ {
+		k := []byte(fmt.Sprintf("cfg/other/%d", i))
+		if pvns, _ := cs1.lookup(1, k); pvns[0].StringID() == vns[0].StringID() {
+			others = append(others, k)
+		}
+	}
+	for _, k := range others {
+		if err = cs1.Put(k, k, ConsistencyOne); err != nil {
+			t.Fatal(err)
+		}
+	}
+	leader.mu.Lock()
+	if leader.offset == 0 || len(leader.log) > 4 {
+		t.Fatal("log should be compacted", leader.offset, len(leader.log))
+	}
+	leader.mu.Unlock()
+
+	// The last replica leaves and is replaced by the next successor
+	cs1.raft.reconfigure(vns[2])
+	cs2.raft.reconfigure(vns[2])
+	for i := 0; i < 20; i++ {
+		<-time.After(50 * time.Millisecond)
+		leaders := raftLeaders(vns[0], cs1, cs2)
+		if len(leaders) != 1 {
+			continue
+		}
+		n := leaders[0]
+		n.mu.Lock()
+		done := n.commit >= n.configIdx && !containsVnode(n.members, vns[2]) && containsVnode(n.members, vns[3])
+		n.mu.Unlock()
+		if done {
+			break
+		}
+	}
+
+	if err = cs2.Put(key, []byte("v2"), ConsistencyOne); err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(100 * time.Millisecond)
+	if val, err = cs1.store.GetKey(vns[3], key); err != nil || string(val) != "v2" {
+		t.Fatal("new member not caught up", string(val), err)
+	}
+	for _, k := range others {
+		if val, err = cs1.store.GetKey(vns[3], k); err != nil || string(val) != string(k) {
+			t.Fatal("snapshot not installed", string(k), string(val), err)
+		}
+	}
+
+	// A restarted member resumes from its persisted state
+	leader = raftLeaders(vns[0], cs1, cs2)[0]
+	leader.mu.Lock()
+	defer leader.mu.Unlock()
+	n, err := newRaftNode(leader.rg, leader.group, leader.self, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.term != leader.term || n.votedFor != leader.votedFor || n.lastIndex() != leader.lastIndex() ||
+		n.offset != leader.offset || len(n.members) != len(leader.members) {
+		t.Fatal("state not restored", n.term, n.lastIndex(), n.offset, len(n.members))
+	}
+}
+
+func Test_ChordStore_StrongHandoff(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "chordstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	setup := func(cfg *Config) {
+		cfg.StrongNamespaces = []string{"cfg/"}
+		cfg.RaftElectionTimeout = 100 * time.Millisecond
+	}
+	cs1, cs2, stop := newTestRing(t, 36049, setup,
+		&DiskKeyValueStore{DataDir: filepath.Join(tmpdir, "1")}, &DiskKeyValueStore{DataDir: filepath.Join(tmpdir, "2")})
+	defer stop()
+
+	keys := make([][]byte, 40)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("cfg/%d", i))
+		if err = cs1.Put(keys[i], keys[i], ConsistencyOne); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A node joining in front of the primaries of some keys
+	c3, err := initConfig(36051, "127.0.0.1:36049")
+	if err != nil {
+		t.Fatal(err)
+	}
+	setup(c3)
+	cs3, err := NewChordStore(c3, &DiskKeyValueStore{DataDir: filepath.Join(tmpdir, "3")})
+	if err != nil {
+		c3.Listener.Close()
+		t.Fatal(err)
+	}
+	defer cs3.Shutdown()
+
+	var moved [][]byte
+	for _, key := range keys {
+		if vns, _ := cs1.lookup(1, key); vns[0].Host == c3.Chord.Hostname {
+			moved = append(moved, key)
+		}
+	}
+	if len(moved) == 0 {
+		t.Fatal("no keys moved to the joined node")
+	}
+
+	checkKeys := func(msg string) {
+		var err error
+		for i := 0; i < 40; i++ {
+			if err = checkReplicas(cs1, cs1.replicas, keys); err == nil {
+				break
+			}
+			<-time.After(100 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatal(msg, err)
+		}
+		for _, key := range keys {
+			if val, err := cs2.Get(key, ConsistencyOne); err != nil || string(val) != string(key) {
+				t.Fatal(msg, string(key), string(val), err)
+			}
+		}
+	}
+	checkKeys("not handed off on join")
+
+	// The joined node leaves handing off the keys of its groups
+	for _, vn := range cs3.vnodes {
+		succs, err := cs3.trans.FindSuccessors(vn, 2, vn.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c3.ChordDelegate().Leaving(vn, nil, succs[1])
+	}
+	c3.Ring.Shutdown()
+	cs3.Shutdown()
+	cs1.raft.reconfigure(nil)
+	cs2.raft.reconfigure(nil)
+
+	checkKeys("not handed off on leave")
+}
+
+// checkReplicas returns an error unless each key is held by all of its replicas
+func checkReplicas(cs *ChordStore, n int, keys [][]byte) error {
+	for _, key := range keys {
+		vns, err := cs.lookup(n, key)
+		if err != nil {
+			return err
+		}
+		for _, vn := range vns {
+			if val, err := cs.store.GetKey(vn, key); err != nil || string(val) != string(key) {
+				return fmt.Errorf("key=%s vnode=%s value=%s %v", key, shortID(vn), val, err)
+			}
+		}
+	}
+	return nil
+}
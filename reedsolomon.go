@@ -0,0 +1,183 @@
+package chordstore
+
+import "fmt"
+
+// GF(2^8) arithmetic using the 0x11d polynomial
+var (
+	gfExp [510]byte
+	gfLog [256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	for i := 255; i < len(gfExp); i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfInv(a byte) byte {
+	return gfExp[255-int(gfLog[a])]
+}
+
+// reedSolomon is a systematic k+m Reed-Solomon code.  The encoding matrix is the
+// identity stacked on a Cauchy matrix so any k of the k+m shards can recover the
+// data.
+type reedSolomon struct {
+	k, m int
+	// (k+m) x k encoding matrix
+	enc [][]byte
+}
+
+func newReedSolomon(k, m int) (*reedSolomon, error) {
+	if k < 1 || m < 1 || k+m > 256 {
+		return nil, fmt.Errorf("invalid erasure coding %d+%d", k, m)
+	}
+
+	rs := &reedSolomon{k: k, m: m, enc: make([][]byte, k+m)}
+	for i := range rs.enc {
+		rs.enc[i] = make([]byte, k)
+		if i < k {
+			rs.enc[i][i] = 1
+			continue
+		}
+		// x = i and y = j are distinct as i >= k > j
+		for j := 0; j < k; j++ {
+			rs.enc[i][j] = gfInv(byte(i) ^ byte(j))
+		}
+	}
+	return rs, nil
+}
+
+// mulAdd computes dst += c * src
+func mulAdd(dst, src []byte, c byte) {
+	if c == 0 {
+		return
+	}
+	for i, b := range src {
+		dst[i] ^= gfMul(c, b)
+	}
+}
+
+// encode computes the m parity shards from the k data shards.  All shards must
+// be of the same size.
+func (rs *reedSolomon) encode(shards [][]byte) {
+	for r := rs.k; r < rs.k+rs.m; r++ {
+		p := make([]byte, len(shards[0]))
+		for j := 0; j < rs.k; j++ {
+			mulAdd(p, shards[j], rs.enc[r][j])
+		}
+		shards[r] = p
+	}
+}
+
+// reconstruct fills in nil shards given at least k shards
+func (rs *reedSolomon) reconstruct(shards [][]byte) error {
+	var (
+		rows    []int
+		dataOk  = true
+		present int
+	)
+	for i, s := range shards {
+		if s == nil {
+			if i < rs.k {
+				dataOk = false
+			}
+			continue
+		}
+		present++
+		if len(rows) < rs.k {
+			rows = append(rows, i)
+		}
+	}
+	if present < rs.k {
+		return fmt.Errorf("not enough shards %d/%d", present, rs.k)
+	}
+
+	if !dataOk {
+		sub := make([][]byte, rs.k)
+		for i, r := range rows {
+			sub[i] = append([]byte{}, rs.enc[r]...)
+		}
+		inv, err := gfInvert(sub)
+		if err != nil {
+			return err
+		}
+
+		size := len(shards[rows[0]])
+		for j := 0; j < rs.k; j++ {
+			if shards[j] != nil {
+				continue
+			}
+			d := make([]byte, size)
+			for c, r := range rows {
+				mulAdd(d, shards[r], inv[j][c])
+			}
+			shards[j] = d
+		}
+	}
+
+	for r := rs.k; r < rs.k+rs.m; r++ {
+		if shards[r] != nil {
+			continue
+		}
+		p := make([]byte, len(shards[0]))
+		for j := 0; j < rs.k; j++ {
+			mulAdd(p, shards[j], rs.enc[r][j])
+		}
+		shards[r] = p
+	}
+	return nil
+}
+
+// gfInvert inverts the square matrix in place using Gauss-Jordan elimination
+func gfInvert(a [][]byte) ([][]byte, error) {
+	n := len(a)
+	inv := make([][]byte, n)
+	for i := range inv {
+		inv[i] = make([]byte, n)
+		inv[i][i] = 1
+	}
+
+	for c := 0; c < n; c++ {
+		p := c
+		for p < n && a[p][c] == 0 {
+			p++
+		}
+		if p == n {
+			return nil, fmt.Errorf("singular matrix")
+		}
+		a[c], a[p] = a[p], a[c]
+		inv[c], inv[p] = inv[p], inv[c]
+
+		s := gfInv(a[c][c])
+		for j := 0; j < n; j++ {
+			a[c][j] = gfMul(a[c][j], s)
+			inv[c][j] = gfMul(inv[c][j], s)
+		}
+
+		for r := 0; r < n; r++ {
+			if r == c || a[r][c] == 0 {
+				continue
+			}
+			f := a[r][c]
+			mulAdd(a[r], a[c], f)
+			mulAdd(inv[r], inv[c], f)
+		}
+	}
+	return inv, nil
+}
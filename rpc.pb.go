@@ -9,12 +9,45 @@ It is generated from these files:
 	rpc.proto
 
 It has these top-level messages:
+	WriteStamp
 	DHTKeyValue
 	DHTHashKeyValue
 	DHTBytes
 	DHTBytesErr
 	SnapshotOptions
+	ObjectMeta
+	DHTObjectMeta
+	DHTListRequest
+	DHTKeys
 	DataStream
+	KeyTxn
+	DHTKeyTxns
+	DHTMerkleRequest
+	MerkleEntry
+	DHTMerkleResponse
+	DHTExpiry
+	DHTTombstone
+	Sibling
+	DHTSiblings
+	DHTVersionedKeyValue
+	DHTSiblingKey
+	DHTSwapRequest
+	DHTSwapResponse
+	RaftEntry
+	RaftVoteRequest
+	RaftVoteResponse
+	RaftAppendRequest
+	RaftState
+	RaftSnapshot
+	RaftAppendResponse
+	RaftProposal
+	RaftProposeResponse
+	TxnOp
+	DHTTxn
+	BatchOp
+	DHTBatch
+	BatchResult
+	DHTBatchResponse
 */
 package chordstore
 
@@ -39,16 +72,58 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+// WriteStamp identifies the write a replica applies.  It is assigned once by
+// the coordinator and sent to every replica.
+type WriteStamp struct {
+	// Vnode coordinating the write
+	Origin string `protobuf:"bytes,1,opt,name=origin" json:"origin,omitempty"`
+	// Hybrid logical clock time of the write in unix nanoseconds.  Receivers
+	// move their clock past it.
+	Timestamp int64 `protobuf:"varint,2,opt,name=timestamp" json:"timestamp,omitempty"`
+	// Layout versions of the ordered namespaces at the coordinator.  Replicas
+	// reject writes to keys of a namespace they hold another layout of.
+	Layouts map[string]int64 `protobuf:"bytes,3,rep,name=layouts" json:"layouts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+}
+
+func (m *WriteStamp) Reset()                    { *m = WriteStamp{} }
+func (m *WriteStamp) String() string            { return proto.CompactTextString(m) }
+func (*WriteStamp) ProtoMessage()               {}
+func (*WriteStamp) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+func (m *WriteStamp) GetOrigin() string {
+	if m != nil {
+		return m.Origin
+	}
+	return ""
+}
+
+func (m *WriteStamp) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+func (m *WriteStamp) GetLayouts() map[string]int64 {
+	if m != nil {
+		return m.Layouts
+	}
+	return nil
+}
+
 type DHTKeyValue struct {
 	Vn    *chord.Vnode `protobuf:"bytes,1,opt,name=vn" json:"vn,omitempty"`
 	Key   []byte       `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
 	Value []byte       `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	// Unix nanoseconds after which the key expires.  0 never expires.
+	Expiry int64       `protobuf:"varint,4,opt,name=expiry" json:"expiry,omitempty"`
+	Stamp  *WriteStamp `protobuf:"bytes,5,opt,name=stamp" json:"stamp,omitempty"`
 }
 
 func (m *DHTKeyValue) Reset()                    { *m = DHTKeyValue{} }
 func (m *DHTKeyValue) String() string            { return proto.CompactTextString(m) }
 func (*DHTKeyValue) ProtoMessage()               {}
-func (*DHTKeyValue) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+func (*DHTKeyValue) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
 
 func (m *DHTKeyValue) GetVn() *chord.Vnode {
 	if m != nil {
@@ -71,17 +146,32 @@ func (m *DHTKeyValue) GetValue() []byte {
 	return nil
 }
 
+func (m *DHTKeyValue) GetExpiry() int64 {
+	if m != nil {
+		return m.Expiry
+	}
+	return 0
+}
+
+func (m *DHTKeyValue) GetStamp() *WriteStamp {
+	if m != nil {
+		return m.Stamp
+	}
+	return nil
+}
+
 type DHTHashKeyValue struct {
 	Vn       *chord.Vnode `protobuf:"bytes,1,opt,name=vn" json:"vn,omitempty"`
 	PrevHash []byte       `protobuf:"bytes,2,opt,name=prevHash,proto3" json:"prevHash,omitempty"`
 	Key      []byte       `protobuf:"bytes,3,opt,name=key,proto3" json:"key,omitempty"`
 	Value    []byte       `protobuf:"bytes,4,opt,name=value,proto3" json:"value,omitempty"`
+	Stamp    *WriteStamp  `protobuf:"bytes,5,opt,name=stamp" json:"stamp,omitempty"`
 }
 
 func (m *DHTHashKeyValue) Reset()                    { *m = DHTHashKeyValue{} }
 func (m *DHTHashKeyValue) String() string            { return proto.CompactTextString(m) }
 func (*DHTHashKeyValue) ProtoMessage()               {}
-func (*DHTHashKeyValue) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+func (*DHTHashKeyValue) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
 
 func (m *DHTHashKeyValue) GetVn() *chord.Vnode {
 	if m != nil {
@@ -111,15 +201,31 @@ func (m *DHTHashKeyValue) GetValue() []byte {
 	return nil
 }
 
+func (m *DHTHashKeyValue) GetStamp() *WriteStamp {
+	if m != nil {
+		return m.Stamp
+	}
+	return nil
+}
+
 type DHTBytes struct {
 	Vn *chord.Vnode `protobuf:"bytes,1,opt,name=vn" json:"vn,omitempty"`
 	B  []byte       `protobuf:"bytes,2,opt,name=b,proto3" json:"b,omitempty"`
+	// Verify object data hashes to the key
+	Verify bool `protobuf:"varint,3,opt,name=verify" json:"verify,omitempty"`
+	// Byte range of an object read.  A length of 0 reads to the end.
+	Offset int64 `protobuf:"varint,4,opt,name=offset" json:"offset,omitempty"`
+	Length int64 `protobuf:"varint,5,opt,name=length" json:"length,omitempty"`
+	// Metadata of an object written
+	Meta *ObjectMeta `protobuf:"bytes,6,opt,name=meta" json:"meta,omitempty"`
+	// Stamp of a key removal
+	Stamp *WriteStamp `protobuf:"bytes,7,opt,name=stamp" json:"stamp,omitempty"`
 }
 
 func (m *DHTBytes) Reset()                    { *m = DHTBytes{} }
 func (m *DHTBytes) String() string            { return proto.CompactTextString(m) }
 func (*DHTBytes) ProtoMessage()               {}
-func (*DHTBytes) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+func (*DHTBytes) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
 
 func (m *DHTBytes) GetVn() *chord.Vnode {
 	if m != nil {
@@ -135,6 +241,41 @@ func (m *DHTBytes) GetB() []byte {
 	return nil
 }
 
+func (m *DHTBytes) GetVerify() bool {
+	if m != nil {
+		return m.Verify
+	}
+	return false
+}
+
+func (m *DHTBytes) GetOffset() int64 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+func (m *DHTBytes) GetLength() int64 {
+	if m != nil {
+		return m.Length
+	}
+	return 0
+}
+
+func (m *DHTBytes) GetMeta() *ObjectMeta {
+	if m != nil {
+		return m.Meta
+	}
+	return nil
+}
+
+func (m *DHTBytes) GetStamp() *WriteStamp {
+	if m != nil {
+		return m.Stamp
+	}
+	return nil
+}
+
 type DHTBytesErr struct {
 	B   []byte `protobuf:"bytes,1,opt,name=b,proto3" json:"b,omitempty"`
 	Err string `protobuf:"bytes,2,opt,name=err" json:"err,omitempty"`
@@ -143,7 +284,7 @@ type DHTBytesErr struct {
 func (m *DHTBytesErr) Reset()                    { *m = DHTBytesErr{} }
 func (m *DHTBytesErr) String() string            { return proto.CompactTextString(m) }
 func (*DHTBytesErr) ProtoMessage()               {}
-func (*DHTBytesErr) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+func (*DHTBytesErr) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
 
 func (m *DHTBytesErr) GetB() []byte {
 	if m != nil {
@@ -161,12 +302,15 @@ func (m *DHTBytesErr) GetErr() string {
 
 type SnapshotOptions struct {
 	Vn *chord.Vnode `protobuf:"bytes,1,opt,name=vn" json:"vn,omitempty"`
+	// Ring hash range (start, end] of keys.  All keys if both are empty
+	Start []byte `protobuf:"bytes,2,opt,name=start,proto3" json:"start,omitempty"`
+	End   []byte `protobuf:"bytes,3,opt,name=end,proto3" json:"end,omitempty"`
 }
 
 func (m *SnapshotOptions) Reset()                    { *m = SnapshotOptions{} }
 func (m *SnapshotOptions) String() string            { return proto.CompactTextString(m) }
 func (*SnapshotOptions) ProtoMessage()               {}
-func (*SnapshotOptions) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+func (*SnapshotOptions) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
 
 func (m *SnapshotOptions) GetVn() *chord.Vnode {
 	if m != nil {
@@ -175,14 +319,190 @@ func (m *SnapshotOptions) GetVn() *chord.Vnode {
 	return nil
 }
 
+func (m *SnapshotOptions) GetStart() []byte {
+	if m != nil {
+		return m.Start
+	}
+	return nil
+}
+
+func (m *SnapshotOptions) GetEnd() []byte {
+	if m != nil {
+		return m.End
+	}
+	return nil
+}
+
+// ObjectMeta is the metadata stored alongside an object
+type ObjectMeta struct {
+	Size int64 `protobuf:"varint,1,opt,name=size" json:"size,omitempty"`
+	// sha256 of the data
+	Hash        []byte `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+	ContentType string `protobuf:"bytes,3,opt,name=contentType" json:"contentType,omitempty"`
+	// User defined headers
+	Headers map[string]string `protobuf:"bytes,4,rep,name=headers" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Unix time in nanoseconds of when the object was written
+	Mtime int64 `protobuf:"varint,5,opt,name=mtime" json:"mtime,omitempty"`
+	// How the object is stored.  Empty for plain objects.
+	Encoding string `protobuf:"bytes,6,opt,name=encoding" json:"encoding,omitempty"`
+}
+
+func (m *ObjectMeta) Reset()                    { *m = ObjectMeta{} }
+func (m *ObjectMeta) String() string            { return proto.CompactTextString(m) }
+func (*ObjectMeta) ProtoMessage()               {}
+func (*ObjectMeta) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *ObjectMeta) GetSize() int64 {
+	if m != nil {
+		return m.Size
+	}
+	return 0
+}
+
+func (m *ObjectMeta) GetHash() []byte {
+	if m != nil {
+		return m.Hash
+	}
+	return nil
+}
+
+func (m *ObjectMeta) GetContentType() string {
+	if m != nil {
+		return m.ContentType
+	}
+	return ""
+}
+
+func (m *ObjectMeta) GetHeaders() map[string]string {
+	if m != nil {
+		return m.Headers
+	}
+	return nil
+}
+
+func (m *ObjectMeta) GetMtime() int64 {
+	if m != nil {
+		return m.Mtime
+	}
+	return 0
+}
+
+func (m *ObjectMeta) GetEncoding() string {
+	if m != nil {
+		return m.Encoding
+	}
+	return ""
+}
+
+type DHTObjectMeta struct {
+	Meta *ObjectMeta `protobuf:"bytes,1,opt,name=meta" json:"meta,omitempty"`
+	Err  string      `protobuf:"bytes,2,opt,name=err" json:"err,omitempty"`
+}
+
+func (m *DHTObjectMeta) Reset()                    { *m = DHTObjectMeta{} }
+func (m *DHTObjectMeta) String() string            { return proto.CompactTextString(m) }
+func (*DHTObjectMeta) ProtoMessage()               {}
+func (*DHTObjectMeta) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *DHTObjectMeta) GetMeta() *ObjectMeta {
+	if m != nil {
+		return m.Meta
+	}
+	return nil
+}
+
+func (m *DHTObjectMeta) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
+type DHTListRequest struct {
+	Vn     *chord.Vnode `protobuf:"bytes,1,opt,name=vn" json:"vn,omitempty"`
+	Prefix []byte       `protobuf:"bytes,2,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	// Only keys sorting after the cursor are returned
+	Cursor []byte `protobuf:"bytes,3,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	// Max keys returned.  0 returns all keys.
+	Limit int32 `protobuf:"varint,4,opt,name=limit" json:"limit,omitempty"`
+	// List object keys rather than key-value keys
+	Objects bool `protobuf:"varint,5,opt,name=objects" json:"objects,omitempty"`
+}
+
+func (m *DHTListRequest) Reset()                    { *m = DHTListRequest{} }
+func (m *DHTListRequest) String() string            { return proto.CompactTextString(m) }
+func (*DHTListRequest) ProtoMessage()               {}
+func (*DHTListRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *DHTListRequest) GetVn() *chord.Vnode {
+	if m != nil {
+		return m.Vn
+	}
+	return nil
+}
+
+func (m *DHTListRequest) GetPrefix() []byte {
+	if m != nil {
+		return m.Prefix
+	}
+	return nil
+}
+
+func (m *DHTListRequest) GetCursor() []byte {
+	if m != nil {
+		return m.Cursor
+	}
+	return nil
+}
+
+func (m *DHTListRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+func (m *DHTListRequest) GetObjects() bool {
+	if m != nil {
+		return m.Objects
+	}
+	return false
+}
+
+type DHTKeys struct {
+	Keys [][]byte `protobuf:"bytes,1,rep,name=keys,proto3" json:"keys,omitempty"`
+	Err  string   `protobuf:"bytes,2,opt,name=err" json:"err,omitempty"`
+}
+
+func (m *DHTKeys) Reset()                    { *m = DHTKeys{} }
+func (m *DHTKeys) String() string            { return proto.CompactTextString(m) }
+func (*DHTKeys) ProtoMessage()               {}
+func (*DHTKeys) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *DHTKeys) GetKeys() [][]byte {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
+func (m *DHTKeys) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
 type DataStream struct {
 	Data []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	// Size of the whole object.  Set on the first message of an object read.
+	Size int64 `protobuf:"varint,2,opt,name=size" json:"size,omitempty"`
 }
 
 func (m *DataStream) Reset()                    { *m = DataStream{} }
 func (m *DataStream) String() string            { return proto.CompactTextString(m) }
 func (*DataStream) ProtoMessage()               {}
-func (*DataStream) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+func (*DataStream) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
 
 func (m *DataStream) GetData() []byte {
 	if m != nil {
@@ -191,308 +511,2023 @@ func (m *DataStream) GetData() []byte {
 	return nil
 }
 
-func init() {
-	proto.RegisterType((*DHTKeyValue)(nil), "chordstore.DHTKeyValue")
-	proto.RegisterType((*DHTHashKeyValue)(nil), "chordstore.DHTHashKeyValue")
-	proto.RegisterType((*DHTBytes)(nil), "chordstore.DHTBytes")
-	proto.RegisterType((*DHTBytesErr)(nil), "chordstore.DHTBytesErr")
-	proto.RegisterType((*SnapshotOptions)(nil), "chordstore.SnapshotOptions")
-	proto.RegisterType((*DataStream)(nil), "chordstore.DataStream")
+func (m *DataStream) GetSize() int64 {
+	if m != nil {
+		return m.Size
+	}
+	return 0
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
+type KeyTxn struct {
+	Op        string `protobuf:"bytes,1,opt,name=op" json:"op,omitempty"`
+	Hash      []byte `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+	PrevHash  []byte `protobuf:"bytes,3,opt,name=prevHash,proto3" json:"prevHash,omitempty"`
+	Timestamp int64  `protobuf:"varint,4,opt,name=timestamp" json:"timestamp,omitempty"`
+	Vnode     string `protobuf:"bytes,5,opt,name=vnode" json:"vnode,omitempty"`
+}
 
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
+func (m *KeyTxn) Reset()                    { *m = KeyTxn{} }
+func (m *KeyTxn) String() string            { return proto.CompactTextString(m) }
+func (*KeyTxn) ProtoMessage()               {}
+func (*KeyTxn) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
 
-// Client API for DHT service
+func (m *KeyTxn) GetOp() string {
+	if m != nil {
+		return m.Op
+	}
+	return ""
+}
 
-type DHTClient interface {
-	PutKeyRPC(ctx context.Context, in *DHTKeyValue, opts ...grpc.CallOption) (*chord.ErrResponse, error)
-	GetKeyRPC(ctx context.Context, in *DHTBytes, opts ...grpc.CallOption) (*DHTBytesErr, error)
-	UpdateKeyRPC(ctx context.Context, in *DHTHashKeyValue, opts ...grpc.CallOption) (*chord.ErrResponse, error)
-	RemoveKeyRPC(ctx context.Context, in *DHTBytes, opts ...grpc.CallOption) (*chord.ErrResponse, error)
-	PutObjectRPC(ctx context.Context, opts ...grpc.CallOption) (DHT_PutObjectRPCClient, error)
-	GetObjectRPC(ctx context.Context, in *DHTBytes, opts ...grpc.CallOption) (DHT_GetObjectRPCClient, error)
-	RemoveObjectRPC(ctx context.Context, in *DHTBytes, opts ...grpc.CallOption) (*chord.ErrResponse, error)
-	SnapshotRPC(ctx context.Context, in *chord.Vnode, opts ...grpc.CallOption) (DHT_SnapshotRPCClient, error)
-	RestoreRPC(ctx context.Context, opts ...grpc.CallOption) (DHT_RestoreRPCClient, error)
+func (m *KeyTxn) GetHash() []byte {
+	if m != nil {
+		return m.Hash
+	}
+	return nil
 }
 
-type dHTClient struct {
-	cc *grpc.ClientConn
+func (m *KeyTxn) GetPrevHash() []byte {
+	if m != nil {
+		return m.PrevHash
+	}
+	return nil
 }
 
-func NewDHTClient(cc *grpc.ClientConn) DHTClient {
-	return &dHTClient{cc}
+func (m *KeyTxn) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
 }
 
-func (c *dHTClient) PutKeyRPC(ctx context.Context, in *DHTKeyValue, opts ...grpc.CallOption) (*chord.ErrResponse, error) {
-	out := new(chord.ErrResponse)
-	err := grpc.Invoke(ctx, "/chordstore.DHT/PutKeyRPC", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func (m *KeyTxn) GetVnode() string {
+	if m != nil {
+		return m.Vnode
 	}
-	return out, nil
+	return ""
 }
 
-func (c *dHTClient) GetKeyRPC(ctx context.Context, in *DHTBytes, opts ...grpc.CallOption) (*DHTBytesErr, error) {
-	out := new(DHTBytesErr)
-	err := grpc.Invoke(ctx, "/chordstore.DHT/GetKeyRPC", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+type DHTKeyTxns struct {
+	Txns []*KeyTxn `protobuf:"bytes,1,rep,name=txns" json:"txns,omitempty"`
+	Err  string    `protobuf:"bytes,2,opt,name=err" json:"err,omitempty"`
+}
+
+func (m *DHTKeyTxns) Reset()                    { *m = DHTKeyTxns{} }
+func (m *DHTKeyTxns) String() string            { return proto.CompactTextString(m) }
+func (*DHTKeyTxns) ProtoMessage()               {}
+func (*DHTKeyTxns) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *DHTKeyTxns) GetTxns() []*KeyTxn {
+	if m != nil {
+		return m.Txns
 	}
-	return out, nil
+	return nil
 }
 
-func (c *dHTClient) UpdateKeyRPC(ctx context.Context, in *DHTHashKeyValue, opts ...grpc.CallOption) (*chord.ErrResponse, error) {
-	out := new(chord.ErrResponse)
-	err := grpc.Invoke(ctx, "/chordstore.DHT/UpdateKeyRPC", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func (m *DHTKeyTxns) GetErr() string {
+	if m != nil {
+		return m.Err
 	}
-	return out, nil
+	return ""
 }
 
-func (c *dHTClient) RemoveKeyRPC(ctx context.Context, in *DHTBytes, opts ...grpc.CallOption) (*chord.ErrResponse, error) {
-	out := new(chord.ErrResponse)
-	err := grpc.Invoke(ctx, "/chordstore.DHT/RemoveKeyRPC", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+type DHTMerkleRequest struct {
+	Vn      *chord.Vnode `protobuf:"bytes,1,opt,name=vn" json:"vn,omitempty"`
+	Level   int32        `protobuf:"varint,2,opt,name=level" json:"level,omitempty"`
+	Indexes []int32      `protobuf:"varint,3,rep,packed,name=indexes" json:"indexes,omitempty"`
+	// Return the entries of the leaves at indexes rather than node hashes
+	Entries bool `protobuf:"varint,4,opt,name=entries" json:"entries,omitempty"`
+}
+
+func (m *DHTMerkleRequest) Reset()                    { *m = DHTMerkleRequest{} }
+func (m *DHTMerkleRequest) String() string            { return proto.CompactTextString(m) }
+func (*DHTMerkleRequest) ProtoMessage()               {}
+func (*DHTMerkleRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *DHTMerkleRequest) GetVn() *chord.Vnode {
+	if m != nil {
+		return m.Vn
 	}
-	return out, nil
+	return nil
 }
 
-func (c *dHTClient) PutObjectRPC(ctx context.Context, opts ...grpc.CallOption) (DHT_PutObjectRPCClient, error) {
-	stream, err := grpc.NewClientStream(ctx, &_DHT_serviceDesc.Streams[0], c.cc, "/chordstore.DHT/PutObjectRPC", opts...)
-	if err != nil {
-		return nil, err
+func (m *DHTMerkleRequest) GetLevel() int32 {
+	if m != nil {
+		return m.Level
 	}
-	x := &dHTPutObjectRPCClient{stream}
-	return x, nil
+	return 0
 }
 
-type DHT_PutObjectRPCClient interface {
-	Send(*DataStream) error
-	CloseAndRecv() (*chord.ErrResponse, error)
-	grpc.ClientStream
+func (m *DHTMerkleRequest) GetIndexes() []int32 {
+	if m != nil {
+		return m.Indexes
+	}
+	return nil
 }
 
-type dHTPutObjectRPCClient struct {
-	grpc.ClientStream
+func (m *DHTMerkleRequest) GetEntries() bool {
+	if m != nil {
+		return m.Entries
+	}
+	return false
 }
 
-func (x *dHTPutObjectRPCClient) Send(m *DataStream) error {
-	return x.ClientStream.SendMsg(m)
+type MerkleEntry struct {
+	Key  []byte `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Hash []byte `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+}
+
+func (m *MerkleEntry) Reset()                    { *m = MerkleEntry{} }
+func (m *MerkleEntry) String() string            { return proto.CompactTextString(m) }
+func (*MerkleEntry) ProtoMessage()               {}
+func (*MerkleEntry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+func (m *MerkleEntry) GetKey() []byte {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *MerkleEntry) GetHash() []byte {
+	if m != nil {
+		return m.Hash
+	}
+	return nil
+}
+
+type DHTMerkleResponse struct {
+	Hashes  [][]byte       `protobuf:"bytes,1,rep,name=hashes,proto3" json:"hashes,omitempty"`
+	Entries []*MerkleEntry `protobuf:"bytes,2,rep,name=entries" json:"entries,omitempty"`
+	Err     string         `protobuf:"bytes,3,opt,name=err" json:"err,omitempty"`
+}
+
+func (m *DHTMerkleResponse) Reset()                    { *m = DHTMerkleResponse{} }
+func (m *DHTMerkleResponse) String() string            { return proto.CompactTextString(m) }
+func (*DHTMerkleResponse) ProtoMessage()               {}
+func (*DHTMerkleResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+func (m *DHTMerkleResponse) GetHashes() [][]byte {
+	if m != nil {
+		return m.Hashes
+	}
+	return nil
+}
+
+func (m *DHTMerkleResponse) GetEntries() []*MerkleEntry {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
+func (m *DHTMerkleResponse) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
+type DHTExpiry struct {
+	Expiry int64  `protobuf:"varint,1,opt,name=expiry" json:"expiry,omitempty"`
+	Err    string `protobuf:"bytes,2,opt,name=err" json:"err,omitempty"`
+}
+
+func (m *DHTExpiry) Reset()                    { *m = DHTExpiry{} }
+func (m *DHTExpiry) String() string            { return proto.CompactTextString(m) }
+func (*DHTExpiry) ProtoMessage()               {}
+func (*DHTExpiry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+
+func (m *DHTExpiry) GetExpiry() int64 {
+	if m != nil {
+		return m.Expiry
+	}
+	return 0
+}
+
+func (m *DHTExpiry) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
+type DHTTombstone struct {
+	// Unix nanoseconds the key was removed.  0 if not removed.
+	Timestamp int64  `protobuf:"varint,1,opt,name=timestamp" json:"timestamp,omitempty"`
+	Err       string `protobuf:"bytes,2,opt,name=err" json:"err,omitempty"`
+}
+
+func (m *DHTTombstone) Reset()                    { *m = DHTTombstone{} }
+func (m *DHTTombstone) String() string            { return proto.CompactTextString(m) }
+func (*DHTTombstone) ProtoMessage()               {}
+func (*DHTTombstone) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+
+func (m *DHTTombstone) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+func (m *DHTTombstone) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
+// Sibling is a version of a versioned key.  The node and counter pair identifies
+// the write and the context is the version vector the writer had seen.  Values
+// written without a version have a counter of 0.
+type Sibling struct {
+	Value   []byte            `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	Node    string            `protobuf:"bytes,2,opt,name=node" json:"node,omitempty"`
+	Counter uint64            `protobuf:"varint,3,opt,name=counter" json:"counter,omitempty"`
+	Context map[string]uint64 `protobuf:"bytes,4,rep,name=context" json:"context,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	// Unix nanoseconds the version was written
+	Timestamp int64 `protobuf:"varint,5,opt,name=timestamp" json:"timestamp,omitempty"`
+}
+
+func (m *Sibling) Reset()                    { *m = Sibling{} }
+func (m *Sibling) String() string            { return proto.CompactTextString(m) }
+func (*Sibling) ProtoMessage()               {}
+func (*Sibling) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+
+func (m *Sibling) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (m *Sibling) GetNode() string {
+	if m != nil {
+		return m.Node
+	}
+	return ""
+}
+
+func (m *Sibling) GetCounter() uint64 {
+	if m != nil {
+		return m.Counter
+	}
+	return 0
+}
+
+func (m *Sibling) GetContext() map[string]uint64 {
+	if m != nil {
+		return m.Context
+	}
+	return nil
+}
+
+func (m *Sibling) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+type DHTSiblings struct {
+	Siblings []*Sibling `protobuf:"bytes,1,rep,name=siblings" json:"siblings,omitempty"`
+	Err      string     `protobuf:"bytes,2,opt,name=err" json:"err,omitempty"`
+}
+
+func (m *DHTSiblings) Reset()                    { *m = DHTSiblings{} }
+func (m *DHTSiblings) String() string            { return proto.CompactTextString(m) }
+func (*DHTSiblings) ProtoMessage()               {}
+func (*DHTSiblings) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+
+func (m *DHTSiblings) GetSiblings() []*Sibling {
+	if m != nil {
+		return m.Siblings
+	}
+	return nil
+}
+
+func (m *DHTSiblings) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
+type DHTVersionedKeyValue struct {
+	Vn    *chord.Vnode `protobuf:"bytes,1,opt,name=vn" json:"vn,omitempty"`
+	Key   []byte       `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Value []byte       `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	// Versions superseded by the write
+	Context map[string]uint64 `protobuf:"bytes,4,rep,name=context" json:"context,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Stamp   *WriteStamp       `protobuf:"bytes,5,opt,name=stamp" json:"stamp,omitempty"`
+}
+
+func (m *DHTVersionedKeyValue) Reset()                    { *m = DHTVersionedKeyValue{} }
+func (m *DHTVersionedKeyValue) String() string            { return proto.CompactTextString(m) }
+func (*DHTVersionedKeyValue) ProtoMessage()               {}
+func (*DHTVersionedKeyValue) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+
+func (m *DHTVersionedKeyValue) GetVn() *chord.Vnode {
+	if m != nil {
+		return m.Vn
+	}
+	return nil
+}
+
+func (m *DHTVersionedKeyValue) GetKey() []byte {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *DHTVersionedKeyValue) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (m *DHTVersionedKeyValue) GetContext() map[string]uint64 {
+	if m != nil {
+		return m.Context
+	}
+	return nil
+}
+
+func (m *DHTVersionedKeyValue) GetStamp() *WriteStamp {
+	if m != nil {
+		return m.Stamp
+	}
+	return nil
+}
+
+type DHTSiblingKey struct {
+	Vn      *chord.Vnode `protobuf:"bytes,1,opt,name=vn" json:"vn,omitempty"`
+	Key     []byte       `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Sibling *Sibling     `protobuf:"bytes,3,opt,name=sibling" json:"sibling,omitempty"`
+}
+
+func (m *DHTSiblingKey) Reset()                    { *m = DHTSiblingKey{} }
+func (m *DHTSiblingKey) String() string            { return proto.CompactTextString(m) }
+func (*DHTSiblingKey) ProtoMessage()               {}
+func (*DHTSiblingKey) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+
+func (m *DHTSiblingKey) GetVn() *chord.Vnode {
+	if m != nil {
+		return m.Vn
+	}
+	return nil
+}
+
+func (m *DHTSiblingKey) GetKey() []byte {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *DHTSiblingKey) GetSibling() *Sibling {
+	if m != nil {
+		return m.Sibling
+	}
+	return nil
+}
+
+type DHTSwapRequest struct {
+	// Primary vnode of the key
+	Vn    *chord.Vnode `protobuf:"bytes,1,opt,name=vn" json:"vn,omitempty"`
+	Key   []byte       `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Old   []byte       `protobuf:"bytes,3,opt,name=old,proto3" json:"old,omitempty"`
+	Value []byte       `protobuf:"bytes,4,opt,name=value,proto3" json:"value,omitempty"`
+	// Swap only if the key does not exist
+	Create      bool        `protobuf:"varint,5,opt,name=create" json:"create,omitempty"`
+	Consistency int32       `protobuf:"varint,6,opt,name=consistency" json:"consistency,omitempty"`
+	Stamp       *WriteStamp `protobuf:"bytes,7,opt,name=stamp" json:"stamp,omitempty"`
+	// Swap on the vnode only instead of coordinating the swap on the replicas
+	Replica bool `protobuf:"varint,8,opt,name=replica" json:"replica,omitempty"`
+	// Remove the key instead of setting the value.  Only used on a replica.
+	Remove bool `protobuf:"varint,9,opt,name=remove" json:"remove,omitempty"`
+}
+
+func (m *DHTSwapRequest) Reset()                    { *m = DHTSwapRequest{} }
+func (m *DHTSwapRequest) String() string            { return proto.CompactTextString(m) }
+func (*DHTSwapRequest) ProtoMessage()               {}
+func (*DHTSwapRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+
+func (m *DHTSwapRequest) GetVn() *chord.Vnode {
+	if m != nil {
+		return m.Vn
+	}
+	return nil
+}
+
+func (m *DHTSwapRequest) GetKey() []byte {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *DHTSwapRequest) GetOld() []byte {
+	if m != nil {
+		return m.Old
+	}
+	return nil
+}
+
+func (m *DHTSwapRequest) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (m *DHTSwapRequest) GetCreate() bool {
+	if m != nil {
+		return m.Create
+	}
+	return false
+}
+
+func (m *DHTSwapRequest) GetConsistency() int32 {
+	if m != nil {
+		return m.Consistency
+	}
+	return 0
+}
+
+func (m *DHTSwapRequest) GetStamp() *WriteStamp {
+	if m != nil {
+		return m.Stamp
+	}
+	return nil
+}
+
+func (m *DHTSwapRequest) GetReplica() bool {
+	if m != nil {
+		return m.Replica
+	}
+	return false
+}
+
+func (m *DHTSwapRequest) GetRemove() bool {
+	if m != nil {
+		return m.Remove
+	}
+	return false
+}
+
+type DHTSwapResponse struct {
+	// Value of the key after the swap
+	Value   []byte `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	Swapped bool   `protobuf:"varint,2,opt,name=swapped" json:"swapped,omitempty"`
+	Err     string `protobuf:"bytes,3,opt,name=err" json:"err,omitempty"`
+	// Whether the key exists after the swap, as an empty value is not
+	// distinguished from none
+	Exists bool `protobuf:"varint,4,opt,name=exists" json:"exists,omitempty"`
+}
+
+func (m *DHTSwapResponse) Reset()                    { *m = DHTSwapResponse{} }
+func (m *DHTSwapResponse) String() string            { return proto.CompactTextString(m) }
+func (*DHTSwapResponse) ProtoMessage()               {}
+func (*DHTSwapResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+
+func (m *DHTSwapResponse) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (m *DHTSwapResponse) GetSwapped() bool {
+	if m != nil {
+		return m.Swapped
+	}
+	return false
+}
+
+func (m *DHTSwapResponse) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
+func (m *DHTSwapResponse) GetExists() bool {
+	if m != nil {
+		return m.Exists
+	}
+	return false
+}
+
+// RaftEntry is an entry of the log of a consensus group
+type RaftEntry struct {
+	Term uint64 `protobuf:"varint,1,opt,name=term" json:"term,omitempty"`
+	// put, remove, adopt or config.  Gets are proposed but served by the
+	// leader without being appended.  Adopts put a key handed off by another
+	// group unless the group wrote or removed it since the stamp.
+	Op    string `protobuf:"bytes,2,opt,name=op" json:"op,omitempty"`
+	Key   []byte `protobuf:"bytes,3,opt,name=key,proto3" json:"key,omitempty"`
+	Value []byte `protobuf:"bytes,4,opt,name=value,proto3" json:"value,omitempty"`
+	// Members of the group set by config entries
+	Members []*chord.Vnode `protobuf:"bytes,5,rep,name=members" json:"members,omitempty"`
+	// Stamp of puts and removes assigned by the leader
+	Stamp *WriteStamp `protobuf:"bytes,6,opt,name=stamp" json:"stamp,omitempty"`
+}
+
+func (m *RaftEntry) Reset()                    { *m = RaftEntry{} }
+func (m *RaftEntry) String() string            { return proto.CompactTextString(m) }
+func (*RaftEntry) ProtoMessage()               {}
+func (*RaftEntry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+
+func (m *RaftEntry) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+func (m *RaftEntry) GetOp() string {
+	if m != nil {
+		return m.Op
+	}
+	return ""
+}
+
+func (m *RaftEntry) GetKey() []byte {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *RaftEntry) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (m *RaftEntry) GetMembers() []*chord.Vnode {
+	if m != nil {
+		return m.Members
+	}
+	return nil
+}
+
+func (m *RaftEntry) GetStamp() *WriteStamp {
+	if m != nil {
+		return m.Stamp
+	}
+	return nil
+}
+
+type RaftVoteRequest struct {
+	// Primary vnode identifying the group
+	Group *chord.Vnode `protobuf:"bytes,1,opt,name=group" json:"group,omitempty"`
+	// Member the request is for
+	Vn           *chord.Vnode `protobuf:"bytes,2,opt,name=vn" json:"vn,omitempty"`
+	Candidate    *chord.Vnode `protobuf:"bytes,3,opt,name=candidate" json:"candidate,omitempty"`
+	Term         uint64       `protobuf:"varint,4,opt,name=term" json:"term,omitempty"`
+	LastLogIndex uint64       `protobuf:"varint,5,opt,name=last_log_index,json=lastLogIndex" json:"last_log_index,omitempty"`
+	LastLogTerm  uint64       `protobuf:"varint,6,opt,name=last_log_term,json=lastLogTerm" json:"last_log_term,omitempty"`
+	// Members known to the candidate used if the group is new to the member
+	Members []*chord.Vnode `protobuf:"bytes,7,rep,name=members" json:"members,omitempty"`
+}
+
+func (m *RaftVoteRequest) Reset()                    { *m = RaftVoteRequest{} }
+func (m *RaftVoteRequest) String() string            { return proto.CompactTextString(m) }
+func (*RaftVoteRequest) ProtoMessage()               {}
+func (*RaftVoteRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+
+func (m *RaftVoteRequest) GetGroup() *chord.Vnode {
+	if m != nil {
+		return m.Group
+	}
+	return nil
+}
+
+func (m *RaftVoteRequest) GetVn() *chord.Vnode {
+	if m != nil {
+		return m.Vn
+	}
+	return nil
+}
+
+func (m *RaftVoteRequest) GetCandidate() *chord.Vnode {
+	if m != nil {
+		return m.Candidate
+	}
+	return nil
+}
+
+func (m *RaftVoteRequest) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+func (m *RaftVoteRequest) GetLastLogIndex() uint64 {
+	if m != nil {
+		return m.LastLogIndex
+	}
+	return 0
+}
+
+func (m *RaftVoteRequest) GetLastLogTerm() uint64 {
+	if m != nil {
+		return m.LastLogTerm
+	}
+	return 0
+}
+
+func (m *RaftVoteRequest) GetMembers() []*chord.Vnode {
+	if m != nil {
+		return m.Members
+	}
+	return nil
+}
+
+type RaftVoteResponse struct {
+	Term    uint64 `protobuf:"varint,1,opt,name=term" json:"term,omitempty"`
+	Granted bool   `protobuf:"varint,2,opt,name=granted" json:"granted,omitempty"`
+	Err     string `protobuf:"bytes,3,opt,name=err" json:"err,omitempty"`
+}
+
+func (m *RaftVoteResponse) Reset()                    { *m = RaftVoteResponse{} }
+func (m *RaftVoteResponse) String() string            { return proto.CompactTextString(m) }
+func (*RaftVoteResponse) ProtoMessage()               {}
+func (*RaftVoteResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
+
+func (m *RaftVoteResponse) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+func (m *RaftVoteResponse) GetGranted() bool {
+	if m != nil {
+		return m.Granted
+	}
+	return false
+}
+
+func (m *RaftVoteResponse) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
+type RaftAppendRequest struct {
+	Group        *chord.Vnode   `protobuf:"bytes,1,opt,name=group" json:"group,omitempty"`
+	Vn           *chord.Vnode   `protobuf:"bytes,2,opt,name=vn" json:"vn,omitempty"`
+	Leader       *chord.Vnode   `protobuf:"bytes,3,opt,name=leader" json:"leader,omitempty"`
+	Term         uint64         `protobuf:"varint,4,opt,name=term" json:"term,omitempty"`
+	PrevLogIndex uint64         `protobuf:"varint,5,opt,name=prev_log_index,json=prevLogIndex" json:"prev_log_index,omitempty"`
+	PrevLogTerm  uint64         `protobuf:"varint,6,opt,name=prev_log_term,json=prevLogTerm" json:"prev_log_term,omitempty"`
+	Entries      []*RaftEntry   `protobuf:"bytes,7,rep,name=entries" json:"entries,omitempty"`
+	Commit       uint64         `protobuf:"varint,8,opt,name=commit" json:"commit,omitempty"`
+	Members      []*chord.Vnode `protobuf:"bytes,9,rep,name=members" json:"members,omitempty"`
+	// Sent in place of entries to a member behind the compacted log of the
+	// leader.  prev_log_index and prev_log_term are its last entry.
+	Snapshot *RaftSnapshot `protobuf:"bytes,10,opt,name=snapshot" json:"snapshot,omitempty"`
+}
+
+func (m *RaftAppendRequest) Reset()                    { *m = RaftAppendRequest{} }
+func (m *RaftAppendRequest) String() string            { return proto.CompactTextString(m) }
+func (*RaftAppendRequest) ProtoMessage()               {}
+func (*RaftAppendRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
+
+func (m *RaftAppendRequest) GetGroup() *chord.Vnode {
+	if m != nil {
+		return m.Group
+	}
+	return nil
+}
+
+func (m *RaftAppendRequest) GetVn() *chord.Vnode {
+	if m != nil {
+		return m.Vn
+	}
+	return nil
+}
+
+func (m *RaftAppendRequest) GetLeader() *chord.Vnode {
+	if m != nil {
+		return m.Leader
+	}
+	return nil
+}
+
+func (m *RaftAppendRequest) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+func (m *RaftAppendRequest) GetPrevLogIndex() uint64 {
+	if m != nil {
+		return m.PrevLogIndex
+	}
+	return 0
+}
+
+func (m *RaftAppendRequest) GetPrevLogTerm() uint64 {
+	if m != nil {
+		return m.PrevLogTerm
+	}
+	return 0
+}
+
+func (m *RaftAppendRequest) GetEntries() []*RaftEntry {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
+func (m *RaftAppendRequest) GetCommit() uint64 {
+	if m != nil {
+		return m.Commit
+	}
+	return 0
+}
+
+func (m *RaftAppendRequest) GetMembers() []*chord.Vnode {
+	if m != nil {
+		return m.Members
+	}
+	return nil
+}
+
+func (m *RaftAppendRequest) GetSnapshot() *RaftSnapshot {
+	if m != nil {
+		return m.Snapshot
+	}
+	return nil
+}
+
+// RaftState is the persisted state of a member of a consensus group
+type RaftState struct {
+	Term     uint64 `protobuf:"varint,1,opt,name=term" json:"term,omitempty"`
+	VotedFor string `protobuf:"bytes,2,opt,name=voted_for,json=votedFor" json:"voted_for,omitempty"`
+	// Index of the last entry compacted into the snapshot
+	Offset uint64 `protobuf:"varint,3,opt,name=offset" json:"offset,omitempty"`
+	// Members as of the snapshot or those the member joined with
+	Members []*chord.Vnode `protobuf:"bytes,4,rep,name=members" json:"members,omitempty"`
+	// Log starting with the last entry compacted into the snapshot
+	Entries []*RaftEntry `protobuf:"bytes,5,rep,name=entries" json:"entries,omitempty"`
+	// Incremented on every rewrite of the state.  Entries appended since are
+	// stored as records of the same generation holding the entries following
+	// the offset.
+	Generation uint64 `protobuf:"varint,6,opt,name=generation" json:"generation,omitempty"`
+}
+
+func (m *RaftState) Reset()                    { *m = RaftState{} }
+func (m *RaftState) String() string            { return proto.CompactTextString(m) }
+func (*RaftState) ProtoMessage()               {}
+func (*RaftState) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{28} }
+
+func (m *RaftState) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+func (m *RaftState) GetVotedFor() string {
+	if m != nil {
+		return m.VotedFor
+	}
+	return ""
+}
+
+func (m *RaftState) GetOffset() uint64 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+func (m *RaftState) GetMembers() []*chord.Vnode {
+	if m != nil {
+		return m.Members
+	}
+	return nil
+}
+
+func (m *RaftState) GetEntries() []*RaftEntry {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
+func (m *RaftState) GetGeneration() uint64 {
+	if m != nil {
+		return m.Generation
+	}
+	return 0
+}
+
+// RaftSnapshot is the state of a group up to and including an entry of its log.
+// Entries are puts of the keys of the group.
+type RaftSnapshot struct {
+	Index uint64 `protobuf:"varint,1,opt,name=index" json:"index,omitempty"`
+	Term  uint64 `protobuf:"varint,2,opt,name=term" json:"term,omitempty"`
+	// Members as of the entry
+	Members []*chord.Vnode `protobuf:"bytes,3,rep,name=members" json:"members,omitempty"`
+	Entries []*RaftEntry   `protobuf:"bytes,4,rep,name=entries" json:"entries,omitempty"`
+}
+
+func (m *RaftSnapshot) Reset()                    { *m = RaftSnapshot{} }
+func (m *RaftSnapshot) String() string            { return proto.CompactTextString(m) }
+func (*RaftSnapshot) ProtoMessage()               {}
+func (*RaftSnapshot) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{29} }
+
+func (m *RaftSnapshot) GetIndex() uint64 {
+	if m != nil {
+		return m.Index
+	}
+	return 0
+}
+
+func (m *RaftSnapshot) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+func (m *RaftSnapshot) GetMembers() []*chord.Vnode {
+	if m != nil {
+		return m.Members
+	}
+	return nil
+}
+
+func (m *RaftSnapshot) GetEntries() []*RaftEntry {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
+type RaftAppendResponse struct {
+	Term    uint64 `protobuf:"varint,1,opt,name=term" json:"term,omitempty"`
+	Success bool   `protobuf:"varint,2,opt,name=success" json:"success,omitempty"`
+	// Last index of the member log that may match the leader
+	LastLogIndex uint64 `protobuf:"varint,3,opt,name=last_log_index,json=lastLogIndex" json:"last_log_index,omitempty"`
+	Err          string `protobuf:"bytes,4,opt,name=err" json:"err,omitempty"`
+}
+
+func (m *RaftAppendResponse) Reset()                    { *m = RaftAppendResponse{} }
+func (m *RaftAppendResponse) String() string            { return proto.CompactTextString(m) }
+func (*RaftAppendResponse) ProtoMessage()               {}
+func (*RaftAppendResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{30} }
+
+func (m *RaftAppendResponse) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+func (m *RaftAppendResponse) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+func (m *RaftAppendResponse) GetLastLogIndex() uint64 {
+	if m != nil {
+		return m.LastLogIndex
+	}
+	return 0
+}
+
+func (m *RaftAppendResponse) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
+type RaftProposal struct {
+	Group   *chord.Vnode   `protobuf:"bytes,1,opt,name=group" json:"group,omitempty"`
+	Vn      *chord.Vnode   `protobuf:"bytes,2,opt,name=vn" json:"vn,omitempty"`
+	Entry   *RaftEntry     `protobuf:"bytes,3,opt,name=entry" json:"entry,omitempty"`
+	Members []*chord.Vnode `protobuf:"bytes,4,rep,name=members" json:"members,omitempty"`
+}
+
+func (m *RaftProposal) Reset()                    { *m = RaftProposal{} }
+func (m *RaftProposal) String() string            { return proto.CompactTextString(m) }
+func (*RaftProposal) ProtoMessage()               {}
+func (*RaftProposal) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{31} }
+
+func (m *RaftProposal) GetGroup() *chord.Vnode {
+	if m != nil {
+		return m.Group
+	}
+	return nil
+}
+
+func (m *RaftProposal) GetVn() *chord.Vnode {
+	if m != nil {
+		return m.Vn
+	}
+	return nil
+}
+
+func (m *RaftProposal) GetEntry() *RaftEntry {
+	if m != nil {
+		return m.Entry
+	}
+	return nil
+}
+
+func (m *RaftProposal) GetMembers() []*chord.Vnode {
+	if m != nil {
+		return m.Members
+	}
+	return nil
+}
+
+type RaftProposeResponse struct {
+	Value []byte `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	// Leader known to the member if it is not the leader
+	Leader *chord.Vnode `protobuf:"bytes,2,opt,name=leader" json:"leader,omitempty"`
+	Err    string       `protobuf:"bytes,3,opt,name=err" json:"err,omitempty"`
+	// Stamp of the last write of the key read by a get
+	Stamp *WriteStamp `protobuf:"bytes,4,opt,name=stamp" json:"stamp,omitempty"`
+}
+
+func (m *RaftProposeResponse) Reset()                    { *m = RaftProposeResponse{} }
+func (m *RaftProposeResponse) String() string            { return proto.CompactTextString(m) }
+func (*RaftProposeResponse) ProtoMessage()               {}
+func (*RaftProposeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{32} }
+
+func (m *RaftProposeResponse) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (m *RaftProposeResponse) GetLeader() *chord.Vnode {
+	if m != nil {
+		return m.Leader
+	}
+	return nil
+}
+
+func (m *RaftProposeResponse) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
+func (m *RaftProposeResponse) GetStamp() *WriteStamp {
+	if m != nil {
+		return m.Stamp
+	}
+	return nil
+}
+
+// TxnOp is a read or write of a key by a transaction
+type TxnOp struct {
+	Key []byte `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	// read, put or remove
+	Op    string `protobuf:"bytes,2,opt,name=op" json:"op,omitempty"`
+	Value []byte `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	// Hash of the value read.  Empty if the key was not found.
+	Hash []byte `protobuf:"bytes,4,opt,name=hash,proto3" json:"hash,omitempty"`
+}
+
+func (m *TxnOp) Reset()                    { *m = TxnOp{} }
+func (m *TxnOp) String() string            { return proto.CompactTextString(m) }
+func (*TxnOp) ProtoMessage()               {}
+func (*TxnOp) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{33} }
+
+func (m *TxnOp) GetKey() []byte {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *TxnOp) GetOp() string {
+	if m != nil {
+		return m.Op
+	}
+	return ""
+}
+
+func (m *TxnOp) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (m *TxnOp) GetHash() []byte {
+	if m != nil {
+		return m.Hash
+	}
+	return nil
+}
+
+// DHTTxn is the part of a transaction on a vnode
+type DHTTxn struct {
+	Vn  *chord.Vnode `protobuf:"bytes,1,opt,name=vn" json:"vn,omitempty"`
+	Id  string       `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
+	Ops []*TxnOp     `protobuf:"bytes,3,rep,name=ops" json:"ops,omitempty"`
+	// Vnodes of all parts of the transaction.  Set when preparing so a replica
+	// resolving it can send the decision to all.
+	Parts []*chord.Vnode `protobuf:"bytes,4,rep,name=parts" json:"parts,omitempty"`
+	// Stamp of the writes applied on commit
+	Stamp *WriteStamp `protobuf:"bytes,5,opt,name=stamp" json:"stamp,omitempty"`
+}
+
+func (m *DHTTxn) Reset()                    { *m = DHTTxn{} }
+func (m *DHTTxn) String() string            { return proto.CompactTextString(m) }
+func (*DHTTxn) ProtoMessage()               {}
+func (*DHTTxn) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{34} }
+
+func (m *DHTTxn) GetVn() *chord.Vnode {
+	if m != nil {
+		return m.Vn
+	}
+	return nil
+}
+
+func (m *DHTTxn) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *DHTTxn) GetOps() []*TxnOp {
+	if m != nil {
+		return m.Ops
+	}
+	return nil
+}
+
+func (m *DHTTxn) GetParts() []*chord.Vnode {
+	if m != nil {
+		return m.Parts
+	}
+	return nil
+}
+
+func (m *DHTTxn) GetStamp() *WriteStamp {
+	if m != nil {
+		return m.Stamp
+	}
+	return nil
+}
+
+// BatchOp is a get, put or remove of a key on a vnode
+type BatchOp struct {
+	Vn  *chord.Vnode `protobuf:"bytes,1,opt,name=vn" json:"vn,omitempty"`
+	Key []byte       `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	// get, put or remove
+	Op    string `protobuf:"bytes,3,opt,name=op" json:"op,omitempty"`
+	Value []byte `protobuf:"bytes,4,opt,name=value,proto3" json:"value,omitempty"`
+	// Stamp of puts and removes
+	Stamp *WriteStamp `protobuf:"bytes,5,opt,name=stamp" json:"stamp,omitempty"`
+}
+
+func (m *BatchOp) Reset()                    { *m = BatchOp{} }
+func (m *BatchOp) String() string            { return proto.CompactTextString(m) }
+func (*BatchOp) ProtoMessage()               {}
+func (*BatchOp) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{35} }
+
+func (m *BatchOp) GetVn() *chord.Vnode {
+	if m != nil {
+		return m.Vn
+	}
+	return nil
+}
+
+func (m *BatchOp) GetKey() []byte {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *BatchOp) GetOp() string {
+	if m != nil {
+		return m.Op
+	}
+	return ""
+}
+
+func (m *BatchOp) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (m *BatchOp) GetStamp() *WriteStamp {
+	if m != nil {
+		return m.Stamp
+	}
+	return nil
+}
+
+// DHTBatch are operations on vnodes of a single host
+type DHTBatch struct {
+	Ops []*BatchOp `protobuf:"bytes,1,rep,name=ops" json:"ops,omitempty"`
+}
+
+func (m *DHTBatch) Reset()                    { *m = DHTBatch{} }
+func (m *DHTBatch) String() string            { return proto.CompactTextString(m) }
+func (*DHTBatch) ProtoMessage()               {}
+func (*DHTBatch) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{36} }
+
+func (m *DHTBatch) GetOps() []*BatchOp {
+	if m != nil {
+		return m.Ops
+	}
+	return nil
+}
+
+// BatchResult of an operation.  Value is only set for gets.
+type BatchResult struct {
+	Value []byte `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	Err   string `protobuf:"bytes,2,opt,name=err" json:"err,omitempty"`
+}
+
+func (m *BatchResult) Reset()                    { *m = BatchResult{} }
+func (m *BatchResult) String() string            { return proto.CompactTextString(m) }
+func (*BatchResult) ProtoMessage()               {}
+func (*BatchResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{37} }
+
+func (m *BatchResult) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (m *BatchResult) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
+// DHTBatchResponse has a result for each operation in request order
+type DHTBatchResponse struct {
+	Results []*BatchResult `protobuf:"bytes,1,rep,name=results" json:"results,omitempty"`
+	Err     string         `protobuf:"bytes,2,opt,name=err" json:"err,omitempty"`
+}
+
+func (m *DHTBatchResponse) Reset()                    { *m = DHTBatchResponse{} }
+func (m *DHTBatchResponse) String() string            { return proto.CompactTextString(m) }
+func (*DHTBatchResponse) ProtoMessage()               {}
+func (*DHTBatchResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{38} }
+
+func (m *DHTBatchResponse) GetResults() []*BatchResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
+func (m *DHTBatchResponse) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*WriteStamp)(nil), "chordstore.WriteStamp")
+	proto.RegisterType((*DHTKeyValue)(nil), "chordstore.DHTKeyValue")
+	proto.RegisterType((*DHTHashKeyValue)(nil), "chordstore.DHTHashKeyValue")
+	proto.RegisterType((*DHTBytes)(nil), "chordstore.DHTBytes")
+	proto.RegisterType((*DHTBytesErr)(nil), "chordstore.DHTBytesErr")
+	proto.RegisterType((*SnapshotOptions)(nil), "chordstore.SnapshotOptions")
+	proto.RegisterType((*ObjectMeta)(nil), "chordstore.ObjectMeta")
+	proto.RegisterType((*DHTObjectMeta)(nil), "chordstore.DHTObjectMeta")
+	proto.RegisterType((*DHTListRequest)(nil), "chordstore.DHTListRequest")
+	proto.RegisterType((*DHTKeys)(nil), "chordstore.DHTKeys")
+	proto.RegisterType((*DataStream)(nil), "chordstore.DataStream")
+	proto.RegisterType((*KeyTxn)(nil), "chordstore.KeyTxn")
+	proto.RegisterType((*DHTKeyTxns)(nil), "chordstore.DHTKeyTxns")
+	proto.RegisterType((*DHTMerkleRequest)(nil), "chordstore.DHTMerkleRequest")
+	proto.RegisterType((*MerkleEntry)(nil), "chordstore.MerkleEntry")
+	proto.RegisterType((*DHTMerkleResponse)(nil), "chordstore.DHTMerkleResponse")
+	proto.RegisterType((*DHTExpiry)(nil), "chordstore.DHTExpiry")
+	proto.RegisterType((*DHTTombstone)(nil), "chordstore.DHTTombstone")
+	proto.RegisterType((*Sibling)(nil), "chordstore.Sibling")
+	proto.RegisterType((*DHTSiblings)(nil), "chordstore.DHTSiblings")
+	proto.RegisterType((*DHTVersionedKeyValue)(nil), "chordstore.DHTVersionedKeyValue")
+	proto.RegisterType((*DHTSiblingKey)(nil), "chordstore.DHTSiblingKey")
+	proto.RegisterType((*DHTSwapRequest)(nil), "chordstore.DHTSwapRequest")
+	proto.RegisterType((*DHTSwapResponse)(nil), "chordstore.DHTSwapResponse")
+	proto.RegisterType((*RaftEntry)(nil), "chordstore.RaftEntry")
+	proto.RegisterType((*RaftVoteRequest)(nil), "chordstore.RaftVoteRequest")
+	proto.RegisterType((*RaftVoteResponse)(nil), "chordstore.RaftVoteResponse")
+	proto.RegisterType((*RaftAppendRequest)(nil), "chordstore.RaftAppendRequest")
+	proto.RegisterType((*RaftState)(nil), "chordstore.RaftState")
+	proto.RegisterType((*RaftSnapshot)(nil), "chordstore.RaftSnapshot")
+	proto.RegisterType((*RaftAppendResponse)(nil), "chordstore.RaftAppendResponse")
+	proto.RegisterType((*RaftProposal)(nil), "chordstore.RaftProposal")
+	proto.RegisterType((*RaftProposeResponse)(nil), "chordstore.RaftProposeResponse")
+	proto.RegisterType((*TxnOp)(nil), "chordstore.TxnOp")
+	proto.RegisterType((*DHTTxn)(nil), "chordstore.DHTTxn")
+	proto.RegisterType((*BatchOp)(nil), "chordstore.BatchOp")
+	proto.RegisterType((*DHTBatch)(nil), "chordstore.DHTBatch")
+	proto.RegisterType((*BatchResult)(nil), "chordstore.BatchResult")
+	proto.RegisterType((*DHTBatchResponse)(nil), "chordstore.DHTBatchResponse")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// Client API for DHT service
+
+type DHTClient interface {
+	PutKeyRPC(ctx context.Context, in *DHTKeyValue, opts ...grpc.CallOption) (*chord.ErrResponse, error)
+	GetKeyRPC(ctx context.Context, in *DHTBytes, opts ...grpc.CallOption) (*DHTBytesErr, error)
+	KeyExpiryRPC(ctx context.Context, in *DHTBytes, opts ...grpc.CallOption) (*DHTExpiry, error)
+	KeyTombstoneRPC(ctx context.Context, in *DHTBytes, opts ...grpc.CallOption) (*DHTTombstone, error)
+	UpdateKeyRPC(ctx context.Context, in *DHTHashKeyValue, opts ...grpc.CallOption) (*chord.ErrResponse, error)
+	RemoveKeyRPC(ctx context.Context, in *DHTBytes, opts ...grpc.CallOption) (*chord.ErrResponse, error)
+	KeyHistoryRPC(ctx context.Context, in *DHTBytes, opts ...grpc.CallOption) (*DHTKeyTxns, error)
+	GetVersionsRPC(ctx context.Context, in *DHTBytes, opts ...grpc.CallOption) (*DHTSiblings, error)
+	PutVersionRPC(ctx context.Context, in *DHTVersionedKeyValue, opts ...grpc.CallOption) (*DHTSiblings, error)
+	AddSiblingRPC(ctx context.Context, in *DHTSiblingKey, opts ...grpc.CallOption) (*chord.ErrResponse, error)
+	CompareAndSwapRPC(ctx context.Context, in *DHTSwapRequest, opts ...grpc.CallOption) (*DHTSwapResponse, error)
+	RaftVoteRPC(ctx context.Context, in *RaftVoteRequest, opts ...grpc.CallOption) (*RaftVoteResponse, error)
+	RaftAppendRPC(ctx context.Context, in *RaftAppendRequest, opts ...grpc.CallOption) (*RaftAppendResponse, error)
+	RaftProposeRPC(ctx context.Context, in *RaftProposal, opts ...grpc.CallOption) (*RaftProposeResponse, error)
+	TxnPrepareRPC(ctx context.Context, in *DHTTxn, opts ...grpc.CallOption) (*chord.ErrResponse, error)
+	TxnCommitRPC(ctx context.Context, in *DHTTxn, opts ...grpc.CallOption) (*chord.ErrResponse, error)
+	TxnAbortRPC(ctx context.Context, in *DHTTxn, opts ...grpc.CallOption) (*chord.ErrResponse, error)
+	BatchRPC(ctx context.Context, in *DHTBatch, opts ...grpc.CallOption) (*DHTBatchResponse, error)
+	MerkleRPC(ctx context.Context, in *DHTMerkleRequest, opts ...grpc.CallOption) (*DHTMerkleResponse, error)
+	PutObjectRPC(ctx context.Context, opts ...grpc.CallOption) (DHT_PutObjectRPCClient, error)
+	GetObjectRPC(ctx context.Context, in *DHTBytes, opts ...grpc.CallOption) (DHT_GetObjectRPCClient, error)
+	StatObjectRPC(ctx context.Context, in *DHTBytes, opts ...grpc.CallOption) (*DHTObjectMeta, error)
+	ListKeysRPC(ctx context.Context, in *DHTListRequest, opts ...grpc.CallOption) (DHT_ListKeysRPCClient, error)
+	RemoveObjectRPC(ctx context.Context, in *DHTBytes, opts ...grpc.CallOption) (*chord.ErrResponse, error)
+	SnapshotRPC(ctx context.Context, in *SnapshotOptions, opts ...grpc.CallOption) (DHT_SnapshotRPCClient, error)
+	RestoreRPC(ctx context.Context, opts ...grpc.CallOption) (DHT_RestoreRPCClient, error)
+}
+
+type dHTClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewDHTClient(cc *grpc.ClientConn) DHTClient {
+	return &dHTClient{cc}
+}
+
+func (c *dHTClient) PutKeyRPC(ctx context.Context, in *DHTKeyValue, opts ...grpc.CallOption) (*chord.ErrResponse, error) {
+	out := new(chord.ErrResponse)
+	err := grpc.Invoke(ctx, "/chordstore.DHT/PutKeyRPC", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dHTClient) GetKeyRPC(ctx context.Context, in *DHTBytes, opts ...grpc.CallOption) (*DHTBytesErr, error) {
+	out := new(DHTBytesErr)
+	err := grpc.Invoke(ctx, "/chordstore.DHT/GetKeyRPC", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dHTClient) KeyExpiryRPC(ctx context.Context, in *DHTBytes, opts ...grpc.CallOption) (*DHTExpiry, error) {
+	out := new(DHTExpiry)
+	err := grpc.Invoke(ctx, "/chordstore.DHT/KeyExpiryRPC", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dHTClient) KeyTombstoneRPC(ctx context.Context, in *DHTBytes, opts ...grpc.CallOption) (*DHTTombstone, error) {
+	out := new(DHTTombstone)
+	err := grpc.Invoke(ctx, "/chordstore.DHT/KeyTombstoneRPC", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dHTClient) UpdateKeyRPC(ctx context.Context, in *DHTHashKeyValue, opts ...grpc.CallOption) (*chord.ErrResponse, error) {
+	out := new(chord.ErrResponse)
+	err := grpc.Invoke(ctx, "/chordstore.DHT/UpdateKeyRPC", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dHTClient) RemoveKeyRPC(ctx context.Context, in *DHTBytes, opts ...grpc.CallOption) (*chord.ErrResponse, error) {
+	out := new(chord.ErrResponse)
+	err := grpc.Invoke(ctx, "/chordstore.DHT/RemoveKeyRPC", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dHTClient) KeyHistoryRPC(ctx context.Context, in *DHTBytes, opts ...grpc.CallOption) (*DHTKeyTxns, error) {
+	out := new(DHTKeyTxns)
+	err := grpc.Invoke(ctx, "/chordstore.DHT/KeyHistoryRPC", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dHTClient) GetVersionsRPC(ctx context.Context, in *DHTBytes, opts ...grpc.CallOption) (*DHTSiblings, error) {
+	out := new(DHTSiblings)
+	err := grpc.Invoke(ctx, "/chordstore.DHT/GetVersionsRPC", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dHTClient) PutVersionRPC(ctx context.Context, in *DHTVersionedKeyValue, opts ...grpc.CallOption) (*DHTSiblings, error) {
+	out := new(DHTSiblings)
+	err := grpc.Invoke(ctx, "/chordstore.DHT/PutVersionRPC", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dHTClient) AddSiblingRPC(ctx context.Context, in *DHTSiblingKey, opts ...grpc.CallOption) (*chord.ErrResponse, error) {
+	out := new(chord.ErrResponse)
+	err := grpc.Invoke(ctx, "/chordstore.DHT/AddSiblingRPC", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dHTClient) CompareAndSwapRPC(ctx context.Context, in *DHTSwapRequest, opts ...grpc.CallOption) (*DHTSwapResponse, error) {
+	out := new(DHTSwapResponse)
+	err := grpc.Invoke(ctx, "/chordstore.DHT/CompareAndSwapRPC", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dHTClient) RaftVoteRPC(ctx context.Context, in *RaftVoteRequest, opts ...grpc.CallOption) (*RaftVoteResponse, error) {
+	out := new(RaftVoteResponse)
+	err := grpc.Invoke(ctx, "/chordstore.DHT/RaftVoteRPC", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dHTClient) RaftAppendRPC(ctx context.Context, in *RaftAppendRequest, opts ...grpc.CallOption) (*RaftAppendResponse, error) {
+	out := new(RaftAppendResponse)
+	err := grpc.Invoke(ctx, "/chordstore.DHT/RaftAppendRPC", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dHTClient) RaftProposeRPC(ctx context.Context, in *RaftProposal, opts ...grpc.CallOption) (*RaftProposeResponse, error) {
+	out := new(RaftProposeResponse)
+	err := grpc.Invoke(ctx, "/chordstore.DHT/RaftProposeRPC", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dHTClient) TxnPrepareRPC(ctx context.Context, in *DHTTxn, opts ...grpc.CallOption) (*chord.ErrResponse, error) {
+	out := new(chord.ErrResponse)
+	err := grpc.Invoke(ctx, "/chordstore.DHT/TxnPrepareRPC", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dHTClient) TxnCommitRPC(ctx context.Context, in *DHTTxn, opts ...grpc.CallOption) (*chord.ErrResponse, error) {
+	out := new(chord.ErrResponse)
+	err := grpc.Invoke(ctx, "/chordstore.DHT/TxnCommitRPC", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dHTClient) TxnAbortRPC(ctx context.Context, in *DHTTxn, opts ...grpc.CallOption) (*chord.ErrResponse, error) {
+	out := new(chord.ErrResponse)
+	err := grpc.Invoke(ctx, "/chordstore.DHT/TxnAbortRPC", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dHTClient) BatchRPC(ctx context.Context, in *DHTBatch, opts ...grpc.CallOption) (*DHTBatchResponse, error) {
+	out := new(DHTBatchResponse)
+	err := grpc.Invoke(ctx, "/chordstore.DHT/BatchRPC", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dHTClient) MerkleRPC(ctx context.Context, in *DHTMerkleRequest, opts ...grpc.CallOption) (*DHTMerkleResponse, error) {
+	out := new(DHTMerkleResponse)
+	err := grpc.Invoke(ctx, "/chordstore.DHT/MerkleRPC", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dHTClient) PutObjectRPC(ctx context.Context, opts ...grpc.CallOption) (DHT_PutObjectRPCClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_DHT_serviceDesc.Streams[0], c.cc, "/chordstore.DHT/PutObjectRPC", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &dHTPutObjectRPCClient{stream}
+	return x, nil
+}
+
+type DHT_PutObjectRPCClient interface {
+	Send(*DataStream) error
+	CloseAndRecv() (*chord.ErrResponse, error)
+	grpc.ClientStream
+}
+
+type dHTPutObjectRPCClient struct {
+	grpc.ClientStream
+}
+
+func (x *dHTPutObjectRPCClient) Send(m *DataStream) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *dHTPutObjectRPCClient) CloseAndRecv() (*chord.ErrResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(chord.ErrResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *dHTClient) GetObjectRPC(ctx context.Context, in *DHTBytes, opts ...grpc.CallOption) (DHT_GetObjectRPCClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_DHT_serviceDesc.Streams[1], c.cc, "/chordstore.DHT/GetObjectRPC", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &dHTGetObjectRPCClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type DHT_GetObjectRPCClient interface {
+	Recv() (*DataStream, error)
+	grpc.ClientStream
+}
+
+type dHTGetObjectRPCClient struct {
+	grpc.ClientStream
+}
+
+func (x *dHTGetObjectRPCClient) Recv() (*DataStream, error) {
+	m := new(DataStream)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *dHTClient) StatObjectRPC(ctx context.Context, in *DHTBytes, opts ...grpc.CallOption) (*DHTObjectMeta, error) {
+	out := new(DHTObjectMeta)
+	err := grpc.Invoke(ctx, "/chordstore.DHT/StatObjectRPC", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dHTClient) ListKeysRPC(ctx context.Context, in *DHTListRequest, opts ...grpc.CallOption) (DHT_ListKeysRPCClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_DHT_serviceDesc.Streams[2], c.cc, "/chordstore.DHT/ListKeysRPC", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &dHTListKeysRPCClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type DHT_ListKeysRPCClient interface {
+	Recv() (*DHTKeys, error)
+	grpc.ClientStream
+}
+
+type dHTListKeysRPCClient struct {
+	grpc.ClientStream
+}
+
+func (x *dHTListKeysRPCClient) Recv() (*DHTKeys, error) {
+	m := new(DHTKeys)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *dHTClient) RemoveObjectRPC(ctx context.Context, in *DHTBytes, opts ...grpc.CallOption) (*chord.ErrResponse, error) {
+	out := new(chord.ErrResponse)
+	err := grpc.Invoke(ctx, "/chordstore.DHT/RemoveObjectRPC", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dHTClient) SnapshotRPC(ctx context.Context, in *SnapshotOptions, opts ...grpc.CallOption) (DHT_SnapshotRPCClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_DHT_serviceDesc.Streams[3], c.cc, "/chordstore.DHT/SnapshotRPC", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &dHTSnapshotRPCClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type DHT_SnapshotRPCClient interface {
+	Recv() (*DataStream, error)
+	grpc.ClientStream
+}
+
+type dHTSnapshotRPCClient struct {
+	grpc.ClientStream
+}
+
+func (x *dHTSnapshotRPCClient) Recv() (*DataStream, error) {
+	m := new(DataStream)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *dHTClient) RestoreRPC(ctx context.Context, opts ...grpc.CallOption) (DHT_RestoreRPCClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_DHT_serviceDesc.Streams[4], c.cc, "/chordstore.DHT/RestoreRPC", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &dHTRestoreRPCClient{stream}
+	return x, nil
+}
+
+type DHT_RestoreRPCClient interface {
+	Send(*DataStream) error
+	CloseAndRecv() (*chord.ErrResponse, error)
+	grpc.ClientStream
+}
+
+type dHTRestoreRPCClient struct {
+	grpc.ClientStream
+}
+
+func (x *dHTRestoreRPCClient) Send(m *DataStream) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *dHTRestoreRPCClient) CloseAndRecv() (*chord.ErrResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(chord.ErrResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Server API for DHT service
+
+type DHTServer interface {
+	PutKeyRPC(context.Context, *DHTKeyValue) (*chord.ErrResponse, error)
+	GetKeyRPC(context.Context, *DHTBytes) (*DHTBytesErr, error)
+	KeyExpiryRPC(context.Context, *DHTBytes) (*DHTExpiry, error)
+	KeyTombstoneRPC(context.Context, *DHTBytes) (*DHTTombstone, error)
+	UpdateKeyRPC(context.Context, *DHTHashKeyValue) (*chord.ErrResponse, error)
+	RemoveKeyRPC(context.Context, *DHTBytes) (*chord.ErrResponse, error)
+	KeyHistoryRPC(context.Context, *DHTBytes) (*DHTKeyTxns, error)
+	GetVersionsRPC(context.Context, *DHTBytes) (*DHTSiblings, error)
+	PutVersionRPC(context.Context, *DHTVersionedKeyValue) (*DHTSiblings, error)
+	AddSiblingRPC(context.Context, *DHTSiblingKey) (*chord.ErrResponse, error)
+	CompareAndSwapRPC(context.Context, *DHTSwapRequest) (*DHTSwapResponse, error)
+	RaftVoteRPC(context.Context, *RaftVoteRequest) (*RaftVoteResponse, error)
+	RaftAppendRPC(context.Context, *RaftAppendRequest) (*RaftAppendResponse, error)
+	RaftProposeRPC(context.Context, *RaftProposal) (*RaftProposeResponse, error)
+	TxnPrepareRPC(context.Context, *DHTTxn) (*chord.ErrResponse, error)
+	TxnCommitRPC(context.Context, *DHTTxn) (*chord.ErrResponse, error)
+	TxnAbortRPC(context.Context, *DHTTxn) (*chord.ErrResponse, error)
+	BatchRPC(context.Context, *DHTBatch) (*DHTBatchResponse, error)
+	MerkleRPC(context.Context, *DHTMerkleRequest) (*DHTMerkleResponse, error)
+	PutObjectRPC(DHT_PutObjectRPCServer) error
+	GetObjectRPC(*DHTBytes, DHT_GetObjectRPCServer) error
+	StatObjectRPC(context.Context, *DHTBytes) (*DHTObjectMeta, error)
+	ListKeysRPC(*DHTListRequest, DHT_ListKeysRPCServer) error
+	RemoveObjectRPC(context.Context, *DHTBytes) (*chord.ErrResponse, error)
+	SnapshotRPC(*SnapshotOptions, DHT_SnapshotRPCServer) error
+	RestoreRPC(DHT_RestoreRPCServer) error
+}
+
+func RegisterDHTServer(s *grpc.Server, srv DHTServer) {
+	s.RegisterService(&_DHT_serviceDesc, srv)
+}
+
+func _DHT_PutKeyRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DHTKeyValue)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DHTServer).PutKeyRPC(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chordstore.DHT/PutKeyRPC",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DHTServer).PutKeyRPC(ctx, req.(*DHTKeyValue))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DHT_GetKeyRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DHTBytes)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DHTServer).GetKeyRPC(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chordstore.DHT/GetKeyRPC",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DHTServer).GetKeyRPC(ctx, req.(*DHTBytes))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DHT_KeyExpiryRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DHTBytes)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DHTServer).KeyExpiryRPC(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chordstore.DHT/KeyExpiryRPC",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DHTServer).KeyExpiryRPC(ctx, req.(*DHTBytes))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DHT_KeyTombstoneRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DHTBytes)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DHTServer).KeyTombstoneRPC(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chordstore.DHT/KeyTombstoneRPC",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DHTServer).KeyTombstoneRPC(ctx, req.(*DHTBytes))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DHT_UpdateKeyRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DHTHashKeyValue)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DHTServer).UpdateKeyRPC(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chordstore.DHT/UpdateKeyRPC",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DHTServer).UpdateKeyRPC(ctx, req.(*DHTHashKeyValue))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DHT_RemoveKeyRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DHTBytes)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DHTServer).RemoveKeyRPC(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chordstore.DHT/RemoveKeyRPC",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DHTServer).RemoveKeyRPC(ctx, req.(*DHTBytes))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (x *dHTPutObjectRPCClient) CloseAndRecv() (*chord.ErrResponse, error) {
-	if err := x.ClientStream.CloseSend(); err != nil {
+func _DHT_KeyHistoryRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DHTBytes)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	m := new(chord.ErrResponse)
-	if err := x.ClientStream.RecvMsg(m); err != nil {
-		return nil, err
+	if interceptor == nil {
+		return srv.(DHTServer).KeyHistoryRPC(ctx, in)
 	}
-	return m, nil
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chordstore.DHT/KeyHistoryRPC",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DHTServer).KeyHistoryRPC(ctx, req.(*DHTBytes))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (c *dHTClient) GetObjectRPC(ctx context.Context, in *DHTBytes, opts ...grpc.CallOption) (DHT_GetObjectRPCClient, error) {
-	stream, err := grpc.NewClientStream(ctx, &_DHT_serviceDesc.Streams[1], c.cc, "/chordstore.DHT/GetObjectRPC", opts...)
-	if err != nil {
+func _DHT_GetVersionsRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DHTBytes)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	x := &dHTGetObjectRPCClient{stream}
-	if err := x.ClientStream.SendMsg(in); err != nil {
-		return nil, err
+	if interceptor == nil {
+		return srv.(DHTServer).GetVersionsRPC(ctx, in)
 	}
-	if err := x.ClientStream.CloseSend(); err != nil {
-		return nil, err
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chordstore.DHT/GetVersionsRPC",
 	}
-	return x, nil
-}
-
-type DHT_GetObjectRPCClient interface {
-	Recv() (*DataStream, error)
-	grpc.ClientStream
-}
-
-type dHTGetObjectRPCClient struct {
-	grpc.ClientStream
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DHTServer).GetVersionsRPC(ctx, req.(*DHTBytes))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (x *dHTGetObjectRPCClient) Recv() (*DataStream, error) {
-	m := new(DataStream)
-	if err := x.ClientStream.RecvMsg(m); err != nil {
+func _DHT_PutVersionRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DHTVersionedKeyValue)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	return m, nil
+	if interceptor == nil {
+		return srv.(DHTServer).PutVersionRPC(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chordstore.DHT/PutVersionRPC",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DHTServer).PutVersionRPC(ctx, req.(*DHTVersionedKeyValue))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (c *dHTClient) RemoveObjectRPC(ctx context.Context, in *DHTBytes, opts ...grpc.CallOption) (*chord.ErrResponse, error) {
-	out := new(chord.ErrResponse)
-	err := grpc.Invoke(ctx, "/chordstore.DHT/RemoveObjectRPC", in, out, c.cc, opts...)
-	if err != nil {
+func _DHT_AddSiblingRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DHTSiblingKey)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	return out, nil
+	if interceptor == nil {
+		return srv.(DHTServer).AddSiblingRPC(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chordstore.DHT/AddSiblingRPC",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DHTServer).AddSiblingRPC(ctx, req.(*DHTSiblingKey))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (c *dHTClient) SnapshotRPC(ctx context.Context, in *chord.Vnode, opts ...grpc.CallOption) (DHT_SnapshotRPCClient, error) {
-	stream, err := grpc.NewClientStream(ctx, &_DHT_serviceDesc.Streams[2], c.cc, "/chordstore.DHT/SnapshotRPC", opts...)
-	if err != nil {
+func _DHT_CompareAndSwapRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DHTSwapRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	x := &dHTSnapshotRPCClient{stream}
-	if err := x.ClientStream.SendMsg(in); err != nil {
-		return nil, err
+	if interceptor == nil {
+		return srv.(DHTServer).CompareAndSwapRPC(ctx, in)
 	}
-	if err := x.ClientStream.CloseSend(); err != nil {
-		return nil, err
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chordstore.DHT/CompareAndSwapRPC",
 	}
-	return x, nil
-}
-
-type DHT_SnapshotRPCClient interface {
-	Recv() (*DataStream, error)
-	grpc.ClientStream
-}
-
-type dHTSnapshotRPCClient struct {
-	grpc.ClientStream
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DHTServer).CompareAndSwapRPC(ctx, req.(*DHTSwapRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (x *dHTSnapshotRPCClient) Recv() (*DataStream, error) {
-	m := new(DataStream)
-	if err := x.ClientStream.RecvMsg(m); err != nil {
+func _DHT_RaftVoteRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RaftVoteRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	return m, nil
+	if interceptor == nil {
+		return srv.(DHTServer).RaftVoteRPC(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chordstore.DHT/RaftVoteRPC",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DHTServer).RaftVoteRPC(ctx, req.(*RaftVoteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (c *dHTClient) RestoreRPC(ctx context.Context, opts ...grpc.CallOption) (DHT_RestoreRPCClient, error) {
-	stream, err := grpc.NewClientStream(ctx, &_DHT_serviceDesc.Streams[3], c.cc, "/chordstore.DHT/RestoreRPC", opts...)
-	if err != nil {
+func _DHT_RaftAppendRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RaftAppendRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	x := &dHTRestoreRPCClient{stream}
-	return x, nil
-}
-
-type DHT_RestoreRPCClient interface {
-	Send(*DataStream) error
-	CloseAndRecv() (*chord.ErrResponse, error)
-	grpc.ClientStream
-}
-
-type dHTRestoreRPCClient struct {
-	grpc.ClientStream
-}
-
-func (x *dHTRestoreRPCClient) Send(m *DataStream) error {
-	return x.ClientStream.SendMsg(m)
+	if interceptor == nil {
+		return srv.(DHTServer).RaftAppendRPC(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chordstore.DHT/RaftAppendRPC",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DHTServer).RaftAppendRPC(ctx, req.(*RaftAppendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (x *dHTRestoreRPCClient) CloseAndRecv() (*chord.ErrResponse, error) {
-	if err := x.ClientStream.CloseSend(); err != nil {
+func _DHT_RaftProposeRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RaftProposal)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	m := new(chord.ErrResponse)
-	if err := x.ClientStream.RecvMsg(m); err != nil {
-		return nil, err
+	if interceptor == nil {
+		return srv.(DHTServer).RaftProposeRPC(ctx, in)
 	}
-	return m, nil
-}
-
-// Server API for DHT service
-
-type DHTServer interface {
-	PutKeyRPC(context.Context, *DHTKeyValue) (*chord.ErrResponse, error)
-	GetKeyRPC(context.Context, *DHTBytes) (*DHTBytesErr, error)
-	UpdateKeyRPC(context.Context, *DHTHashKeyValue) (*chord.ErrResponse, error)
-	RemoveKeyRPC(context.Context, *DHTBytes) (*chord.ErrResponse, error)
-	PutObjectRPC(DHT_PutObjectRPCServer) error
-	GetObjectRPC(*DHTBytes, DHT_GetObjectRPCServer) error
-	RemoveObjectRPC(context.Context, *DHTBytes) (*chord.ErrResponse, error)
-	SnapshotRPC(*chord.Vnode, DHT_SnapshotRPCServer) error
-	RestoreRPC(DHT_RestoreRPCServer) error
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chordstore.DHT/RaftProposeRPC",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DHTServer).RaftProposeRPC(ctx, req.(*RaftProposal))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func RegisterDHTServer(s *grpc.Server, srv DHTServer) {
-	s.RegisterService(&_DHT_serviceDesc, srv)
+func _DHT_TxnPrepareRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DHTTxn)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DHTServer).TxnPrepareRPC(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chordstore.DHT/TxnPrepareRPC",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DHTServer).TxnPrepareRPC(ctx, req.(*DHTTxn))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _DHT_PutKeyRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(DHTKeyValue)
+func _DHT_TxnCommitRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DHTTxn)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(DHTServer).PutKeyRPC(ctx, in)
+		return srv.(DHTServer).TxnCommitRPC(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/chordstore.DHT/PutKeyRPC",
+		FullMethod: "/chordstore.DHT/TxnCommitRPC",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(DHTServer).PutKeyRPC(ctx, req.(*DHTKeyValue))
+		return srv.(DHTServer).TxnCommitRPC(ctx, req.(*DHTTxn))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _DHT_GetKeyRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(DHTBytes)
+func _DHT_TxnAbortRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DHTTxn)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(DHTServer).GetKeyRPC(ctx, in)
+		return srv.(DHTServer).TxnAbortRPC(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/chordstore.DHT/GetKeyRPC",
+		FullMethod: "/chordstore.DHT/TxnAbortRPC",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(DHTServer).GetKeyRPC(ctx, req.(*DHTBytes))
+		return srv.(DHTServer).TxnAbortRPC(ctx, req.(*DHTTxn))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _DHT_UpdateKeyRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(DHTHashKeyValue)
+func _DHT_BatchRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DHTBatch)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(DHTServer).UpdateKeyRPC(ctx, in)
+		return srv.(DHTServer).BatchRPC(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/chordstore.DHT/UpdateKeyRPC",
+		FullMethod: "/chordstore.DHT/BatchRPC",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(DHTServer).UpdateKeyRPC(ctx, req.(*DHTHashKeyValue))
+		return srv.(DHTServer).BatchRPC(ctx, req.(*DHTBatch))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _DHT_RemoveKeyRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(DHTBytes)
+func _DHT_MerkleRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DHTMerkleRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(DHTServer).RemoveKeyRPC(ctx, in)
+		return srv.(DHTServer).MerkleRPC(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/chordstore.DHT/RemoveKeyRPC",
+		FullMethod: "/chordstore.DHT/MerkleRPC",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(DHTServer).RemoveKeyRPC(ctx, req.(*DHTBytes))
+		return srv.(DHTServer).MerkleRPC(ctx, req.(*DHTMerkleRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -544,6 +2579,45 @@ func (x *dHTGetObjectRPCServer) Send(m *DataStream) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _DHT_StatObjectRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DHTBytes)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DHTServer).StatObjectRPC(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chordstore.DHT/StatObjectRPC",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DHTServer).StatObjectRPC(ctx, req.(*DHTBytes))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DHT_ListKeysRPC_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DHTListRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DHTServer).ListKeysRPC(m, &dHTListKeysRPCServer{stream})
+}
+
+type DHT_ListKeysRPCServer interface {
+	Send(*DHTKeys) error
+	grpc.ServerStream
+}
+
+type dHTListKeysRPCServer struct {
+	grpc.ServerStream
+}
+
+func (x *dHTListKeysRPCServer) Send(m *DHTKeys) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _DHT_RemoveObjectRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(DHTBytes)
 	if err := dec(in); err != nil {
@@ -563,7 +2637,7 @@ func _DHT_RemoveObjectRPC_Handler(srv interface{}, ctx context.Context, dec func
 }
 
 func _DHT_SnapshotRPC_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(chord.Vnode)
+	m := new(SnapshotOptions)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
@@ -621,6 +2695,14 @@ var _DHT_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetKeyRPC",
 			Handler:    _DHT_GetKeyRPC_Handler,
 		},
+		{
+			MethodName: "KeyExpiryRPC",
+			Handler:    _DHT_KeyExpiryRPC_Handler,
+		},
+		{
+			MethodName: "KeyTombstoneRPC",
+			Handler:    _DHT_KeyTombstoneRPC_Handler,
+		},
 		{
 			MethodName: "UpdateKeyRPC",
 			Handler:    _DHT_UpdateKeyRPC_Handler,
@@ -629,6 +2711,62 @@ var _DHT_serviceDesc = grpc.ServiceDesc{
 			MethodName: "RemoveKeyRPC",
 			Handler:    _DHT_RemoveKeyRPC_Handler,
 		},
+		{
+			MethodName: "KeyHistoryRPC",
+			Handler:    _DHT_KeyHistoryRPC_Handler,
+		},
+		{
+			MethodName: "GetVersionsRPC",
+			Handler:    _DHT_GetVersionsRPC_Handler,
+		},
+		{
+			MethodName: "PutVersionRPC",
+			Handler:    _DHT_PutVersionRPC_Handler,
+		},
+		{
+			MethodName: "AddSiblingRPC",
+			Handler:    _DHT_AddSiblingRPC_Handler,
+		},
+		{
+			MethodName: "CompareAndSwapRPC",
+			Handler:    _DHT_CompareAndSwapRPC_Handler,
+		},
+		{
+			MethodName: "RaftVoteRPC",
+			Handler:    _DHT_RaftVoteRPC_Handler,
+		},
+		{
+			MethodName: "RaftAppendRPC",
+			Handler:    _DHT_RaftAppendRPC_Handler,
+		},
+		{
+			MethodName: "RaftProposeRPC",
+			Handler:    _DHT_RaftProposeRPC_Handler,
+		},
+		{
+			MethodName: "TxnPrepareRPC",
+			Handler:    _DHT_TxnPrepareRPC_Handler,
+		},
+		{
+			MethodName: "TxnCommitRPC",
+			Handler:    _DHT_TxnCommitRPC_Handler,
+		},
+		{
+			MethodName: "TxnAbortRPC",
+			Handler:    _DHT_TxnAbortRPC_Handler,
+		},
+		{
+			MethodName: "BatchRPC",
+			Handler:    _DHT_BatchRPC_Handler,
+		},
+		{
+			MethodName: "MerkleRPC",
+			Handler:    _DHT_MerkleRPC_Handler,
+		},
+		{
+			MethodName: "StatObjectRPC",
+			Handler:    _DHT_StatObjectRPC_Handler,
+		},
 		{
 			MethodName: "RemoveObjectRPC",
 			Handler:    _DHT_RemoveObjectRPC_Handler,
@@ -645,6 +2783,11 @@ var _DHT_serviceDesc = grpc.ServiceDesc{
 			Handler:       _DHT_GetObjectRPC_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "ListKeysRPC",
+			Handler:       _DHT_ListKeysRPC_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "SnapshotRPC",
 			Handler:       _DHT_SnapshotRPC_Handler,
@@ -662,32 +2805,139 @@ var _DHT_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("rpc.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 431 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x9c, 0x93, 0x5f, 0x6b, 0xdb, 0x30,
-	0x10, 0xc0, 0xa3, 0x24, 0x1b, 0xf5, 0xc5, 0x90, 0x21, 0xca, 0x1a, 0xbc, 0x3d, 0x04, 0xc1, 0x20,
-	0x2f, 0xb5, 0x47, 0x07, 0x1b, 0x94, 0x6e, 0xec, 0x8f, 0x43, 0x0d, 0x7d, 0x68, 0x70, 0xbc, 0xbe,
-	0xcb, 0xf6, 0xad, 0xce, 0xb6, 0x58, 0x46, 0x96, 0x0d, 0xfe, 0x62, 0xfb, 0x7c, 0xc3, 0xb2, 0xd3,
-	0xb9, 0xc5, 0x4d, 0x46, 0xdf, 0x64, 0xe9, 0x7e, 0xf7, 0xbb, 0xd3, 0xc9, 0x60, 0xc8, 0x2c, 0xb2,
-	0x33, 0x29, 0x94, 0xa0, 0x10, 0x25, 0x42, 0xc6, 0xb9, 0x12, 0x12, 0xad, 0x37, 0xb7, 0x1b, 0x95,
-	0x14, 0xa1, 0x1d, 0x89, 0xad, 0x83, 0xc5, 0x0f, 0x21, 0x37, 0xdc, 0xb9, 0x15, 0xa7, 0x3a, 0xc2,
-	0x49, 0x51, 0x35, 0x08, 0x5b, 0xc3, 0xc4, 0xf5, 0x82, 0x2b, 0xac, 0x6e, 0xf8, 0xef, 0x02, 0xe9,
-	0x6b, 0x18, 0x96, 0xe9, 0x8c, 0xcc, 0xc9, 0x62, 0x72, 0x66, 0xda, 0x3a, 0xd8, 0xbe, 0x49, 0x45,
-	0x8c, 0xfe, 0xb0, 0x4c, 0xe9, 0x0b, 0x18, 0xfd, 0xc2, 0x6a, 0x36, 0x9c, 0x93, 0x85, 0xe9, 0xd7,
-	0x4b, 0x7a, 0x0c, 0xcf, 0xca, 0x1a, 0x9c, 0x8d, 0xf4, 0x5e, 0xf3, 0xc1, 0x72, 0x98, 0xba, 0x5e,
-	0xe0, 0xf1, 0x3c, 0xf9, 0xcf, 0xc4, 0x16, 0x1c, 0x65, 0x12, 0xcb, 0x9a, 0x68, 0xb3, 0xdf, 0x7d,
-	0xef, 0xa4, 0xa3, 0x1e, 0xe9, 0xb8, 0x2b, 0x7d, 0x0f, 0x47, 0xae, 0x17, 0x7c, 0xad, 0x14, 0xe6,
-	0x07, 0x6c, 0x26, 0x90, 0xb0, 0xd5, 0x90, 0x90, 0x9d, 0xea, 0x1b, 0xd0, 0xdc, 0x52, 0xca, 0xe6,
-	0x90, 0xb4, 0x87, 0xb5, 0x1c, 0xa5, 0xd4, 0xc1, 0x86, 0x5f, 0x2f, 0x99, 0x03, 0xd3, 0x75, 0xca,
-	0xb3, 0x3c, 0x11, 0xea, 0x3a, 0x53, 0x1b, 0x91, 0x1e, 0xb0, 0xb1, 0x39, 0x80, 0xcb, 0x15, 0x5f,
-	0x2b, 0x89, 0x7c, 0x4b, 0x29, 0x8c, 0x63, 0xae, 0x78, 0x6b, 0xd0, 0xeb, 0xb3, 0x3f, 0x63, 0x18,
-	0xb9, 0x5e, 0x40, 0xcf, 0xc1, 0x58, 0x15, 0xea, 0x0a, 0x2b, 0x7f, 0xf5, 0x8d, 0x9e, 0xd8, 0xff,
-	0x86, 0x69, 0x77, 0x46, 0x64, 0xd1, 0xd6, 0xb0, 0x94, 0xd2, 0xc7, 0x3c, 0x13, 0x69, 0x8e, 0x6c,
-	0x40, 0x2f, 0xc0, 0xb8, 0xc4, 0x1d, 0x7b, 0xfc, 0x80, 0xd5, 0xcd, 0x59, 0x27, 0x7d, 0xbb, 0x4b,
-	0x29, 0xd9, 0x80, 0x7e, 0x01, 0xf3, 0x7b, 0x16, 0x73, 0x85, 0x6d, 0x82, 0x57, 0x0f, 0x42, 0xbb,
-	0xa3, 0x7c, 0xa4, 0x80, 0x73, 0x30, 0x7d, 0xdc, 0x8a, 0x12, 0xf7, 0xd6, 0xd0, 0xcf, 0x7e, 0x02,
-	0x73, 0x55, 0xa8, 0xeb, 0xf0, 0x27, 0x46, 0xaa, 0x66, 0x5f, 0xde, 0x63, 0xef, 0x2e, 0xaf, 0x9f,
-	0x5e, 0x10, 0xfa, 0x19, 0xcc, 0x4b, 0xec, 0xf0, 0xfd, 0xee, 0x47, 0xb2, 0xb2, 0xc1, 0x5b, 0x42,
-	0x3f, 0xc2, 0xb4, 0xa9, 0xfe, 0x50, 0x92, 0xfe, 0x06, 0x3e, 0xc0, 0x64, 0xf7, 0x28, 0x6a, 0xf4,
-	0xde, 0x23, 0xd8, 0xeb, 0xbd, 0x00, 0xf0, 0x51, 0x9f, 0x3c, 0xa1, 0xef, 0xf0, 0xb9, 0xfe, 0x87,
-	0xdf, 0xfd, 0x0d, 0x00, 0x00, 0xff, 0xff, 0x4b, 0xc1, 0xfe, 0x56, 0x03, 0x04, 0x00, 0x00,
+	// 2137 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x59, 0x4b, 0x73, 0xdb, 0xc8,
+	0xf1, 0x17, 0x08, 0x50, 0x24, 0x9b, 0xd4, 0xc3, 0x63, 0xaf, 0xcd, 0x3f, 0xed, 0xdd, 0xbf, 0x0a,
+	0xfb, 0x28, 0xd7, 0x26, 0x96, 0x62, 0xef, 0x6e, 0x65, 0xe3, 0x5a, 0x6f, 0x2c, 0x8b, 0xb2, 0x99,
+	0xc8, 0x8e, 0x59, 0x10, 0xe2, 0x3d, 0xba, 0x20, 0x62, 0x44, 0x21, 0x26, 0x67, 0x90, 0xc1, 0x50,
+	0x4b, 0xba, 0x72, 0x48, 0x25, 0x95, 0x63, 0x92, 0xca, 0x31, 0xc7, 0x1c, 0x72, 0xdc, 0x8f, 0x90,
+	0x8f, 0x90, 0x7b, 0x4e, 0xb9, 0xe4, 0x8b, 0xa4, 0xe6, 0x01, 0x60, 0x04, 0x82, 0x22, 0x95, 0xf5,
+	0x0d, 0x3d, 0xd3, 0xd3, 0xd3, 0xf3, 0xeb, 0xe7, 0x0c, 0xa0, 0xc1, 0xe2, 0xc1, 0x6e, 0xcc, 0x28,
+	0xa7, 0x08, 0x06, 0x67, 0x94, 0x85, 0x09, 0xa7, 0x0c, 0x77, 0x3e, 0x1e, 0x46, 0xfc, 0x6c, 0x72,
+	0xb2, 0x3b, 0xa0, 0xe3, 0x3d, 0x3c, 0x39, 0xa5, 0x2c, 0x0a, 0xf6, 0x86, 0xf4, 0x9e, 0xe4, 0xd8,
+	0x23, 0x98, 0xab, 0x25, 0xee, 0x3f, 0x2c, 0x80, 0x6f, 0x58, 0xc4, 0xf1, 0x31, 0x0f, 0xc6, 0x31,
+	0xba, 0x09, 0xeb, 0x94, 0x45, 0xc3, 0x88, 0xb4, 0xad, 0x1d, 0xeb, 0x6e, 0xc3, 0xd3, 0x14, 0xba,
+	0x03, 0x0d, 0x1e, 0x8d, 0x71, 0x22, 0x98, 0xda, 0x95, 0x1d, 0xeb, 0xae, 0xed, 0xe5, 0x03, 0xe8,
+	0x11, 0xd4, 0x46, 0xc1, 0x8c, 0x4e, 0x78, 0xd2, 0xb6, 0x77, 0xec, 0xbb, 0xcd, 0x07, 0x1f, 0xee,
+	0xe6, 0x9a, 0xec, 0xe6, 0xe2, 0x77, 0x9f, 0x2b, 0xae, 0x43, 0xc2, 0xd9, 0xcc, 0x4b, 0xd7, 0x74,
+	0x1e, 0x42, 0xcb, 0x9c, 0x40, 0xdb, 0x60, 0xbf, 0xc1, 0x33, 0xad, 0x81, 0xf8, 0x44, 0x37, 0xa0,
+	0x7a, 0x1e, 0x8c, 0x26, 0x58, 0x6f, 0xad, 0x88, 0x87, 0x95, 0x2f, 0x2d, 0xf7, 0xaf, 0x16, 0x34,
+	0xbb, 0x3d, 0xff, 0x08, 0xcf, 0x5e, 0x89, 0x31, 0x74, 0x07, 0x2a, 0xe7, 0x4a, 0xf9, 0xe6, 0x83,
+	0x96, 0xd2, 0x62, 0xf7, 0x15, 0xa1, 0x21, 0xf6, 0x2a, 0xe7, 0x24, 0x95, 0x2c, 0xa4, 0xb4, 0x0a,
+	0x92, 0x6d, 0x39, 0xa6, 0x08, 0x01, 0x03, 0x9e, 0xc6, 0x11, 0x9b, 0xb5, 0x1d, 0xb9, 0xa1, 0xa6,
+	0xd0, 0x0f, 0xa1, 0xaa, 0x20, 0xa8, 0xca, 0x0d, 0x6e, 0x96, 0x1f, 0xd3, 0x53, 0x4c, 0xee, 0xdf,
+	0x2c, 0xd8, 0xea, 0xf6, 0xfc, 0x5e, 0x90, 0x9c, 0xad, 0xa8, 0x5f, 0x07, 0xea, 0x31, 0xc3, 0xe7,
+	0x62, 0x85, 0x56, 0x32, 0xa3, 0x53, 0xdd, 0xed, 0x12, 0xdd, 0x1d, 0x53, 0xf7, 0xab, 0xe9, 0xf8,
+	0x2f, 0x0b, 0xea, 0xdd, 0x9e, 0xff, 0x64, 0xc6, 0x71, 0xb2, 0x44, 0xb9, 0x16, 0x58, 0x27, 0x5a,
+	0x2b, 0xeb, 0x44, 0x40, 0x74, 0x8e, 0x59, 0x74, 0xaa, 0x34, 0xaa, 0x7b, 0x9a, 0x12, 0xe3, 0xf4,
+	0xf4, 0x34, 0xc1, 0x3c, 0x85, 0x4e, 0x51, 0x62, 0x7c, 0x84, 0xc9, 0x90, 0x9f, 0x49, 0xbd, 0x6c,
+	0x4f, 0x53, 0xe8, 0x53, 0x70, 0xc6, 0x98, 0x07, 0xed, 0xf5, 0x79, 0x6d, 0x5f, 0x9e, 0xfc, 0x0a,
+	0x0f, 0xf8, 0x0b, 0xcc, 0x03, 0x4f, 0xf2, 0xe4, 0x47, 0xab, 0xad, 0x72, 0xb4, 0x7b, 0xd0, 0x4c,
+	0x4f, 0x76, 0xc8, 0x98, 0x52, 0xdf, 0x4a, 0xd5, 0xdf, 0x06, 0x1b, 0x33, 0x26, 0x8f, 0xd3, 0xf0,
+	0xc4, 0xa7, 0xfb, 0x0d, 0x6c, 0x1d, 0x93, 0x20, 0x4e, 0xce, 0x28, 0x7f, 0x19, 0xf3, 0x88, 0x92,
+	0x65, 0x78, 0xdc, 0x90, 0xda, 0x30, 0xae, 0x31, 0x51, 0x84, 0x14, 0x4c, 0xc2, 0xd4, 0x4c, 0x98,
+	0x84, 0xee, 0xef, 0x2b, 0x00, 0xf9, 0x51, 0x10, 0x02, 0x27, 0x89, 0xde, 0x62, 0x29, 0xd6, 0xf6,
+	0xe4, 0xb7, 0x18, 0x3b, 0xcb, 0x6d, 0x2e, 0xbf, 0xd1, 0x0e, 0x34, 0x07, 0x94, 0x70, 0x4c, 0xb8,
+	0x3f, 0x8b, 0x95, 0x7f, 0x36, 0x3c, 0x73, 0x48, 0x84, 0xdd, 0x19, 0x0e, 0x42, 0xcc, 0x92, 0xb6,
+	0x33, 0x1f, 0x76, 0xf9, 0x96, 0xbb, 0x3d, 0xc5, 0xa5, 0xc3, 0x4e, 0xaf, 0x11, 0xfa, 0x8f, 0x45,
+	0x0c, 0x6b, 0x83, 0x28, 0x42, 0xb8, 0x20, 0x26, 0x03, 0x1a, 0x46, 0x64, 0x28, 0x6d, 0xd2, 0xf0,
+	0x32, 0x5a, 0x04, 0xaa, 0x29, 0x6a, 0x59, 0xa0, 0x36, 0xcc, 0x40, 0x7d, 0x01, 0x1b, 0xdd, 0x9e,
+	0x6f, 0xe0, 0x90, 0x1a, 0xde, 0x5a, 0xc1, 0xf0, 0xf3, 0xd6, 0xfa, 0x93, 0x05, 0x9b, 0xdd, 0x9e,
+	0xff, 0x3c, 0x4a, 0xb8, 0x87, 0x7f, 0x3d, 0xc1, 0x09, 0x5f, 0x62, 0xad, 0x9b, 0xb0, 0x1e, 0x33,
+	0x7c, 0x1a, 0x4d, 0x35, 0xc8, 0x9a, 0x12, 0xe3, 0x83, 0x09, 0x4b, 0x28, 0xd3, 0x26, 0xd3, 0x94,
+	0x38, 0xc9, 0x28, 0x1a, 0x47, 0xca, 0x8d, 0xab, 0x9e, 0x22, 0x50, 0x1b, 0x6a, 0x54, 0x2a, 0x97,
+	0x48, 0xd4, 0xea, 0x5e, 0x4a, 0xba, 0x7b, 0x50, 0x53, 0x79, 0x28, 0x11, 0xd6, 0x7c, 0x83, 0x67,
+	0x49, 0xdb, 0xda, 0xb1, 0x85, 0x35, 0xc5, 0x77, 0xc9, 0x09, 0x3e, 0x07, 0xe8, 0x06, 0x3c, 0x38,
+	0xe6, 0x0c, 0x07, 0x63, 0xb1, 0x26, 0x0c, 0x34, 0x1a, 0x2d, 0x4f, 0x7e, 0x67, 0x9e, 0x52, 0xc9,
+	0x3d, 0xc5, 0xfd, 0x0d, 0xac, 0x1f, 0xe1, 0x99, 0x3f, 0x25, 0x68, 0x13, 0x2a, 0x34, 0xd6, 0xd8,
+	0x57, 0x68, 0x5c, 0xea, 0x43, 0x66, 0x3e, 0xb1, 0x0b, 0xf9, 0xe4, 0x42, 0x4a, 0x77, 0x8a, 0x29,
+	0x5d, 0x18, 0x52, 0x60, 0xd7, 0xae, 0x6a, 0x43, 0x0a, 0xc2, 0x7d, 0x0a, 0xa0, 0x0e, 0xe9, 0x4f,
+	0x49, 0x82, 0x3e, 0x01, 0x87, 0x4f, 0x89, 0x3a, 0x67, 0xf3, 0x01, 0x32, 0x2d, 0xa8, 0x58, 0x3c,
+	0x39, 0x5f, 0x72, 0xf6, 0xb7, 0xb0, 0xdd, 0xed, 0xf9, 0x2f, 0x30, 0x7b, 0x33, 0xc2, 0xab, 0x99,
+	0x4f, 0x98, 0x03, 0x9f, 0xe3, 0x51, 0xbb, 0xa2, 0xcd, 0x21, 0x08, 0x61, 0x8e, 0x88, 0x84, 0x78,
+	0x8a, 0x55, 0xe1, 0xa9, 0x7a, 0x29, 0x29, 0x66, 0x30, 0xe1, 0x2c, 0xc2, 0x89, 0x3c, 0x5b, 0xdd,
+	0x4b, 0x49, 0xf7, 0x33, 0x68, 0xaa, 0x8d, 0xe7, 0x7c, 0x58, 0xa7, 0xd5, 0x12, 0x20, 0xdd, 0x18,
+	0xae, 0x19, 0x0a, 0x27, 0x31, 0x25, 0x89, 0xac, 0x12, 0x62, 0x12, 0xa7, 0x96, 0xd6, 0x14, 0xba,
+	0x9f, 0xef, 0x5d, 0x91, 0xd0, 0xdc, 0x32, 0xa1, 0x31, 0x36, 0xcf, 0x94, 0x4a, 0x21, 0xb2, 0x73,
+	0x88, 0xbe, 0x80, 0x46, 0xb7, 0xe7, 0x1f, 0xaa, 0xba, 0x93, 0xd7, 0x23, 0xeb, 0x42, 0x3d, 0x9a,
+	0x47, 0xf6, 0x6b, 0x68, 0x75, 0x7b, 0xbe, 0x4f, 0xc7, 0x27, 0x09, 0xa7, 0x04, 0x5f, 0xb4, 0xb2,
+	0x55, 0xb4, 0xf2, 0xfc, 0xfa, 0xff, 0x58, 0x50, 0x3b, 0x8e, 0x4e, 0x46, 0x11, 0x19, 0xe6, 0xc1,
+	0x6c, 0x99, 0xf5, 0x05, 0x81, 0x23, 0x1d, 0x43, 0x2d, 0x92, 0xdf, 0x02, 0xed, 0x01, 0x9d, 0x10,
+	0x8e, 0xd5, 0x11, 0x1c, 0x2f, 0x25, 0xd1, 0x43, 0x31, 0x43, 0x38, 0x9e, 0x72, 0x9d, 0xa3, 0x76,
+	0x4c, 0x2c, 0xf4, 0x4e, 0xbb, 0x07, 0x8a, 0x45, 0x83, 0xa2, 0x17, 0x5c, 0xd4, 0xbd, 0x5a, 0xd0,
+	0x5d, 0x24, 0x23, 0x73, 0xd9, 0xb2, 0x64, 0xe4, 0x98, 0xc9, 0xa8, 0x2f, 0x4b, 0x83, 0xde, 0x3d,
+	0x41, 0x7b, 0x50, 0x4f, 0xf4, 0xb7, 0x76, 0xe6, 0xeb, 0x25, 0x5a, 0x7a, 0x19, 0x53, 0x09, 0x6e,
+	0x7f, 0xae, 0xc0, 0x8d, 0x6e, 0xcf, 0x7f, 0x85, 0x59, 0x12, 0x51, 0x82, 0xc3, 0x77, 0xdc, 0x90,
+	0x3c, 0x2b, 0xc2, 0x78, 0xcf, 0x54, 0xb0, 0x6c, 0xe3, 0x05, 0x98, 0x5e, 0xa9, 0x3b, 0xf8, 0x5e,
+	0x18, 0x13, 0x99, 0xf0, 0x35, 0x76, 0x47, 0x78, 0x76, 0x65, 0x24, 0xee, 0x41, 0x4d, 0x03, 0x2e,
+	0xb1, 0x58, 0x60, 0x94, 0x94, 0xc7, 0xfd, 0x5d, 0x45, 0x56, 0x84, 0xe3, 0x6f, 0x83, 0x78, 0xb5,
+	0x94, 0x32, 0xbf, 0xe3, 0x36, 0xd8, 0x74, 0x94, 0xd5, 0x6e, 0x3a, 0x0a, 0x17, 0xb4, 0x58, 0xa2,
+	0x66, 0x30, 0x1c, 0x70, 0xac, 0x8b, 0x80, 0xa6, 0x74, 0xc9, 0x4e, 0xa2, 0x84, 0x63, 0x32, 0x98,
+	0xc9, 0xf2, 0x59, 0xf5, 0xcc, 0xa1, 0xab, 0x75, 0x30, 0x22, 0xac, 0x18, 0x8e, 0x47, 0xd1, 0x20,
+	0x68, 0xd7, 0x55, 0x12, 0xd3, 0xa4, 0xd0, 0x80, 0xe1, 0x31, 0x3d, 0xc7, 0xed, 0x86, 0xd2, 0x40,
+	0x51, 0xee, 0x1b, 0xd8, 0xca, 0x30, 0xd0, 0x59, 0xaa, 0x3c, 0x8a, 0xdb, 0x50, 0x4b, 0xbe, 0x0d,
+	0xe2, 0x18, 0x87, 0x12, 0x80, 0xba, 0x97, 0x92, 0xf3, 0xa9, 0x48, 0x65, 0x9f, 0x28, 0xe1, 0x69,
+	0x2a, 0xd5, 0x94, 0xfb, 0x9d, 0x05, 0x0d, 0x2f, 0x38, 0xd5, 0xbe, 0x81, 0xc0, 0xe1, 0x98, 0x8d,
+	0xe5, 0x36, 0x8e, 0x27, 0xbf, 0x75, 0x8d, 0xaa, 0x64, 0x35, 0x6a, 0xd5, 0x1e, 0xf6, 0x13, 0xa8,
+	0x8d, 0xf1, 0xf8, 0x44, 0x74, 0x36, 0xd5, 0x1d, 0x7b, 0xce, 0x7a, 0xe9, 0x64, 0x0e, 0xe7, 0xfa,
+	0x2a, 0x0d, 0xe1, 0x1f, 0x2a, 0xb0, 0x25, 0xf4, 0x7d, 0x45, 0x79, 0x56, 0x75, 0x5c, 0xa8, 0x0e,
+	0x19, 0x9d, 0xc4, 0xa5, 0x5e, 0xa2, 0xa6, 0xb4, 0x1b, 0x55, 0x16, 0xb8, 0xd1, 0xa7, 0xd0, 0x18,
+	0x04, 0x24, 0x8c, 0x42, 0xe1, 0x0f, 0x76, 0x09, 0x53, 0x3e, 0x9d, 0x61, 0xe4, 0x18, 0x18, 0x7d,
+	0x04, 0x9b, 0xa3, 0x20, 0xe1, 0xaf, 0x47, 0x74, 0xf8, 0x5a, 0x56, 0x2f, 0xe9, 0x54, 0x8e, 0xd7,
+	0x12, 0xa3, 0xcf, 0xe9, 0xf0, 0x67, 0x62, 0x0c, 0xb9, 0xb0, 0x91, 0x71, 0x49, 0x11, 0xeb, 0x92,
+	0xa9, 0xa9, 0x99, 0x7c, 0x21, 0xc9, 0x40, 0xad, 0x76, 0x09, 0x6a, 0xae, 0x07, 0xdb, 0x39, 0x0c,
+	0xda, 0x4b, 0xca, 0xac, 0xd7, 0x86, 0xda, 0x90, 0x05, 0x84, 0xe7, 0x3e, 0xa2, 0xc9, 0x92, 0x72,
+	0xf5, 0x5b, 0x1b, 0xae, 0x09, 0xa1, 0xfb, 0x71, 0x8c, 0x49, 0xf8, 0xee, 0xd0, 0xfd, 0x48, 0x5c,
+	0x1b, 0x44, 0xcb, 0x59, 0x0a, 0xad, 0x9e, 0x5b, 0x84, 0xab, 0xe8, 0x75, 0xe6, 0x71, 0x15, 0xa3,
+	0x26, 0xae, 0x19, 0x97, 0x89, 0xab, 0x66, 0x92, 0xb8, 0xee, 0xe5, 0xf5, 0x5c, 0xe1, 0xfa, 0x9e,
+	0xe9, 0x67, 0x59, 0x04, 0xe4, 0xd5, 0x5c, 0xe4, 0x07, 0x3a, 0x16, 0xcd, 0x63, 0x5d, 0x4a, 0xd3,
+	0x94, 0x69, 0xa0, 0xc6, 0x65, 0x6e, 0xfd, 0x39, 0xd4, 0x13, 0x7d, 0x15, 0x69, 0x83, 0x3c, 0x76,
+	0xbb, 0xb8, 0x63, 0x7a, 0x55, 0xf1, 0x32, 0x4e, 0xf7, 0x9f, 0x3a, 0x1c, 0x8f, 0xb9, 0xe9, 0x6a,
+	0xa6, 0x41, 0x6f, 0x43, 0xe3, 0x9c, 0x72, 0x1c, 0xbe, 0x3e, 0xa5, 0x69, 0xf1, 0xaa, 0xcb, 0x81,
+	0xa7, 0x94, 0x19, 0x17, 0x37, 0x55, 0xc2, 0x35, 0x65, 0x2a, 0xed, 0x5c, 0xa6, 0xb4, 0x81, 0x52,
+	0x75, 0x25, 0x94, 0x3e, 0x00, 0x18, 0x62, 0x82, 0x59, 0x20, 0x2e, 0x5b, 0x1a, 0x77, 0x63, 0xc4,
+	0xfd, 0x8b, 0x05, 0x2d, 0xf3, 0xa8, 0x22, 0x57, 0x28, 0x43, 0xaa, 0x33, 0x29, 0x22, 0x3b, 0x68,
+	0xc5, 0x38, 0xa8, 0xa1, 0xb3, 0xbd, 0xa2, 0xce, 0xce, 0x2a, 0x3a, 0xbb, 0x6f, 0x01, 0x99, 0x5e,
+	0x7e, 0x79, 0xf0, 0x24, 0x93, 0xc1, 0x00, 0x27, 0x49, 0x96, 0x60, 0x15, 0x59, 0x12, 0xf0, 0x76,
+	0x49, 0xc0, 0xeb, 0x10, 0x73, 0xf2, 0x10, 0xfb, 0xbb, 0xc6, 0xa3, 0xcf, 0x68, 0x4c, 0x93, 0x60,
+	0xf4, 0x0e, 0xa2, 0xeb, 0x07, 0x50, 0x15, 0x27, 0x9b, 0xe9, 0xe0, 0x5a, 0x70, 0x7a, 0xc5, 0xb3,
+	0xaa, 0x23, 0x08, 0xbb, 0x5d, 0xcf, 0xf5, 0xc4, 0x4b, 0x0a, 0x51, 0x1e, 0xe0, 0x95, 0x4b, 0x02,
+	0x7c, 0xbe, 0x28, 0x65, 0xa9, 0xdf, 0x59, 0x25, 0xf5, 0x1f, 0x43, 0xd5, 0x9f, 0x92, 0x97, 0x71,
+	0x49, 0xbb, 0x5f, 0xac, 0x51, 0xe5, 0x0d, 0x58, 0x7a, 0x29, 0x70, 0x8c, 0x4b, 0xc1, 0x77, 0x16,
+	0xac, 0x8b, 0x66, 0x7b, 0x4a, 0x96, 0x74, 0x1a, 0x9b, 0x50, 0x89, 0xc2, 0x74, 0x8b, 0x28, 0x44,
+	0x1f, 0x82, 0x4d, 0xe3, 0xd4, 0x35, 0xaf, 0x99, 0x9a, 0x4b, 0x25, 0x3d, 0x31, 0x2b, 0xac, 0x1b,
+	0x07, 0x8c, 0x97, 0x83, 0xad, 0xa6, 0xae, 0xf8, 0xd6, 0xf3, 0x47, 0x0b, 0x6a, 0x4f, 0x02, 0x3e,
+	0x38, 0x7b, 0x19, 0x5f, 0xb9, 0x35, 0x52, 0x28, 0xd9, 0xf3, 0x28, 0x7d, 0x8f, 0xb7, 0xa7, 0xfb,
+	0xea, 0xe9, 0x49, 0x68, 0x84, 0x3e, 0x56, 0x90, 0x94, 0x74, 0xdf, 0x5a, 0x63, 0x09, 0x8a, 0xfb,
+	0x05, 0x34, 0x25, 0xed, 0xe1, 0x64, 0x32, 0xe2, 0x0b, 0x5c, 0xaa, 0xec, 0x6d, 0x67, 0x3b, 0xdd,
+	0x29, 0x73, 0xc7, 0xfb, 0xa2, 0xb9, 0x12, 0x52, 0xd2, 0x5d, 0x6f, 0xcd, 0xed, 0xaa, 0x76, 0xf1,
+	0x52, 0xbe, 0x79, 0xc1, 0x0f, 0xfe, 0xbd, 0x01, 0x76, 0xb7, 0xe7, 0xa3, 0x87, 0xd0, 0xe8, 0x4f,
+	0xf8, 0x11, 0x9e, 0x79, 0xfd, 0x03, 0x74, 0xab, 0xd0, 0x9b, 0xa7, 0x2d, 0x79, 0x47, 0x5f, 0x91,
+	0x77, 0x0f, 0x19, 0x4b, 0xd5, 0x70, 0xd7, 0xd0, 0x57, 0xd0, 0x78, 0x86, 0xd3, 0xb5, 0x37, 0x0a,
+	0x6b, 0xe5, 0xf3, 0x55, 0xe7, 0x56, 0xd9, 0xe8, 0x21, 0x63, 0xee, 0x1a, 0x7a, 0x04, 0xad, 0x23,
+	0x3c, 0x53, 0xf7, 0xc4, 0xc5, 0x02, 0xde, 0x2b, 0x8c, 0x2a, 0x7e, 0x77, 0x0d, 0x1d, 0xc0, 0x96,
+	0xb8, 0xab, 0xa7, 0xf7, 0xc5, 0xc5, 0x12, 0xda, 0x85, 0xd1, 0x6c, 0x89, 0xbb, 0x86, 0xf6, 0xa1,
+	0xf5, 0xcb, 0x58, 0x34, 0x38, 0xfa, 0x10, 0xb7, 0x0b, 0xbc, 0xe6, 0x0b, 0xe8, 0x02, 0x10, 0x1e,
+	0x42, 0xcb, 0x93, 0x2d, 0xec, 0xa5, 0x38, 0x94, 0xaf, 0xfd, 0x29, 0x6c, 0x1c, 0xe1, 0x59, 0x2f,
+	0x12, 0xcc, 0x97, 0x2c, 0xbe, 0x39, 0x6f, 0x16, 0xf1, 0x8c, 0x21, 0xf5, 0xdf, 0x7c, 0x86, 0xb9,
+	0xbe, 0x42, 0x25, 0xab, 0x9b, 0x21, 0xbd, 0x40, 0xba, 0x6b, 0xe8, 0x39, 0x6c, 0xf4, 0x27, 0xa9,
+	0x08, 0x21, 0x61, 0x67, 0xd9, 0x05, 0xed, 0x32, 0x69, 0x8f, 0x61, 0x63, 0x3f, 0x0c, 0xf5, 0x80,
+	0x90, 0xf6, 0x7f, 0xe5, 0xbc, 0x47, 0x78, 0xb6, 0x00, 0x93, 0x5f, 0xc0, 0xb5, 0x03, 0x3a, 0x8e,
+	0x03, 0x86, 0xf7, 0x49, 0x28, 0xef, 0x03, 0xfd, 0x03, 0xd4, 0x29, 0x4a, 0xc9, 0xef, 0x4a, 0x9d,
+	0xdb, 0xa5, 0x73, 0x99, 0xbc, 0x9f, 0x43, 0x33, 0xeb, 0x19, 0x8b, 0x16, 0x2e, 0xf4, 0xd4, 0x9d,
+	0x3b, 0xe5, 0x93, 0x99, 0xac, 0x3e, 0x6c, 0x18, 0x45, 0xb4, 0x7f, 0x80, 0xde, 0x2f, 0x2e, 0xb8,
+	0xd0, 0x45, 0x76, 0x3e, 0x58, 0x34, 0x9d, 0x49, 0x7c, 0x01, 0x9b, 0x66, 0xc5, 0xe9, 0x1f, 0xa0,
+	0xb9, 0x86, 0x29, 0xad, 0x9a, 0x9d, 0xff, 0x2f, 0x9f, 0x31, 0x15, 0xfc, 0x09, 0x6c, 0xf8, 0x53,
+	0xd2, 0x67, 0x58, 0xe0, 0x27, 0xa4, 0xa1, 0xa2, 0xf3, 0x4f, 0xc9, 0x02, 0xdc, 0xbf, 0x84, 0x96,
+	0x3f, 0x25, 0x07, 0xb2, 0xdf, 0xbb, 0xda, 0xca, 0x1f, 0x43, 0xd3, 0x9f, 0x92, 0xfd, 0x13, 0xca,
+	0xae, 0xb8, 0xf0, 0x31, 0xd4, 0x55, 0xb6, 0x2a, 0xf3, 0x5b, 0x31, 0xd1, 0xb9, 0x53, 0x36, 0x7a,
+	0xc1, 0xb8, 0x0d, 0xfd, 0xb4, 0xd5, 0x3f, 0x40, 0x45, 0xe6, 0x0b, 0xaf, 0x74, 0x9d, 0xf7, 0x17,
+	0xcc, 0x66, 0xb2, 0xbe, 0x86, 0x56, 0x7f, 0xc2, 0xd5, 0x0b, 0xae, 0x10, 0x77, 0x31, 0xea, 0xb2,
+	0x07, 0xcf, 0xf2, 0xb3, 0xdc, 0xb5, 0xd0, 0x63, 0x68, 0x3d, 0xc3, 0xc6, 0xfa, 0x55, 0x62, 0x39,
+	0x93, 0xea, 0xae, 0xfd, 0xc8, 0x42, 0x4f, 0x60, 0x43, 0xb4, 0xc0, 0xcb, 0x44, 0x14, 0x43, 0x2a,
+	0x7f, 0x74, 0x76, 0xd7, 0xd0, 0x13, 0x68, 0x8a, 0xa7, 0x65, 0xf1, 0x9c, 0x5b, 0x16, 0x38, 0xc6,
+	0xb3, 0x73, 0xe7, 0xfa, 0x7c, 0x5a, 0x49, 0xa4, 0x1e, 0x8f, 0x60, 0x4b, 0xa5, 0xb4, 0x65, 0x9a,
+	0x94, 0x9b, 0xf5, 0x29, 0x34, 0xb3, 0x26, 0xbf, 0x18, 0x71, 0x85, 0x1f, 0x15, 0x97, 0xc2, 0xf1,
+	0x15, 0x80, 0x87, 0xe5, 0xcc, 0xff, 0x60, 0x8e, 0x93, 0x75, 0xf9, 0x9b, 0xf0, 0xb3, 0xff, 0x0e,
+	0x00, 0xbf, 0x8f, 0xf1, 0xe0, 0x66, 0x1c, 0x00, 0x00,
 }
@@ -1,15 +1,21 @@
 package chordstore
 
 import (
+	"bufio"
 	"bytes"
 	"compress/zlib"
 	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
+	"io/ioutil"
 	"log"
 	"sync"
+	"time"
 
 	chord "github.com/euforia/go-chord"
+	context "golang.org/x/net/context"
 	"gopkg.in/vmihailenco/msgpack.v2"
 )
 
@@ -17,6 +23,12 @@ import (
 type TransparentStore struct {
 	remote Store
 	local  map[string]VnodeStore
+	// ring hash function used for key ranges
+	hashFunc func() hash.Hash
+	// coordinates a compare-and-swap on a local primary vnode
+	swap func(vn *chord.Vnode, key, old, value []byte, c Consistency, ws *WriteStamp) ([]byte, bool, error)
+	// swaps in progress on local vnodes
+	swaps *keyLocks
 }
 
 // NewTransparentStore Initialized with the given vnodes
@@ -24,11 +36,21 @@ func NewTransparentStore(vnstore VnodeStore, vnodes ...*chord.Vnode) (*Transpare
 	ts := &TransparentStore{
 		local:  map[string]VnodeStore{},
 		remote: NewChordStoreTransport(),
+		swaps:  newKeyLocks(),
 	}
 	err := ts.init(vnstore, vnodes...)
 	return ts, err
 }
 
+// withContext returns a copy of the store making remote calls with the context
+func (ts *TransparentStore) withContext(ctx context.Context) *TransparentStore {
+	out := *ts
+	if st, ok := ts.remote.(*ChordStoreTransport); ok {
+		out.remote = st.WithContext(ctx)
+	}
+	return &out
+}
+
 func (ts *TransparentStore) init(vnstore VnodeStore, vnodes ...*chord.Vnode) (err error) {
 
 	for _, vn := range vnodes {
@@ -49,35 +71,172 @@ func (ts *TransparentStore) GetKey(vn *chord.Vnode, key []byte) ([]byte, error)
 }
 
 // PutKey to local or remote vnode
-func (ts *TransparentStore) PutKey(vn *chord.Vnode, key, value []byte) error {
+func (ts *TransparentStore) PutKey(vn *chord.Vnode, key, value []byte, expiry int64, ws *WriteStamp) error {
+	if st, ok := ts.local[vn.StringID()]; ok {
+		return st.PutKey(key, value, expiry, ws)
+	}
+	return ts.remote.PutKey(vn, key, value, expiry, ws)
+}
+
+// KeyTombstone from local or remote vnode
+func (ts *TransparentStore) KeyTombstone(vn *chord.Vnode, key []byte) (int64, error) {
+	if st, ok := ts.local[vn.StringID()]; ok {
+		return st.KeyTombstone(key)
+	}
+	return ts.remote.KeyTombstone(vn, key)
+}
+
+// KeyExpiry from local or remote vnode
+func (ts *TransparentStore) KeyExpiry(vn *chord.Vnode, key []byte) (int64, error) {
 	if st, ok := ts.local[vn.StringID()]; ok {
-		return st.PutKey(key, value)
+		return st.KeyExpiry(key)
 	}
-	return ts.remote.PutKey(vn, key, value)
+	return ts.remote.KeyExpiry(vn, key)
 }
 
 // UpdateKey to local or remote vnode
-func (ts *TransparentStore) UpdateKey(vn *chord.Vnode, prevHash, key, value []byte) error {
+func (ts *TransparentStore) UpdateKey(vn *chord.Vnode, prevHash, key, value []byte, ws *WriteStamp) error {
 	if st, ok := ts.local[vn.StringID()]; ok {
-		return st.UpdateKey(prevHash, key, value)
+		return st.UpdateKey(prevHash, key, value, ws)
 	}
-	return ts.remote.UpdateKey(vn, prevHash, key, value)
+	return ts.remote.UpdateKey(vn, prevHash, key, value, ws)
 }
 
 // RemoveKey from local or remote vnode
-func (ts *TransparentStore) RemoveKey(vn *chord.Vnode, key []byte) error {
+func (ts *TransparentStore) RemoveKey(vn *chord.Vnode, key []byte, ws *WriteStamp) error {
+	if st, ok := ts.local[vn.StringID()]; ok {
+		return st.RemoveKey(key, ws)
+	}
+	return ts.remote.RemoveKey(vn, key, ws)
+}
+
+// KeyHistory from local or remote vnode
+func (ts *TransparentStore) KeyHistory(vn *chord.Vnode, key []byte) ([]*KeyTxn, error) {
+	if st, ok := ts.local[vn.StringID()]; ok {
+		return st.KeyHistory(key)
+	}
+	return ts.remote.KeyHistory(vn, key)
+}
+
+// GetVersions from local or remote vnode
+func (ts *TransparentStore) GetVersions(vn *chord.Vnode, key []byte) ([]*Sibling, error) {
+	if st, ok := ts.local[vn.StringID()]; ok {
+		return st.GetVersions(key)
+	}
+	return ts.remote.GetVersions(vn, key)
+}
+
+// PutVersion to local or remote vnode
+func (ts *TransparentStore) PutVersion(vn *chord.Vnode, key, value []byte, ctx VectorClock, ws *WriteStamp) (*Sibling, error) {
 	if st, ok := ts.local[vn.StringID()]; ok {
-		return st.RemoveKey(key)
+		return st.PutVersion(key, value, ctx, ws)
 	}
-	return ts.remote.RemoveKey(vn, key)
+	return ts.remote.PutVersion(vn, key, value, ctx, ws)
 }
 
-// Snapshot a local or remote vnode
-func (ts *TransparentStore) Snapshot(vn *chord.Vnode, wr io.Writer) error {
+// AddSibling to local or remote vnode
+func (ts *TransparentStore) AddSibling(vn *chord.Vnode, key []byte, sib *Sibling) error {
 	if st, ok := ts.local[vn.StringID()]; ok {
-		return st.Snapshot(wr)
+		return st.AddSibling(key, sib)
+	}
+	return ts.remote.AddSibling(vn, key, sib)
+}
+
+// CompareAndSwap the key on the local or remote primary vnode.  A nil old value
+// swaps only if the key does not exist.
+func (ts *TransparentStore) CompareAndSwap(vn *chord.Vnode, key, old, value []byte, c Consistency, ws *WriteStamp) ([]byte, bool, error) {
+	if _, ok := ts.local[vn.StringID()]; ok {
+		if ts.swap == nil {
+			return nil, false, fmt.Errorf("compare and swap not supported")
+		}
+		return ts.swap(vn, key, old, value, c, ws)
 	}
-	return ts.remote.Snapshot(vn, wr)
+	return ts.remote.CompareAndSwap(vn, key, old, value, c, ws)
+}
+
+// SwapKey sets the key on the local or remote vnode to value if it holds old,
+// removing it if value is nil.  A nil old value swaps only if the key does not
+// exist.  It returns the value after the call, nil if the key does not exist, and
+// whether it was swapped.  Only swaps of a key on a vnode are serialized.
+func (ts *TransparentStore) SwapKey(vn *chord.Vnode, key, old, value []byte, ws *WriteStamp) ([]byte, bool, error) {
+	st, ok := ts.local[vn.StringID()]
+	if !ok {
+		return ts.remote.SwapKey(vn, key, old, value, ws)
+	}
+
+	unlock := ts.swaps.lock(append([]byte(vn.StringID()+"/"), key...))
+	defer unlock()
+
+	cur, err := st.GetKey(key)
+	if err != nil {
+		if !isNotFound(err) {
+			return nil, false, err
+		}
+		cur = nil
+	} else if cur == nil {
+		cur = []byte{}
+	}
+	if (cur == nil) != (old == nil) || !bytes.Equal(cur, old) {
+		return cur, false, nil
+	}
+
+	if value == nil {
+		err = st.RemoveKey(key, ws)
+	} else {
+		err = st.PutKey(key, value, 0, ws)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Batch applies the ops on local vnodes or sends them to the remote host of the
+// vnodes.  A result is returned for each op in order.
+func (ts *TransparentStore) Batch(ops []*BatchOp) ([]*BatchResult, error) {
+	if len(ops) == 0 {
+		return nil, nil
+	}
+	if _, ok := ts.local[ops[0].Vn.StringID()]; !ok {
+		return ts.remote.Batch(ops)
+	}
+
+	results := make([]*BatchResult, len(ops))
+	for i, op := range ops {
+		res := &BatchResult{}
+		var err error
+		if st, ok := ts.local[op.Vn.StringID()]; !ok {
+			err = fmt.Errorf("vnode not local: %s", op.Vn.StringID())
+		} else {
+			switch op.Op {
+			case batchOpGet:
+				res.Value, err = st.GetKey(op.Key)
+			case batchOpPut:
+				err = st.PutKey(op.Key, op.Value, 0, op.Stamp)
+			case batchOpRemove:
+				err = st.RemoveKey(op.Key, op.Stamp)
+			default:
+				err = fmt.Errorf("invalid batch op: %s", op.Op)
+			}
+		}
+		if err != nil {
+			res.Err = err.Error()
+		}
+		results[i] = res
+	}
+	return results, nil
+}
+
+// Snapshot the keys in the range of a local or remote vnode.  A nil range
+// snapshots all keys.
+func (ts *TransparentStore) Snapshot(vn *chord.Vnode, wr io.Writer, kr *KeyRange) error {
+	if st, ok := ts.local[vn.StringID()]; ok {
+		if kr != nil && kr.HashFunc == nil {
+			kr.HashFunc = ts.hashFunc
+		}
+		return st.Snapshot(wr, kr)
+	}
+	return ts.remote.Snapshot(vn, wr, kr)
 }
 
 // Restore a local or remote vnode
@@ -96,12 +255,37 @@ func (ts *TransparentStore) GetObject(vn *chord.Vnode, key []byte) (io.Reader, e
 	return ts.remote.GetObject(vn, key)
 }
 
-// PutObject data from the reader to the vnode
-func (ts *TransparentStore) PutObject(vn *chord.Vnode, key []byte, rd io.Reader) error {
+// GetObjectRange reads a byte range of an object from the given vnode
+func (ts *TransparentStore) GetObjectRange(vn *chord.Vnode, key []byte, offset, length int64) (io.Reader, int64, error) {
 	if st, ok := ts.local[vn.StringID()]; ok {
-		return st.PutObject(key, rd)
+		return st.GetObjectRange(key, offset, length)
 	}
-	return ts.remote.PutObject(vn, key, rd)
+	return ts.remote.GetObjectRange(vn, key, offset, length)
+}
+
+// PutObject data from the reader to the vnode along with its metadata
+func (ts *TransparentStore) PutObject(vn *chord.Vnode, key []byte, rd io.Reader, meta *ObjectMeta) error {
+	if st, ok := ts.local[vn.StringID()]; ok {
+		return st.PutObject(key, rd, meta)
+	}
+	return ts.remote.PutObject(vn, key, rd, meta)
+}
+
+// StatObject returns the metadata of the object on the vnode
+func (ts *TransparentStore) StatObject(vn *chord.Vnode, key []byte) (*ObjectMeta, error) {
+	if st, ok := ts.local[vn.StringID()]; ok {
+		return st.StatObject(key)
+	}
+	return ts.remote.StatObject(vn, key)
+}
+
+// PutContent data from the reader to the vnode with the metadata which may be
+// nil.  The data must hash to the key otherwise it is not stored.
+func (ts *TransparentStore) PutContent(vn *chord.Vnode, key []byte, rd io.Reader, meta *ObjectMeta) error {
+	if st, ok := ts.local[vn.StringID()]; ok {
+		return st.PutObject(key, newHashVerifier(rd, key), meta)
+	}
+	return ts.remote.PutContent(vn, key, rd, meta)
 }
 
 // RemoveObject from vnode with the given key
@@ -112,6 +296,40 @@ func (ts *TransparentStore) RemoveObject(vn *chord.Vnode, key []byte) error {
 	return ts.remote.RemoveObject(vn, key)
 }
 
+// ListKeys on the local or remote vnode
+func (ts *TransparentStore) ListKeys(vn *chord.Vnode, prefix, cursor []byte, limit int) ([][]byte, error) {
+	if st, ok := ts.local[vn.StringID()]; ok {
+		return st.ListKeys(prefix, cursor, limit)
+	}
+	return ts.remote.ListKeys(vn, prefix, cursor, limit)
+}
+
+// ListObjects on the local or remote vnode
+func (ts *TransparentStore) ListObjects(vn *chord.Vnode, prefix, cursor []byte, limit int) ([][]byte, error) {
+	if st, ok := ts.local[vn.StringID()]; ok {
+		return st.ListObjects(prefix, cursor, limit)
+	}
+	return ts.remote.ListObjects(vn, prefix, cursor, limit)
+}
+
+// MerkleLevel returns the hashes of nodes at the given indexes of a level of the
+// hash tree of a local or remote vnode
+func (ts *TransparentStore) MerkleLevel(vn *chord.Vnode, level int, indexes []int) ([][]byte, error) {
+	if st, ok := ts.local[vn.StringID()]; ok {
+		return st.MerkleTree().Level(level, indexes)
+	}
+	return ts.remote.MerkleLevel(vn, level, indexes)
+}
+
+// MerkleEntries returns the keys and entry hashes in the given leaves of the hash
+// tree of a local or remote vnode
+func (ts *TransparentStore) MerkleEntries(vn *chord.Vnode, leaves []int) ([]*MerkleEntry, error) {
+	if st, ok := ts.local[vn.StringID()]; ok {
+		return st.MerkleTree().Entries(leaves)
+	}
+	return ts.remote.MerkleEntries(vn, leaves)
+}
+
 // Shutdown remote and local stores
 func (ts *TransparentStore) Shutdown() error {
 	return ts.remote.Shutdown()
@@ -124,6 +342,22 @@ type MemKeyValueStore struct {
 	m map[string][]byte
 	// objects
 	o map[string][]byte
+	// object metadata keyed the same as objects
+	om map[string]*ObjectMeta
+	// key expiries in unix nanoseconds.  Keys without one are not present.
+	ex map[string]int64
+	// removal time of deleted keys in unix nanoseconds
+	ts map[string]int64
+	// siblings of versioned keys.  The value in m is that of the latest.
+	vv map[string][]*Sibling
+	// per key transaction log
+	l map[string][]*KeyTxn
+	// hash tree over the keys
+	mt *MerkleTree
+	// chooses between differing local and restored values.  nil restores.
+	resolver ConflictResolver
+	// ring hash function placing keys in the hash tree
+	hashFunc func() hash.Hash
 	// vnode
 	vn *chord.Vnode
 }
@@ -131,27 +365,62 @@ type MemKeyValueStore struct {
 // New instantiates a new store
 func (s *MemKeyValueStore) New(vn *chord.Vnode) (VnodeStore, error) {
 	return &MemKeyValueStore{
-		m:  map[string][]byte{},
-		o:  map[string][]byte{},
-		vn: vn,
+		m:        map[string][]byte{},
+		o:        map[string][]byte{},
+		om:       map[string]*ObjectMeta{},
+		ex:       map[string]int64{},
+		ts:       map[string]int64{},
+		vv:       map[string][]*Sibling{},
+		l:        map[string][]*KeyTxn{},
+		mt:       NewMerkleTree(s.hashFunc),
+		resolver: s.resolver,
+		hashFunc: s.hashFunc,
+		vn:       vn,
 	}, nil
 }
 
+func (s *MemKeyValueStore) setConflictResolver(r ConflictResolver) {
+	s.resolver = r
+}
+
+func (s *MemKeyValueStore) setHashFunc(hf func() hash.Hash) {
+	s.hashFunc = hf
+}
+
+// GetObject returns a reader to the object.  Objects are never modified in
+// place so the data is not copied.
 func (s *MemKeyValueStore) GetObject(key []byte) (io.Reader, error) {
+	rd, _, err := s.GetObjectRange(key, 0, 0)
+	return rd, err
+}
+
+// GetObjectRange returns a reader to length bytes of the object from offset, or
+// to the end if length < 1, along with the object size.
+func (s *MemKeyValueStore) GetObjectRange(key []byte, offset, length int64) (io.Reader, int64, error) {
+	s.mu.Lock()
 	v, ok := s.o[fmt.Sprintf("%x", key)]
+	s.mu.Unlock()
 	if !ok {
-		return nil, fmt.Errorf("object not found: %x", key)
+		return nil, 0, fmt.Errorf("object not found: %x", key)
 	}
 
-	buf := new(bytes.Buffer)
-	_, err := buf.Write(v)
-	return buf, err
+	size := int64(len(v))
+	if offset > size {
+		offset = size
+	}
+	end := size
+	if length > 0 && offset+length < size {
+		end = offset + length
+	}
+	return bytes.NewReader(v[offset:end]), size, nil
 }
 
-// PutObject with the given key with the data from the reader
-func (s *MemKeyValueStore) PutObject(key []byte, rd io.Reader) error {
+// PutObject with the given key with the data from the reader.  The object is
+// held in memory in its entirety.
+func (s *MemKeyValueStore) PutObject(key []byte, rd io.Reader, meta *ObjectMeta) error {
 	buf := new(bytes.Buffer)
-	_, err := io.Copy(buf, rd)
+	mr := newMetaReader(rd)
+	_, err := io.Copy(buf, mr)
 	if err != nil {
 		return err
 	}
@@ -159,10 +428,25 @@ func (s *MemKeyValueStore) PutObject(key []byte, rd io.Reader) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.o[fmt.Sprintf("%x", key)] = buf.Bytes()
+	k := fmt.Sprintf("%x", key)
+	s.o[k] = buf.Bytes()
+	s.om[k] = mr.meta(meta)
 	return nil
 }
 
+// StatObject returns the metadata of the object
+func (s *MemKeyValueStore) StatObject(key []byte) (*ObjectMeta, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.om[fmt.Sprintf("%x", key)]
+	if !ok {
+		return nil, fmt.Errorf("object not found: %x", key)
+	}
+	out := *m
+	return &out, nil
+}
+
 // RemoveObject with the given key
 func (s *MemKeyValueStore) RemoveObject(key []byte) error {
 	k := fmt.Sprintf("%x", key)
@@ -172,34 +456,131 @@ func (s *MemKeyValueStore) RemoveObject(key []byte) error {
 
 	if _, ok := s.o[k]; ok {
 		delete(s.o, k)
+		delete(s.om, k)
 		return nil
 	}
 	return fmt.Errorf("object not found: %x", key)
 }
 
-// GetKey from datastore
+// ListKeys returns the sorted keys with the prefix after the cursor
+func (s *MemKeyValueStore) ListKeys(prefix, cursor []byte, limit int) ([][]byte, error) {
+	now := time.Now().UnixNano()
+
+	s.mu.Lock()
+	keys := make([]string, 0, len(s.m))
+	for k := range s.m {
+		if !expired(s.ex[k], now) && !hiddenKey(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	s.mu.Unlock()
+
+	return selectKeys(keys, prefix, cursor, limit), nil
+}
+
+// ListObjects returns the sorted object keys with the prefix after the cursor
+func (s *MemKeyValueStore) ListObjects(prefix, cursor []byte, limit int) ([][]byte, error) {
+	s.mu.Lock()
+	keys := make([]string, 0, len(s.o))
+	for k := range s.o {
+		b, _ := hex.DecodeString(k)
+		keys = append(keys, string(b))
+	}
+	s.mu.Unlock()
+
+	return selectKeys(keys, prefix, cursor, limit), nil
+}
+
+// GetKey from datastore.  An expired key is removed.
 func (s *MemKeyValueStore) GetKey(key []byte) ([]byte, error) {
-	if val, ok := s.m[string(key)]; ok {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := string(key)
+	if expired(s.ex[k], time.Now().UnixNano()) {
+		s.expireKey(k)
+	}
+	if val, ok := s.m[k]; ok {
 		return val, nil
 	}
 
 	return nil, fmt.Errorf("key not found: %s", key)
 }
 
-// PutKey key-value
-func (s *MemKeyValueStore) PutKey(key []byte, v []byte) error {
+// PutKey key-value expiring at the given time in unix nanoseconds.  0 never
+// expires.
+func (s *MemKeyValueStore) PutKey(key []byte, v []byte, expiry int64, ws *WriteStamp) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	k := string(key)
+	var prevHash []byte
+	if cv, ok := s.m[k]; ok {
+		prevHash = valueHash(cv)
+	}
 	s.m[k] = v
+	s.setExpiry(k, expiry)
+	delete(s.ts, k)
+	delete(s.vv, k)
+	s.appendTxn(k, newKeyTxn(s.vn, ws, TxnOpPut, prevHash, valueHash(v)))
+	s.mt.Set(key, v)
 
 	return nil
 }
 
+// KeyExpiry returns the expiry of the key or 0 if it does not expire
+func (s *MemKeyValueStore) KeyExpiry(key []byte) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := string(key)
+	if _, ok := s.m[k]; !ok || expired(s.ex[k], time.Now().UnixNano()) {
+		return 0, fmt.Errorf("key not found: %s", key)
+	}
+	return s.ex[k], nil
+}
+
+// ExpireKeys removes all expired keys
+func (s *MemKeyValueStore) ExpireKeys() (int, error) {
+	now := time.Now().UnixNano()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int
+	for k, e := range s.ex {
+		if expired(e, now) {
+			s.expireKey(k)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *MemKeyValueStore) setExpiry(k string, expiry int64) {
+	if expiry > 0 {
+		s.ex[k] = expiry
+	} else {
+		delete(s.ex, k)
+	}
+}
+
+// expireKey removes the key recording it as expired.  The lock must be held.
+func (s *MemKeyValueStore) expireKey(k string) {
+	delete(s.ex, k)
+	cv, ok := s.m[k]
+	if !ok {
+		return
+	}
+	delete(s.m, k)
+	delete(s.vv, k)
+	s.appendTxn(k, newKeyTxn(s.vn, nil, TxnOpExpire, valueHash(cv), nil))
+	s.mt.Delete([]byte(k))
+}
+
 // UpdateKey that exists.  previousHash is the hash of the previous value of the
 // key.
-func (s *MemKeyValueStore) UpdateKey(prevHash, key, value []byte) error {
+func (s *MemKeyValueStore) UpdateKey(prevHash, key, value []byte, ws *WriteStamp) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -212,70 +593,451 @@ func (s *MemKeyValueStore) UpdateKey(prevHash, key, value []byte) error {
 	pv := sha256.Sum256(cv)
 	if bytes.Equal(pv[:], prevHash) {
 		s.m[k] = value
+		delete(s.vv, k)
+		s.appendTxn(k, newKeyTxn(s.vn, ws, TxnOpUpdate, pv[:], valueHash(value)))
+		s.mt.Set(key, value)
 		return nil
 	}
 
 	return fmt.Errorf("invalid previous hash: %x != %x", pv, prevHash)
 }
 
-// RemoveKey a key from the datastore
-func (s *MemKeyValueStore) RemoveKey(key []byte) error {
+// RemoveKey a key from the datastore leaving a tombstone.  The tombstone is
+// recorded even if the key does not exist so a value restored from a replica
+// that missed the removal is not resurrected.
+func (s *MemKeyValueStore) RemoveKey(key []byte, ws *WriteStamp) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	delete(s.m, string(key))
+	k := string(key)
+	s.ts[k] = stampTime(ws)
+	if cv, ok := s.m[k]; ok {
+		delete(s.m, k)
+		delete(s.ex, k)
+		delete(s.vv, k)
+		s.appendTxn(k, newKeyTxn(s.vn, ws, TxnOpRemove, valueHash(cv), nil))
+		s.mt.Delete(key)
+	}
 	return nil
 }
 
-// Snapshot the dataset serializing and compressing it to the writer.
-func (s *MemKeyValueStore) Snapshot(wr io.Writer) error {
-	if len(s.m) == 0 && len(s.o) == 0 {
-		return io.EOF
+// PruneKey removes a key the vnode no longer replicates.  Unlike RemoveKey no
+// tombstone or history is recorded as the key has not been deleted.
+func (s *MemKeyValueStore) PruneKey(key []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := string(key)
+	delete(s.m, k)
+	delete(s.ex, k)
+	delete(s.vv, k)
+	delete(s.l, k)
+	s.mt.Delete(key)
+	return nil
+}
+
+// KeyTombstone returns the time the key was removed or 0 if it has no tombstone
+func (s *MemKeyValueStore) KeyTombstone(key []byte) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.ts[string(key)], nil
+}
+
+// PurgeTombstones removes tombstones of keys removed before the given time
+func (s *MemKeyValueStore) PurgeTombstones(before int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int
+	for k, t := range s.ts {
+		if t < before {
+			delete(s.ts, k)
+			n++
+		}
 	}
+	return n, nil
+}
 
+// GetVersions returns the siblings of the key.  A key written without a version
+// has a single sibling with its value.
+func (s *MemKeyValueStore) GetVersions(key []byte) ([]*Sibling, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	zw := zlib.NewWriter(wr)
-	defer zw.Close()
+	k := string(key)
+	if expired(s.ex[k], time.Now().UnixNano()) {
+		s.expireKey(k)
+	}
+	if sibs, ok := s.vv[k]; ok {
+		out := make([]*Sibling, len(sibs))
+		copy(out, sibs)
+		return out, nil
+	}
+	if val, ok := s.m[k]; ok {
+		return []*Sibling{&Sibling{Value: val}}, nil
+	}
+	return nil, fmt.Errorf("key not found: %s", key)
+}
+
+// PutVersion writes a new version of the key by this vnode superseding the
+// versions in ctx
+func (s *MemKeyValueStore) PutVersion(key, value []byte, ctx VectorClock, ws *WriteStamp) (*Sibling, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := string(key)
+	sib := newSibling(s.vn.StringID(), s.vv[k], value, ctx, ws)
+	s.addSibling(k, sib)
+	return sib, nil
+}
 
-	log.Printf("DBG [snapshot] vnode=%s keys=%d objects=%d", s.vn.StringID(), len(s.m), len(s.o))
+// AddSibling adds a version written on another replica
+func (s *MemKeyValueStore) AddSibling(key []byte, sib *Sibling) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.addSibling(string(key), sib)
+	return nil
+}
+
+// addSibling merges the version into the siblings of the key.  Versioned keys do
+// not expire.  The lock must be held.
+func (s *MemKeyValueStore) addSibling(k string, sib *Sibling) {
+	sibs, ok := addSibling(s.vv[k], sib)
+	if !ok {
+		return
+	}
+
+	var prevHash []byte
+	if cv, ok := s.m[k]; ok {
+		prevHash = valueHash(cv)
+	}
+	v := versionedValue(sibs)
+	s.m[k] = v
+	s.vv[k] = sibs
+	delete(s.ex, k)
+	delete(s.ts, k)
+	s.appendTxn(k, newKeyTxn(s.vn, siblingStamp(sib), TxnOpPut, prevHash, valueHash(v)))
+	s.mt.Set([]byte(k), merkleValue(v, sibs))
+}
+
+// appendTxn appends the entry to the key's transaction log.  The lock must be
+// held.
+func (s *MemKeyValueStore) appendTxn(k string, txn *KeyTxn) {
+	s.l[k] = append(s.l[k], txn)
+}
 
-	menc := msgpack.NewEncoder(zw)
-	return menc.Encode(s.m, s.o)
+// KeyHistory returns the transaction log for the key
+func (s *MemKeyValueStore) KeyHistory(key []byte) ([]*KeyTxn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	txns, ok := s.l[string(key)]
+	if !ok {
+		return nil, fmt.Errorf("key not found: %s", key)
+	}
+	out := make([]*KeyTxn, len(txns))
+	copy(out, txns)
+	return out, nil
+}
+
+// MerkleTree returns the hash tree over the keys
+func (s *MemKeyValueStore) MerkleTree() *MerkleTree {
+	return s.mt
+}
+
+// Snapshot the keys in the range serializing and compressing them to the writer.
+func (s *MemKeyValueStore) Snapshot(wr io.Writer, kr *KeyRange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys, objects, logs := filterSnapshot(kr, s.m, s.o, s.l)
+	tombstones := filterKeyTimes(kr, s.ts)
+	if len(keys) == 0 && len(objects) == 0 && len(tombstones) == 0 {
+		return io.EOF
+	}
+
+	log.Printf("DBG [snapshot] vnode=%s range=%s keys=%d objects=%d tombstones=%d", s.vn.StringID(), kr, len(keys), len(objects), len(tombstones))
+
+	sw, err := encodeSnapshot(wr, &vnodeSnapshot{
+		Keys:       keys,
+		Logs:       logs,
+		Metas:      objectMetas(objects, s.om),
+		Expiries:   keyExpiries(keys, s.ex),
+		Tombstones: tombstones,
+		Versions:   keyVersions(keys, s.vv),
+	})
+	if err != nil {
+		return err
+	}
+	for k, v := range objects {
+		if err = sw.writeObject(k, int64(len(v)), bytes.NewReader(v)); err != nil {
+			return err
+		}
+	}
+	return sw.Close()
 }
 
 // Restore dataset from reader de-compressing and de-serializing the data to the
 // datastructure.  We may need to reset the current data before restoring ???.
-// Currently a merge is performed overwriting an existing key.
+// Currently a merge is performed overwriting an existing key.  Transaction logs
+// are taken from the snapshot for keys with no local history, otherwise changed
+// values are recorded as a restore.  Keys removed after the snapshot value was
+// written are not restored and snapshot tombstones remove older local values.
+// Siblings of versioned keys are merged with the local ones and the conflict
+// resolver, if any, chooses between differing plain values.
 func (s *MemKeyValueStore) Restore(r io.Reader) error {
-
-	rd, err := zlib.NewReader(r)
+	snap, err := decodeSnapshot(r)
 	if err != nil {
 		return err
 	}
-	defer rd.Close()
+	defer snap.Close()
+
+	to := map[string][]byte{}
+	for {
+		name, rd, err := snap.nextObject()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if to[name], err = ioutil.ReadAll(rd); err != nil {
+			return err
+		}
+	}
+	tk, tl, tm, te := snap.Keys, snap.Logs, snap.Metas, snap.Expiries
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	var tk, to map[string][]byte
-	dec := msgpack.NewDecoder(rd)
+	log.Printf("DBG [restore] Received vnode=%s keys=%d objects=%d tombstones=%d", s.vn.StringID(), len(tk), len(to), len(snap.Tombstones))
 
-	if err = dec.Decode(&tk, &to); err != nil {
-		return err
-	}
+	for k, v := range tk {
+		if t, ok := s.ts[k]; ok {
+			if t >= keyWriteTime(tl[k]) {
+				// Removed after the value was written
+				continue
+			}
+			delete(s.ts, k)
+		}
 
-	log.Printf("DBG [restore] Received vnode=%s keys=%d objects=%d", s.vn.StringID(), len(tk), len(to))
+		cv, exists := s.m[k]
+		sibs := snap.Versions[k]
+		if exists && len(sibs) == 0 && !bytes.Equal(cv, v) {
+			if v = resolveRestore(s.resolver, []byte(k), cv, v, s.l[k], tl[k]); bytes.Equal(v, cv) {
+				// Local value wins
+				continue
+			}
+		}
 
-	for k, v := range tk {
-		// TODO if !bytes.Equal(s.m[k],v) { 'inconsistent data' }
+		if len(sibs) > 0 {
+			sibs = mergeSiblings(s.vv[k], sibs)
+			v = versionedValue(sibs)
+			s.vv[k] = sibs
+		} else {
+			delete(s.vv, k)
+		}
+
+		if _, ok := s.l[k]; !ok && len(tl[k]) > 0 {
+			s.l[k] = tl[k]
+		} else if txn := restoreKeyTxn(s.vn, cv, exists, v); txn != nil {
+			s.appendTxn(k, txn)
+		}
 		s.m[k] = v
+		s.setExpiry(k, te[k])
+		s.mt.Set([]byte(k), merkleValue(v, sibs))
+	}
+	// History of removed keys
+	for k, txns := range tl {
+		if _, ok := s.l[k]; !ok {
+			s.l[k] = txns
+		}
+	}
+	for k, t := range snap.Tombstones {
+		if cv, ok := s.m[k]; ok {
+			if keyWriteTime(s.l[k]) >= t {
+				// Written after the removal
+				continue
+			}
+			delete(s.m, k)
+			delete(s.ex, k)
+			delete(s.vv, k)
+			s.appendTxn(k, newKeyTxn(s.vn, nil, TxnOpRemove, valueHash(cv), nil))
+			s.mt.Delete([]byte(k))
+		}
+		if t > s.ts[k] {
+			s.ts[k] = t
+		}
 	}
 	for k, v := range to {
 		// TODO if !bytes.Equal(s.o[k],v) { 'inconsistent data' }
 		s.o[k] = v
+		s.om[k] = restoreObjectMeta(v, tm[k])
 	}
 
 	return nil
 }
+
+// vnodeSnapshot is the header of the data of a vnode transferred by Snapshot and
+// Restore.  Keys, logs, expiries, tombstones and versions are keyed by raw key
+// strings and object metadata by hex encoded keys.  The objects follow the header
+// one at a time so they are never held in memory as a whole.
+type vnodeSnapshot struct {
+	Keys       map[string][]byte
+	Logs       map[string][]*KeyTxn
+	Metas      map[string]*ObjectMeta
+	Expiries   map[string]int64
+	Tombstones map[string]int64
+	Versions   map[string][]*Sibling
+}
+
+// snapshotObject precedes the data of each object in a snapshot.  An empty key
+// marks the end of the objects.
+type snapshotObject struct {
+	Key  string // hex encoded
+	Size int64
+}
+
+// snapshotWriter writes the objects of a snapshot after its header
+type snapshotWriter struct {
+	zw  *zlib.Writer
+	enc *msgpack.Encoder
+}
+
+// encodeSnapshot serializes and compresses the snapshot header to the writer.
+// All VnodeStore implementations use this format so data can be transferred
+// between vnodes regardless of the underlying store.  The returned writer must be
+// closed once all objects have been written.
+func encodeSnapshot(wr io.Writer, snap *vnodeSnapshot) (*snapshotWriter, error) {
+	zw := zlib.NewWriter(wr)
+	sw := &snapshotWriter{zw: zw, enc: msgpack.NewEncoder(zw)}
+	if err := sw.enc.Encode(snap); err != nil {
+		zw.Close()
+		return nil, err
+	}
+	return sw, nil
+}
+
+// writeObject streams size bytes of the object from the reader
+func (sw *snapshotWriter) writeObject(key string, size int64, rd io.Reader) error {
+	if err := sw.enc.Encode(&snapshotObject{Key: key, Size: size}); err != nil {
+		return err
+	}
+	n, err := io.Copy(sw.zw, io.LimitReader(rd, size))
+	if err == nil && n != size {
+		err = fmt.Errorf("object size mismatch %s: %d != %d", key, n, size)
+	}
+	return err
+}
+
+// Close marks the end of the objects and flushes the snapshot
+func (sw *snapshotWriter) Close() error {
+	err := sw.enc.Encode(&snapshotObject{})
+	return mergeErrors(err, sw.zw.Close())
+}
+
+// snapshotReader is a decoded snapshot header.  Its objects are read one at a time
+// with nextObject.
+type snapshotReader struct {
+	*vnodeSnapshot
+	zr  io.ReadCloser
+	br  *bufio.Reader
+	dec *msgpack.Decoder
+	obj *io.LimitedReader
+}
+
+// decodeSnapshot de-compresses and de-serializes the header of a snapshot written
+// by encodeSnapshot.  The hybrid clock is moved past the timestamps of the
+// snapshot so later local writes sort after the restored ones.
+func decodeSnapshot(r io.Reader) (*snapshotReader, error) {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// The decoder reads through the same buffer as the objects
+	br := bufio.NewReader(zr)
+	sr := &snapshotReader{vnodeSnapshot: &vnodeSnapshot{}, zr: zr, br: br, dec: msgpack.NewDecoder(br)}
+	if err = sr.dec.Decode(sr.vnodeSnapshot); err != nil {
+		zr.Close()
+		return nil, err
+	}
+	for _, txns := range sr.Logs {
+		for _, txn := range txns {
+			hlc.Update(txn.Timestamp)
+		}
+	}
+	for _, t := range sr.Tombstones {
+		hlc.Update(t)
+	}
+	return sr, nil
+}
+
+// nextObject returns the hex encoded key and a reader of the next object, or
+// io.EOF after the last one.  The reader is only valid until the next call.  The
+// key is validated so it is safe to use as a filename.
+func (sr *snapshotReader) nextObject() (string, io.Reader, error) {
+	if sr.obj != nil {
+		if _, err := io.Copy(ioutil.Discard, sr.obj); err != nil {
+			return "", nil, err
+		}
+		if sr.obj.N > 0 {
+			return "", nil, io.ErrUnexpectedEOF
+		}
+	}
+
+	var so snapshotObject
+	if err := sr.dec.Decode(&so); err != nil {
+		return "", nil, err
+	}
+	if so.Key == "" {
+		return "", nil, io.EOF
+	}
+	key, err := hex.DecodeString(so.Key)
+	if err != nil || len(key) == 0 {
+		return "", nil, fmt.Errorf("invalid object key: %q", so.Key)
+	}
+	sr.obj = &io.LimitedReader{R: sr.br, N: so.Size}
+	return hex.EncodeToString(key), &exactReader{lr: sr.obj}, nil
+}
+
+// Close the decompressor
+func (sr *snapshotReader) Close() error {
+	return sr.zr.Close()
+}
+
+// exactReader returns io.ErrUnexpectedEOF if the stream ends before the limit
+type exactReader struct {
+	lr *io.LimitedReader
+}
+
+func (er *exactReader) Read(p []byte) (int, error) {
+	n, err := er.lr.Read(p)
+	if err == io.EOF && er.lr.N > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// objectMetas returns the metadata of the objects
+func objectMetas(objects map[string][]byte, metas map[string]*ObjectMeta) map[string]*ObjectMeta {
+	out := make(map[string]*ObjectMeta, len(objects))
+	for k := range objects {
+		if m, ok := metas[k]; ok {
+			out[k] = m
+		}
+	}
+	return out
+}
+
+// restoreObjectMeta returns the metadata of a restored object.  It is computed
+// from the data if the snapshot has none.
+func restoreObjectMeta(data []byte, meta *ObjectMeta) *ObjectMeta {
+	if meta != nil {
+		return meta
+	}
+	mr := newMetaReader(bytes.NewReader(data))
+	io.Copy(ioutil.Discard, mr)
+	return mr.meta(nil)
+}
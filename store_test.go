@@ -2,6 +2,7 @@ package chordstore
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"testing"
 
 	chord "github.com/euforia/go-chord"
@@ -20,7 +21,7 @@ func Test_MemKeyValueStore(t *testing.T) {
 
 	buf := new(bytes.Buffer)
 
-	err := kvs1.Snapshot(buf)
+	err := kvs1.Snapshot(buf, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -41,3 +42,59 @@ func Test_MemKeyValueStore(t *testing.T) {
 		t.Fatal("value mismatch", string(val))
 	}
 }
+
+func Test_MemKeyValueStore_KeyHistory(t *testing.T) {
+	kvs, _ := (&MemKeyValueStore{}).New(testVn1)
+
+	kvs.PutKey([]byte("key"), []byte("v1"), 0, nil)
+	ph := sha256.Sum256([]byte("v1"))
+	if err := kvs.UpdateKey(ph[:], []byte("key"), []byte("v2"), nil); err != nil {
+		t.Fatal(err)
+	}
+	kvs.PutKey([]byte("key"), []byte("v3"), 0, nil)
+	kvs.RemoveKey([]byte("key"), nil)
+	kvs.PutKey([]byte("key"), []byte("v4"), 0, nil)
+
+	txns, err := kvs.KeyHistory([]byte("key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ops := []string{TxnOpPut, TxnOpUpdate, TxnOpPut, TxnOpRemove, TxnOpPut}
+	if len(txns) != len(ops) {
+		t.Fatal("txn count mismatch", len(txns))
+	}
+	for i, txn := range txns {
+		if txn.Op != ops[i] {
+			t.Fatalf("op mismatch at %d: %s != %s", i, txn.Op, ops[i])
+		}
+		if txn.Vnode != testVn1.StringID() {
+			t.Fatal("wrong vnode", txn.Vnode)
+		}
+	}
+	if err = VerifyKeyTxns(txns); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restore should carry the history to a new vnode
+	buf := new(bytes.Buffer)
+	if err = kvs.Snapshot(buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	kvs2, _ := (&MemKeyValueStore{}).New(testVn2)
+	kvs2.PutKey([]byte("other"), []byte("other"), 0, nil)
+	if err = kvs2.Restore(buf); err != nil {
+		t.Fatal(err)
+	}
+	txns2, err := kvs2.KeyHistory([]byte("key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txns2) != len(txns) || txns2[0].Vnode != testVn1.StringID() {
+		t.Fatal("history not restored")
+	}
+
+	if _, err = kvs2.KeyHistory([]byte("missing")); err == nil {
+		t.Fatal("should fail")
+	}
+}
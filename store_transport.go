@@ -1,7 +1,6 @@
 package chordstore
 
 import (
-	"bytes"
 	"fmt"
 	"io"
 	"sync"
@@ -20,6 +19,12 @@ type rpcOutClient struct {
 
 // ChordStoreTransport is a remote store
 type ChordStoreTransport struct {
+	*rpcPool
+	// context of unary calls and of setting up streams
+	ctx context.Context
+}
+
+type rpcPool struct {
 	plock    sync.Mutex                 // connection pool lock
 	pool     map[string][]*rpcOutClient //conneciton pool
 	shutdown int32
@@ -27,17 +32,46 @@ type ChordStoreTransport struct {
 
 // NewChordStoreTransport initialzed with and empty pool
 func NewChordStoreTransport() *ChordStoreTransport {
-	return &ChordStoreTransport{pool: map[string][]*rpcOutClient{}}
+	return &ChordStoreTransport{
+		rpcPool: &rpcPool{pool: map[string][]*rpcOutClient{}},
+		ctx:     context.Background(),
+	}
+}
+
+// WithContext returns a transport sharing the connection pool whose calls are
+// cancelled with the context.  Streams are only bound by it until they are set
+// up.
+func (st *ChordStoreTransport) WithContext(ctx context.Context) *ChordStoreTransport {
+	return &ChordStoreTransport{rpcPool: st.rpcPool, ctx: ctx}
+}
+
+// cancelOnDone cancels a stream if the context is done before the returned
+// function is called once the stream is set up
+func cancelOnDone(ctx context.Context, cancel context.CancelFunc) func() {
+	set := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-set:
+		}
+	}()
+	return func() { close(set) }
 }
 
-func (st *ChordStoreTransport) Snapshot(vn *chord.Vnode, wr io.Writer) error {
+func (st *ChordStoreTransport) Snapshot(vn *chord.Vnode, wr io.Writer, kr *KeyRange) error {
 	out, err := st.getClient(vn.Host)
 	if err != nil {
 		return err
 	}
 	defer st.returnClient(out)
 
-	cli, err := out.c.SnapshotRPC(context.Background(), vn)
+	opts := &SnapshotOptions{Vn: vn}
+	if kr != nil {
+		opts.Start, opts.End = kr.Start, kr.End
+	}
+
+	cli, err := out.c.SnapshotRPC(context.Background(), opts)
 	if err != nil {
 		return err
 	}
@@ -110,7 +144,7 @@ func (st *ChordStoreTransport) GetKey(vn *chord.Vnode, key []byte) ([]byte, erro
 		defer st.returnClient(out)
 
 		var resp *DHTBytesErr
-		resp, err = out.c.GetKeyRPC(context.Background(), &DHTBytes{B: key, Vn: vn})
+		resp, err = out.c.GetKeyRPC(st.ctx, &DHTBytes{B: key, Vn: vn})
 		if err == nil {
 			if resp.Err == "" {
 				return resp.B, nil
@@ -121,47 +155,219 @@ func (st *ChordStoreTransport) GetKey(vn *chord.Vnode, key []byte) ([]byte, erro
 	return nil, err
 }
 
+// KeyExpiry returns the expiry of a key on the vnode.  0 is returned if the key
+// does not expire.
+func (st *ChordStoreTransport) KeyExpiry(vn *chord.Vnode, key []byte) (int64, error) {
+	out, err := st.getClient(vn.Host)
+	if err == nil {
+		defer st.returnClient(out)
+
+		var resp *DHTExpiry
+		resp, err = out.c.KeyExpiryRPC(st.ctx, &DHTBytes{B: key, Vn: vn})
+		if err == nil {
+			if resp.Err == "" {
+				return resp.Expiry, nil
+			}
+			err = fmt.Errorf(resp.Err)
+		}
+	}
+	return 0, err
+}
+
+// KeyTombstone returns the time a key was removed on the vnode.  0 is returned if
+// the vnode has no tombstone for the key.
+func (st *ChordStoreTransport) KeyTombstone(vn *chord.Vnode, key []byte) (int64, error) {
+	out, err := st.getClient(vn.Host)
+	if err == nil {
+		defer st.returnClient(out)
+
+		var resp *DHTTombstone
+		resp, err = out.c.KeyTombstoneRPC(st.ctx, &DHTBytes{B: key, Vn: vn})
+		if err == nil {
+			if resp.Err == "" {
+				return resp.Timestamp, nil
+			}
+			err = fmt.Errorf(resp.Err)
+		}
+	}
+	return 0, err
+}
+
+// GetObject returns a reader streaming the object from the vnode.  The reader
+// must be read to the end or closed to release the connection.
 func (st *ChordStoreTransport) GetObject(vn *chord.Vnode, key []byte) (io.Reader, error) {
+	rd, _, err := st.GetObjectRange(vn, key, 0, 0)
+	return rd, err
+}
+
+// GetObjectRange returns a reader streaming length bytes of the object from
+// offset, or to the end if length < 1, along with the object size.  The reader
+// must be read to the end or closed to release the connection.
+func (st *ChordStoreTransport) GetObjectRange(vn *chord.Vnode, key []byte, offset, length int64) (io.Reader, int64, error) {
 	out, err := st.getClient(vn.Host)
+	if err != nil {
+		return nil, 0, err
+	}
+	if length < 0 {
+		length = 0
+	}
+
+	// The stream outlives the call so only its setup is bound by the context
+	ctx, cancel := context.WithCancel(context.Background())
+	stop := cancelOnDone(st.ctx, cancel)
+	defer stop()
+
+	cli, err := out.c.GetObjectRPC(ctx, &DHTBytes{B: key, Vn: vn, Offset: offset, Length: length})
+	if err != nil {
+		cancel()
+		st.returnClient(out)
+		return nil, 0, err
+	}
+
+	sr := &streamReader{st: st, out: out, cli: cli, cancel: cancel}
+	// Receive the first chunk so a missing object is reported here rather than
+	// on the first read.  It also carries the object size.
+	ds, err := cli.Recv()
+	if err != nil {
+		sr.err = err
+		sr.Close()
+		if err == io.EOF {
+			// Empty object
+			return sr, 0, nil
+		}
+		return nil, 0, err
+	}
+	sr.buf = ds.Data
+
+	return sr, ds.Size, nil
+}
+
+// streamReader lazily reads an object stream.  The connection is returned to
+// the pool once the stream has ended or the reader is closed.
+type streamReader struct {
+	st     *ChordStoreTransport
+	out    *rpcOutClient
+	cli    DHT_GetObjectRPCClient
+	cancel context.CancelFunc
+
+	buf  []byte
+	err  error
+	once sync.Once
+}
+
+func (sr *streamReader) Read(p []byte) (int, error) {
+	for len(sr.buf) == 0 {
+		if sr.err != nil {
+			return 0, sr.err
+		}
+
+		ds, err := sr.cli.Recv()
+		if err != nil {
+			sr.err = err
+			sr.Close()
+			continue
+		}
+		sr.buf = ds.Data
+	}
+
+	n := copy(p, sr.buf)
+	sr.buf = sr.buf[n:]
+	return n, nil
+}
+
+// Close the stream releasing the connection
+func (sr *streamReader) Close() error {
+	sr.once.Do(func() {
+		sr.cancel()
+		sr.st.returnClient(sr.out)
+	})
+	return nil
+}
+
+// PutObject streams the reader to the vnode.  The metadata is sent ahead of the
+// data.
+func (st *ChordStoreTransport) PutObject(vn *chord.Vnode, key []byte, rd io.Reader, meta *ObjectMeta) error {
+	return st.putObject(&DHTBytes{Vn: vn, B: key, Meta: meta}, rd)
+}
+
+// StatObject returns the metadata of an object from a specific vnode
+func (st *ChordStoreTransport) StatObject(vn *chord.Vnode, key []byte) (*ObjectMeta, error) {
+	out, err := st.getClient(vn.Host)
+	if err == nil {
+		defer st.returnClient(out)
+
+		var resp *DHTObjectMeta
+		if resp, err = out.c.StatObjectRPC(st.ctx, &DHTBytes{B: key, Vn: vn}); err == nil {
+			if resp.Err == "" {
+				return resp.Meta, nil
+			}
+			err = fmt.Errorf(resp.Err)
+		}
+	}
+	return nil, err
+}
+
+// ListKeys returns the sorted keys with the prefix after the cursor on the vnode
+func (st *ChordStoreTransport) ListKeys(vn *chord.Vnode, prefix, cursor []byte, limit int) ([][]byte, error) {
+	return st.listKeys(&DHTListRequest{Vn: vn, Prefix: prefix, Cursor: cursor, Limit: int32(limit)})
+}
+
+// ListObjects returns the sorted object keys with the prefix after the cursor on
+// the vnode
+func (st *ChordStoreTransport) ListObjects(vn *chord.Vnode, prefix, cursor []byte, limit int) ([][]byte, error) {
+	return st.listKeys(&DHTListRequest{Vn: vn, Prefix: prefix, Cursor: cursor, Limit: int32(limit), Objects: true})
+}
+
+func (st *ChordStoreTransport) listKeys(req *DHTListRequest) ([][]byte, error) {
+	out, err := st.getClient(req.Vn.Host)
 	if err != nil {
 		return nil, err
 	}
 	defer st.returnClient(out)
 
-	cli, err := out.c.GetObjectRPC(context.Background(), &DHTBytes{B: key, Vn: vn})
+	cli, err := out.c.ListKeysRPC(st.ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
-	buf := new(bytes.Buffer)
+	keys := [][]byte{}
 	for {
-		ds, e := cli.Recv()
-		if e != nil {
-			if e != io.EOF {
-				err = e
-			}
-			break
+		resp, err := cli.Recv()
+		if err == io.EOF {
+			return keys, nil
+		} else if err != nil {
+			return nil, err
 		}
-		buf.Write(ds.Data)
+		if len(resp.Err) > 0 {
+			return nil, fmt.Errorf(resp.Err)
+		}
+		keys = append(keys, resp.Keys...)
 	}
+}
 
-	return buf, err
+// PutContent streams the reader to the vnode which verifies the data hashes to
+// the key before storing it
+func (st *ChordStoreTransport) PutContent(vn *chord.Vnode, key []byte, rd io.Reader, meta *ObjectMeta) error {
+	return st.putObject(&DHTBytes{Vn: vn, B: key, Verify: true, Meta: meta}, rd)
 }
 
-func (st *ChordStoreTransport) PutObject(vn *chord.Vnode, key []byte, rd io.Reader) error {
-	out, err := st.getClient(vn.Host)
+func (st *ChordStoreTransport) putObject(mt *DHTBytes, rd io.Reader) error {
+	out, err := st.getClient(mt.Vn.Host)
 	if err != nil {
 		return err
 	}
 	defer st.returnClient(out)
 
-	cli, err := out.c.PutObjectRPC(context.Background())
+	// Cancelled on return so the server is not left waiting if we bail early
+	ctx, cancel := context.WithCancel(st.ctx)
+	defer cancel()
+
+	cli, err := out.c.PutObjectRPC(ctx)
 	if err != nil {
 		return err
 	}
 
 	// Send header with vnode and objec id
-	mt := &DHTBytes{Vn: vn, B: key}
 	if err = cli.SendMsg(mt); err != nil {
 		return err
 	}
@@ -170,22 +376,17 @@ func (st *ChordStoreTransport) PutObject(vn *chord.Vnode, key []byte, rd io.Read
 	buf := make([]byte, 65519)
 	for {
 		n, err := rd.Read(buf)
+		if n > 0 {
+			if e := cli.Send(&DataStream{Data: buf[:n]}); e != nil {
+				return e
+			}
+		}
 		if err != nil {
 			if err == io.EOF {
 				break
 			}
 			return err
 		}
-		/*if err == io.EOF {
-			break
-		} else if err != nil {
-			return err
-		}*/
-
-		ds := &DataStream{Data: buf[:n]}
-		if err = cli.Send(ds); err != nil {
-			return err
-		}
 	}
 
 	ersp, err := cli.CloseAndRecv()
@@ -200,13 +401,13 @@ func (st *ChordStoreTransport) PutObject(vn *chord.Vnode, key []byte, rd io.Read
 }
 
 // PutKey writes a key value to the vnode
-func (st *ChordStoreTransport) PutKey(vn *chord.Vnode, key, value []byte) error {
+func (st *ChordStoreTransport) PutKey(vn *chord.Vnode, key, value []byte, expiry int64, ws *WriteStamp) error {
 	out, err := st.getClient(vn.Host)
 	if err == nil {
 		defer st.returnClient(out)
 
 		var resp *chord.ErrResponse
-		if resp, err = out.c.PutKeyRPC(context.Background(), &DHTKeyValue{Vn: vn, Key: key, Value: value}); err == nil {
+		if resp, err = out.c.PutKeyRPC(st.ctx, &DHTKeyValue{Vn: vn, Key: key, Value: value, Expiry: expiry, Stamp: ws}); err == nil {
 			if resp.Err == "" {
 				return nil
 			}
@@ -218,14 +419,14 @@ func (st *ChordStoreTransport) PutKey(vn *chord.Vnode, key, value []byte) error
 
 // UpdateKey updates a key value to the vnode.  The previousHash is that of the previous
 // value of the key.
-func (st *ChordStoreTransport) UpdateKey(vn *chord.Vnode, prevHash, key, value []byte) error {
+func (st *ChordStoreTransport) UpdateKey(vn *chord.Vnode, prevHash, key, value []byte, ws *WriteStamp) error {
 	out, err := st.getClient(vn.Host)
 	if err == nil {
 		defer st.returnClient(out)
 
 		var resp *chord.ErrResponse
-		if resp, err = out.c.UpdateKeyRPC(context.Background(),
-			&DHTHashKeyValue{Vn: vn, PrevHash: prevHash, Key: key, Value: value}); err == nil {
+		if resp, err = out.c.UpdateKeyRPC(st.ctx,
+			&DHTHashKeyValue{Vn: vn, PrevHash: prevHash, Key: key, Value: value, Stamp: ws}); err == nil {
 
 			if resp.Err == "" {
 				return nil
@@ -237,12 +438,84 @@ func (st *ChordStoreTransport) UpdateKey(vn *chord.Vnode, prevHash, key, value [
 }
 
 // RemoveKey from a specific vnode
-func (st *ChordStoreTransport) RemoveKey(vn *chord.Vnode, key []byte) error {
+func (st *ChordStoreTransport) RemoveKey(vn *chord.Vnode, key []byte, ws *WriteStamp) error {
+	out, err := st.getClient(vn.Host)
+	if err == nil {
+		defer st.returnClient(out)
+		var resp *chord.ErrResponse
+		if resp, err = out.c.RemoveKeyRPC(st.ctx, &DHTBytes{B: key, Vn: vn, Stamp: ws}); err == nil {
+			if resp.Err == "" {
+				return nil
+			}
+			err = fmt.Errorf(resp.Err)
+		}
+	}
+	return err
+}
+
+// KeyHistory returns the transaction log of a key from a specific vnode
+func (st *ChordStoreTransport) KeyHistory(vn *chord.Vnode, key []byte) ([]*KeyTxn, error) {
+	out, err := st.getClient(vn.Host)
+	if err == nil {
+		defer st.returnClient(out)
+
+		var resp *DHTKeyTxns
+		if resp, err = out.c.KeyHistoryRPC(st.ctx, &DHTBytes{B: key, Vn: vn}); err == nil {
+			if resp.Err == "" {
+				return resp.Txns, nil
+			}
+			err = fmt.Errorf(resp.Err)
+		}
+	}
+	return nil, err
+}
+
+// GetVersions returns the siblings of a key from a specific vnode
+func (st *ChordStoreTransport) GetVersions(vn *chord.Vnode, key []byte) ([]*Sibling, error) {
+	out, err := st.getClient(vn.Host)
+	if err == nil {
+		defer st.returnClient(out)
+
+		var resp *DHTSiblings
+		if resp, err = out.c.GetVersionsRPC(st.ctx, &DHTBytes{B: key, Vn: vn}); err == nil {
+			if resp.Err == "" {
+				return resp.Siblings, nil
+			}
+			err = fmt.Errorf(resp.Err)
+		}
+	}
+	return nil, err
+}
+
+// PutVersion writes a new version of a key on the vnode returning it
+func (st *ChordStoreTransport) PutVersion(vn *chord.Vnode, key, value []byte, ctx VectorClock, ws *WriteStamp) (*Sibling, error) {
+	out, err := st.getClient(vn.Host)
+	if err == nil {
+		defer st.returnClient(out)
+
+		var resp *DHTSiblings
+		if resp, err = out.c.PutVersionRPC(st.ctx,
+			&DHTVersionedKeyValue{Vn: vn, Key: key, Value: value, Context: ctx, Stamp: ws}); err == nil {
+			if resp.Err == "" {
+				if len(resp.Siblings) != 1 {
+					return nil, fmt.Errorf("invalid version response: %d siblings", len(resp.Siblings))
+				}
+				return resp.Siblings[0], nil
+			}
+			err = fmt.Errorf(resp.Err)
+		}
+	}
+	return nil, err
+}
+
+// AddSibling adds a version of a key written on another replica to the vnode
+func (st *ChordStoreTransport) AddSibling(vn *chord.Vnode, key []byte, sib *Sibling) error {
 	out, err := st.getClient(vn.Host)
 	if err == nil {
 		defer st.returnClient(out)
+
 		var resp *chord.ErrResponse
-		if resp, err = out.c.RemoveKeyRPC(context.Background(), &DHTBytes{B: key, Vn: vn}); err == nil {
+		if resp, err = out.c.AddSiblingRPC(st.ctx, &DHTSiblingKey{Vn: vn, Key: key, Sibling: sib}); err == nil {
 			if resp.Err == "" {
 				return nil
 			}
@@ -252,13 +525,199 @@ func (st *ChordStoreTransport) RemoveKey(vn *chord.Vnode, key []byte) error {
 	return err
 }
 
+// CompareAndSwap the key on the primary vnode returning the value after the swap
+// and whether it was swapped
+func (st *ChordStoreTransport) CompareAndSwap(vn *chord.Vnode, key, old, value []byte, c Consistency, ws *WriteStamp) ([]byte, bool, error) {
+	out, err := st.getClient(vn.Host)
+	if err == nil {
+		defer st.returnClient(out)
+
+		var resp *DHTSwapResponse
+		if resp, err = out.c.CompareAndSwapRPC(st.ctx, &DHTSwapRequest{
+			Vn: vn, Key: key, Old: old, Value: value, Create: old == nil, Consistency: int32(c), Stamp: ws,
+		}); err == nil {
+			return swapResult(resp)
+		}
+	}
+	return nil, false, err
+}
+
+// SwapKey compares and sets the key on the vnode only returning the value after
+// the swap and whether it was swapped.  A nil value removes the key.
+func (st *ChordStoreTransport) SwapKey(vn *chord.Vnode, key, old, value []byte, ws *WriteStamp) ([]byte, bool, error) {
+	out, err := st.getClient(vn.Host)
+	if err == nil {
+		defer st.returnClient(out)
+
+		var resp *DHTSwapResponse
+		if resp, err = out.c.CompareAndSwapRPC(st.ctx, &DHTSwapRequest{
+			Vn: vn, Key: key, Old: old, Value: value, Create: old == nil, Remove: value == nil, Stamp: ws, Replica: true,
+		}); err == nil {
+			return swapResult(resp)
+		}
+	}
+	return nil, false, err
+}
+
+// swapResult returns the value and outcome of a swap response.  The value is nil
+// only if the key does not exist.
+func swapResult(resp *DHTSwapResponse) ([]byte, bool, error) {
+	if resp.Err != "" {
+		return nil, false, fmt.Errorf(resp.Err)
+	}
+	if !resp.Exists {
+		return nil, resp.Swapped, nil
+	}
+	if resp.Value == nil {
+		return []byte{}, resp.Swapped, nil
+	}
+	return resp.Value, resp.Swapped, nil
+}
+
+// RaftVote requests the vote of a consensus group member
+func (st *ChordStoreTransport) RaftVote(req *RaftVoteRequest) (*RaftVoteResponse, error) {
+	out, err := st.getClient(req.Vn.Host)
+	if err == nil {
+		defer st.returnClient(out)
+
+		var resp *RaftVoteResponse
+		if resp, err = out.c.RaftVoteRPC(st.ctx, req); err == nil {
+			if resp.Err == "" {
+				return resp, nil
+			}
+			err = fmt.Errorf(resp.Err)
+		}
+	}
+	return nil, err
+}
+
+// RaftAppend appends log entries to a consensus group member
+func (st *ChordStoreTransport) RaftAppend(req *RaftAppendRequest) (*RaftAppendResponse, error) {
+	out, err := st.getClient(req.Vn.Host)
+	if err == nil {
+		defer st.returnClient(out)
+
+		var resp *RaftAppendResponse
+		if resp, err = out.c.RaftAppendRPC(st.ctx, req); err == nil {
+			if resp.Err == "" {
+				return resp, nil
+			}
+			err = fmt.Errorf(resp.Err)
+		}
+	}
+	return nil, err
+}
+
+// RaftPropose proposes a log entry to a consensus group member.  Errors
+// applying the entry are returned in the response along with the leader if the
+// member is not the leader.
+func (st *ChordStoreTransport) RaftPropose(req *RaftProposal) (*RaftProposeResponse, error) {
+	out, err := st.getClient(req.Vn.Host)
+	if err != nil {
+		return nil, err
+	}
+	defer st.returnClient(out)
+
+	return out.c.RaftProposeRPC(st.ctx, req)
+}
+
+// TxnPrepare prepares the part of a transaction on the vnode
+func (st *ChordStoreTransport) TxnPrepare(req *DHTTxn) error {
+	return st.txn(req, DHTClient.TxnPrepareRPC)
+}
+
+// TxnCommit commits the part of a transaction on the vnode
+func (st *ChordStoreTransport) TxnCommit(req *DHTTxn) error {
+	return st.txn(req, DHTClient.TxnCommitRPC)
+}
+
+// TxnAbort aborts the part of a transaction on the vnode
+func (st *ChordStoreTransport) TxnAbort(req *DHTTxn) error {
+	return st.txn(req, DHTClient.TxnAbortRPC)
+}
+
+func (st *ChordStoreTransport) txn(req *DHTTxn, rpc func(DHTClient, context.Context, *DHTTxn, ...grpc.CallOption) (*chord.ErrResponse, error)) error {
+	out, err := st.getClient(req.Vn.Host)
+	if err == nil {
+		defer st.returnClient(out)
+
+		var resp *chord.ErrResponse
+		if resp, err = rpc(out.c, st.ctx, req); err == nil {
+			if resp.Err == "" {
+				return nil
+			}
+			err = fmt.Errorf(resp.Err)
+		}
+	}
+	return err
+}
+
+// Batch sends ops on vnodes of a single host in one rpc call
+func (st *ChordStoreTransport) Batch(ops []*BatchOp) ([]*BatchResult, error) {
+	if len(ops) == 0 {
+		return nil, nil
+	}
+	out, err := st.getClient(ops[0].Vn.Host)
+	if err == nil {
+		defer st.returnClient(out)
+
+		var resp *DHTBatchResponse
+		if resp, err = out.c.BatchRPC(st.ctx, &DHTBatch{Ops: ops}); err == nil {
+			if resp.Err != "" {
+				err = fmt.Errorf(resp.Err)
+			} else if len(resp.Results) != len(ops) {
+				err = fmt.Errorf("batch results mismatch %d/%d", len(resp.Results), len(ops))
+			} else {
+				return resp.Results, nil
+			}
+		}
+	}
+	return nil, err
+}
+
+// MerkleLevel returns the hashes of nodes at the given indexes of a level of the
+// hash tree of a specific vnode
+func (st *ChordStoreTransport) MerkleLevel(vn *chord.Vnode, level int, indexes []int) ([][]byte, error) {
+	resp, err := st.merkle(&DHTMerkleRequest{Vn: vn, Level: int32(level), Indexes: toInt32s(indexes)})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Hashes, nil
+}
+
+// MerkleEntries returns the keys and entry hashes in the given leaves of the hash
+// tree of a specific vnode
+func (st *ChordStoreTransport) MerkleEntries(vn *chord.Vnode, leaves []int) ([]*MerkleEntry, error) {
+	resp, err := st.merkle(&DHTMerkleRequest{Vn: vn, Level: merkleDepth, Indexes: toInt32s(leaves), Entries: true})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Entries, nil
+}
+
+func (st *ChordStoreTransport) merkle(req *DHTMerkleRequest) (*DHTMerkleResponse, error) {
+	out, err := st.getClient(req.Vn.Host)
+	if err == nil {
+		defer st.returnClient(out)
+
+		var resp *DHTMerkleResponse
+		if resp, err = out.c.MerkleRPC(st.ctx, req); err == nil {
+			if resp.Err == "" {
+				return resp, nil
+			}
+			err = fmt.Errorf(resp.Err)
+		}
+	}
+	return nil, err
+}
+
 // RemoveObject from a vnode
 func (st *ChordStoreTransport) RemoveObject(vn *chord.Vnode, key []byte) error {
 	out, err := st.getClient(vn.Host)
 	if err == nil {
 		defer st.returnClient(out)
 		var resp *chord.ErrResponse
-		if resp, err = out.c.RemoveObjectRPC(context.Background(), &DHTBytes{B: key, Vn: vn}); err == nil {
+		if resp, err = out.c.RemoveObjectRPC(st.ctx, &DHTBytes{B: key, Vn: vn}); err == nil {
 			if resp.Err == "" {
 				return nil
 			}
@@ -0,0 +1,159 @@
+package chordstore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func testTombstones(t *testing.T, kvs VnodeStore, newStore func() VnodeStore) {
+	// A replica that missed the removal
+	stale := newStore()
+	stale.PutKey([]byte("gone"), []byte("v"), 0, nil)
+	stale.PutKey([]byte("kept"), []byte("v"), 0, nil)
+
+	kvs.PutKey([]byte("gone"), []byte("v"), 0, nil)
+	kvs.RemoveKey([]byte("gone"), nil)
+	if ts, _ := kvs.KeyTombstone([]byte("gone")); ts == 0 {
+		t.Fatal("tombstone not recorded")
+	}
+	// Tombstones are recorded for keys never seen
+	kvs.RemoveKey([]byte("never"), nil)
+	if ts, _ := kvs.KeyTombstone([]byte("never")); ts == 0 {
+		t.Fatal("tombstone not recorded for missing key")
+	}
+
+	// Stamped removals record the time assigned by the coordinator
+	ws := &WriteStamp{Origin: "coordinator", Timestamp: hlc.Now()}
+	kvs.PutKey([]byte("stamped"), []byte("v"), 0, nil)
+	kvs.RemoveKey([]byte("stamped"), ws)
+	if ts, _ := kvs.KeyTombstone([]byte("stamped")); ts != ws.Timestamp {
+		t.Fatal("tombstone time mismatch", ts, ws.Timestamp)
+	}
+	txns, _ := kvs.KeyHistory([]byte("stamped"))
+	if len(txns) != 2 || txns[1].Timestamp != ws.Timestamp || txns[1].Vnode != ws.Origin {
+		t.Fatal("removal stamp not recorded", len(txns))
+	}
+
+	// The stale value is not resurrected
+	buf := new(bytes.Buffer)
+	if err := stale.Snapshot(buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := kvs.Restore(buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kvs.GetKey([]byte("gone")); err == nil {
+		t.Fatal("removed key resurrected")
+	}
+	if _, err := kvs.GetKey([]byte("kept")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The tombstone removes the stale value
+	buf.Reset()
+	if err := kvs.Snapshot(buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := stale.Restore(buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stale.GetKey([]byte("gone")); err == nil {
+		t.Fatal("stale value not removed")
+	}
+
+	// A later write clears the tombstone
+	kvs.PutKey([]byte("gone"), []byte("v2"), 0, nil)
+	if ts, _ := kvs.KeyTombstone([]byte("gone")); ts != 0 {
+		t.Fatal("tombstone should be cleared", ts)
+	}
+
+	n, err := stale.PurgeTombstones(time.Now().UnixNano())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatal("should purge 3 tombstones", n)
+	}
+
+	// Pruning leaves no tombstone
+	kvs.PruneKey([]byte("kept"))
+	if ts, _ := kvs.KeyTombstone([]byte("kept")); ts != 0 {
+		t.Fatal("pruned key should have no tombstone")
+	}
+	if _, err = kvs.GetKey([]byte("kept")); err == nil {
+		t.Fatal("key not pruned")
+	}
+}
+
+func Test_MemKeyValueStore_Tombstones(t *testing.T) {
+	kvs, _ := (&MemKeyValueStore{}).New(testVn1)
+	testTombstones(t, kvs, func() VnodeStore {
+		st, _ := (&MemKeyValueStore{}).New(testVn2)
+		return st
+	})
+}
+
+func Test_DiskKeyValueStore_Tombstones(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "chordstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	st := &DiskKeyValueStore{DataDir: tmpdir}
+	kvs, err := st.New(testVn1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTombstones(t, kvs, func() VnodeStore {
+		vs, err := st.New(testVn2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return vs
+	})
+
+	// Tombstones persist
+	kvs.RemoveKey([]byte("persisted"), nil)
+	if kvs, err = st.New(testVn1); err != nil {
+		t.Fatal(err)
+	}
+	if ts, _ := kvs.KeyTombstone([]byte("persisted")); ts == 0 {
+		t.Fatal("tombstone not loaded")
+	}
+}
+
+func Test_ChordStore_HealRemoval(t *testing.T) {
+	c1, err := initConfig(36035)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs1, err := NewChordStore(c1, &MemKeyValueStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs1.Shutdown()
+	defer c1.Ring.Shutdown()
+
+	<-time.After(200 * time.Millisecond)
+
+	key := []byte("deleted")
+	if _, err = cs1.PutKey(3, key, []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	vns, _ := cs1.lookup(3, key)
+	// The removal misses a replica
+	for _, vn := range vns[:2] {
+		cs1.store.RemoveKey(vn, key, nil)
+	}
+
+	if err = cs1.healer.healKey(HealRequest{Type: HealTypeKey, Key: key}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cs1.store.GetKey(vns[2], key); !isNotFound(err) {
+		t.Fatal("removal not healed", err)
+	}
+}
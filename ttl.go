@@ -0,0 +1,92 @@
+package chordstore
+
+import (
+	"log"
+	"time"
+)
+
+// expired returns true if the expiry is set and has passed
+func expired(expiry, now int64) bool {
+	return expiry > 0 && expiry <= now
+}
+
+// keyExpiries returns the expiries of the keys that have one
+func keyExpiries(keys map[string][]byte, expiries map[string]int64) map[string]int64 {
+	out := map[string]int64{}
+	for k := range keys {
+		if e, ok := expiries[k]; ok {
+			out[k] = e
+		}
+	}
+	return out
+}
+
+// expiryReaper periodically removes the expired keys of the local vnodes along
+// with tombstones older than the grace period.  Keys are also expired lazily
+// when read so the interval only bounds how long expired keys that are not read
+// take up space.
+type expiryReaper struct {
+	cs       *ChordStore
+	interval time.Duration
+	// tombstone retention.  0 keeps tombstones forever.
+	grace time.Duration
+	stop  chan bool
+}
+
+func newExpiryReaper(cs *ChordStore, interval, grace time.Duration) *expiryReaper {
+	return &expiryReaper{cs: cs, interval: interval, grace: grace, stop: make(chan bool, 1)}
+}
+
+// start reaping on every interval.  This blocks until stop is called.
+func (er *expiryReaper) start() {
+	tick := time.NewTicker(er.interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-tick.C:
+			er.reapAll()
+		case <-er.stop:
+			return
+		}
+	}
+}
+
+func (er *expiryReaper) shutdown() {
+	er.stop <- true
+}
+
+func (er *expiryReaper) reapAll() {
+	before := time.Now().Add(-er.grace).UnixNano()
+	for id, st := range er.cs.store.local {
+		n, err := st.ExpireKeys()
+		if err != nil {
+			log.Printf("ERR [expiry] vnode=%s %v", id[:12], err)
+		} else if n > 0 {
+			log.Printf("DBG [expiry] vnode=%s expired=%d", id[:12], n)
+		}
+
+		if er.grace <= 0 {
+			continue
+		}
+		if n, err = st.PurgeTombstones(before); err != nil {
+			log.Printf("ERR [expiry] vnode=%s %v", id[:12], err)
+		} else if n > 0 {
+			log.Printf("DBG [expiry] vnode=%s tombstones=%d", id[:12], n)
+		}
+	}
+}
+
+// PutKeyWithTTL puts the key-value on the ring with a replica count of n.  The
+// key expires once ttl has elapsed.  A ttl less than 1 never expires.  Keys of
+// strong namespaces are rejected so they never expire.
+func (cs *ChordStore) PutKeyWithTTL(n int, key, value []byte, ttl time.Duration) ([]*VnodeData, error) {
+	if err := cs.checkWeak(key); err != nil {
+		return nil, err
+	}
+	var expiry int64
+	if ttl > 0 {
+		expiry = time.Now().Add(ttl).UnixNano()
+	}
+	return cs.putKey(n, ConsistencyAll, key, value, expiry)
+}
@@ -0,0 +1,123 @@
+package chordstore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func testKeyExpiry(t *testing.T, kvs VnodeStore) {
+	past := time.Now().Add(-time.Second).UnixNano()
+	future := time.Now().Add(time.Hour).UnixNano()
+
+	kvs.PutKey([]byte("gone"), []byte("v"), past, nil)
+	kvs.PutKey([]byte("reaped"), []byte("v"), past, nil)
+	kvs.PutKey([]byte("live"), []byte("v"), future, nil)
+	kvs.PutKey([]byte("forever"), []byte("v"), 0, nil)
+
+	if _, err := kvs.GetKey([]byte("gone")); err == nil {
+		t.Fatal("should be expired")
+	}
+	txns, _ := kvs.KeyHistory([]byte("gone"))
+	if len(txns) != 2 || txns[1].Op != TxnOpExpire {
+		t.Fatal("expiry not logged", txns)
+	}
+
+	if e, err := kvs.KeyExpiry([]byte("live")); err != nil || e != future {
+		t.Fatal("wrong expiry", e, err)
+	}
+	keys, _ := kvs.ListKeys(nil, nil, 0)
+	if len(keys) != 2 {
+		t.Fatalf("should not list expired keys %q", keys)
+	}
+
+	n, err := kvs.ExpireKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatal("should reap 1 key", n)
+	}
+
+	// Expiries are carried by snapshots
+	buf := new(bytes.Buffer)
+	if err = kvs.Snapshot(buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	kvs2, _ := (&MemKeyValueStore{}).New(testVn2)
+	if err = kvs2.Restore(buf); err != nil {
+		t.Fatal(err)
+	}
+	if e, _ := kvs2.KeyExpiry([]byte("live")); e != future {
+		t.Fatal("expiry not restored", e)
+	}
+
+	// Overwriting without an expiry clears it
+	kvs.PutKey([]byte("live"), []byte("v2"), 0, nil)
+	if e, _ := kvs.KeyExpiry([]byte("live")); e != 0 {
+		t.Fatal("expiry should be cleared", e)
+	}
+}
+
+func Test_MemKeyValueStore_Expiry(t *testing.T) {
+	kvs, _ := (&MemKeyValueStore{}).New(testVn1)
+	testKeyExpiry(t, kvs)
+}
+
+func Test_DiskKeyValueStore_Expiry(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "chordstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	st := &DiskKeyValueStore{DataDir: tmpdir}
+	kvs, err := st.New(testVn1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testKeyExpiry(t, kvs)
+
+	// Expiries persist
+	future := time.Now().Add(time.Hour).UnixNano()
+	kvs.PutKey([]byte("live"), []byte("v3"), future, nil)
+	if kvs, err = st.New(testVn1); err != nil {
+		t.Fatal(err)
+	}
+	if e, _ := kvs.KeyExpiry([]byte("live")); e != future {
+		t.Fatal("expiry not loaded", e)
+	}
+}
+
+func Test_ChordStore_PutKeyWithTTL(t *testing.T) {
+	cs1, cs2, stop := newTestRing(t, 36033, nil)
+	defer stop()
+
+	key := []byte("session")
+	vds, err := cs1.PutKeyWithTTL(3, key, []byte("v"), 300*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, vd := range vds {
+		if vd.Err != nil {
+			t.Fatal(vd.Err)
+		}
+		// Remote replicas get the expiry
+		if e, err := cs2.store.KeyExpiry(vd.Vnode, key); err != nil || e == 0 {
+			t.Fatal("expiry not replicated", e, err)
+		}
+	}
+
+	if vds, _ = cs2.GetKey(3, key); vds[0].Err != nil {
+		t.Fatal(vds[0].Err)
+	}
+	<-time.After(400 * time.Millisecond)
+	vds, _ = cs2.GetKey(3, key)
+	for _, vd := range vds {
+		if !isNotFound(vd.Err) {
+			t.Fatal("should have expired", vd.Err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package chordstore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	chord "github.com/euforia/go-chord"
+)
+
+// Key transaction log operations
+const (
+	TxnOpPut     = "put"
+	TxnOpUpdate  = "update"
+	TxnOpRemove  = "remove"
+	TxnOpExpire  = "expire"  // key removed once its expiry passed
+	TxnOpRestore = "restore" // value changed by a vnode restore/transfer
+)
+
+// newKeyTxn returns a log entry for a mutation on the vnode.  hash is nil for
+// removals and prevHash is nil if the key did not previously exist.  The entry
+// records the origin and time of the stamp, or the vnode itself and the current
+// time for a nil stamp as with expiries and restores.
+func newKeyTxn(vn *chord.Vnode, ws *WriteStamp, op string, prevHash, hash []byte) *KeyTxn {
+	origin := vn.StringID()
+	if ws.GetOrigin() != "" {
+		origin = ws.Origin
+	}
+	return &KeyTxn{
+		Op:        op,
+		Hash:      hash,
+		PrevHash:  prevHash,
+		Timestamp: stampTime(ws),
+		Vnode:     origin,
+	}
+}
+
+// newWriteStamp returns the stamp of a write coordinated by this node.  The
+// first local vnode identifies the node.  The timestamp is assigned here once so
+// all replicas record the same time for the write.  The layout versions of the
+// ordered namespaces are carried for replicas to check the placement against.
+func (cs *ChordStore) newWriteStamp() *WriteStamp {
+	ws := &WriteStamp{Timestamp: hlc.Now()}
+	if len(cs.vnodes) > 0 {
+		ws.Origin = cs.vnodes[0].StringID()
+	}
+	if cs.layouts != nil {
+		ws.Layouts = cs.layouts.versions()
+	}
+	return ws
+}
+
+// siblingStamp returns the stamp of the write of a version
+func siblingStamp(sib *Sibling) *WriteStamp {
+	return &WriteStamp{Origin: sib.Node, Timestamp: sib.Timestamp}
+}
+
+// valueHash returns the sha256 hash of a value as used in the transaction log
+func valueHash(v []byte) []byte {
+	h := sha256.Sum256(v)
+	return h[:]
+}
+
+// restoreKeyTxn returns a restore log entry if the restored value differs from
+// the current one, or nil if it is the same.
+func restoreKeyTxn(vn *chord.Vnode, current []byte, exists bool, value []byte) *KeyTxn {
+	if exists && bytes.Equal(current, value) {
+		return nil
+	}
+	var prevHash []byte
+	if exists {
+		prevHash = valueHash(current)
+	}
+	return newKeyTxn(vn, nil, TxnOpRestore, prevHash, valueHash(value))
+}
+
+// keyWriteTime returns the time the value was last written according to the
+// transaction log.  Restores are only used if the value was never put or updated
+// locally.  0 is returned for an empty log.
+func keyWriteTime(txns []*KeyTxn) int64 {
+	var restored int64
+	for i := len(txns) - 1; i >= 0; i-- {
+		switch txns[i].Op {
+		case TxnOpPut, TxnOpUpdate:
+			return txns[i].Timestamp
+		case TxnOpRestore:
+			if restored == 0 {
+				restored = txns[i].Timestamp
+			}
+		}
+	}
+	return restored
+}
+
+// lastWriteStamp returns the stamp of the last write of the key in the vnode
+// store according to its transaction log, or nil if it has none
+func lastWriteStamp(st VnodeStore, key []byte) *WriteStamp {
+	txns, err := st.KeyHistory(key)
+	if err != nil || len(txns) == 0 {
+		return nil
+	}
+	return &WriteStamp{Origin: txns[len(txns)-1].Vnode, Timestamp: keyWriteTime(txns)}
+}
+
+// keyWriteCount returns the number of times the value was written according to
+// the transaction log
+func keyWriteCount(txns []*KeyTxn) int {
+	var n int
+	for _, txn := range txns {
+		switch txn.Op {
+		case TxnOpPut, TxnOpUpdate, TxnOpRestore:
+			n++
+		}
+	}
+	return n
+}
+
+// VerifyKeyTxns checks the hash chain of a key transaction log i.e. each entry's
+// previous hash must be the hash of the entry before it.
+func VerifyKeyTxns(txns []*KeyTxn) error {
+	for i := 1; i < len(txns); i++ {
+		if !bytes.Equal(txns[i].PrevHash, txns[i-1].Hash) {
+			return fmt.Errorf("broken hash chain at %d: %x != %x", i, txns[i].PrevHash, txns[i-1].Hash)
+		}
+	}
+	return nil
+}
+
+// VnodeKeyHistory is the transaction log of a key from a vnode on the ring
+type VnodeKeyHistory struct {
+	Vnode *chord.Vnode
+	Txns  []*KeyTxn
+	Err   error
+}
+
+// Verify the hash chain of the transaction log
+func (vh *VnodeKeyHistory) Verify() error {
+	return VerifyKeyTxns(vh.Txns)
+}
+
+// MarshalJSON custom
+func (vh *VnodeKeyHistory) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{
+		"vnode": map[string]string{
+			"id":   vh.Vnode.StringID(),
+			"host": vh.Vnode.Host,
+		},
+	}
+	if vh.Txns != nil {
+		m["txns"] = vh.Txns
+		m["verified"] = vh.Verify() == nil
+	}
+	if vh.Err != nil {
+		m["error"] = vh.Err.Error()
+	}
+	return json.Marshal(m)
+}
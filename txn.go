@@ -0,0 +1,547 @@
+package chordstore
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	chord "github.com/euforia/go-chord"
+	"github.com/golang/protobuf/proto"
+	context "golang.org/x/net/context"
+)
+
+// Transaction operations
+const (
+	txnOpRead   = "read"
+	txnOpPut    = "put"
+	txnOpRemove = "remove"
+)
+
+// Transaction decisions recorded on the ring
+const (
+	txnCommitted = "committed"
+	txnAborted   = "aborted"
+)
+
+// Time a prepared transaction waits on the decision before being resolved
+const defaultTxnTimeout = time.Minute
+
+// txnRecordPrefix is the prefix of the keys holding transaction decisions
+const txnRecordPrefix = internalKeyPrefix + "txn/"
+
+func txnRecordKey(id string) []byte {
+	return []byte(txnRecordPrefix + id)
+}
+
+// Txn is a multi-key transaction.  Keys read are validated to be unchanged and
+// keys written are written together when the transaction commits, regardless of
+// the vnodes holding them.  A Txn is not safe for concurrent use.
+type Txn struct {
+	cs *ChordStore
+	// hash of the value of each key read.  nil if not found.
+	reads  map[string][]byte
+	writes map[string]*TxnOp
+}
+
+// Txn starts a transaction.  It is committed with two-phase commit coordinated
+// by this node: the replicas of the keys lock them and validate the reads, then
+// the decision is recorded on the ring before being sent to the replicas.
+// Replicas that do not hear the decision resolve the transaction from the
+// record, aborting it if none was recorded.  Keys written outside of
+// transactions are not locked.
+func (cs *ChordStore) Txn() *Txn {
+	return &Txn{cs: cs, reads: map[string][]byte{}, writes: map[string]*TxnOp{}}
+}
+
+// Get the value of the key adding it to the read set.  Keys written by the
+// transaction return the written value.
+func (txn *Txn) Get(key []byte) ([]byte, error) {
+	if w, ok := txn.writes[string(key)]; ok {
+		if w.Op == txnOpRemove {
+			return nil, fmt.Errorf("key not found: %s", key)
+		}
+		return w.Value, nil
+	}
+
+	val, err := txn.cs.Get(key, ConsistencyQuorum)
+	if err == nil {
+		txn.reads[string(key)] = valueHash(val)
+	} else if isNotFound(err) {
+		txn.reads[string(key)] = nil
+	}
+	return val, err
+}
+
+// Put the key-value on commit
+func (txn *Txn) Put(key, value []byte) {
+	txn.writes[string(key)] = &TxnOp{Key: key, Op: txnOpPut, Value: value}
+}
+
+// Remove the key on commit
+func (txn *Txn) Remove(key []byte) {
+	txn.writes[string(key)] = &TxnOp{Key: key, Op: txnOpRemove}
+}
+
+// Commit the transaction.  It returns an error if a key read has changed, a key
+// is locked by another transaction or not enough replicas of a key prepare.
+// If the decision could not be recorded the transaction is in doubt and is
+// resolved by the replicas.  Transactions on keys of strong namespaces are
+// rejected.
+func (txn *Txn) Commit() error {
+	ops := txn.ops()
+	for _, op := range ops {
+		if err := txn.cs.checkWeak(op.Key); err != nil {
+			return err
+		}
+	}
+
+	id, err := newTxnID()
+	if err != nil {
+		return err
+	}
+	return txn.cs.txns.coordinate(id, ops)
+}
+
+// ops returns the reads followed by the writes.  A key read then written has
+// both so the value read is still validated.
+func (txn *Txn) ops() []*TxnOp {
+	ops := make([]*TxnOp, 0, len(txn.reads)+len(txn.writes))
+	for k, h := range txn.reads {
+		ops = append(ops, &TxnOp{Key: []byte(k), Op: txnOpRead, Hash: h})
+	}
+	for _, w := range txn.writes {
+		ops = append(ops, w)
+	}
+	return ops
+}
+
+func newTxnID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// txnPart is the part of a transaction on a replica vnode
+type txnPart struct {
+	vn  *chord.Vnode
+	ops []*TxnOp
+}
+
+// preparedTxn is a transaction prepared by a local vnode awaiting the decision
+type preparedTxn struct {
+	id       string
+	vn       *chord.Vnode
+	ops      []*TxnOp
+	parts    []*chord.Vnode
+	stamp    *WriteStamp
+	prepared time.Time
+}
+
+// txnManager coordinates transactions committed locally and holds those
+// prepared by the local vnodes.  Prepared transactions are persisted by vnode
+// stores supporting it before they are acknowledged.
+type txnManager struct {
+	cs    *ChordStore
+	trans *ChordStoreTransport
+	// time a prepared transaction waits on the decision before being resolved
+	timeout time.Duration
+
+	mu       sync.Mutex
+	prepared map[string]*preparedTxn // by txn id and vnode
+	locks    map[string]string       // txn id by vnode and key
+	// parts decided locally by txn id and vnode, kept for the timeout so late
+	// prepares are refused
+	decided map[string]time.Time
+
+	stop chan bool
+}
+
+func newTxnManager(cs *ChordStore, timeout time.Duration) *txnManager {
+	if timeout <= 0 {
+		timeout = defaultTxnTimeout
+	}
+	return &txnManager{
+		cs:       cs,
+		trans:    NewChordStoreTransport(),
+		timeout:  timeout,
+		prepared: map[string]*preparedTxn{},
+		locks:    map[string]string{},
+		decided:  map[string]time.Time{},
+		stop:     make(chan bool, 1),
+	}
+}
+
+// load the transactions persisted by the local vnodes, locking their keys.  They
+// are resolved once they time out unless the decision arrives first.
+func (tm *txnManager) load() error {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	for _, st := range tm.cs.store.local {
+		disk, ok := st.(txnPersisting)
+		if !ok {
+			continue
+		}
+		recs, err := disk.loadPreparedTxns()
+		if err != nil {
+			return err
+		}
+		for _, b := range recs {
+			var req DHTTxn
+			if err = proto.Unmarshal(b, &req); err != nil {
+				return err
+			}
+			for _, op := range req.Ops {
+				tm.locks[txnLockKey(req.Vn, op.Key)] = req.Id
+			}
+			tm.prepared[txnPartID(req.Id, req.Vn)] = &preparedTxn{id: req.Id, vn: req.Vn, ops: req.Ops, parts: req.Parts, stamp: req.Stamp, prepared: time.Now()}
+		}
+		if len(recs) > 0 {
+			log.Printf("DBG [txn] Loaded prepared=%d", len(recs))
+		}
+	}
+	return nil
+}
+
+// start resolving in doubt transactions on every timeout.  This blocks until
+// shutdown is called.
+func (tm *txnManager) start() {
+	tick := time.NewTicker(tm.timeout)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-tick.C:
+			tm.resolveInDoubt()
+		case <-tm.stop:
+			return
+		}
+	}
+}
+
+func (tm *txnManager) shutdown() {
+	tm.stop <- true
+	tm.trans.Shutdown()
+}
+
+// parts groups the ops by the replica vnodes of their keys
+func (tm *txnManager) parts(ops []*TxnOp) (map[string]*txnPart, map[string][]*chord.Vnode, error) {
+	parts := map[string]*txnPart{}
+	replicas := map[string][]*chord.Vnode{}
+	for _, op := range ops {
+		vns, err := tm.cs.lookup(tm.cs.replicas, op.Key)
+		if err != nil {
+			return nil, nil, err
+		}
+		replicas[string(op.Key)] = vns
+		for _, vn := range vns {
+			p, ok := parts[vn.StringID()]
+			if !ok {
+				p = &txnPart{vn: vn}
+				parts[vn.StringID()] = p
+			}
+			p.ops = append(p.ops, op)
+		}
+	}
+	return parts, replicas, nil
+}
+
+// coordinate the two-phase commit of the transaction
+func (tm *txnManager) coordinate(id string, ops []*TxnOp) error {
+	if len(ops) == 0 {
+		return nil
+	}
+	parts, replicas, err := tm.parts(ops)
+	if err != nil {
+		return err
+	}
+	list := make([]*txnPart, 0, len(parts))
+	vns := make([]*chord.Vnode, 0, len(parts))
+	for _, p := range parts {
+		list = append(list, p)
+		vns = append(vns, p.vn)
+	}
+
+	// Prepare
+	ws := tm.cs.newWriteStamp()
+	errs := make([]error, len(list))
+	done := fanOut(len(list), len(list), tm.cs.timeout, func(ctx context.Context, i int) bool {
+		errs[i] = tm.send(tm.prepare, tm.trans.WithContext(ctx).TxnPrepare, &DHTTxn{Vn: list[i].vn, Id: id, Ops: list[i].ops, Parts: vns, Stamp: ws})
+		return errs[i] == nil
+	})
+
+	prepared := map[string]bool{}
+	var perr error
+	for i, p := range list {
+		if !done[i] {
+			errs[i] = errReplicaPending
+		}
+		if errs[i] == nil {
+			prepared[p.vn.StringID()] = true
+		} else {
+			perr = mergeErrors(perr, fmt.Errorf("%s: %v", shortID(p.vn), errs[i]))
+		}
+	}
+	// A quorum of the replicas of each key must prepare so concurrent
+	// transactions cannot both lock it
+	for k, vns := range replicas {
+		var n int
+		for _, vn := range vns {
+			if prepared[vn.StringID()] {
+				n++
+			}
+		}
+		if n < ConsistencyQuorum.Required(len(vns)) {
+			tm.finish(id, list, txnAborted, nil)
+			return fmt.Errorf("txn %s aborted key=%s prepared=%d/%d: %v", id, k, n, len(vns), perr)
+		}
+	}
+
+	// Record the decision.  Replicas resolving the transaction record an abort
+	// if they find none so only one decision is ever recorded.
+	val, ok, err := tm.cs.CompareAndSwap(txnRecordKey(id), nil, []byte(txnCommitted), ConsistencyQuorum)
+	if err != nil {
+		return fmt.Errorf("txn %s in doubt: %v", id, err)
+	}
+	if !ok && string(val) != txnCommitted {
+		if tm.finish(id, list, txnAborted, nil) {
+			tm.removeRecord(id)
+		}
+		return fmt.Errorf("txn %s aborted by replicas", id)
+	}
+
+	if tm.finish(id, list, txnCommitted, ws) {
+		tm.removeRecord(id)
+	}
+	return nil
+}
+
+// removeRecord removes the decision once all parts acknowledged it as no replica
+// is left to resolve it
+func (tm *txnManager) removeRecord(id string) {
+	if err := tm.cs.Remove(txnRecordKey(id), ConsistencyQuorum); err != nil && !isNotFound(err) {
+		log.Printf("ERR [txn] id=%s Failed to remove record: %v", id, err)
+	}
+}
+
+// finish sends the decision to all parts returning true if all acknowledged.
+// Parts not acknowledging resolve the transaction once it times out.  Commits
+// carry the stamp of the writes.
+func (tm *txnManager) finish(id string, list []*txnPart, decision string, ws *WriteStamp) bool {
+	errs := make([]error, len(list))
+	done := fanOut(len(list), len(list), tm.cs.timeout, func(ctx context.Context, i int) bool {
+		req := &DHTTxn{Vn: list[i].vn, Id: id}
+		if decision == txnCommitted {
+			req.Ops, req.Stamp = list[i].ops, ws
+			errs[i] = tm.send(tm.commit, tm.trans.WithContext(ctx).TxnCommit, req)
+		} else {
+			errs[i] = tm.send(tm.abort, tm.trans.WithContext(ctx).TxnAbort, req)
+		}
+		return errs[i] == nil
+	})
+
+	all := true
+	for i, p := range list {
+		if !done[i] || errs[i] != nil {
+			log.Printf("ERR [txn] id=%s vnode=%s Failed to %s: %v", id, shortID(p.vn), decision, errs[i])
+			all = false
+		}
+	}
+	return all
+}
+
+// send the request to the local vnode or remote
+func (tm *txnManager) send(local, remote func(*DHTTxn) error, req *DHTTxn) error {
+	if _, ok := tm.cs.store.local[req.Vn.StringID()]; ok {
+		return local(req)
+	}
+	return remote(req)
+}
+
+func txnLockKey(vn *chord.Vnode, key []byte) string {
+	return vn.StringID() + "/" + string(key)
+}
+
+func txnPartID(id string, vn *chord.Vnode) string {
+	return id + "/" + vn.StringID()
+}
+
+// prepare the part of the transaction on a local vnode, locking its keys and
+// validating the reads
+func (tm *txnManager) prepare(req *DHTTxn) error {
+	st, ok := tm.cs.store.local[req.Vn.StringID()]
+	if !ok {
+		return fmt.Errorf("vnode not found: %s", shortID(req.Vn))
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	pid := txnPartID(req.Id, req.Vn)
+	if _, ok = tm.decided[pid]; ok {
+		return fmt.Errorf("txn already decided: %s", req.Id)
+	}
+	if _, ok = tm.prepared[pid]; ok {
+		return nil
+	}
+
+	for _, op := range req.Ops {
+		if id, ok := tm.locks[txnLockKey(req.Vn, op.Key)]; ok && id != req.Id {
+			return fmt.Errorf("key locked by txn %s: %s", id, op.Key)
+		}
+		if op.Op != txnOpRead {
+			continue
+		}
+		val, err := st.GetKey(op.Key)
+		if err != nil && !isNotFound(err) {
+			return err
+		}
+		var h []byte
+		if err == nil {
+			h = valueHash(val)
+		}
+		if !bytes.Equal(h, op.Hash) {
+			return fmt.Errorf("key changed: %s", op.Key)
+		}
+	}
+
+	// The part is durable before it is acknowledged
+	if disk, ok := st.(txnPersisting); ok {
+		b, err := proto.Marshal(req)
+		if err == nil {
+			err = disk.savePreparedTxn(req.Id, b)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, op := range req.Ops {
+		tm.locks[txnLockKey(req.Vn, op.Key)] = req.Id
+	}
+	tm.prepared[pid] = &preparedTxn{id: req.Id, vn: req.Vn, ops: req.Ops, parts: req.Parts, stamp: req.Stamp, prepared: time.Now()}
+	return nil
+}
+
+// commit the part of the transaction on a local vnode.  The writes are applied
+// even if the vnode did not prepare so all replicas hold them.
+func (tm *txnManager) commit(req *DHTTxn) error {
+	st, ok := tm.cs.store.local[req.Vn.StringID()]
+	if !ok {
+		return fmt.Errorf("vnode not found: %s", shortID(req.Vn))
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if t, ok := tm.prepared[txnPartID(req.Id, req.Vn)]; ok && len(req.Ops) == 0 {
+		req.Ops, req.Stamp = t.ops, t.stamp
+	}
+
+	var err error
+	for _, op := range req.Ops {
+		var e error
+		switch op.Op {
+		case txnOpPut:
+			e = st.PutKey(op.Key, op.Value, 0, req.Stamp)
+		case txnOpRemove:
+			if e = st.RemoveKey(op.Key, req.Stamp); isNotFound(e) {
+				e = nil
+			}
+		}
+		err = mergeErrors(err, e)
+	}
+	tm.release(req.Id, req.Vn)
+	return err
+}
+
+// abort the part of the transaction on a local vnode
+func (tm *txnManager) abort(req *DHTTxn) error {
+	if _, ok := tm.cs.store.local[req.Vn.StringID()]; !ok {
+		return fmt.Errorf("vnode not found: %s", shortID(req.Vn))
+	}
+
+	tm.mu.Lock()
+	tm.release(req.Id, req.Vn)
+	tm.mu.Unlock()
+	return nil
+}
+
+// release the locks of a part marking it decided.  Callers must hold the lock.
+func (tm *txnManager) release(id string, vn *chord.Vnode) {
+	pid := txnPartID(id, vn)
+	if t, ok := tm.prepared[pid]; ok {
+		for _, op := range t.ops {
+			if k := txnLockKey(vn, op.Key); tm.locks[k] == id {
+				delete(tm.locks, k)
+			}
+		}
+		delete(tm.prepared, pid)
+
+		if disk, ok := tm.cs.store.local[vn.StringID()].(txnPersisting); ok {
+			if err := disk.removePreparedTxn(id); err != nil {
+				log.Printf("ERR [txn] id=%s vnode=%s Failed to remove prepared: %v", id, shortID(vn), err)
+			}
+		}
+	}
+	tm.decided[pid] = time.Now()
+}
+
+// resolveInDoubt resolves transactions prepared for longer than the timeout from the
+// decision recorded by the coordinator, recording an abort if there is none.  The
+// decision is sent to all parts and its record removed once all acknowledge.
+func (tm *txnManager) resolveInDoubt() {
+	now := time.Now()
+
+	tm.mu.Lock()
+	var doubt []*DHTTxn
+	for _, t := range tm.prepared {
+		if now.Sub(t.prepared) > tm.timeout {
+			doubt = append(doubt, &DHTTxn{Vn: t.vn, Id: t.id, Parts: t.parts})
+		}
+	}
+	for pid, ts := range tm.decided {
+		if now.Sub(ts) > tm.timeout {
+			delete(tm.decided, pid)
+		}
+	}
+	tm.mu.Unlock()
+
+	for _, req := range doubt {
+		val, _, err := tm.cs.CompareAndSwap(txnRecordKey(req.Id), nil, []byte(txnAborted), ConsistencyQuorum)
+		if err != nil {
+			log.Printf("ERR [txn] id=%s vnode=%s Failed to resolve: %v", req.Id, shortID(req.Vn), err)
+			continue
+		}
+
+		decision := txnAborted
+		if string(val) == txnCommitted {
+			decision = txnCommitted
+		}
+		switch {
+		case len(req.Parts) > 0:
+			// Parts committing without ops apply those they prepared
+			list := make([]*txnPart, len(req.Parts))
+			for i, vn := range req.Parts {
+				list[i] = &txnPart{vn: vn}
+			}
+			if tm.finish(req.Id, list, decision, nil) {
+				tm.removeRecord(req.Id)
+			}
+		case decision == txnCommitted:
+			err = tm.commit(req)
+		default:
+			err = tm.abort(req)
+		}
+		log.Printf("DBG [txn] id=%s vnode=%s Resolved %s", req.Id, shortID(req.Vn), val)
+		if err != nil {
+			log.Printf("ERR [txn] id=%s vnode=%s %v", req.Id, shortID(req.Vn), err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package chordstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	chord "github.com/euforia/go-chord"
+)
+
+func Test_ChordStore_Txn(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "chordstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	timeout := 200 * time.Millisecond
+	cs1, cs2, stop := newTestRing(t, 36043, func(cfg *Config) {
+		cfg.TxnTimeout = timeout
+	}, &DiskKeyValueStore{DataDir: filepath.Join(tmpdir, "1")}, &DiskKeyValueStore{DataDir: filepath.Join(tmpdir, "2")})
+	defer stop()
+
+	a, b := []byte("inventory/a"), []byte("inventory/b")
+	if err = cs1.Put(a, []byte("10"), ConsistencyAll); err != nil {
+		t.Fatal(err)
+	}
+
+	// Move counts between keys
+	txn := cs1.Txn()
+	if val, err := txn.Get(a); err != nil || string(val) != "10" {
+		t.Fatal(string(val), err)
+	}
+	if _, err = txn.Get(b); !isNotFound(err) {
+		t.Fatal("should not be found", err)
+	}
+	txn.Put(a, []byte("7"))
+	txn.Put(b, []byte("3"))
+	if err = txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range map[string]string{"inventory/a": "7", "inventory/b": "3"} {
+		if val, err := cs2.Get([]byte(k), ConsistencyAll); err != nil || string(val) != v {
+			t.Fatal("value mismatch", k, string(val), err)
+		}
+	}
+
+	// A key read changes before commit
+	txn = cs2.Txn()
+	txn.Get(a)
+	txn.Put(b, []byte("0"))
+	if err = cs1.Put(a, []byte("8"), ConsistencyAll); err != nil {
+		t.Fatal(err)
+	}
+	if err = txn.Commit(); err == nil || !strings.Contains(err.Error(), "key changed") {
+		t.Fatal("should fail validation", err)
+	}
+	if val, _ := cs1.Get(b, ConsistencyAll); string(val) != "3" {
+		t.Fatal("aborted write applied", string(val))
+	}
+
+	// A coordinator fails after preparing
+	prepare := func(id string, ops ...*TxnOp) {
+		parts, _, err := cs1.txns.parts(ops)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var vns []*chord.Vnode
+		for _, p := range parts {
+			vns = append(vns, p.vn)
+		}
+		for _, p := range parts {
+			if err = cs1.txns.send(cs1.txns.prepare, cs1.txns.trans.TxnPrepare, &DHTTxn{Vn: p.vn, Id: id, Ops: p.ops, Parts: vns}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// In doubt transactions are resolved once they time out
+	resolve := func() {
+		<-time.After(4 * timeout)
+	}
+
+	prepare("lost", &TxnOp{Key: a, Op: txnOpPut, Value: []byte("100")})
+	// Prepared parts are persisted so they stay locked after a restart
+	tm := newTxnManager(cs1, timeout)
+	if err = tm.load(); err != nil {
+		t.Fatal(err)
+	}
+	vns, _ := cs1.lookup(cs1.replicas, a)
+	for _, vn := range vns {
+		if _, ok := cs1.store.local[vn.StringID()]; ok && tm.locks[txnLockKey(vn, a)] != "lost" {
+			t.Fatal("prepared part not loaded", shortID(vn))
+		}
+	}
+	txn = cs2.Txn()
+	txn.Put(a, []byte("9"))
+	if err = txn.Commit(); err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Fatal("should be locked", err)
+	}
+	// No decision was recorded so it is aborted
+	resolve()
+	if val, _ := cs1.Get(a, ConsistencyAll); string(val) != "8" {
+		t.Fatal("in doubt write applied", string(val))
+	}
+	if err = txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	// All parts acknowledged the abort so its record is removed
+	if _, err = cs1.Get(txnRecordKey("lost"), ConsistencyAll); !isNotFound(err) {
+		t.Fatal("abort record should be removed", err)
+	}
+
+	// The decision was recorded before the coordinator failed
+	prepare("decided", &TxnOp{Key: b, Op: txnOpRemove})
+	if _, _, err = cs1.CompareAndSwap(txnRecordKey("decided"), nil, []byte(txnCommitted), ConsistencyAll); err != nil {
+		t.Fatal(err)
+	}
+	resolve()
+	if _, err = cs2.Get(b, ConsistencyAll); !isNotFound(err) {
+		t.Fatal("in doubt removal should be committed", err)
+	}
+	if _, err = cs1.Get(txnRecordKey("decided"), ConsistencyAll); !isNotFound(err) {
+		t.Fatal("commit record should be removed", err)
+	}
+	for _, st := range cs1.store.local {
+		if recs, _ := st.(txnPersisting).loadPreparedTxns(); len(recs) > 0 {
+			t.Fatal("decided parts should be removed", len(recs))
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package chordstore
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sort"
+
+	context "golang.org/x/net/context"
+)
+
+// VectorClock maps a node to the counter of the latest of its writes seen.  Along
+// with the node and counter identifying each write (a dotted version vector) it
+// tells apart versions that supersede one another from concurrent ones.
+type VectorClock map[string]uint64
+
+// Copy returns a copy of the clock
+func (vc VectorClock) Copy() VectorClock {
+	out := make(VectorClock, len(vc))
+	for n, c := range vc {
+		out[n] = c
+	}
+	return out
+}
+
+// Merge returns a clock descending both clocks
+func (vc VectorClock) Merge(o VectorClock) VectorClock {
+	out := vc.Copy()
+	for n, c := range o {
+		if c > out[n] {
+			out[n] = c
+		}
+	}
+	return out
+}
+
+// Descends returns true if the clock has seen every write the other has
+func (vc VectorClock) Descends(o VectorClock) bool {
+	for n, c := range o {
+		if vc[n] < c {
+			return false
+		}
+	}
+	return true
+}
+
+// Concurrent returns true if neither clock descends the other
+func (vc VectorClock) Concurrent(o VectorClock) bool {
+	return !vc.Descends(o) && !o.Descends(vc)
+}
+
+// Clock returns the context of the sibling including its own write
+func (s *Sibling) Clock() VectorClock {
+	vc := VectorClock(s.Context).Copy()
+	if s.Counter > vc[s.Node] {
+		vc[s.Node] = s.Counter
+	}
+	return vc
+}
+
+// Versioned is the result of a versioned read
+type Versioned struct {
+	// Concurrent versions of the key.  There is more than one if writes did not
+	// see each other.
+	Siblings []*Sibling
+	// Passing this to PutVersioned supersedes all siblings
+	Context VectorClock
+}
+
+// newSibling returns a version of the key written by the node superseding the
+// versions in ctx.  The counter follows every write of the node seen by the
+// replica or the writer so it is only assigned by the replica coordinating the
+// write.  The timestamp is that of the stamp.
+func newSibling(node string, sibs []*Sibling, value []byte, ctx VectorClock, ws *WriteStamp) *Sibling {
+	counter := ctx[node]
+	for _, t := range sibs {
+		if t.Node == node && t.Counter > counter {
+			counter = t.Counter
+		}
+		if c := t.Context[node]; c > counter {
+			counter = c
+		}
+	}
+	return &Sibling{
+		Value:     value,
+		Node:      node,
+		Counter:   counter + 1,
+		Context:   ctx.Copy(),
+		Timestamp: stampTime(ws),
+	}
+}
+
+// supersedes returns true if t was written having seen s.  Values written without
+// a version are superseded by any version.
+func supersedes(t, s *Sibling) bool {
+	if s.Counter == 0 {
+		return t.Counter > 0
+	}
+	return t.Context[s.Node] >= s.Counter
+}
+
+// sameVersion returns true if both are the same write
+func sameVersion(t, s *Sibling) bool {
+	if t.Node != s.Node || t.Counter != s.Counter {
+		return false
+	}
+	return t.Counter > 0 || bytes.Equal(t.Value, s.Value)
+}
+
+// addSibling adds the version to the siblings dropping those it supersedes.  It
+// returns false if the siblings already have it or supersede it.
+func addSibling(sibs []*Sibling, sib *Sibling) ([]*Sibling, bool) {
+	for _, t := range sibs {
+		if sameVersion(t, sib) || supersedes(t, sib) {
+			return sibs, false
+		}
+	}
+
+	out := make([]*Sibling, 0, len(sibs)+1)
+	for _, t := range sibs {
+		if !supersedes(sib, t) {
+			out = append(out, t)
+		}
+	}
+	out = append(out, sib)
+	sort.Slice(out, func(i, j int) bool { return siblingLess(out[i], out[j]) })
+	return out, true
+}
+
+// siblingLess orders siblings by write time, ties broken by node and counter so
+// all replicas order the same siblings the same
+func siblingLess(a, b *Sibling) bool {
+	if a.Timestamp != b.Timestamp {
+		return a.Timestamp < b.Timestamp
+	}
+	if a.Node != b.Node {
+		return a.Node < b.Node
+	}
+	if a.Counter != b.Counter {
+		return a.Counter < b.Counter
+	}
+	return bytes.Compare(a.Value, b.Value) < 0
+}
+
+// mergeSiblings returns the versions of all sets not superseded by another
+func mergeSiblings(sets ...[]*Sibling) []*Sibling {
+	var out []*Sibling
+	for _, sibs := range sets {
+		for _, sib := range sibs {
+			out, _ = addSibling(out, sib)
+		}
+	}
+	return out
+}
+
+// missingSiblings returns the versions in want not in have
+func missingSiblings(have, want []*Sibling) []*Sibling {
+	var out []*Sibling
+	for _, w := range want {
+		var found bool
+		for _, h := range have {
+			if sameVersion(h, w) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// isVersioned returns true if any of the siblings were written with a version
+func isVersioned(sibs []*Sibling) bool {
+	for _, s := range sibs {
+		if s.Counter > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// siblingsContext returns the clock superseding all siblings
+func siblingsContext(sibs []*Sibling) VectorClock {
+	vc := VectorClock{}
+	for _, s := range sibs {
+		vc = vc.Merge(s.Clock())
+	}
+	return vc
+}
+
+// versionedValue returns the value a plain read of a versioned key returns, that
+// of the latest sibling
+func versionedValue(sibs []*Sibling) []byte {
+	if len(sibs) == 0 {
+		return nil
+	}
+	return sibs[len(sibs)-1].Value
+}
+
+// merkleValue returns the value hashed into the hash tree for a key.  Versioned
+// keys are hashed by the identity of their siblings so replicas holding
+// different siblings are found by anti-entropy even if their plain values match.
+func merkleValue(value []byte, sibs []*Sibling) []byte {
+	if len(sibs) == 0 {
+		return value
+	}
+	buf := new(bytes.Buffer)
+	for _, s := range sibs {
+		fmt.Fprintf(buf, "%s:%d:%x\n", s.Node, s.Counter, valueHash(s.Value))
+	}
+	return buf.Bytes()
+}
+
+// keyVersions returns the versions of the keys that have them
+func keyVersions(keys map[string][]byte, versions map[string][]*Sibling) map[string][]*Sibling {
+	out := map[string][]*Sibling{}
+	for k := range keys {
+		if sibs, ok := versions[k]; ok {
+			out[k] = sibs
+		}
+	}
+	return out
+}
+
+// GetVersioned returns the siblings of the key merged from the replicas along
+// with the context superseding them.  It returns once enough replicas respond to
+// satisfy the consistency level.  Replicas missing versions are queued for
+// healing.  Keys written with Put have a single sibling without a version.
+func (cs *ChordStore) GetVersioned(key []byte, c Consistency) (*Versioned, error) {
+	c = cs.readConsistency(c)
+	vns, err := cs.lookup(cs.replicas, key)
+	if err != nil {
+		return nil, err
+	}
+
+	sets := make([][]*Sibling, len(vns))
+	errs := make([]error, len(vns))
+	done := fanOut(len(vns), c.Required(len(vns)), cs.timeout, func(ctx context.Context, i int) bool {
+		sets[i], errs[i] = cs.store.withContext(ctx).GetVersions(vns[i], key)
+		return errs[i] == nil
+	})
+
+	var (
+		merged   []*Sibling
+		have     int
+		notFound int
+		rerr     error
+	)
+	for i, ok := range done {
+		if !ok {
+			continue
+		}
+		if errs[i] == nil {
+			have++
+			merged = mergeSiblings(merged, sets[i])
+		} else if isNotFound(errs[i]) {
+			notFound++
+		} else {
+			rerr = mergeErrors(rerr, fmt.Errorf("%s: %v", shortID(vns[i]), errs[i]))
+		}
+	}
+
+	r := c.Required(len(vns))
+	if have < r {
+		if notFound >= r {
+			return nil, fmt.Errorf("key not found: %s", key)
+		}
+		return nil, fmt.Errorf("read consistency not met %d/%d: %v", have, r, rerr)
+	}
+
+	for i, ok := range done {
+		if !ok {
+			continue
+		}
+		if isNotFound(errs[i]) || (errs[i] == nil && len(missingSiblings(sets[i], merged)) > 0) {
+			cs.enqueueHeal(HealRequest{Type: HealTypeKey, Vnode: vns[i], Key: key})
+			break
+		}
+	}
+
+	return &Versioned{Siblings: merged, Context: siblingsContext(merged)}, nil
+}
+
+// PutVersioned writes a version of the key superseding the versions in ctx, as
+// returned by GetVersioned.  Siblings not in ctx are kept alongside the new
+// version.  The first reachable replica assigns the version which is then added
+// to the others.  It returns an error if not enough replicas acknowledge the
+// write to satisfy the consistency level.  Keys of strong namespaces are
+// rejected.
+func (cs *ChordStore) PutVersioned(key, value []byte, ctx VectorClock, c Consistency) (*Sibling, error) {
+	if err := cs.checkWeak(key); err != nil {
+		return nil, err
+	}
+	c = cs.writeConsistency(c)
+	vns, err := cs.lookup(cs.replicas, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		sib   *Sibling
+		coord = -1
+		ws    = cs.newWriteStamp()
+	)
+	for i, vn := range vns {
+		if sib, err = cs.store.PutVersion(vn, key, value, ctx, ws); err == nil {
+			coord = i
+			break
+		}
+		log.Printf("ERR [version] vnode=%s key=%s %v", shortID(vn), key, err)
+	}
+	if coord < 0 {
+		return nil, err
+	}
+
+	res := make([]*VnodeData, len(vns))
+	done := fanOut(len(vns), c.Required(len(vns)), cs.timeout, func(ctx context.Context, i int) bool {
+		o := &VnodeData{Vnode: vns[i]}
+		if i != coord {
+			o.Err = cs.store.withContext(ctx).AddSibling(vns[i], key, sib)
+		}
+		res[i] = o
+		return o.Err == nil
+	})
+
+	if err = resolveWrite(collectVnodeData(vns, res, done), c.Required(len(vns))); err != nil {
+		return nil, err
+	}
+	return sib, nil
+}
@@ -0,0 +1,249 @@
+package chordstore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_VectorClock(t *testing.T) {
+	a := VectorClock{"n1": 2, "n2": 1}
+	b := VectorClock{"n1": 1, "n2": 1}
+	c := VectorClock{"n1": 1, "n3": 1}
+
+	if !a.Descends(b) || b.Descends(a) || a.Concurrent(b) {
+		t.Fatal("a should descend b")
+	}
+	if !a.Concurrent(c) {
+		t.Fatal("a and c should be concurrent")
+	}
+	m := a.Merge(c)
+	if !m.Descends(a) || !m.Descends(c) || m["n1"] != 2 {
+		t.Fatal("merge should descend both", m)
+	}
+	if len(a) != 2 {
+		t.Fatal("merge should not modify the clock", a)
+	}
+}
+
+func Test_addSibling(t *testing.T) {
+	plain := &Sibling{Value: []byte("plain")}
+	a := newSibling("n1", nil, []byte("a"), nil, nil)
+	b := newSibling("n2", nil, []byte("b"), nil, nil)
+
+	sibs, ok := addSibling([]*Sibling{plain}, a)
+	if !ok || len(sibs) != 1 || sibs[0] != a {
+		t.Fatal("version should supersede unversioned value", sibs)
+	}
+	if sibs, ok = addSibling(sibs, b); !ok || len(sibs) != 2 {
+		t.Fatal("concurrent versions should be siblings", sibs)
+	}
+	if _, ok = addSibling(sibs, a); ok {
+		t.Fatal("same version should not be added")
+	}
+
+	c := newSibling("n1", sibs, []byte("c"), siblingsContext(sibs), nil)
+	if c.Counter != 2 {
+		t.Fatal("counter should follow the node's writes", c.Counter)
+	}
+	if sibs, ok = addSibling(sibs, c); !ok || len(sibs) != 1 || sibs[0] != c {
+		t.Fatal("should supersede both siblings", sibs)
+	}
+	if _, ok = addSibling(sibs, b); ok {
+		t.Fatal("superseded version should not be added")
+	}
+
+	merged := mergeSiblings([]*Sibling{a}, []*Sibling{b}, []*Sibling{c})
+	if len(merged) != 1 || merged[0] != c {
+		t.Fatal("merge mismatch", merged)
+	}
+	if missing := missingSiblings([]*Sibling{a}, []*Sibling{a, b}); len(missing) != 1 || missing[0] != b {
+		t.Fatal("missing mismatch", missing)
+	}
+}
+
+func testVersions(t *testing.T, kvs VnodeStore, newStore func() VnodeStore) {
+	key := []byte("versioned")
+
+	kvs.PutKey([]byte("plain"), []byte("v"), 0, nil)
+	sibs, err := kvs.GetVersions([]byte("plain"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sibs) != 1 || sibs[0].Counter != 0 || string(sibs[0].Value) != "v" {
+		t.Fatal("plain key should have an unversioned sibling", sibs)
+	}
+
+	// Concurrent writes on 2 replicas
+	other := newStore()
+	a, err := kvs.PutVersion(key, []byte("a"), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := other.PutVersion(key, []byte("b"), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = kvs.AddSibling(key, b); err != nil {
+		t.Fatal(err)
+	}
+	if err = kvs.AddSibling(key, a); err != nil {
+		t.Fatal(err)
+	}
+	if sibs, _ = kvs.GetVersions(key); len(sibs) != 2 {
+		t.Fatal("should have 2 siblings", sibs)
+	}
+	if val, _ := kvs.GetKey(key); string(val) != "b" {
+		t.Fatal("plain read should return the latest sibling", string(val))
+	}
+	if txns, _ := kvs.KeyHistory(key); len(txns) != 2 {
+		t.Fatal("adding a known version should not be logged", len(txns))
+	}
+
+	// Writing with the context resolves the siblings
+	c, err := kvs.PutVersion(key, []byte("c"), siblingsContext(sibs), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sibs, _ = kvs.GetVersions(key); len(sibs) != 1 || string(sibs[0].Value) != "c" {
+		t.Fatal("siblings not resolved", sibs)
+	}
+	if c.Counter != a.Counter+1 {
+		t.Fatal("counter should follow the vnode's writes", c.Counter)
+	}
+
+	// Versions are merged on restore
+	buf := new(bytes.Buffer)
+	if err = kvs.Snapshot(buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = other.Restore(buf); err != nil {
+		t.Fatal(err)
+	}
+	if sibs, _ = other.GetVersions(key); len(sibs) != 1 || string(sibs[0].Value) != "c" {
+		t.Fatal("restored siblings mismatch", sibs)
+	}
+	if !bytes.Equal(kvs.MerkleTree().Root(), other.MerkleTree().Root()) {
+		t.Fatal("hash trees should match")
+	}
+
+	// A plain write drops the versions
+	kvs.PutKey(key, []byte("p"), 0, nil)
+	if sibs, _ = kvs.GetVersions(key); len(sibs) != 1 || sibs[0].Counter != 0 {
+		t.Fatal("versions should be dropped", sibs)
+	}
+}
+
+func Test_MemKeyValueStore_Versions(t *testing.T) {
+	kvs, _ := (&MemKeyValueStore{}).New(testVn1)
+	testVersions(t, kvs, func() VnodeStore {
+		st, _ := (&MemKeyValueStore{}).New(testVn2)
+		return st
+	})
+}
+
+func Test_DiskKeyValueStore_Versions(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "chordstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	st := &DiskKeyValueStore{DataDir: tmpdir}
+	kvs, err := st.New(testVn1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testVersions(t, kvs, func() VnodeStore {
+		vs, err := st.New(testVn2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return vs
+	})
+
+	// Versions persist
+	kvs.PutVersion([]byte("persisted"), []byte("v"), nil, nil)
+	root := kvs.MerkleTree().Root()
+	if kvs, err = st.New(testVn1); err != nil {
+		t.Fatal(err)
+	}
+	sibs, err := kvs.GetVersions([]byte("persisted"))
+	if err != nil || len(sibs) != 1 || sibs[0].Counter != 1 {
+		t.Fatal("versions not loaded", sibs, err)
+	}
+	if !bytes.Equal(root, kvs.MerkleTree().Root()) {
+		t.Fatal("hash tree mismatch after reopen")
+	}
+}
+
+func Test_ChordStore_PutVersioned(t *testing.T) {
+	c1, err := initConfig(36036)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs1, err := NewChordStore(c1, &MemKeyValueStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs1.Shutdown()
+	defer c1.Ring.Shutdown()
+
+	<-time.After(200 * time.Millisecond)
+	c2, err := initConfig(36037, "127.0.0.1:36036")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs2, err := NewChordStore(c2, &MemKeyValueStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs2.Shutdown()
+	defer c2.Ring.Shutdown()
+
+	<-time.After(300 * time.Millisecond)
+
+	key := []byte("dynamo")
+	// Writes from both nodes not having seen each other
+	if _, err = cs1.PutVersioned(key, []byte("one"), nil, ConsistencyAll); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cs2.PutVersioned(key, []byte("two"), nil, ConsistencyAll); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := cs2.GetVersioned(key, ConsistencyQuorum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Siblings) != 2 {
+		t.Fatal("should have 2 siblings", v.Siblings)
+	}
+
+	sib, err := cs1.PutVersioned(key, []byte("resolved"), v.Context, ConsistencyAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sib.Clock().Descends(v.Context) {
+		t.Fatal("write should descend the context", sib.Clock(), v.Context)
+	}
+
+	// A replica that lost the key is healed with the versions
+	vns, _ := cs1.lookup(3, key)
+	cs1.store.local[vns[2].StringID()].PruneKey(key)
+	if err = cs1.healer.healKey(HealRequest{Type: HealTypeKey, Key: key}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, vn := range vns {
+		sibs, err := cs1.store.GetVersions(vn, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sibs) != 1 || string(sibs[0].Value) != "resolved" {
+			t.Fatal("siblings not resolved", vn.StringID(), sibs)
+		}
+	}
+}